@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// archiveList 列出已归档的对话
+var archiveList bool
+
+// archiveCmd 为结束的项目生成总结并归档对话
+var archiveCmd = &cobra.Command{
+	Use:   "archive <conversation-id>",
+	Short: "为已结束的项目对话生成总结并归档",
+	Long: `由模型为对话生成结项总结（目标、关键决策、产出文件、后续事项），
+总结保存到对话中并另存为 histories/archive/<id>.md，完整对话记录压缩保存为 histories/archive/<id>.json.gz，
+之后对话不再出现在 /history 列表中；在交互模式中仍可用 /load <id> 加载归档的对话。
+
+示例:
+  agentcli archive alice_1717000000
+  agentcli archive --list`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if archiveList {
+			return printArchived()
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("用法: agentcli archive <conversation-id>，或使用 --list 查看已归档的对话")
+		}
+
+		conv, err := historyMgr.LoadConversation(args[0])
+		if err != nil {
+			return err
+		}
+		if !conv.HasAssistantMessages() {
+			return fmt.Errorf("对话 %s 还没有任何回答，无需归档", conv.ID)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		a := agent.NewAgent(cfg, log)
+		a.SetUsageTracker(tracker)
+		if chatModel != "" {
+			a.UpdateModel(chatModel)
+		}
+
+		ui.Printf("📝 正在生成对话 %s 的项目总结（消息数: %d）...\n", conv.ID, len(conv.Messages))
+		summary, err := a.SummarizeProject(ctx, conv)
+		if err != nil {
+			return err
+		}
+		archived, err := historyMgr.ArchiveConversation(conv, summary)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%s\n\n", summary)
+		ui.Printf("📦 已归档对话 %s\n", archived.ID)
+		fmt.Printf("  项目总结: %s\n", archived.SummaryPath)
+		fmt.Printf("  完整记录: %s\n", archived.ArchivePath)
+		log.Info("归档对话", map[string]interface{}{"conversation_id": archived.ID, "summary": archived.SummaryPath})
+		return nil
+	},
+}
+
+func init() {
+	archiveCmd.Flags().BoolVar(&archiveList, "list", false, "列出已归档的对话")
+	rootCmd.AddCommand(archiveCmd)
+}
+
+// printArchived 列出当前用户已归档的对话
+func printArchived() error {
+	archived, err := historyMgr.ListArchived(userID)
+	if err != nil {
+		return fmt.Errorf("读取归档失败: %w", err)
+	}
+	if len(archived) == 0 {
+		ui.Println("📭 没有已归档的对话")
+		return nil
+	}
+	ui.Printf("📦 已归档的对话 (%d):\n", len(archived))
+	for i, entry := range archived {
+		fmt.Printf("  %d. ID: %s | 消息数: %d | 归档: %s", i+1, entry.ID, entry.MessageCount, entry.ArchivedAt.Format("2006-01-02 15:04"))
+		if entry.Title != "" {
+			fmt.Printf(" | %s", entry.Title)
+		}
+		fmt.Println()
+		if entry.SummaryPath != "" {
+			fmt.Printf("     总结: %s\n", entry.SummaryPath)
+		}
+	}
+	return nil
+}
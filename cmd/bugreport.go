@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"agentcli/internal/bugreport"
+	"agentcli/internal/config"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/ui"
+	"bufio"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// bugReportDir 问题报告的保存目录
+const bugReportDir = "bug-reports"
+
+// recoverTurn 执行一轮请求，将其中的panic转换为错误，避免整个会话退出
+func recoverTurn(fn func() (string, error)) (response string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicErr := &bugreport.PanicError{Value: v, Stack: debug.Stack()}
+			log.Error("处理请求时发生panic", panicErr, map[string]interface{}{"stack": string(panicErr.Stack)})
+			err = panicErr
+		}
+	}()
+	return fn()
+}
+
+// offerBugReport 本轮因内部错误失败时，询问是否生成脱敏后的问题报告
+func offerBugReport(reader *bufio.Reader, turnErr error, input, model string, env map[string]string) {
+	fmt.Print("这可能是程序内部错误，是否生成脱敏后的问题报告以便提交到 GitHub issue? (y/N): ")
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return
+	}
+
+	report := bugreport.Report{
+		Version:  appVersion,
+		Model:    model,
+		Provider: cfg.API.Provider,
+		Err:      turnErr,
+		Input:    input,
+		Env:      env,
+	}
+	if path := config.FileUsed(); path != "" {
+		report.Config, _ = os.ReadFile(path)
+	}
+	if path := eventLog.Path(); path != "" {
+		report.Events, _ = eventlog.Load(path)
+	}
+
+	path, err := bugreport.Write(bugReportDir, report)
+	if err != nil {
+		log.Error("生成问题报告失败", err, nil)
+		ui.Printf("❌ 生成问题报告失败: %v\n", err)
+		return
+	}
+	log.Info("生成问题报告", map[string]interface{}{"file": path})
+	ui.Printf("📦 问题报告已保存到 %s\n", path)
+	ui.Println("💡 密钥和主目录已替换为占位符，附加到 issue 前请再检查一遍内容")
+}
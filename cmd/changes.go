@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+)
+
+// printChanges 展示待确认修改的统计和diff
+func printChanges(changes []*tools.Change) {
+	for _, c := range changes {
+		added, removed := c.Stat()
+		status := "修改"
+		if !c.Existed {
+			status = "新建"
+		}
+		ui.Printf("\n📄 %s（%s，+%d -%d）\n", c.Path, status, added, removed)
+		fmt.Print(c.Diff())
+	}
+}
+
+// reportProposedChanges 计划模式下展示本轮新提出的修改
+func reportProposedChanges(a *agent.Agent) {
+	recent := a.Changes().ConsumeRecent()
+	if len(recent) == 0 {
+		return
+	}
+	ui.Printf("\n\n📝 计划模式：本轮提出了 %d 个文件的修改，尚未写入:\n", len(recent))
+	printChanges(recent)
+	ui.Printf("\n💡 输入 '/apply' 应用全部修改（共 %d 个文件待确认），'/apply <文件>' 只应用指定文件，'/discard' 丢弃\n", len(a.Changes().Changes()))
+}
+
+// showChanges /changes 列出全部待确认的修改
+func showChanges(a *agent.Agent) {
+	changes := a.Changes().Changes()
+	if len(changes) == 0 {
+		ui.Println("📭 没有待确认的修改")
+		return
+	}
+	ui.Printf("📝 %d 个文件有待确认的修改:\n", len(changes))
+	printChanges(changes)
+	fmt.Println()
+}
+
+// applyChanges /apply 写入待确认的修改，paths 为空时写入全部
+func applyChanges(a *agent.Agent, paths []string) {
+	if len(a.Changes().Changes()) == 0 {
+		ui.Println("📭 没有待确认的修改")
+		return
+	}
+	applied, err := a.Changes().Apply(context.Background(), paths)
+	for _, path := range applied {
+		ui.Printf("✅ 已写入 %s\n", path)
+	}
+	if len(applied) > 0 {
+		log.Info("应用计划模式的修改", map[string]interface{}{"files": applied})
+	}
+	if err != nil {
+		log.Error("应用修改失败", err, nil)
+		ui.Printf("❌ %v\n", err)
+	}
+}
+
+// discardChanges /discard 丢弃待确认的修改，paths 为空时丢弃全部
+func discardChanges(a *agent.Agent, paths []string) {
+	discarded, err := a.Changes().Discard(paths)
+	if err != nil {
+		ui.Printf("❌ %v\n", err)
+		return
+	}
+	if len(discarded) == 0 {
+		ui.Println("📭 没有待确认的修改")
+		return
+	}
+	ui.Printf("🗑️  已丢弃 %d 个文件的修改\n", len(discarded))
+	log.Info("丢弃计划模式的修改", map[string]interface{}{"files": discarded})
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/config"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// confirmConfigEdit 代理通过 update_config 修改配置前展示diff并请用户确认，默认不修改
+func confirmConfigEdit(diff, reason string) bool {
+	smoother.Drain()
+	ui.Println("\n⚙️  代理请求修改配置:")
+	if reason != "" {
+		fmt.Printf("  理由: %s\n", reason)
+	}
+	fmt.Print(diff)
+	fmt.Print("是否应用该修改？(y/N): ")
+	answer, err := replReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// handleConfigCommand /config history 查看代理对配置的修改记录，/config revert [记录号] 撤销
+func handleConfigCommand(a *agent.Agent, args []string) {
+	path := config.FileUsed()
+	if path == "" {
+		ui.Println("❌ 当前没有使用配置文件")
+		return
+	}
+	if len(args) == 0 {
+		ui.Printf("⚙️  配置文件: %s\n", path)
+		fmt.Println("用法: /config history  (查看配置修改记录)")
+		fmt.Println("用法: /config revert [记录号]  (撤销指定的修改，默认最近一次)")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "history":
+		printConfigHistory(path)
+	case "revert":
+		version := 0
+		if len(args) > 1 {
+			var err error
+			if version, err = strconv.Atoi(strings.TrimPrefix(args[1], "#")); err != nil {
+				ui.Printf("❌ 无效的记录号: %s\n", args[1])
+				return
+			}
+		}
+		record, newCfg, err := config.RevertEdit(path, version)
+		if newCfg != nil {
+			a.ApplyConfig(newCfg)
+		}
+		if err != nil {
+			ui.Printf("❌ %v\n", err)
+			return
+		}
+		ui.Printf("✅ 已撤销配置修改 #%d:\n", record.Version)
+		fmt.Print(tools.UnifiedDiff(path, record.After, record.Before))
+		log.Info("撤销配置修改", map[string]interface{}{"version": record.Version, "config": path})
+	default:
+		fmt.Println("用法: /config history | /config revert [记录号]")
+	}
+}
+
+// printConfigHistory 列出配置修改记录（最新在前）
+func printConfigHistory(path string) {
+	records, err := config.LoadEditHistory(path)
+	if err != nil {
+		ui.Printf("❌ %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		ui.Println("📭 还没有配置修改记录")
+		return
+	}
+
+	ui.Printf("\n🕘 %s 的修改记录（最新在前）:\n", path)
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		summary := strings.Join(r.Edits, "; ")
+		if r.Action == config.EditActionRevert {
+			summary = fmt.Sprintf("撤销 #%d", r.RevertedFrom)
+		}
+		fmt.Printf("  #%-3d %s  %s\n", r.Version, r.CreatedAt.Format("2006-01-02 15:04"), summary)
+		if r.Reason != "" {
+			fmt.Printf("        理由: %s\n", preview(r.Reason, 60))
+		}
+	}
+	ui.Println("💡 使用 /config revert [记录号] 撤销，默认撤销最近一次")
+	fmt.Println()
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/history"
+	"agentcli/internal/ui"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// openConversation 交互模式中打开的一个对话，以及切走时保存的工具执行方式
+type openConversation struct {
+	conv    *history.Conversation
+	dryRun  bool // 演练模式
+	sandbox bool // 影子工作区模式
+}
+
+// openConversations 交互模式中同时打开的多个对话，同一时间只有一个处于活动状态
+type openConversations struct {
+	items  []*openConversation
+	active int
+}
+
+// newOpenConversations 以启动时的对话创建打开列表，之后新打开的对话沿用当前对话的工具执行方式
+func newOpenConversations(conv *history.Conversation) *openConversations {
+	return &openConversations{items: []*openConversation{{conv: conv, dryRun: dryRun, sandbox: useSandbox}}}
+}
+
+// current 当前活动的对话
+func (o *openConversations) current() *history.Conversation {
+	return o.items[o.active].conv
+}
+
+// find 按列表编号或对话ID查找打开的对话
+func (o *openConversations) find(ref string) int {
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(o.items) {
+		return n - 1
+	}
+	for i, item := range o.items {
+		if item.conv.ID == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// switchTo 保存当前对话的工具执行方式，切换到第 index 个对话并恢复它的模型和工具执行方式
+func (o *openConversations) switchTo(index int, a *agent.Agent) {
+	cur := o.items[o.active]
+	cur.dryRun = a.DryRunEnabled()
+	cur.sandbox = useSandbox
+
+	o.active = index
+	next := o.items[index]
+	enforceModelPolicy(next.conv)
+	cfg.API.Model = next.conv.Model
+	a.UpdateModel(next.conv.Model)
+	a.SetDryRun(next.dryRun)
+	useSandbox = next.sandbox
+	a.SetVariables(next.conv.Variables)
+	a.SetWorkdir(next.conv.Workdir)
+	a.SetEnv(next.conv.Env)
+}
+
+// enforceModelPolicy 对话记录的模型不在组织策略允许的范围内时（如策略在对话保存后收紧），改用当前模型
+func enforceModelPolicy(conv *history.Conversation) {
+	if err := cfg.Policy.CheckModel(conv.Model); err != nil {
+		ui.Printf("⚠️  %v，对话改用当前模型 %s\n", err, cfg.API.Model)
+		conv.Model = cfg.API.Model
+	}
+}
+
+// saveAll 保存所有有回答的打开对话
+func (o *openConversations) saveAll() {
+	for _, item := range o.items {
+		saveConversationOnExit(item.conv)
+	}
+}
+
+// print 列出打开的对话
+func (o *openConversations) print() {
+	ui.Printf("\n🗂  打开的对话 (%d):\n", len(o.items))
+	for i, item := range o.items {
+		marker := " "
+		if i == o.active {
+			marker = ui.Mark("▶", "*")
+		}
+		posture := []string{}
+		if item.dryRun {
+			posture = append(posture, "演练")
+		}
+		if item.sandbox {
+			posture = append(posture, "影子工作区")
+		}
+		fmt.Printf("  [%s] %d. ID: %s | 模型: %s | 消息数: %d", marker, i+1, item.conv.ID, item.conv.Model, len(item.conv.Messages))
+		if len(posture) > 0 {
+			fmt.Printf(" | %s", strings.Join(posture, "、"))
+		}
+		if title := item.conv.Title; title != "" {
+			fmt.Printf(" | %s", title)
+		} else if last, ok := item.conv.LastUserMessage(); ok {
+			fmt.Printf(" | %s", preview(last, 40))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// handle 处理 /switch 和 /list-open 命令，返回活动对话是否发生了变化
+//
+//	/list-open          列出打开的对话
+//	/switch new [模型]   在保留当前对话的同时打开一个新对话
+//	/switch <编号|ID>    切换到打开的对话，ID不在列表中时从历史记录加载
+//	/switch close [编号|ID] 保存并关闭打开的对话（默认当前对话）
+func (o *openConversations) handle(input string, a *agent.Agent) bool {
+	parts := strings.Fields(input)
+	if parts[0] == "/list-open" || len(parts) < 2 {
+		o.print()
+		if parts[0] == "/switch" {
+			fmt.Println("用法: /switch <编号|对话ID>  |  /switch new [模型]  |  /switch close [编号|对话ID]")
+		}
+		return false
+	}
+
+	switch parts[1] {
+	case "new":
+		model := o.current().Model
+		if len(parts) >= 3 {
+			model = parts[2]
+			if err := cfg.Policy.CheckModel(model); err != nil {
+				ui.Printf("❌ %v\n", err)
+				return false
+			}
+		}
+		conv := history.NewConversation(userID, model)
+		// 对话ID按秒生成，同一秒内打开的对话加上序号区分
+		if o.find(conv.ID) >= 0 {
+			conv.ID = fmt.Sprintf("%s_%d", conv.ID, len(o.items)+1)
+		}
+		o.items = append(o.items, &openConversation{conv: conv, dryRun: a.DryRunEnabled(), sandbox: useSandbox})
+		o.switchTo(len(o.items)-1, a)
+		ui.Printf("🆕 已打开新对话 %d (ID: %s, 模型: %s)\n", len(o.items), conv.ID, conv.Model)
+		log.Info("打开新对话", map[string]interface{}{"conversation_id": conv.ID, "model": conv.Model})
+		return true
+
+	case "close":
+		index := o.active
+		if len(parts) >= 3 {
+			if index = o.find(parts[2]); index < 0 {
+				ui.Printf("❌ 没有打开的对话: %s\n", parts[2])
+				return false
+			}
+		}
+		if len(o.items) == 1 {
+			ui.Println("❌ 只剩一个打开的对话，使用 '/new' 开始新对话")
+			return false
+		}
+		closed := o.items[index].conv
+		saveConversationOnExit(closed)
+		// 关闭当前对话时切换到前一个对话
+		if index == o.active {
+			if index > 0 {
+				o.switchTo(index-1, a)
+			} else {
+				o.switchTo(1, a)
+			}
+		}
+		o.items = append(o.items[:index], o.items[index+1:]...)
+		if o.active > index {
+			o.active--
+		}
+		ui.Printf("📕 已关闭对话 %s，当前对话: %s\n", closed.ID, o.current().ID)
+		log.Info("关闭对话", map[string]interface{}{"conversation_id": closed.ID})
+		return true
+	}
+
+	index := o.find(parts[1])
+	if index < 0 {
+		loaded, err := historyMgr.LoadConversation(parts[1])
+		if err != nil {
+			ui.Printf("❌ 没有打开的对话 %s，从历史记录加载失败: %v\n", parts[1], err)
+			return false
+		}
+		o.items = append(o.items, &openConversation{conv: loaded, dryRun: a.DryRunEnabled(), sandbox: useSandbox})
+		index = len(o.items) - 1
+		ui.Printf("📂 已从历史记录打开对话 (ID: %s, 消息数: %d)\n", loaded.ID, len(loaded.Messages))
+	}
+	if index == o.active {
+		ui.Printf("📌 已经在对话 %d (ID: %s)\n", index+1, o.current().ID)
+		return false
+	}
+	o.switchTo(index, a)
+	conv := o.current()
+	ui.Printf("🔀 已切换到对话 %d (ID: %s, 模型: %s, 消息数: %d)\n", index+1, conv.ID, conv.Model, len(conv.Messages))
+	if last, ok := conv.LastUserMessage(); ok {
+		fmt.Printf("  上一条消息: %s\n", preview(last, 80))
+	}
+	log.Info("切换对话", map[string]interface{}{"conversation_id": conv.ID})
+	return true
+}
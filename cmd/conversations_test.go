@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	"agentcli/internal/agent"
+	"agentcli/internal/config"
+	"agentcli/internal/history"
+	"agentcli/internal/logger"
+)
+
+// setupPolicyTest 使用只允许 gpt-4o 的组织策略，并在历史记录中保存一个使用 o1 的对话
+func setupPolicyTest(t *testing.T) (*agent.Agent, *history.Conversation) {
+	t.Helper()
+	prevCfg, prevMgr, prevLog := cfg, historyMgr, log
+	t.Cleanup(func() { cfg, historyMgr, log = prevCfg, prevMgr, prevLog })
+
+	var err error
+	if log, err = logger.NewLogger("test", logger.Options{Output: logger.OutputStderr, Level: "error"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &config.Config{Policy: &config.OrgPolicy{Models: []string{"gpt-4o"}}}
+	cfg.API.Model = "gpt-4o"
+	historyMgr = history.NewManager(t.TempDir())
+	if err := historyMgr.Init(); err != nil {
+		t.Fatal(err)
+	}
+	saved := history.NewConversation("tester", "o1")
+	saved.ID = "conv_o1"
+	if err := historyMgr.SaveConversation(saved); err != nil {
+		t.Fatal(err)
+	}
+	return agent.NewAgent(cfg, nil), saved
+}
+
+func TestLoadEnforcesModelPolicy(t *testing.T) {
+	a, saved := setupPolicyTest(t)
+
+	model := cfg.API.Model
+	conv := history.NewConversation("tester", model)
+	handleCommand("/load "+saved.ID, &model, conv, historyMgr, a, log)
+	if conv.ID != saved.ID {
+		t.Fatalf("未加载对话: %s", conv.ID)
+	}
+	if model != "gpt-4o" || conv.Model != "gpt-4o" || cfg.API.Model != "gpt-4o" {
+		t.Fatalf("加载后的模型 = %s/%s/%s, want gpt-4o", model, conv.Model, cfg.API.Model)
+	}
+}
+
+func TestSwitchEnforcesModelPolicy(t *testing.T) {
+	a, saved := setupPolicyTest(t)
+
+	open := newOpenConversations(history.NewConversation("tester", cfg.API.Model))
+	if !open.handle("/switch "+saved.ID, a) {
+		t.Fatalf("未切换对话")
+	}
+	if conv := open.current(); conv.ID != saved.ID || conv.Model != "gpt-4o" || cfg.API.Model != "gpt-4o" {
+		t.Fatalf("切换后的对话 = %s 模型 %s/%s, want gpt-4o", conv.ID, conv.Model, cfg.API.Model)
+	}
+}
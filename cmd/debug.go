@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// debugPageLines 分页查看完整内容时每页的行数
+const debugPageLines = 40
+
+// debugCmd 逐步回看会话事件日志
+var debugCmd = &cobra.Command{
+	Use:   "debug <session-id>",
+	Short: "逐步回看会话中的每次LLM请求和工具执行，可换模型重新执行某次请求",
+	Long: `读取会话事件日志（logs/<日期>/<会话ID>.events.jsonl，见配置项 logging.event_log），
+按时间顺序逐条查看每次LLM请求和工具执行的输入、输出和耗时；
+对LLM请求可使用其他模型重新发送记录的提示词，与原回答对比。
+
+查看器命令:
+  回车 / n     下一条
+  p            上一条
+  <编号>       跳转到指定事件
+  l            列出所有事件
+  f            分页查看当前事件的完整内容（全部消息、回答、工具参数和结果）
+  r [模型]     用指定模型（默认为配置的模型）重新发送当前LLM请求并对比回答
+  q            退出
+
+示例:
+  agentcli debug alice_1717000000`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true, // 错误由main输出
+	RunE: func(cmd *cobra.Command, args []string) error {
+		events, err := eventlog.LoadSession(args[0])
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return fmt.Errorf("会话 %s 的事件日志为空", args[0])
+		}
+		v := &debugViewer{events: events, in: bufio.NewReader(os.Stdin)}
+		v.run()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+}
+
+// debugViewer 会话事件查看器
+type debugViewer struct {
+	events []eventlog.Event
+	pos    int
+	in     *bufio.Reader
+	agent  *agent.Agent // 重新执行请求时按需创建
+}
+
+// run 显示第一条事件并循环处理查看器命令，输入结束时退出
+func (v *debugViewer) run() {
+	llmCalls, toolCalls := 0, 0
+	for _, e := range v.events {
+		if e.Kind == eventlog.KindLLM {
+			llmCalls++
+		} else {
+			toolCalls++
+		}
+	}
+	ui.Printf("🔍 共 %d 条事件（LLM请求 %d 次，工具执行 %d 次）\n", len(v.events), llmCalls, toolCalls)
+	v.show()
+
+	for {
+		fmt.Print("\n[回车/n]下一条 [p]上一条 [编号]跳转 [l]列表 [f]完整内容 [r 模型]重新执行 [q]退出 > ")
+		line, err := v.in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println()
+			return
+		}
+		fields := strings.Fields(line)
+		command := "n"
+		if len(fields) > 0 {
+			command = strings.ToLower(fields[0])
+		}
+
+		switch command {
+		case "n":
+			if v.pos >= len(v.events)-1 {
+				fmt.Println("已是最后一条")
+				continue
+			}
+			v.pos++
+			v.show()
+		case "p":
+			if v.pos == 0 {
+				fmt.Println("已是第一条")
+				continue
+			}
+			v.pos--
+			v.show()
+		case "l":
+			v.list()
+		case "f":
+			v.page(v.detail())
+		case "r":
+			model := ""
+			if len(fields) > 1 {
+				model = fields[1]
+			}
+			v.rerun(model)
+		case "q":
+			return
+		default:
+			n, err := strconv.Atoi(command)
+			if err != nil || n < 1 || n > len(v.events) {
+				fmt.Printf("未知命令: %s\n", command)
+				continue
+			}
+			v.pos = n - 1
+			v.show()
+		}
+	}
+}
+
+// title 事件的单行摘要
+func (v *debugViewer) title(i int) string {
+	e := v.events[i]
+	name := "工具 " + e.Tool
+	if e.Kind == eventlog.KindLLM && e.LLM != nil {
+		name = "LLM请求 " + e.LLM.Model
+	}
+	status := ""
+	if e.Error != "" {
+		status = "  [失败]"
+	}
+	return fmt.Sprintf("[%d/%d] %s  %s  耗时 %s%s", i+1, len(v.events), e.Time.Format("15:04:05"), name, formatMS(e.DurationMS), status)
+}
+
+// show 显示当前事件的摘要
+func (v *debugViewer) show() {
+	e := v.events[v.pos]
+	fmt.Printf("\n%s\n", v.title(v.pos))
+	if e.Kind == eventlog.KindLLM && e.LLM != nil {
+		r := e.LLM
+		mode := "普通"
+		if r.Stream {
+			mode = "流式"
+		}
+		fmt.Printf("  请求: %d 条消息，%d 个可用工具（%s）\n", len(r.Messages), len(r.Tools), mode)
+		if n := len(r.Messages); n > 0 {
+			last := r.Messages[n-1]
+			fmt.Printf("  最后一条消息 [%s]: %s\n", last.Role, preview(last.Content, 200))
+		}
+		printCallOutput(r)
+	} else {
+		fmt.Printf("  参数: %s\n", preview(toJSON(e.Params, false), 300))
+		fmt.Printf("  结果: %s\n", preview(toJSON(e.Result, false), 500))
+	}
+	if e.Error != "" {
+		ui.Printf("  ❌ 错误: %s\n", e.Error)
+	}
+}
+
+// printCallOutput 输出LLM请求的回答、工具调用和用量摘要
+func printCallOutput(r *llm.CallRecord) {
+	if r.Content != "" {
+		fmt.Printf("  回答: %s\n", preview(r.Content, 500))
+	}
+	for _, call := range r.ToolCalls {
+		fmt.Printf("  工具调用: %s %s\n", call.Function.Name, preview(call.Function.Arguments, 200))
+	}
+	if r.Usage != nil {
+		fmt.Printf("  用量: 输入 %d token（缓存 %d），输出 %d token\n", r.Usage.PromptTokens, r.Usage.CachedTokens(), r.Usage.CompletionTokens)
+	}
+}
+
+// list 每条事件一行列出
+func (v *debugViewer) list() {
+	for i := range v.events {
+		marker := "  "
+		if i == v.pos {
+			marker = "> "
+		}
+		fmt.Println(marker + v.title(i))
+	}
+}
+
+// detail 当前事件的完整内容
+func (v *debugViewer) detail() string {
+	e := v.events[v.pos]
+	var sb strings.Builder
+	sb.WriteString(v.title(v.pos) + "\n")
+	if e.Kind == eventlog.KindLLM && e.LLM != nil {
+		r := e.LLM
+		for i, msg := range r.Messages {
+			fmt.Fprintf(&sb, "\n── 消息 %d [%s] ──\n%s\n", i+1, msg.Role, msg.Content)
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&sb, "工具调用: %s %s\n", call.Function.Name, call.Function.Arguments)
+			}
+		}
+		if len(r.Tools) > 0 {
+			names := make([]string, len(r.Tools))
+			for i, tool := range r.Tools {
+				names[i] = tool.Function.Name
+			}
+			fmt.Fprintf(&sb, "\n── 可用工具 ──\n%s\n", strings.Join(names, ", "))
+		}
+		fmt.Fprintf(&sb, "\n── 回答 ──\n%s\n", r.Content)
+		for _, call := range r.ToolCalls {
+			fmt.Fprintf(&sb, "工具调用: %s %s\n", call.Function.Name, call.Function.Arguments)
+		}
+	} else {
+		fmt.Fprintf(&sb, "\n── 参数 ──\n%s\n", toJSON(e.Params, true))
+		fmt.Fprintf(&sb, "\n── 结果 ──\n%s\n", toJSON(e.Result, true))
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&sb, "\n── 错误 ──\n%s\n", e.Error)
+	}
+	return sb.String()
+}
+
+// page 分页输出长文本，每页之后等待回车，输入 q 返回
+func (v *debugViewer) page(text string) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for start := 0; start < len(lines); start += debugPageLines {
+		end := min(start+debugPageLines, len(lines))
+		fmt.Println(strings.Join(lines[start:end], "\n"))
+		if end == len(lines) {
+			return
+		}
+		fmt.Printf("-- %d/%d 行，回车继续，q 返回 --", end, len(lines))
+		line, err := v.in.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) == "q" {
+			fmt.Println()
+			return
+		}
+	}
+}
+
+// rerun 用指定模型重新发送当前LLM请求，与记录的回答对比
+func (v *debugViewer) rerun(model string) {
+	e := v.events[v.pos]
+	if e.Kind != eventlog.KindLLM || e.LLM == nil {
+		fmt.Println("只能重新执行LLM请求")
+		return
+	}
+	if v.agent == nil {
+		v.agent = agent.NewAgent(cfg, log)
+		v.agent.SetUsageTracker(tracker)
+	}
+
+	ui.Printf("⏳ 正在重新执行...\n")
+	result, err := v.agent.RerunCall(context.Background(), *e.LLM, model)
+	if err != nil {
+		ui.Printf("❌ 重新执行失败: %v\n", err)
+		return
+	}
+	fmt.Printf("\n── 原请求 %s  耗时 %s ──\n", e.LLM.Model, formatMS(e.DurationMS))
+	printCallOutput(e.LLM)
+	fmt.Printf("\n── 重新执行 %s  耗时 %s ──\n", result.Model, formatMS(result.Duration.Milliseconds()))
+	printCallOutput(&result)
+}
+
+// formatMS 格式化毫秒耗时
+func formatMS(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// toJSON 将工具参数和结果格式化为JSON，indent 为 true 时缩进输出
+func toJSON(value interface{}, indent bool) string {
+	var data []byte
+	var err error
+	if indent {
+		data, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"agentcli/internal/history"
+	"agentcli/internal/ui"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyUsage /history 的用法说明
+const historyUsage = "用法: /history [页码] [model=<模型>] [since=<YYYY-MM-DD|Nd>] [until=<YYYY-MM-DD>] [sort=updated|created|messages|id] [asc]"
+
+// parseHistoryQuery 解析 /history 的参数：页码、model/since/until 筛选、sort 排序和 asc 升序
+func parseHistoryQuery(args []string) (history.Query, error) {
+	q := history.Query{Page: 1}
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n < 1 {
+				return q, fmt.Errorf("页码必须大于0: %s", arg)
+			}
+			q.Page = n
+			continue
+		}
+		if arg == "asc" || arg == "desc" {
+			q.Ascending = arg == "asc"
+			continue
+		}
+
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return q, fmt.Errorf("无法识别的参数: %s", arg)
+		}
+		var err error
+		switch key {
+		case "model":
+			q.Model = value
+		case "since":
+			q.Since, err = parseHistoryDate(value)
+		case "until":
+			// 截止日期包含当天
+			q.Until, err = parseHistoryDate(value)
+			q.Until = q.Until.AddDate(0, 0, 1)
+		case "sort":
+			q.Sort = value
+		default:
+			return q, fmt.Errorf("无法识别的参数: %s", arg)
+		}
+		if err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseHistoryDate 解析日期（YYYY-MM-DD，本地时区）或相对天数（如 7d 表示7天前的0点）
+func parseHistoryDate(value string) (time.Time, error) {
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") && days >= 0 {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day()-days, 0, 0, 0, 0, time.Local), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法解析日期 %s（格式 YYYY-MM-DD 或 7d）", value)
+	}
+	return t, nil
+}
+
+// showHistory 按条件分页列出历史对话
+func showHistory(historyMgr *history.Manager, userID string, args []string) error {
+	q, err := parseHistoryQuery(args)
+	if err != nil {
+		return err
+	}
+	page, err := historyMgr.QueryConversations(userID, q)
+	if err != nil {
+		return err
+	}
+	if page.Total == 0 {
+		ui.Println("📭 没有符合条件的历史对话")
+		return nil
+	}
+
+	ui.Printf("\n📜 历史对话（第 %d/%d 页，共 %d 个）:\n", page.Number, page.Pages, page.Total)
+	start := (page.Number - 1) * history.DefaultPageSize
+	for i, c := range page.Conversations {
+		fmt.Printf("  %d. ID: %s | 模型: %s | 消息数: %d | 更新: %s",
+			start+i+1, c.ID, c.Model, c.Messages, c.Updated.Format("2006-01-02 15:04"))
+		if c.Title != "" {
+			fmt.Printf(" | %s", c.Title)
+		}
+		fmt.Println()
+	}
+	if page.Number < page.Pages {
+		fmt.Printf("\n下一页: /history %d%s\n", page.Number+1, queryFilters(args))
+	}
+	fmt.Println()
+	return nil
+}
+
+// queryFilters 翻页时沿用的筛选和排序参数
+func queryFilters(args []string) string {
+	var kept []string
+	for _, arg := range args {
+		if _, err := strconv.Atoi(arg); err != nil {
+			kept = append(kept, arg)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return " " + strings.Join(kept, " ")
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"time"
+)
+
+// precheckModel 开启预检（api.precheck 或 --precheck）时，在第一次提问前验证模型服务，失败时返回带处理建议的错误
+func precheckModel(ctx context.Context, a *agent.Agent, model string) error {
+	if !cfg.API.Precheck && !precheck {
+		return nil
+	}
+	timeout := time.Duration(cfg.API.PrecheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ui.Printf("🔌 正在检查模型服务（%s）...\n", model)
+	latency, err := a.Ping(ctx)
+	if err != nil {
+		if advice := a.PingAdvice(err); advice != "" {
+			return fmt.Errorf("模型服务预检失败: %w\n%s", err, ui.Sprintf("💡 %s", advice))
+		}
+		return fmt.Errorf("模型服务预检失败: %w", err)
+	}
+	ui.Printf("✅ 模型 %s 可用，响应延迟 %s\n", model, latency.Round(time.Millisecond))
+	return nil
+}
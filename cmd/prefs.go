@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"agentcli/internal/config"
+	"agentcli/internal/prefs"
+	"agentcli/internal/ui"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// prefsCmd 用户偏好设置
+var prefsCmd = &cobra.Command{
+	Use:   "prefs",
+	Short: "查看和修改用户偏好设置（默认模型、回答详细程度、语言、命令确认）",
+	Long: `偏好设置按用户（--user，默认当前系统用户）保存在 prefs/<用户>.json 中，每次启动时加载。
+优先级：命令行参数（--model、--yes）> 偏好设置 > 配置文件。
+
+示例:
+  agentcli prefs set model gpt-4o          # 以后启动时默认使用 gpt-4o
+  agentcli prefs set verbosity concise
+  agentcli prefs set language English
+  agentcli prefs get                       # 查看所有偏好设置
+  agentcli prefs unset model               # 恢复使用配置文件中的模型`,
+	// 偏好设置不依赖配置文件，不执行根命令的初始化
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		resolveUserID()
+		return nil
+	},
+}
+
+// prefsGetCmd 查看偏好设置
+var prefsGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "查看偏好设置，不指定key时列出所有项",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := prefs.NewStore(prefs.DefaultDir, userID).Load()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			value, err := p.Get(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		}
+
+		ui.Printf("⚙️  用户 %s 的偏好设置:\n", userID)
+		for _, key := range prefs.KeyNames() {
+			value, _ := p.Get(key)
+			if value == "" {
+				value = "-"
+			}
+			fmt.Printf("  %-13s %-20s %s\n", key, value, prefs.Keys[key])
+		}
+		return nil
+	},
+}
+
+// prefsSetCmd 修改偏好设置
+var prefsSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "修改偏好设置",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := prefs.NewStore(prefs.DefaultDir, userID)
+		if err := store.Set(args[0], strings.Join(args[1:], " ")); err != nil {
+			return err
+		}
+		ui.Printf("✅ 已保存偏好设置 %s（%s）\n", args[0], store.Path())
+		return nil
+	},
+}
+
+// prefsUnsetCmd 取消偏好设置
+var prefsUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "取消偏好设置，恢复使用配置文件中的值",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := prefs.NewStore(prefs.DefaultDir, userID).Set(args[0], ""); err != nil {
+			return err
+		}
+		ui.Printf("✅ 已取消偏好设置 %s\n", args[0])
+		return nil
+	},
+}
+
+// userPrefs 启动时加载的用户偏好设置
+var userPrefs *prefs.Prefs
+
+// applyPrefs 启动时加载用户偏好设置：覆盖配置文件中的值，命令行参数优先
+func applyPrefs(cmd *cobra.Command) {
+	p, err := prefs.NewStore(prefs.DefaultDir, userID).Load()
+	if err != nil {
+		ui.Printf("⚠️  %v\n", err)
+		return
+	}
+	userPrefs = p
+
+	if p.Model != "" && chatModel == "" {
+		if err := cfg.Policy.CheckModel(p.Model); err != nil {
+			ui.Printf("⚠️  忽略偏好设置中的默认模型: %v\n", err)
+			p.Model = ""
+		}
+	}
+	overridePrefs(cfg)
+	if p.AutoApprove != nil && !cmd.Flags().Changed("yes") {
+		assumeYes = *p.AutoApprove
+	}
+}
+
+// overridePrefs 用偏好设置覆盖配置；配置文件在会话中被修改并重新加载时同样调用
+func overridePrefs(c *config.Config) {
+	if userPrefs == nil {
+		return
+	}
+	if userPrefs.Model != "" && chatModel == "" {
+		c.API.Model = userPrefs.Model
+	}
+	if userPrefs.Verbosity != "" {
+		c.Response.Verbosity = userPrefs.Verbosity
+	}
+	if userPrefs.Language != "" {
+		c.Response.Language = userPrefs.Language
+	}
+}
+
+func init() {
+	prefsCmd.AddCommand(prefsGetCmd)
+	prefsCmd.AddCommand(prefsSetCmd)
+	prefsCmd.AddCommand(prefsUnsetCmd)
+	rootCmd.AddCommand(prefsCmd)
+}
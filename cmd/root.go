@@ -2,14 +2,35 @@ package cmd
 
 import (
 	"agentcli/internal/agent"
+	"agentcli/internal/audit"
+	"agentcli/internal/bugreport"
+	"agentcli/internal/clock"
 	"agentcli/internal/config"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/events"
+	"agentcli/internal/fsutil"
 	"agentcli/internal/history"
+	"agentcli/internal/llm"
 	"agentcli/internal/logger"
+	"agentcli/internal/profile"
+	"agentcli/internal/sandbox"
+	"agentcli/internal/snapshot"
+	"agentcli/internal/snippets"
+	"agentcli/internal/team"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"agentcli/internal/usage"
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"os/user"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,14 +39,26 @@ import (
 )
 
 var (
-	configFile string
-	chatModel  string
-	sessionID  string
-	cfg        *config.Config
-	historyMgr *history.Manager
-	log        *logger.Logger
-	userID     string
-	memory     string // Agent定制化记忆
+	configFile   string
+	chatModel    string
+	sessionID    string
+	cfg          *config.Config
+	historyMgr   *history.Manager
+	snippetStore *snippets.Store
+	tracker      *usage.Tracker
+	auditLog     *audit.Logger
+	eventLog     *eventlog.Recorder
+	log          *logger.Logger
+	userID       string
+	memory       string // Agent定制化记忆
+	useSandbox   bool   // 影子工作区模式
+	dryRun       bool   // 演练模式
+	planMode     bool   // 计划模式
+	assumeYes    bool   // 执行命令前不询问确认
+	precheck     bool   // 会话开始前预检模型服务
+
+	replReader   *bufio.Reader     // 交互模式的输入，默认读取标准输入
+	llmTransport http.RoundTripper // LLM请求的HTTP传输层（simulate命令用于录制/回放）
 )
 
 // rootCmd 根命令
@@ -35,13 +68,24 @@ var rootCmd = &cobra.Command{
 	Long: `AgentCLI 是一个智能终端助手，使用DAG（有向无环图）进行深度思考，
 支持多种工具调用，包括：
   - 写代码 (write_code)
+  - 写文件 (write_file)
+  - 应用补丁 (apply_patch)
+  - 局部编辑文件 (edit_file)
   - 读取文件 (read_file)
+  - 列出文件 (list_files)
+  - 搜索文件内容 (search_files)
+  - 网页搜索 (web_search)
+  - 读取网页 (fetch_url)
   - 识别图片 (recognize_image)
   - 执行命令 (execute_command)
+  - 浏览器自动化 (browser)
+  - 提醒事项 (reminders)
+  - Go代码分析 (go_inspect)
 
 通过API Key连接大语言模型，智能理解用户意图并自动调用相应工具完成任务。`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 默认启动交互式模式
+		initTelemetry()
 		return runInteractive()
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
@@ -51,21 +95,20 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("加载配置失败: %w", err)
 		}
-
-		// 获取用户ID
-		if userID == "" {
-			currentUser, err := user.Current()
-			if err == nil {
-				userID = currentUser.Username
-				// 处理 Windows 下的 DOMAIN\User 格式
-				if idx := strings.LastIndex(userID, "\\"); idx >= 0 {
-					userID = userID[idx+1:]
-				}
-			} else {
-				userID = "default"
+		if !ui.ValidStyle(cfg.UI.Style) {
+			return fmt.Errorf("加载配置失败: ui.style 只能是 emoji、plain 或 minimal，当前为 %q", cfg.UI.Style)
+		}
+		ui.SetStyle(cfg.UI.Style)
+		if chatModel != "" {
+			if err := cfg.Policy.CheckModel(chatModel); err != nil {
+				return err
 			}
 		}
 
+		// 获取用户ID，加载用户偏好设置
+		resolveUserID()
+		applyPrefs(cmd)
+
 		// 初始化历史记录管理器（当前目录下）
 		historyDir := "histories"
 		historyMgr = history.NewManager(historyDir)
@@ -77,31 +120,115 @@ var rootCmd = &cobra.Command{
 		if sessionID == "" {
 			sessionID = fmt.Sprintf("%s_%d", userID, time.Now().Unix())
 		}
-		log, err = logger.NewLogger(sessionID)
+		// 恢复的会话接续之前的日志和事件序号
+		clock.Session.Advance(max(logger.LastSeq(sessionID), eventlog.LastSeq(sessionID)))
+		log, err = logger.NewLogger(sessionID, logger.Options{
+			Level:      cfg.Logging.Level,
+			Output:     cfg.Logging.Output,
+			Format:     cfg.Logging.Format,
+			MaxSizeMB:  cfg.Logging.MaxSizeMB,
+			MaxBackups: cfg.Logging.MaxBackups,
+			MaxAgeDays: cfg.Logging.MaxAgeDays,
+		})
 		if err != nil {
 			return fmt.Errorf("初始化日志失败: %w", err)
 		}
+		log.SetContentPolicy(logger.ContentPolicy{
+			Mode:       cfg.Logging.Content,
+			SampleRate: cfg.Logging.SampleRate,
+			MaxChars:   cfg.Logging.MaxContentChars,
+			Redact:     cfg.Logging.Redact,
+			Secrets:    logSecrets(),
+		})
+
+		// 初始化用量追踪（预算按用户和会话统计）
+		tracker = usage.NewTracker(cfg.Budget, userID, "usage")
+		tracker.Warn = func(message string) {
+			ui.Printf("\n⚠️  预算提醒: %s\n", message)
+			log.Info("预算提醒", map[string]interface{}{"message": message})
+		}
+		tracker.Recorded = func(model string, request, session usage.Totals) {
+			log.Info("LLM用量", map[string]interface{}{
+				"model":             model,
+				"prompt_tokens":     request.PromptTokens,
+				"cached_tokens":     request.CachedTokens,
+				"completion_tokens": request.CompletionTokens,
+				"cost":              request.Cost,
+				"session_tokens":    session.Tokens(),
+				"session_cost":      session.Cost,
+			})
+		}
+
+		// 初始化审计日志
+		if cfg.Audit.Enabled {
+			auditFile := cfg.Audit.File
+			if auditFile == "" {
+				auditFile = filepath.Join("audit", "audit.jsonl")
+			}
+			auditLog, err = audit.NewLogger(auditFile, cfg.Audit.Syslog, cfg.Audit.SyslogTag, userID, sessionID)
+			if err != nil {
+				return fmt.Errorf("初始化审计日志失败: %w", err)
+			}
+		}
+
+		if cfg.Sandbox.Enabled {
+			useSandbox = true
+		}
 
 		// 加载持久化的memory（如果命令行没有指定）
 		if memory == "" {
 			loadedMemory, err := agent.LoadMemoryFromFile(userID)
 			if err == nil && loadedMemory != "" {
 				memory = loadedMemory
-				fmt.Printf("📝 已加载定制化记忆: %s\n", memory)
+				ui.Printf("📝 已加载定制化记忆: %s\n", memory)
 			}
 		}
 
+		// 提示已到期的提醒事项
+		showDueReminders()
+
 		return nil
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-		// 关闭日志记录器
-		if log != nil {
-			log.Close()
-		}
+		closeSession()
 		return nil
 	},
 }
 
+// resolveUserID 未指定用户ID时使用当前系统用户名
+func resolveUserID() {
+	if userID != "" {
+		return
+	}
+	currentUser, err := user.Current()
+	if err != nil {
+		userID = "default"
+		return
+	}
+	userID = currentUser.Username
+	// 处理 Windows 下的 DOMAIN\User 格式
+	if idx := strings.LastIndex(userID, "\\"); idx >= 0 {
+		userID = userID[idx+1:]
+	}
+}
+
+// logSecrets 会话日志中需要脱敏的密钥：配置文件中的密钥字段、API Key 和密钥类环境变量
+func logSecrets() []string {
+	var secrets []string
+	if path := config.FileUsed(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			secrets = profile.SecretValues(data)
+		}
+	}
+	secrets = append(secrets, cfg.API.OpenAIKey, cfg.API.AnthropicKey, cfg.Recall.APIKey)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && tools.IsSecretEnv(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
 // Execute 执行命令
 func Execute() error {
 	return rootCmd.Execute()
@@ -113,9 +240,25 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&sessionID, "session", "s", "", "会话ID")
 	rootCmd.PersistentFlags().StringVarP(&chatModel, "model", "m", "", "指定使用的模型")
 	rootCmd.PersistentFlags().StringVarP(&memory, "memory", "", "", "Agent定制化记忆")
+	rootCmd.PersistentFlags().BoolVar(&useSandbox, "sandbox", false, "在影子工作区中执行每轮的文件修改，确认后再应用")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "演练模式：只展示计划的工具调用及参数，不实际执行")
+	rootCmd.PersistentFlags().BoolVar(&planMode, "plan", false, "计划模式：write_code 和 edit_file 的修改展示diff后需 /apply 确认才写入")
+	rootCmd.PersistentFlags().BoolVar(&precheck, "precheck", false, "开始会话前验证模型服务的连通性、认证和模型可用性（配置项 api.precheck）")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "执行命令前不再询问确认（命令白名单和黑名单仍然生效）")
+
+	historyImportCmd.Flags().StringVar(&importFormat, "format", history.ImportFormatAuto, "导入格式: auto/chatgpt/claude/text")
+	historyCmd.AddCommand(historyImportCmd)
+	historyMergeCmd.Flags().DurationVar(&mergeWindow, "window", history.DefaultMergeWindow, "自动合并时允许的对话间隔")
+	historyMergeCmd.Flags().BoolVar(&mergeDryRun, "dry-run", false, "只展示将要合并和删除的对话，不修改文件")
+	historyCmd.AddCommand(historyMergeCmd)
 
 	// 添加子命令
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(historyCmd)
+
+	importProfileCmd.Flags().BoolVar(&profileForce, "force", false, "覆盖已有的配置文件和记忆")
+	rootCmd.AddCommand(exportProfileCmd)
+	rootCmd.AddCommand(importProfileCmd)
 }
 
 // runInteractive 运行交互式模式
@@ -126,57 +269,104 @@ func runInteractive() error {
 	}
 
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Printf("🤖 AgentCLI - 交互式模式\n")
-	fmt.Printf("📦 模型: %s\n", model)
-	fmt.Printf("👤 用户: %s\n", userID)
+	ui.Printf("🤖 AgentCLI - 交互式模式\n")
+	ui.Printf("📦 模型: %s\n", model)
+	ui.Printf("👤 用户: %s\n", userID)
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 	fmt.Printf("提示:\n")
 	fmt.Printf("  - 输入 'exit' 或 'quit' 退出\n")
 	fmt.Printf("  - 输入 '/new' 开始新对话\n")
 	fmt.Printf("  - 输入 '/model' 切换模型\n")
-	fmt.Printf("  - 输入 '/history' 查看历史对话\n")
+	fmt.Printf("  - 输入 '/history [页码]' 查看历史对话，可按 model=、since=、until= 筛选，sort= 排序\n")
 	fmt.Printf("  - 输入 '/load <id>' 加载历史对话\n")
+	fmt.Printf("  - 输入 '/switch new' 同时打开另一个对话，'/switch <编号|id>' 切换，'/list-open' 查看打开的对话\n")
 	fmt.Printf("  - 输入 '/memory <text>' 设置Agent定制化记忆\n")
 	fmt.Printf("  - 输入 '/memory clear' 删除定制化记忆\n")
+	fmt.Printf("  - 输入 '/memory history' 查看记忆的历史版本，'/memory revert <版本号>' 恢复\n")
+	fmt.Printf("  - 输入 '/recall <查询>' 查看相关的长期记忆，'/recall clear' 删除长期记忆\n")
+	fmt.Printf("  - 输入 '/docs on|off' 开关库文档自动检索\n")
+	fmt.Printf("  - 输入 '/set name=value' 设置对话变量，使用 {{name}} 引用\n")
+	fmt.Printf("  - 输入 '/cd <目录>' 设置工具的默认执行目录，'/cd /' 回到工作区根目录\n")
+	fmt.Printf("  - 输入 '/env set KEY=VALUE' 设置本次对话执行命令时的环境变量，'/env unset KEY' 删除，'/env' 查看\n")
+	fmt.Printf("  - 输入 '/verbosity concise|normal|detailed' 调整回答详细程度\n")
+	fmt.Printf("  - 输入 '/consensus on|off' 开关双模型共识模式\n")
+	fmt.Printf("  - 输入 '/sandbox on|off' 开关影子工作区模式\n")
+	fmt.Printf("  - 输入 '/dryrun on|off' 开关演练模式（只展示计划的工具调用）\n")
+	fmt.Printf("  - 输入 '/plan on|off' 开关计划模式，'/changes' 查看待确认的修改，'/apply [文件...]' 应用，'/discard [文件...]' 丢弃\n")
+	fmt.Printf("  - 输入 '/config history' 查看代理对配置的修改记录，'/config revert [记录号]' 撤销\n")
+	fmt.Printf("  - 输入 '/artifacts' 查看本次对话生成的文件\n")
+	fmt.Printf("  - 输入 '/usage' 查看用量与预算\n")
+	fmt.Printf("  - 输入 '/capabilities' 查看当前可用的工具、模型能力和权限策略\n")
+	fmt.Printf("  - 输入 '/context' 查看下一轮将发送的上下文组成和token占用\n")
+	fmt.Printf("  - 输入 '/why [编号]' 根据事件日志查看上一轮为什么调用这些工具、参数从何而来\n")
+	fmt.Printf("  - 输入 '/snippet save <name>' 保存可复用的文本片段，'/snippet insert <name> [消息]' 插入消息，'/snippets' 查看\n")
+	fmt.Printf("  - 输入 '/extract on|off' 开关从回答的代码块中提取文件并询问写入\n")
+	fmt.Printf("  - 输入 '/run-tool <工具名> <JSON参数>' 手动执行工具，可选择将结果加入对话\n")
+	fmt.Printf("  - 输入 '/resume' 继续上次失败的轮次\n")
+	fmt.Printf("  - 输入 '/retry' 重新生成上一轮回答，'/edit <text>' 修改上一条消息后重新生成\n")
+	fmt.Printf("  - 输入 '/pin <n>' 固定消息始终保留在上下文中，'/pins' 查看，'/unpin <n>' 取消\n")
+	fmt.Printf("  - 输入 '/team' 查看团队共享指令，'/team sync' 重新同步\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	// 创建新对话
+	// 创建新对话（/switch 可同时打开多个对话）
 	conv := history.NewConversation(userID, model)
+	openConvs := newOpenConversations(conv)
 
 	// 创建Agent
-	a := agent.NewAgent(cfg, log)
+	a := newSessionAgent()
+	defer a.CloseMCP()
 
-	// 应用命令行指定的记忆
-	if memory != "" {
-		a.SetMemory(memory)
+	// 创建读取器（simulate命令会替换为脚本输入）
+	scripted := replReader != nil
+	if replReader == nil {
+		replReader = bufio.NewReader(os.Stdin)
+	}
+	reader := replReader
+	a.SetFilePicker(pickFile)
+	// 标准输入不是终端时无法询问确认，策略要求确认的命令不会执行（--yes 跳过确认）
+	if scripted || isTerminal(os.Stdin) {
+		a.SetCommandConfirmer(confirmCommand)
+		a.SetConfigApprover(confirmConfigEdit)
+	}
+	if err := a.SetAutoApprove(assumeYes); err != nil {
+		ui.Printf("⚠️  %v\n", err)
+	}
+
+	// 退出信号：取消正在执行的工具，等待其停止后保存对话
+	exit := newShutdown(openConvs.saveAll)
+	defer exit.stop()
+	ctx := exit.ctx
+
+	if err := precheckModel(ctx, a, model); err != nil {
+		return err
 	}
 
-	// 创建读取器
-	reader := bufio.NewReader(os.Stdin)
-	ctx := context.Background()
+	snippetStore = snippets.NewStore(snippets.DefaultDir, userID)
+	var pendingSnippets []string // 待附加到下一条消息的片段
 
 	for {
-		fmt.Print("👤 你: ")
+		// 处理上一轮时收到退出信号：工具已停止，保存对话后退出
+		if exit.end() {
+			exit.quit()
+			break
+		}
+
+		ui.Print(replPrompt)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			log.Error("读取输入失败", err, nil)
 			return fmt.Errorf("读取输入失败: %w", err)
 		}
+		exit.begin()
 
 		input = strings.TrimSpace(input)
 
 		// 检查退出命令
 		if input == "exit" || input == "quit" {
-			// 保存对话
-			if len(conv.Messages) > 0 {
-				if err := historyMgr.SaveConversation(conv); err != nil {
-					log.Error("保存对话失败", err, nil)
-					fmt.Printf("⚠️  保存对话失败: %v\n", err)
-				} else {
-					fmt.Printf("✅ 对话已保存 (ID: %s)\n", conv.ID)
-				}
+			if pending := len(a.Changes().Changes()); pending > 0 {
+				ui.Printf("⚠️  %d 个文件的修改未应用，已丢弃\n", pending)
 			}
-			fmt.Println("\n👋 再见!")
+			exit.quit()
 			break
 		}
 
@@ -184,16 +374,109 @@ func runInteractive() error {
 			continue
 		}
 
-		// 处理特殊命令
-		if strings.HasPrefix(input, "/") {
+		// 每个对话使用独立的轮次检查点（使用绝对路径，影子工作区模式下也写入真实目录）
+		if checkpointFile, absErr := filepath.Abs(filepath.Join("checkpoints", conv.ID+".json")); absErr == nil {
+			a.SetCheckpointFile(checkpointFile)
+		}
+
+		// /resume：从上次失败轮次的最后一次成功工具调用处继续（用户输入已在对话中）
+		// 没有检查点时（如重新加载的对话），根据对话中保存的工具调用和结果继续
+		resume := false
+		if input == "/resume" {
+			pending, ok := a.PendingTurn()
+			if !ok {
+				pending, _, _, ok = conv.UnfinishedTurn()
+			}
+			if !ok {
+				ui.Println("📭 没有可恢复的未完成轮次")
+				continue
+			}
+			input = pending
+			resume = true
+			telemetryCollector.RecordCommand("/resume")
+			ui.Printf("🔁 继续未完成的轮次: %s\n", input)
+		}
+
+		// /snippet insert：将片段插入本条消息，未附带消息时附加到下一条消息
+		if !resume && (input == "/snippet insert" || strings.HasPrefix(input, "/snippet insert ")) {
+			name, message, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(input, "/snippet insert")), " ")
+			if name == "" {
+				ui.Println("❌ 用法: /snippet insert <name> [消息]")
+				continue
+			}
+			snippet, err := snippetStore.Get(name)
+			if err != nil {
+				ui.Printf("❌ %v\n", err)
+				continue
+			}
+			pendingSnippets = append(pendingSnippets, snippet.Content)
+			telemetryCollector.RecordCommand("/snippet insert")
+			if message = strings.TrimSpace(message); message == "" {
+				ui.Printf("📎 片段 %s 将附加到下一条消息\n", name)
+				continue
+			}
+			ui.Printf("📎 已插入片段 %s\n", name)
+			input = message
+		}
+
+		// /retry、/edit：撤回上一轮（用户消息及其回答），重新发送原消息或修改后的消息
+		if !resume && (input == "/retry" || input == "/edit" || strings.HasPrefix(input, "/edit ")) {
+			last, ok := conv.LastUserMessage()
+			if !ok {
+				ui.Println("📭 当前对话还没有可重新发送的消息")
+				continue
+			}
+
+			edited := strings.TrimSpace(strings.TrimPrefix(input, "/edit"))
+			if input != "/retry" && edited == "" {
+				fmt.Printf("上一条消息: %s\n", last)
+				fmt.Println("用法: /edit <修改后的消息>")
+				continue
+			}
+
+			conv.PopLastTurn()
+			telemetryCollector.RecordCommand(strings.Fields(input)[0])
+			if input == "/retry" {
+				input = last
+				ui.Printf("🔁 重新发送（模型: %s）: %s\n", model, input)
+			} else {
+				input = edited
+				ui.Printf("✏️  已修改上一条消息并重新生成: %s\n", input)
+			}
+			log.Info("重新生成上一轮", map[string]interface{}{"input": input})
+		} else if !resume && (input == "/list-open" || input == "/switch" || strings.HasPrefix(input, "/switch ")) {
+			// /switch、/list-open：在同时打开的多个对话之间切换，每个对话保留自己的模型和工具执行方式
+			if openConvs.handle(input, a) {
+				conv = openConvs.current()
+				model = conv.Model
+			}
+			telemetryCollector.RecordCommand(strings.Fields(input)[0])
+			continue
+		} else if !resume && strings.HasPrefix(input, "/") {
+			// 处理其他特殊命令
 			if handleCommand(input, &model, conv, historyMgr, a, log) {
+				telemetryCollector.RecordCommand(strings.Fields(input)[0])
 				continue
 			}
 		}
 
-		// 记录用户输入
-		log.UserInput(input)
-		conv.AddMessage("user", input)
+		if !resume {
+			// 附加待插入的片段
+			if len(pendingSnippets) > 0 {
+				input = strings.Join(pendingSnippets, "\n\n") + "\n\n" + input
+				pendingSnippets = nil
+			}
+
+			// 展开对话级变量
+			input = history.ExpandVariables(input, conv.Variables)
+			a.SetVariables(conv.Variables)
+			a.SetWorkdir(conv.Workdir)
+			a.SetEnv(conv.Env)
+
+			// 记录用户输入
+			log.UserInput(input)
+			conv.AddMessage("user", input)
+		}
 
 		// 获取对话历史（不包括刚添加的用户消息，因为会在Agent内部处理）
 		conversationHistory := conv.ToLLMMessages()
@@ -202,17 +485,75 @@ func runInteractive() error {
 			conversationHistory = conversationHistory[:len(conversationHistory)-1]
 		}
 
+		// 在进入影子工作区之前记录真实工作区的状态，本轮结束回到真实工作区后对比
+		workspace := snapshot.Take(".")
+
+		// 影子工作区模式：本轮的文件修改先在副本中进行
+		var sb *sandbox.Sandbox
+		if useSandbox {
+			sb, err = enterSandbox()
+			if err != nil {
+				log.Error("创建影子工作区失败", err, nil)
+				ui.Printf("⚠️  创建影子工作区失败，本轮将直接在当前目录执行: %v\n", err)
+			}
+		}
+
 		// 流式输出处理请求（带对话历史）
 		var fullResponse string
-		response, err := a.ProcessRequestStream(ctx, input, conversationHistory, func(chunk string) error {
-			fmt.Print(chunk)
+		onChunk := func(chunk string) error {
+			smoother.Write(chunk)
 			fullResponse += chunk
 			return nil
+		}
+		var response string
+		turnStarted := time.Now()
+		response, err = recoverTurn(func() (string, error) {
+			if resume {
+				return a.ResumeSession(ctx, conv, onChunk)
+			}
+			return a.ProcessRequestStream(ctx, input, conversationHistory, onChunk)
 		})
+		smoother.Flush(ctx)
+		telemetryCollector.RecordLatency("turn", time.Since(turnStarted))
 
+		// 记录本轮生成的文件（影子工作区中的路径映射回真实目录）
+		artifacts := a.ConsumeArtifacts()
+		if sb != nil {
+			for i := range artifacts {
+				if rel, relErr := filepath.Rel(sb.Dir, artifacts[i].Path); relErr == nil && !strings.HasPrefix(rel, "..") {
+					artifacts[i].Path = filepath.Join(sb.Root, rel)
+				}
+			}
+			if exit.stopping() {
+				discardSandbox(sb)
+			} else {
+				leaveSandbox(sb, reader)
+			}
+		}
+		conv.AddArtifacts(artifacts)
+		// 保存本轮的工具调用和结果（失败时为已完成的部分），重新加载对话后可继续未完成的任务
+		conv.AddTurnMessages(a.ConsumeTurnMessages())
+
+		if err != nil && exit.stopping() {
+			ui.Println("⏹  当前任务已停止")
+			if changes := workspace.Summarize(artifactPaths(artifacts)); changes != nil {
+				ui.Printf("📝 %s\n", changes)
+			}
+			continue
+		}
 		if err != nil {
 			log.Error("处理请求失败", err, nil)
-			fmt.Printf("\n❌ 错误: %v\n\n", err)
+			ui.Printf("\n❌ 错误: %v\n", err)
+			if changes := workspace.Summarize(artifactPaths(artifacts)); changes != nil {
+				ui.Printf("📝 %s\n", changes)
+			}
+			if _, ok := a.PendingTurn(); ok {
+				ui.Println("💡 输入 '/resume' 可从最后一次成功的工具调用处继续，已执行的工具不会重复执行")
+			}
+			if bugreport.IsInternal(err) {
+				offerBugReport(reader, err, input, conv.Model, conv.Env)
+			}
+			fmt.Println()
 			continue
 		}
 
@@ -225,12 +566,53 @@ func runInteractive() error {
 		log.AgentOutput(response)
 		conv.AddMessage("assistant", response)
 
+		if cfg.Response.ExtractCodeFiles {
+			written := offerCodeFiles(ctx, a, response)
+			conv.AddArtifacts(written)
+			artifacts = append(artifacts, written...)
+		}
+
+		// 本轮对工作区的实际改动
+		if changes := workspace.Summarize(artifactPaths(artifacts)); changes != nil {
+			ui.Printf("\n\n📝 %s", changes)
+			conv.SetLastChanges(changes.String())
+		}
+		reportProposedChanges(a)
+
 		fmt.Println("\n\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	}
 
 	return nil
 }
 
+// saveConversationOnExit 退出前保存有回答的对话
+func saveConversationOnExit(conv *history.Conversation) {
+	if !conv.HasAssistantMessages() {
+		return
+	}
+	if err := historyMgr.SaveConversation(conv); err != nil {
+		log.Error("保存对话失败", err, nil)
+		ui.Printf("⚠️  保存对话失败: %v\n", err)
+	} else {
+		ui.Printf("✅ 对话已保存 (ID: %s)\n", conv.ID)
+	}
+}
+
+// closeSession 上报使用统计并关闭日志，正常退出和收到退出信号时都会调用
+func closeSession() {
+	// 上报匿名使用统计（仅在用户开启时）
+	flushTelemetry()
+
+	// 关闭日志记录器
+	if log != nil {
+		log.Close()
+	}
+	if auditLog != nil {
+		auditLog.Close()
+	}
+	eventLog.Close()
+}
+
 // interactiveCmd 交互式命令（流式输出）
 var interactiveCmd = &cobra.Command{
 	Use:     "interactive",
@@ -238,6 +620,7 @@ var interactiveCmd = &cobra.Command{
 	Long:    "进入交互式模式，可以持续与Agent对话，支持流式输出、历史记录、模型切换等",
 	Aliases: []string{"i", "repl"},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		initTelemetry()
 		return runInteractive()
 	},
 }
@@ -247,219 +630,1501 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "显示版本信息",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("AgentCLI v2.0.0")
+		fmt.Printf("AgentCLI v%s\n", appVersion)
 		fmt.Println("基于DAG的智能终端助手 - 流式输出版本")
 	},
 }
 
-// handleCommand 处理特殊命令
-func handleCommand(input string, model *string, conv *history.Conversation, historyMgr *history.Manager, a *agent.Agent, log *logger.Logger) bool {
-	parts := strings.Fields(input)
-	if len(parts) == 0 {
-		return false
-	}
+// importFormat history import 的导入格式
+var importFormat string
 
-	cmd := parts[0]
+// historyCmd 历史记录命令
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "管理历史对话",
+}
 
-	switch cmd {
-	case "/new":
-		// 保存当前对话
-		if len(conv.Messages) > 0 {
-			if err := historyMgr.SaveConversation(conv); err != nil {
-				log.Error("保存对话失败", err, nil)
-				fmt.Printf("⚠️  保存对话失败: %v\n", err)
-			} else {
-				fmt.Printf("✅ 对话已保存 (ID: %s)\n", conv.ID)
-			}
+// historyImportCmd 从其他助手的导出文件导入历史对话
+var historyImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "导入ChatGPT/Claude的对话导出文件",
+	Long: `将其他助手的对话导出解析为本地历史对话，之后可在交互模式中用 /load <id> 继续对话。
+支持的格式：
+  - chatgpt: ChatGPT 数据导出中的 conversations.json
+  - claude:  Claude 数据导出中的 conversations.json
+  - text:    以 Human:/Assistant: 开头分段的纯文本对话记录`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		model := cfg.API.Model
+		if chatModel != "" {
+			model = chatModel
 		}
-		// 创建新对话
-		*conv = *history.NewConversation(conv.UserID, *model)
-		fmt.Println("🆕 开始新对话")
-		log.Info("开始新对话", map[string]interface{}{"conversation_id": conv.ID})
-		return true
 
-	case "/model":
-		availableModels := []string{
-			"gpt-4",
-			"gpt-5.2",
-			"o4-mini",
-			"o3",
-			"o3-pro",
-			"sora_image",
-			"sora-2-pro",
-			"claude-opus-4-5-20251101-thinking",
-			"claude-sonnet-4-5-20250929",
-			"claude-sonnet-4-5-20250929-thinking",
-			"gemini-3-pro-preview-thinking",
-			"gemini-3-pro-preview",
-			"gemini-3-pro-all",
-			"gemini-3-pro-image-preview",
-			"qwen-plus",
-		}
-
-		fmt.Println("\n📦 可用模型列表:")
-		for i, m := range availableModels {
-			marker := " "
-			if m == *model {
-				marker = "✓"
-			}
-			fmt.Printf("  [%s] %d. %s\n", marker, i+1, m)
+		conversations, err := history.ImportFile(args[0], importFormat, userID, model)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("\n当前模型: %s\n", *model)
-		fmt.Print("请输入模型编号或名称 (回车保持当前): ")
-
-		reader := bufio.NewReader(os.Stdin)
-		choice, _ := reader.ReadString('\n')
-		choice = strings.TrimSpace(choice)
 
-		if choice == "" {
-			fmt.Println("保持当前模型")
-			return true
+		for _, conv := range conversations {
+			if err := historyMgr.SaveConversation(conv); err != nil {
+				return fmt.Errorf("保存对话失败: %w", err)
+			}
+			title := conv.Title
+			if title == "" {
+				title = "(无标题)"
+			}
+			ui.Printf("  ✅ %s | %s | 消息数: %d\n", conv.ID, title, len(conv.Messages))
 		}
+		ui.Printf("📥 已导入 %d 个对话，在交互模式中使用 /load <id> 继续\n", len(conversations))
+		log.Info("导入历史对话", map[string]interface{}{"file": args[0], "count": len(conversations)})
+		return nil
+	},
+}
 
-		var selectedModel string
+// history merge 的选项
+var (
+	mergeWindow time.Duration
+	mergeDryRun bool
+)
 
-		// 1) 先尝试按“编号”解析（支持 >9）
-		if idx, err := strconv.Atoi(choice); err == nil {
-			idx-- // 变成 0-based
-			if idx >= 0 && idx < len(availableModels) {
-				selectedModel = availableModels[idx]
-			} else {
-				fmt.Printf("❌ 无效编号: %d (范围: 1-%d)\n", idx+1, len(availableModels))
-				return true
+// historyMergeCmd 合并或去重历史对话
+var historyMergeCmd = &cobra.Command{
+	Use:   "merge [id...]",
+	Short: "合并指定的对话，或自动清理空对话、重复对话和时间相邻的对话",
+	Long: `指定两个及以上对话ID时，将它们按创建时间合并到最早的对话中。
+不指定ID时对当前用户的历史执行去重：
+  - 删除没有任何助手回答的对话
+  - 删除内容完全相同的重复对话
+  - 将ID前缀、模型相同且间隔不超过 --window 的相邻对话合并为一个`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			if mergeDryRun {
+				return fmt.Errorf("--dry-run 仅适用于自动去重")
 			}
-		} else {
-			// 2) 再按“名称”匹配（可选：也可以做不区分大小写）
-			selectedModel = choice
-		}
-
-		// 可选：验证名称是否在列表中，避免输入不存在的模型
-		found := false
-		for _, m := range availableModels {
-			if m == selectedModel {
-				found = true
-				break
+			conv, err := historyMgr.MergeConversations(args)
+			if err != nil {
+				return err
 			}
+			ui.Printf("✅ 已合并 %d 个对话到 %s (消息数: %d)\n", len(args), conv.ID, len(conv.Messages))
+			log.Info("合并历史对话", map[string]interface{}{"target": conv.ID, "sources": args})
+			return nil
 		}
-		if !found {
-			fmt.Printf("❌ 未知模型名称: %s\n", selectedModel)
-			return true
-		}
-
-		*model = selectedModel
-		conv.Model = selectedModel
-		cfg.API.Model = selectedModel
-		a.UpdateModel(selectedModel)
-		fmt.Printf("✅ 已切换到模型: %s\n", selectedModel)
-		log.Info("切换模型", map[string]interface{}{"model": selectedModel})
-		return true
 
-	case "/history":
-		conversations, err := historyMgr.ListConversations(conv.UserID)
+		result, err := historyMgr.Dedupe(userID, mergeWindow, mergeDryRun)
 		if err != nil {
-			log.Error("获取历史记录失败", err, nil)
-			fmt.Printf("❌ 获取历史记录失败: %v\n", err)
-			return true
+			return err
 		}
-		if len(conversations) == 0 {
-			fmt.Println("📭 没有历史对话记录")
-			return true
+		for _, id := range result.Empty {
+			ui.Printf("  🗑️  空对话: %s\n", id)
 		}
-		fmt.Println("\n📜 历史对话:")
-		for i, c := range conversations {
-			fmt.Printf("  %d. ID: %s | 模型: %s | 消息数: %d | 更新: %s\n",
-				i+1, c.ID, c.Model, len(c.Messages), c.Updated.Format("2006-01-02 15:04"))
+		for target, ids := range result.Duplicates {
+			ui.Printf("  🗑️  与 %s 重复: %s\n", target, strings.Join(ids, ", "))
+		}
+		for target, ids := range result.Merged {
+			ui.Printf("  🔗 合并到 %s: %s\n", target, strings.Join(ids, ", "))
 		}
-		fmt.Println()
-		return true
 
-	case "/load":
-		if len(parts) < 2 {
-			fmt.Println("用法: /load <对话ID>")
-			return true
+		switch {
+		case result.Removed() == 0:
+			ui.Println("✨ 没有需要清理的对话")
+		case mergeDryRun:
+			ui.Printf("🔍 演练模式：将减少 %d 个对话文件\n", result.Removed())
+		default:
+			ui.Printf("✅ 已清理 %d 个对话文件\n", result.Removed())
+			log.Info("历史对话去重", map[string]interface{}{"user_id": userID, "removed": result.Removed()})
 		}
-		convID := parts[1]
-		loadedConv, err := historyMgr.LoadConversation(convID)
-		if err != nil {
-			log.Error("加载对话失败", err, map[string]interface{}{"conversation_id": convID})
-			fmt.Printf("❌ 加载对话失败: %v\n", err)
-			return true
+		return nil
+	},
+}
+
+// profileForce import-profile 是否覆盖已有配置
+var profileForce bool
+
+// exportProfileCmd 导出记忆和配置
+var exportProfileCmd = &cobra.Command{
+	Use:   "export-profile <file>",
+	Short: "将定制化记忆和配置（不含密钥）打包导出",
+	Long: `将当前用户的定制化记忆和配置文件打包为一个 tar.gz 文件，用于迁移到新机器或分享团队基线配置。
+配置中的API Key、token、password等密钥字段会被清空。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bundle := &profile.Bundle{
+			Manifest: profile.Manifest{UserID: userID, CreatedAt: time.Now()},
+			Memory:   memory,
 		}
 
-		// 保存当前对话
-		if len(conv.Messages) > 0 {
-			historyMgr.SaveConversation(conv)
+		if path := config.FileUsed(); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("读取配置文件失败: %w", err)
+			}
+			redactedConfig, redacted, err := profile.RedactSecrets(data)
+			if err != nil {
+				return err
+			}
+			bundle.Config = redactedConfig
+			for _, key := range redacted {
+				ui.Printf("🔒 已移除密钥: %s\n", key)
+			}
 		}
 
-		*conv = *loadedConv
-		*model = conv.Model
-		cfg.API.Model = conv.Model
-		a.UpdateModel(conv.Model)
+		if err := profile.Write(args[0], bundle); err != nil {
+			return err
+		}
+		ui.Printf("📦 已导出到 %s（%s）\n", args[0], strings.Join(bundle.Manifest.Files, ", "))
+		log.Info("导出配置包", map[string]interface{}{"file": args[0], "files": bundle.Manifest.Files})
+		return nil
+	},
+}
 
-		fmt.Printf("✅ 已加载对话 (ID: %s, 消息数: %d)\n", conv.ID, len(conv.Messages))
-		log.Info("加载历史对话", map[string]interface{}{
-			"conversation_id": conv.ID,
-			"message_count":   len(conv.Messages),
-		})
+// importProfileCmd 导入记忆和配置
+var importProfileCmd = &cobra.Command{
+	Use:   "import-profile <file>",
+	Short: "导入 export-profile 生成的配置包",
+	Long: `导入配置包中的定制化记忆和配置文件。
+配置文件写入 ./configs/config.yaml（或 --config 指定的路径），覆盖时保留现有配置中的密钥。`,
+	Args: cobra.ExactArgs(1),
+	// 新机器上可能还没有配置文件，不执行根命令的初始化
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		resolveUserID()
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bundle, err := profile.Read(args[0])
+		if err != nil {
+			return err
+		}
 
-		// 显示最近几条消息
-		recent := conv.GetRecentMessages(6)
-		if len(recent) > 0 {
-			fmt.Println("\n📝 最近的对话记录:")
-			for _, msg := range recent {
-				role := "👤"
-				if msg.Role == "assistant" {
-					role = "🤖"
+		if bundle.Config != nil {
+			target := configFile
+			if target == "" {
+				target = filepath.Join("configs", "config.yaml")
+			}
+
+			data := bundle.Config
+			if existing, err := os.ReadFile(target); err == nil {
+				if !profileForce {
+					return fmt.Errorf("配置文件 %s 已存在，使用 --force 覆盖", target)
 				}
-				content := msg.Content
-				if len(content) > 100 {
-					content = content[:100] + "..."
+				if data, err = profile.RestoreSecrets(data, existing); err != nil {
+					return err
 				}
-				fmt.Printf("  %s: %s\n", role, content)
 			}
-			fmt.Println()
-		}
-		return true
 
-	case "/memory":
-		if len(parts) < 2 {
-			if memory == "" {
-				fmt.Println("📝 当前没有设置定制化记忆")
-			} else {
-				fmt.Printf("📝 当前定制化记忆: %s\n", memory)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("创建配置目录失败: %w", err)
 			}
-			fmt.Println("用法: /memory <定制化文本>")
-			fmt.Println("用法: /memory clear  (删除定制化记忆)")
-			fmt.Println("例如: /memory 你是一个专业的Go语言开发专家，擅长性能优化")
-			return true
+			if err := fsutil.WriteFileAtomic(target, data, 0600); err != nil {
+				return fmt.Errorf("写入配置文件失败: %w", err)
+			}
+			ui.Printf("✅ 已导入配置: %s\n", target)
 		}
 
-		if strings.EqualFold(parts[1], "clear") || strings.EqualFold(parts[1], "delete") {
-			memory = ""
-			a.SetMemory("")
-			if err := agent.DeleteMemoryFromFile(userID); err != nil {
-				log.Error("删除记忆失败", err, nil)
-				fmt.Printf("⚠️  删除记忆失败: %v\n", err)
-			} else {
-				fmt.Println("✅ 已删除定制化记忆")
-				log.Info("删除定制化记忆", nil)
+		if bundle.Memory != "" {
+			existing, err := agent.LoadMemoryFromFile(userID)
+			if err != nil {
+				return err
 			}
-			return true
+			if existing != "" && !profileForce {
+				return fmt.Errorf("用户 %s 已有定制化记忆，使用 --force 覆盖", userID)
+			}
+			if err := agent.SaveMemoryToFile(userID, bundle.Memory); err != nil {
+				return err
+			}
+			ui.Printf("✅ 已导入定制化记忆: %s\n", bundle.Memory)
 		}
 
-		memory = strings.Join(parts[1:], " ")
+		ui.Println("💡 如配置中的API Key为空，请重新设置 api.openai_key 或环境变量 OPENAI_API_KEY")
+		return nil
+	},
+}
+
+// enterSandbox 创建影子工作区并切换到其中
+func enterSandbox() (*sandbox.Sandbox, error) {
+	excludes := cfg.Sandbox.Exclude
+	if len(excludes) == 0 {
+		excludes = sandbox.DefaultExcludes
+	}
+
+	sb, err := sandbox.Create(".", excludes)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(sb.Dir); err != nil {
+		sb.Cleanup()
+		return nil, fmt.Errorf("切换到影子工作区失败: %w", err)
+	}
+	log.Info("进入影子工作区", map[string]interface{}{"dir": sb.Dir})
+	return sb, nil
+}
+
+// discardSandbox 退出时切换回真实工作区并丢弃本轮的影子工作区变更
+func discardSandbox(sb *sandbox.Sandbox) {
+	defer sb.Cleanup()
+	if err := os.Chdir(sb.Root); err != nil {
+		log.Error("切换回工作区失败", err, nil)
+		return
+	}
+	ui.Println("🗑️  已丢弃影子工作区中未应用的文件变更")
+}
+
+// leaveSandbox 切换回真实工作区，展示本轮变更并在确认后应用
+func leaveSandbox(sb *sandbox.Sandbox, reader *bufio.Reader) {
+	defer sb.Cleanup()
+
+	if err := os.Chdir(sb.Root); err != nil {
+		log.Error("切换回工作区失败", err, nil)
+		ui.Printf("\n❌ 切换回工作区失败: %v\n", err)
+		return
+	}
+
+	changes, err := sb.Changes()
+	if err != nil {
+		log.Error("比较影子工作区失败", err, nil)
+		ui.Printf("\n❌ 比较影子工作区失败: %v\n", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	ui.Printf("\n\n🧪 本轮在影子工作区中产生了 %d 处文件变更:\n", len(changes))
+	fmt.Println(sb.Diff(changes))
+	fmt.Print("是否将这些变更应用到当前目录? (y/N): ")
+
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		ui.Println("🗑️  已丢弃本轮的文件变更")
+		log.Info("丢弃影子工作区变更", map[string]interface{}{"changes": len(changes)})
+		return
+	}
+
+	if err := sb.Apply(changes); err != nil {
+		log.Error("应用影子工作区变更失败", err, nil)
+		ui.Printf("❌ 应用变更失败: %v\n", err)
+		return
+	}
+	ui.Printf("✅ 已应用 %d 处文件变更\n", len(changes))
+	log.Info("应用影子工作区变更", map[string]interface{}{"changes": len(changes)})
+}
+
+// printUsage 显示本次会话和今日的用量及预算
+func printUsage() {
+	budget := tracker.Budget()
+	ui.Println("\n📊 用量统计:")
+	for _, item := range []struct {
+		scope  string
+		totals usage.Totals
+		limits config.BudgetLimits
+	}{
+		{"本次会话", tracker.Session(), budget.Session},
+		{"今日", tracker.Daily(), budget.Daily},
+	} {
+		fmt.Printf("  %s: 请求 %d 次 | tokens %s | 费用 %s | 工具调用 %s\n",
+			item.scope,
+			item.totals.Requests,
+			formatLimit(float64(item.totals.Tokens()), float64(item.limits.MaxTokens), "%.0f"),
+			formatLimit(item.totals.Cost, item.limits.MaxCost, "%.4f"),
+			formatLimit(float64(item.totals.ToolCalls), float64(item.limits.MaxToolCalls), "%.0f"),
+		)
+		if item.totals.CachedTokens > 0 {
+			fmt.Printf("    缓存命中: %d/%d prompt tokens (%.1f%%) | 节省费用 %.4f\n",
+				item.totals.CachedTokens,
+				item.totals.PromptTokens,
+				float64(item.totals.CachedTokens)/float64(item.totals.PromptTokens)*100,
+				item.totals.CacheSavings,
+			)
+		}
+	}
+	fmt.Println()
+}
+
+// renderEvent 在终端显示运行事件
+func renderEvent(e events.Event) {
+	switch e.Type {
+	case events.LLMStreamWaiting:
+		// 平滑输出仍在显示已接收的内容时不需要等待提示
+		if !smoother.Busy() {
+			streamSpinner.update(e.Delay)
+		}
+		return
+	case events.LLMStreamActive:
+		streamSpinner.clear()
+		return
+	}
+
+	streamSpinner.clear()
+	smoother.Drain()
+	switch e.Type {
+	case events.LLMRetry:
+		ui.Printf("\n⏳ 请求失败，%s 后重试 (第 %d/%d 次): %v\n", e.Delay.Round(time.Second), e.Attempt, e.MaxAttempts, e.Err)
+	case events.LLMRateLimited:
+		if e.Delay > 0 {
+			ui.Printf("\n🚦 模型 %s 请求被限流，服务建议 %s 后再试\n", e.Model, e.Delay.Round(time.Second))
+		} else {
+			ui.Printf("\n🚦 模型 %s 请求被限流\n", e.Model)
+		}
+	case events.LLMTimeout:
+		fmt.Printf("\n⌛ 模型 %s 请求超时\n", e.Model)
+	case events.LLMStreamResumed:
+		ui.Printf("\n🔌 连接中断，正在从中断处续接回答 (第 %d/%d 次): %v\n", e.Attempt, e.MaxAttempts, e.Err)
+	case events.LLMStreamStalled:
+		fmt.Printf("\n⌛ 模型 %s 超过 %s 没有返回数据，已断开连接\n", e.Model, e.Delay.Round(time.Second))
+	}
+	if log != nil {
+		log.Info("运行事件", map[string]interface{}{
+			"type":    string(e.Type),
+			"model":   e.Model,
+			"attempt": e.Attempt,
+			"delay":   e.Delay.String(),
+		})
+	}
+}
+
+// formatLimit 格式化用量与限额
+func formatLimit(used, limit float64, format string) string {
+	if limit <= 0 {
+		return fmt.Sprintf(format, used)
+	}
+	return fmt.Sprintf(format+"/"+format, used, limit)
+}
+
+// artifactStatus 检查产物当前状态（是否被修改或删除）
+func artifactStatus(art history.Artifact) string {
+	data, err := os.ReadFile(art.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "已删除"
+		}
+		return "无法读取"
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != art.SHA256 {
+		return "已修改"
+	}
+	return "未变更"
+}
+
+// newSessionAgent 创建Agent并应用会话设置：用量追踪、事件展示、审计、记忆、团队与项目指令、MCP服务等
+// 调用方负责在结束时调用 CloseMCP
+func newSessionAgent() *agent.Agent {
+	a := agent.NewAgent(cfg, log)
+	a.SetConfigOverride(overridePrefs)
+
+	a.SetUsageTracker(tracker)
+	if transport := telemetryCollector.Transport(llmTransport); transport != nil {
+		a.SetTransport(transport)
+	}
+	a.SetTelemetry(telemetryCollector)
+	if dryRun {
+		a.SetDryRun(true)
+		ui.Println("🧪 演练模式：只展示计划的工具调用，不会实际执行（/dryrun off 关闭）")
+	}
+	if planMode {
+		a.SetPlanMode(true)
+	}
+	if a.PlanModeEnabled() {
+		ui.Println("📝 计划模式：write_code 和 edit_file 的修改需 /apply 确认后才写入（/plan off 关闭）")
+	}
+	if a.LocalMode() && cfg.API.UsesOllama() {
+		ui.Println("🏠 本地模型模式（Ollama原生接口）：已关闭图片识别并缩小上下文预算（配置项 api.local）")
+	} else if a.LocalMode() {
+		ui.Println("🏠 本地模型模式：使用文本工具调用，已关闭图片识别并缩小上下文预算（配置项 api.local）")
+	}
+
+	// 将LLM请求的限流、超时、重试状态展示给用户，避免看起来像卡住
+	bus := events.NewBus()
+	bus.Subscribe(renderEvent)
+	a.SetEventBus(bus)
+	if auditLog != nil {
+		a.SetAuditLogger(auditLog)
+	}
+	if cfg.Recall.Enabled {
+		if err := a.EnableLongTermMemory(userID); err != nil {
+			log.Error("开启长期记忆失败", err, nil)
+			ui.Printf("⚠️  开启长期记忆失败: %v\n", err)
+		}
+	}
+	if cfg.Logging.EventLog {
+		if eventLog == nil {
+			var err error
+			if eventLog, err = eventlog.Open(sessionID); err != nil {
+				log.Error("创建会话事件日志失败", err, nil)
+			}
+		}
+		a.SetEventLog(eventLog)
+	}
+
+	// 应用命令行指定的记忆
+	if memory != "" {
+		a.SetMemory(memory)
+	}
+
+	// 同步团队共享指令
+	syncTeamInstructions(a)
+
+	// 加载工作区各级目录的指令文件
+	if cwd, err := os.Getwd(); err == nil {
+		files, err := a.LoadProjectInstructions(cwd)
+		if err != nil {
+			log.Error("加载项目指令文件失败", err, nil)
+			ui.Printf("⚠️  加载项目指令文件失败: %v\n", err)
+		} else if len(files) > 0 {
+			ui.Printf("📄 已加载项目指令文件: %s\n", strings.Join(files, ", "))
+		}
+	}
+
+	// 完整的长命令输出保存到真实目录（影子工作区模式下也不会随工作区丢弃）
+	if outputDir, err := filepath.Abs("outputs"); err == nil {
+		a.SetOutputDir(outputDir)
+	}
+
+	// 平滑输出（response.smooth_output）
+	smoother.rate = cfg.Response.SmoothOutput
+
+	// 连接配置的MCP服务，注册其工具和资源
+	connectMCPServers(a)
+	return a
+}
+
+// connectMCPServers 连接配置的MCP服务并展示结果，连接失败只提示、不影响启动
+func connectMCPServers(a *agent.Agent) {
+	for _, status := range a.ConnectMCP(context.Background()) {
+		if status.Err != nil {
+			log.Error("连接MCP服务失败", status.Err, map[string]interface{}{"name": status.Name})
+			ui.Printf("⚠️  MCP服务 %s 不可用: %v\n", status.Name, status.Err)
+			continue
+		}
+		ui.Printf("🔌 已连接MCP服务 %s（%d个工具，%d个资源）\n", status.Name, status.Tools, status.Resources)
+	}
+}
+
+// newTeamSource 根据配置创建团队指令来源
+func newTeamSource() *team.Source {
+	return team.NewSource(cfg.Team.Source, cfg.Team.Ref, cfg.Team.Path, time.Duration(cfg.Team.Timeout)*time.Second)
+}
+
+// syncTeamInstructions 同步团队共享指令并应用到Agent，同步失败时使用本地缓存
+func syncTeamInstructions(a *agent.Agent) {
+	if cfg.Team.Source == "" {
+		return
+	}
+
+	source := newTeamSource()
+	instructions, err := source.Sync(context.Background())
+	if err != nil {
+		log.Error("同步团队共享指令失败", err, map[string]interface{}{"source": cfg.Team.Source})
+		cached, cacheErr := source.Cached()
+		if cacheErr != nil || cached == "" {
+			ui.Printf("⚠️  同步团队共享指令失败: %v\n", err)
+			return
+		}
+		ui.Printf("⚠️  同步团队共享指令失败，使用本地缓存: %v\n", err)
+		instructions = cached
+	} else {
+		ui.Printf("👥 已同步团队共享指令（%d 字）\n", len([]rune(instructions)))
+	}
+
+	a.SetTeamMemory(instructions)
+}
+
+// printPinnableMessage 显示带编号的消息摘要
+func printPinnableMessage(n int, msg history.Message) {
+	mark := " "
+	if msg.Pinned {
+		mark = ui.Mark("📌", "*")
+	}
+	fmt.Printf("  %s %d. [%s] %s\n", mark, n, msg.Role, preview(msg.Content, 60))
+}
+
+// offerCodeFiles 检测回答中标注了文件路径的代码块，预览diff后询问是否通过write_code写入，返回写入产生的产物
+func offerCodeFiles(ctx context.Context, a *agent.Agent, response string) []history.Artifact {
+	files := agent.ExtractCodeFiles(response)
+	if len(files) == 0 {
+		return nil
+	}
+
+	ui.Printf("\n📝 回答中包含 %d 个标注了路径的代码文件\n", len(files))
+	writeAll := false
+	for _, file := range files {
+		action := "新建"
+		if _, err := os.Stat(file.Path); err == nil {
+			action = "修改"
+		}
+		fmt.Printf("\n── %s %s ──\n", action, file.Path)
+		if diff := agent.DiffPreview(file.Path, file.Code); diff != "" {
+			fmt.Print(diff)
+		} else if action == "修改" {
+			fmt.Println("(内容与现有文件相同，跳过)")
+			continue
+		}
+
+		if !writeAll {
+			fmt.Print("写入该文件？(y=写入 / a=全部写入 / N=跳过 / q=跳过剩余): ")
+			answer, _ := replReader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+			case "a", "all":
+				writeAll = true
+			case "q", "quit":
+				return a.ConsumeArtifacts()
+			default:
+				continue
+			}
+		}
+
+		params := map[string]interface{}{"filepath": file.Path, "code": file.Code}
+		if file.Language != "" {
+			params["language"] = file.Language
+		}
+		if _, err := a.RunTool(ctx, "write_code", params); err != nil {
+			log.Error("写入回答中的代码失败", err, map[string]interface{}{"file": file.Path})
+			ui.Printf("❌ 写入 %s 失败: %v\n", file.Path, err)
+			continue
+		}
+		ui.Printf("✅ 已写入 %s\n", file.Path)
+		log.Info("写入回答中的代码", map[string]interface{}{"file": file.Path})
+	}
+	return a.ConsumeArtifacts()
+}
+
+// artifactPaths 产物的文件路径
+func artifactPaths(artifacts []history.Artifact) []string {
+	paths := make([]string, 0, len(artifacts))
+	for _, art := range artifacts {
+		paths = append(paths, art.Path)
+	}
+	return paths
+}
+
+// preview 压缩空白并截取文本开头的maxRunes个字符，用于单行展示
+func preview(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxRunes {
+		return string(runes[:maxRunes]) + "..."
+	}
+	return text
+}
+
+// printMemoryHistory 按从新到旧列出记忆的历史版本
+func printMemoryHistory(current string) {
+	versions, err := agent.LoadMemoryHistory(userID)
+	if err != nil {
+		ui.Printf("❌ %v\n", err)
+		return
+	}
+	if len(versions) == 0 {
+		ui.Println("📭 还没有记忆修改记录")
+		return
+	}
+
+	ui.Println("\n🕘 记忆历史版本（最新在前）:")
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		action := map[string]string{
+			agent.MemoryActionInitial: "原有记忆",
+			agent.MemoryActionSet:     "设置",
+			agent.MemoryActionClear:   "清除",
+			agent.MemoryActionRevert:  fmt.Sprintf("恢复自 v%d", v.RevertedFrom),
+		}[v.Action]
+		content := preview(v.Memory, 60)
+		if content == "" {
+			content = "(空)"
+		}
+		mark := ""
+		if i == len(versions)-1 && v.Memory == current {
+			mark = " ← 当前"
+		}
+		fmt.Printf("  v%-3d %s  %-8s %s%s\n", v.Version, v.CreatedAt.Format("2006-01-02 15:04"), action, content, mark)
+	}
+	ui.Println("💡 使用 /memory revert <版本号> 恢复")
+	fmt.Println()
+}
+
+// showDueReminders 显示已到期的提醒事项
+func showDueReminders() {
+	due, err := tools.NewReminderStore(tools.DefaultReminderFile).Due(time.Now())
+	if err != nil {
+		log.Error("读取提醒事项失败", err, nil)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	ui.Println("⏰ 到期提醒:")
+	for _, r := range due {
+		fmt.Printf("  - [%s] %s (到期: %s)\n", r.ID, r.Title, r.DueAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Println()
+}
+
+// confirmCommand 执行命令前展示确认原因和模型的解释并请用户确认，破坏性和高风险命令默认不执行
+func confirmCommand(command string, explanation *agent.CommandExplanation, reason string) bool {
+	smoother.Drain()
+	ui.Printf("\n🔎 即将执行: %s\n", command)
+	if reason != "" {
+		ui.Printf("  ⚠️  %s\n", reason)
+	}
+	if explanation != nil {
+		fmt.Print(explanation)
+	} else if cfg.Tools.ExecuteCommand.Explain {
+		fmt.Println("  （未能获取命令解释）")
+	}
+
+	defaultNo := reason != "" || explanation.HighRisk()
+	if defaultNo {
+		fmt.Print("是否执行？(y/N): ")
+	} else {
+		fmt.Print("是否执行？(Y/n): ")
+	}
+	answer, err := replReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	case "":
+		return !defaultNo
+	}
+	return false
+}
+
+// pickFile 意图分析猜测的文件不存在时，让用户从工作区中相近的文件里选择
+func pickFile(missing string, candidates []string) (string, bool) {
+	smoother.Drain()
+	ui.Printf("\n❓ 文件 %s 不存在，您是指:\n", missing)
+	for i, candidate := range candidates {
+		fmt.Printf("  %d. %s\n", i+1, candidate)
+	}
+	fmt.Print("请输入编号或路径 (回车选择 1，输入 0 跳过): ")
+
+	choice, err := replReader.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	choice = strings.TrimSpace(choice)
+
+	switch choice {
+	case "":
+		return candidates[0], true
+	case "0", "n", "no":
+		return "", false
+	}
+	if idx, err := strconv.Atoi(choice); err == nil {
+		if idx >= 1 && idx <= len(candidates) {
+			return candidates[idx-1], true
+		}
+		ui.Println("⚠️  编号超出范围，已跳过")
+		return "", false
+	}
+	if _, err := os.Stat(choice); err != nil {
+		ui.Printf("⚠️  文件不存在: %s，已跳过\n", choice)
+		return "", false
+	}
+	return choice, true
+}
+
+// handleCommand 处理特殊命令
+func handleCommand(input string, model *string, conv *history.Conversation, historyMgr *history.Manager, a *agent.Agent, log *logger.Logger) bool {
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		return false
+	}
+
+	cmd := parts[0]
+
+	switch cmd {
+	case "/new":
+		// 保存当前对话
+		if conv.HasAssistantMessages() {
+			if err := historyMgr.SaveConversation(conv); err != nil {
+				log.Error("保存对话失败", err, nil)
+				ui.Printf("⚠️  保存对话失败: %v\n", err)
+			} else {
+				ui.Printf("✅ 对话已保存 (ID: %s)\n", conv.ID)
+			}
+		}
+		// 创建新对话
+		*conv = *history.NewConversation(conv.UserID, *model)
+		fmt.Println("🆕 开始新对话")
+		log.Info("开始新对话", map[string]interface{}{"conversation_id": conv.ID})
+		return true
+
+	case "/model":
+		availableModels := make([]string, 0, len(llm.Catalog))
+		for _, m := range llm.Catalog {
+			availableModels = append(availableModels, m.Name)
+		}
+
+		ui.Println("\n📦 可用模型列表:")
+		for i, m := range availableModels {
+			marker := " "
+			if m == *model {
+				marker = ui.Mark("✓", "*")
+			}
+			note := ""
+			if !llm.SupportsFunctionCalling(m) {
+				note = " (不支持函数调用，使用文本工具调用)"
+			}
+			fmt.Printf("  [%s] %d. %s%s\n", marker, i+1, m, note)
+		}
+		fmt.Printf("\n当前模型: %s\n", *model)
+		fmt.Print("请输入模型编号或名称 (回车保持当前): ")
+
+		choice, _ := replReader.ReadString('\n')
+		choice = strings.TrimSpace(choice)
+
+		if choice == "" {
+			fmt.Println("保持当前模型")
+			return true
+		}
+
+		var selectedModel string
+
+		// 1) 先尝试按“编号”解析（支持 >9）
+		if idx, err := strconv.Atoi(choice); err == nil {
+			idx-- // 变成 0-based
+			if idx >= 0 && idx < len(availableModels) {
+				selectedModel = availableModels[idx]
+			} else {
+				ui.Printf("❌ 无效编号: %d (范围: 1-%d)\n", idx+1, len(availableModels))
+				return true
+			}
+		} else {
+			// 2) 再按“名称”匹配（可选：也可以做不区分大小写）
+			selectedModel = choice
+		}
+
+		// 可选：验证名称是否在列表中，避免输入不存在的模型
+		found := false
+		for _, m := range availableModels {
+			if m == selectedModel {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ui.Printf("❌ 未知模型名称: %s\n", selectedModel)
+			return true
+		}
+		if err := cfg.Policy.CheckModel(selectedModel); err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+
+		*model = selectedModel
+		conv.Model = selectedModel
+		cfg.API.Model = selectedModel
+		a.UpdateModel(selectedModel)
+		ui.Printf("✅ 已切换到模型: %s\n", selectedModel)
+		log.Info("切换模型", map[string]interface{}{"model": selectedModel})
+		if userPrefs == nil || userPrefs.Model != selectedModel {
+			ui.Printf("💡 本次切换只在当前会话有效，使用 agentcli prefs set model %s 设为以后启动时的默认模型\n", selectedModel)
+		}
+		return true
+
+	case "/history":
+		if err := showHistory(historyMgr, conv.UserID, parts[1:]); err != nil {
+			log.Error("获取历史记录失败", err, nil)
+			ui.Printf("❌ 获取历史记录失败: %v\n", err)
+			fmt.Println(historyUsage)
+		}
+		return true
+
+	case "/load":
+		if len(parts) < 2 {
+			fmt.Println("用法: /load <对话ID>")
+			return true
+		}
+		convID := parts[1]
+		loadedConv, err := historyMgr.LoadConversation(convID)
+		if err != nil {
+			// 归档的对话不在历史列表中，加载后再保存会重新出现在历史列表中
+			archived, archiveErr := historyMgr.LoadArchivedConversation(convID)
+			if archiveErr != nil {
+				log.Error("加载对话失败", err, map[string]interface{}{"conversation_id": convID})
+				ui.Printf("❌ 加载对话失败: %v\n", err)
+				return true
+			}
+			loadedConv = archived
+			ui.Printf("📦 已从归档中加载对话 %s，保存后会重新出现在历史列表中\n", convID)
+		}
+
+		// 保存当前对话
+		if conv.HasAssistantMessages() {
+			historyMgr.SaveConversation(conv)
+		}
+
+		*conv = *loadedConv
+		enforceModelPolicy(conv)
+		*model = conv.Model
+		cfg.API.Model = conv.Model
+		a.UpdateModel(conv.Model)
+
+		ui.Printf("✅ 已加载对话 (ID: %s, 消息数: %d)\n", conv.ID, len(conv.Messages))
+		if pending, _, _, ok := conv.UnfinishedTurn(); ok {
+			ui.Printf("💡 该对话有未完成的任务（%s），输入 '/resume' 从已执行的工具调用处继续\n", preview(pending, 40))
+		}
+		log.Info("加载历史对话", map[string]interface{}{
+			"conversation_id": conv.ID,
+			"message_count":   len(conv.Messages),
+		})
+
+		// 显示最近几条消息
+		recent := conv.GetRecentMessages(6)
+		if len(recent) > 0 {
+			ui.Println("\n📝 最近的对话记录:")
+			for _, msg := range recent {
+				role := ui.Mark("👤", "user")
+				content := msg.Content
+				switch {
+				case len(msg.ToolCalls) > 0:
+					role = ui.Mark("⚙️", "tool call")
+					var names []string
+					for _, call := range msg.ToolCalls {
+						names = append(names, call.Function.Name)
+					}
+					content = strings.Join(names, ", ")
+				case msg.Role == "tool":
+					role = ui.Mark("✅", "tool result")
+				case msg.Role == "assistant":
+					role = ui.Mark("🤖", "assistant")
+				}
+				if len(content) > 100 {
+					content = content[:100] + "..."
+				}
+				fmt.Printf("  %s: %s\n", role, content)
+			}
+			fmt.Println()
+		}
+		return true
+
+	case "/recall":
+		recall := a.LongTermMemory()
+		if recall == nil {
+			ui.Println("🧠 长期记忆未开启（配置项 recall.enabled）")
+			return true
+		}
+		if len(parts) < 2 {
+			ui.Printf("🧠 长期记忆: %d 条\n", recall.Store().Len())
+			fmt.Println("用法: /recall <查询>  (查看与查询最相关的记忆)")
+			fmt.Println("用法: /recall clear  (删除所有长期记忆)")
+			return true
+		}
+		if strings.EqualFold(parts[1], "clear") {
+			if err := recall.Store().Clear(); err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			ui.Println("✅ 已删除所有长期记忆")
+			log.Info("删除长期记忆", nil)
+			return true
+		}
+		results, err := recall.Search(context.Background(), strings.Join(parts[1:], " "))
+		if err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+		if len(results) == 0 {
+			ui.Println("🧠 没有相关的记忆")
+			return true
+		}
+		for i, result := range results {
+			fmt.Printf("%d. [%.2f] %s %s\n   %s\n", i+1, result.Score, result.Created.Format("2006-01-02 15:04"), result.Kind, preview(result.Text, 160))
+		}
+		return true
+
+	case "/memory":
+		if len(parts) < 2 {
+			if memory == "" {
+				ui.Println("📝 当前没有设置定制化记忆")
+			} else {
+				ui.Printf("📝 当前定制化记忆: %s\n", memory)
+			}
+			fmt.Println("用法: /memory <定制化文本>")
+			fmt.Println("用法: /memory clear  (删除定制化记忆)")
+			fmt.Println("用法: /memory history  (查看记忆的历史版本)")
+			fmt.Println("用法: /memory revert <版本号>  (恢复到指定版本)")
+			fmt.Println("例如: /memory 你是一个专业的Go语言开发专家，擅长性能优化")
+			return true
+		}
+
+		if strings.EqualFold(parts[1], "clear") || strings.EqualFold(parts[1], "delete") {
+			memory = ""
+			a.SetMemory("")
+			if err := agent.DeleteMemoryFromFile(userID); err != nil {
+				log.Error("删除记忆失败", err, nil)
+				ui.Printf("⚠️  删除记忆失败: %v\n", err)
+			} else {
+				ui.Println("✅ 已删除定制化记忆")
+				log.Info("删除定制化记忆", nil)
+			}
+			return true
+		}
+
+		if strings.EqualFold(parts[1], "history") {
+			printMemoryHistory(memory)
+			return true
+		}
+
+		if strings.EqualFold(parts[1], "revert") {
+			if len(parts) < 3 {
+				fmt.Println("用法: /memory revert <版本号>（使用 /memory history 查看版本）")
+				return true
+			}
+			version, err := strconv.Atoi(strings.TrimPrefix(parts[2], "v"))
+			if err != nil {
+				ui.Printf("❌ 无效的版本号: %s\n", parts[2])
+				return true
+			}
+			reverted, err := agent.RevertMemory(userID, version)
+			if err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			memory = reverted
+			a.SetMemory(memory)
+			log.Info("回退定制化记忆", map[string]interface{}{"version": version})
+			if memory == "" {
+				ui.Printf("✅ 已恢复到版本 v%d（无定制化记忆）\n", version)
+			} else {
+				ui.Printf("✅ 已恢复到版本 v%d: %s\n", version, memory)
+			}
+			return true
+		}
+
+		memory = strings.Join(parts[1:], " ")
 		a.SetMemory(memory)
 
 		// 保存memory到文件
 		if err := agent.SaveMemoryToFile(userID, memory); err != nil {
 			log.Error("保存记忆失败", err, nil)
-			fmt.Printf("⚠️  保存记忆失败: %v\n", err)
+			ui.Printf("⚠️  保存记忆失败: %v\n", err)
 		} else {
-			fmt.Printf("✅ 已设置并保存定制化记忆: %s\n", memory)
+			ui.Printf("✅ 已设置并保存定制化记忆: %s\n", memory)
 			log.Info("设置定制化记忆", map[string]interface{}{"memory": memory})
 		}
 		return true
 
+	case "/pin", "/unpin":
+		pin := parts[0] == "/pin"
+		if len(parts) < 2 {
+			// 列出最近的消息，方便选择编号
+			fmt.Printf("用法: %s <消息编号>\n", parts[0])
+			start := len(conv.Messages) - 10
+			if start < 0 {
+				start = 0
+			}
+			for i := start; i < len(conv.Messages); i++ {
+				printPinnableMessage(i+1, conv.Messages[i])
+			}
+			return true
+		}
+
+		n, err := strconv.Atoi(parts[1])
+		if err == nil {
+			err = conv.SetPinned(n, pin)
+		}
+		if err != nil {
+			ui.Printf("❌ 无效的消息编号: %s\n", parts[1])
+			return true
+		}
+		if pin {
+			ui.Printf("📌 已固定第 %d 条消息，它将始终完整保留在上下文中\n", n)
+		} else {
+			ui.Printf("✅ 已取消固定第 %d 条消息\n", n)
+		}
+		log.Info("设置固定消息", map[string]interface{}{"message": n, "pinned": pin})
+		return true
+
+	case "/snippet", "/snippets":
+		if len(parts) == 1 || parts[1] == "list" {
+			list, err := snippetStore.List()
+			if err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			if len(list) == 0 {
+				ui.Println("📭 还没有保存的片段，使用 /snippet save <name> 保存")
+				return true
+			}
+			ui.Println("\n📎 已保存的片段:")
+			for _, snippet := range list {
+				fmt.Printf("  • %s (%d 字符, %s): %s\n", snippet.Name, len([]rune(snippet.Content)),
+					snippet.UpdatedAt.Format("2006-01-02 15:04"), preview(snippet.Content, 60))
+			}
+			return true
+		}
+
+		if len(parts) < 3 {
+			ui.Println("❌ 用法: /snippet save|show|insert|delete <name>")
+			return true
+		}
+		name := parts[2]
+		switch parts[1] {
+		case "save":
+			content := strings.TrimSpace(strings.TrimPrefix(input, cmd))
+			content = strings.TrimSpace(strings.TrimPrefix(content, parts[1]))
+			content = strings.TrimSpace(strings.TrimPrefix(content, name))
+			if content == "" {
+				// 未附带内容时读取多行输入
+				fmt.Println("请输入片段内容，单独一行 . 结束:")
+				var lines []string
+				for {
+					line, err := replReader.ReadString('\n')
+					if strings.TrimRight(line, "\r\n") == "." {
+						break
+					}
+					lines = append(lines, strings.TrimRight(line, "\r\n"))
+					if err != nil {
+						break
+					}
+				}
+				content = strings.TrimSpace(strings.Join(lines, "\n"))
+			}
+			if err := snippetStore.Save(name, content); err != nil {
+				ui.Printf("❌ 保存片段失败: %v\n", err)
+				return true
+			}
+			ui.Printf("✅ 已保存片段 %s (%d 字符)，使用 /snippet insert %s 插入消息\n", name, len([]rune(content)), name)
+			log.Info("保存片段", map[string]interface{}{"name": name})
+		case "show":
+			snippet, err := snippetStore.Get(name)
+			if err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			ui.Printf("\n📎 %s:\n%s\n", snippet.Name, snippet.Content)
+		case "delete", "rm":
+			if err := snippetStore.Delete(name); err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			ui.Printf("🗑️  已删除片段 %s\n", name)
+			log.Info("删除片段", map[string]interface{}{"name": name})
+		default:
+			ui.Println("❌ 用法: /snippet save|show|insert|delete <name>")
+		}
+		return true
+
+	case "/run-tool":
+		rest := strings.TrimSpace(strings.TrimPrefix(input, cmd))
+		if rest == "" {
+			ui.Println("❌ 用法: /run-tool <工具名> [JSON参数]")
+			fmt.Printf("   可用工具: %s\n", strings.Join(a.ToolNames(), ", "))
+			return true
+		}
+		name, args, _ := strings.Cut(rest, " ")
+		args = strings.TrimSpace(args)
+		if args == "" {
+			args = "{}"
+		}
+
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(args), &params); err != nil {
+			ui.Printf("❌ 参数不是有效的JSON对象: %v\n", err)
+			return true
+		}
+
+		ui.Printf("⚙️  执行工具: %s\n", name)
+		result, err := a.RunTool(context.Background(), name, params)
+		if err != nil {
+			log.Error("手动执行工具失败", err, map[string]interface{}{"tool": name})
+			ui.Printf("❌ 执行失败: %v\n", err)
+			return true
+		}
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Printf("%s\n", output)
+		log.Info("手动执行工具", map[string]interface{}{"tool": name, "params": args})
+
+		fmt.Print("是否将结果作为工具消息加入对话？(y/N): ")
+		answer, _ := replReader.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "y" || answer == "yes" {
+			conv.AddToolResult(name, args, string(output))
+			ui.Println("✅ 已加入对话，模型在下一轮回答时可以使用该结果")
+		}
+		return true
+
+	case "/capabilities", "/caps":
+		fmt.Println()
+		fmt.Print(a.DescribeCapabilities(useSandbox))
+		fmt.Println()
+		return true
+
+	case "/why":
+		handleWhyCommand(a, parts[1:])
+		return true
+
+	case "/context":
+		fmt.Println()
+		fmt.Print(a.ContextUsage(conv.ToLLMMessages()))
+		fmt.Println()
+		return true
+
+	case "/pins":
+		pinned := conv.PinnedMessages()
+		if len(pinned) == 0 {
+			ui.Println("📭 当前对话没有固定的消息，使用 /pin <编号> 固定")
+			return true
+		}
+		ui.Println("\n📌 固定的消息:")
+		for _, n := range pinned {
+			printPinnableMessage(n, conv.Messages[n-1])
+		}
+		fmt.Println()
+		return true
+
+	case "/set":
+		if len(parts) < 2 {
+			if len(conv.Variables) == 0 {
+				ui.Println("📭 当前对话没有变量")
+			} else {
+				ui.Println("\n🔖 对话变量:")
+				for _, name := range conv.VariableNames() {
+					fmt.Printf("  %s = %s\n", name, conv.Variables[name])
+				}
+				fmt.Println()
+			}
+			fmt.Println("用法: /set name=value  (在后续输入和工具参数中使用 {{name}} 引用)")
+			return true
+		}
+
+		assignment := strings.TrimSpace(strings.TrimPrefix(input, "/set"))
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			fmt.Println("用法: /set name=value")
+			return true
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if err := conv.SetVariable(name, value); err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+		ui.Printf("✅ 已设置变量 {{%s}} = %s\n", name, value)
+		log.Info("设置对话变量", map[string]interface{}{"name": name, "value": value})
+		return true
+
+	case "/cd":
+		if len(parts) < 2 {
+			current := conv.Workdir
+			if current == "" {
+				current = "（工作区根目录）"
+			}
+			ui.Printf("📂 工具默认执行目录: %s\n", current)
+			fmt.Println("用法: /cd <目录>  (相对当前执行目录，'/cd /' 回到工作区根目录)")
+			return true
+		}
+
+		target := strings.TrimSpace(strings.TrimPrefix(input, "/cd"))
+		if target == "/" || target == `\` {
+			target = "."
+		} else if !filepath.IsAbs(target) {
+			target = filepath.Join(conv.Workdir, target)
+		}
+		dir, err := tools.ResolveWorkdir(target)
+		if err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+		if dir == "." {
+			dir = ""
+		}
+		conv.Workdir = dir
+		a.SetWorkdir(dir)
+		if dir == "" {
+			ui.Println("✅ 工具默认执行目录已恢复为工作区根目录")
+		} else {
+			ui.Printf("✅ 工具默认执行目录: %s\n", dir)
+		}
+		log.Info("设置默认执行目录", map[string]interface{}{"workdir": dir})
+		return true
+
+	case "/env":
+		// 对话级环境变量只保存在内存中，注入到 execute_command 的子进程，密钥类变量在展示和日志中打码
+		if len(parts) < 2 {
+			if len(conv.Env) == 0 {
+				ui.Println("📭 当前对话没有设置环境变量")
+			} else {
+				names := make([]string, 0, len(conv.Env))
+				for name := range conv.Env {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				ui.Println("\n🌱 对话环境变量:")
+				for _, name := range names {
+					fmt.Printf("  %s=%s\n", name, tools.MaskEnvValue(name, conv.Env[name]))
+				}
+				fmt.Println()
+			}
+			fmt.Println("用法: /env set KEY=VALUE | /env unset KEY  (仅对本次对话的命令执行生效，不会保存到历史记录)")
+			return true
+		}
+
+		switch parts[1] {
+		case "set":
+			assignment := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(input, "/env")), "set"))
+			name, value, ok := strings.Cut(assignment, "=")
+			name = strings.TrimSpace(name)
+			if !ok || !tools.ValidEnvName(name) {
+				ui.Println("❌ 用法: /env set KEY=VALUE（变量名只能包含字母、数字和下划线，且不能以数字开头）")
+				return true
+			}
+			if conv.Env == nil {
+				conv.Env = make(map[string]string)
+			}
+			conv.Env[name] = value
+			a.SetEnv(conv.Env)
+			ui.Printf("✅ 已设置环境变量 %s=%s\n", name, tools.MaskEnvValue(name, value))
+			log.Info("设置对话环境变量", map[string]interface{}{"name": name, "value": tools.MaskEnvValue(name, value)})
+		case "unset":
+			if len(parts) < 3 {
+				fmt.Println("用法: /env unset KEY")
+				return true
+			}
+			if _, ok := conv.Env[parts[2]]; !ok {
+				ui.Printf("❌ 环境变量不存在: %s\n", parts[2])
+				return true
+			}
+			delete(conv.Env, parts[2])
+			a.SetEnv(conv.Env)
+			ui.Printf("✅ 已删除环境变量 %s\n", parts[2])
+			log.Info("删除对话环境变量", map[string]interface{}{"name": parts[2]})
+		default:
+			fmt.Println("用法: /env set KEY=VALUE | /env unset KEY")
+		}
+		return true
+
+	case "/unset":
+		if len(parts) < 2 {
+			fmt.Println("用法: /unset name")
+			return true
+		}
+		if conv.UnsetVariable(parts[1]) {
+			ui.Printf("✅ 已删除变量 {{%s}}\n", parts[1])
+			log.Info("删除对话变量", map[string]interface{}{"name": parts[1]})
+		} else {
+			ui.Printf("❌ 变量不存在: %s\n", parts[1])
+		}
+		return true
+
+	case "/verbosity":
+		if len(parts) < 2 {
+			ui.Printf("📏 当前回答详细程度: %s\n", a.Verbosity())
+			fmt.Println("用法: /verbosity concise|normal|detailed")
+			return true
+		}
+		if err := a.SetVerbosity(parts[1]); err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+		ui.Printf("✅ 回答详细程度已设置为: %s\n", a.Verbosity())
+		return true
+
+	case "/consensus":
+		if len(parts) < 2 {
+			status := "关闭"
+			if a.ConsensusEnabled() {
+				status = "开启"
+			}
+			ui.Printf("⚖️ 双模型共识模式: %s\n", status)
+			fmt.Println("用法: /consensus on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			if err := a.SetConsensus(true); err != nil {
+				ui.Printf("❌ %v\n", err)
+				return true
+			}
+			ui.Println("✅ 已开启双模型共识模式（只生成方案，不执行工具）")
+		case "off":
+			a.SetConsensus(false)
+			ui.Println("✅ 已关闭双模型共识模式")
+		default:
+			fmt.Println("用法: /consensus on|off")
+		}
+		return true
+
+	case "/sandbox":
+		if len(parts) < 2 {
+			status := "关闭"
+			if useSandbox {
+				status = "开启"
+			}
+			ui.Printf("🧪 影子工作区模式: %s\n", status)
+			fmt.Println("用法: /sandbox on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			useSandbox = true
+			ui.Println("✅ 已开启影子工作区模式（每轮的文件修改需确认后才会应用）")
+		case "off":
+			useSandbox = false
+			ui.Println("✅ 已关闭影子工作区模式")
+		default:
+			fmt.Println("用法: /sandbox on|off")
+		}
+		log.Info("设置影子工作区模式", map[string]interface{}{"enabled": useSandbox})
+		return true
+
+	case "/usage":
+		printUsage()
+		return true
+
+	case "/team":
+		if cfg.Team.Source == "" {
+			ui.Println("📭 未配置团队共享指令来源（配置项 team.source）")
+			return true
+		}
+		if len(parts) >= 2 && strings.EqualFold(parts[1], "sync") {
+			syncTeamInstructions(a)
+			return true
+		}
+		cached, err := newTeamSource().Cached()
+		if err != nil {
+			ui.Printf("❌ %v\n", err)
+			return true
+		}
+		if cached == "" {
+			ui.Println("📭 尚未同步到团队共享指令，使用 /team sync 重试")
+			return true
+		}
+		ui.Printf("\n👥 团队共享指令（来源: %s）:\n%s\n\n", cfg.Team.Source, cached)
+		return true
+
+	case "/artifacts":
+		if len(conv.Artifacts) == 0 {
+			ui.Println("📭 本次对话还没有生成文件")
+			return true
+		}
+		ui.Printf("\n📦 本次对话生成的文件 (%d):\n", len(conv.Artifacts))
+		for i, art := range conv.Artifacts {
+			fmt.Printf("  %d. %s | %d 字节 | sha256:%s | 工具: %s | %s | %s\n",
+				i+1, art.Path, art.Size, art.SHA256[:12], art.Tool, art.CreatedAt.Format("2006-01-02 15:04"), artifactStatus(art))
+		}
+		fmt.Println()
+		return true
+
+	case "/dryrun":
+		if len(parts) < 2 {
+			status := "关闭"
+			if a.DryRunEnabled() {
+				status = "开启"
+			}
+			ui.Printf("🧪 演练模式: %s\n", status)
+			fmt.Println("用法: /dryrun on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			a.SetDryRun(true)
+			ui.Println("✅ 已开启演练模式，工具调用只展示不执行")
+		case "off":
+			a.SetDryRun(false)
+			ui.Println("✅ 已关闭演练模式")
+		default:
+			fmt.Println("用法: /dryrun on|off")
+		}
+		return true
+
+	case "/plan":
+		if len(parts) < 2 {
+			status := "关闭"
+			if a.PlanModeEnabled() {
+				status = "开启"
+			}
+			ui.Printf("📝 计划模式: %s（%d 个文件有待确认的修改）\n", status, len(a.Changes().Changes()))
+			fmt.Println("用法: /plan on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			a.SetPlanMode(true)
+			ui.Println("✅ 已开启计划模式，write_code 和 edit_file 的修改需 /apply 确认后才写入")
+		case "off":
+			a.SetPlanMode(false)
+			ui.Println("✅ 已关闭计划模式")
+			if pending := len(a.Changes().Changes()); pending > 0 {
+				ui.Printf("💡 仍有 %d 个文件的修改待确认，可使用 /apply 或 /discard\n", pending)
+			}
+		default:
+			fmt.Println("用法: /plan on|off")
+		}
+		return true
+
+	case "/changes":
+		showChanges(a)
+		return true
+
+	case "/config":
+		handleConfigCommand(a, parts[1:])
+		return true
+
+	case "/apply":
+		applyChanges(a, parts[1:])
+		return true
+
+	case "/discard":
+		discardChanges(a, parts[1:])
+		return true
+
+	case "/extract":
+		if len(parts) < 2 {
+			status := "关闭"
+			if cfg.Response.ExtractCodeFiles {
+				status = "开启"
+			}
+			ui.Printf("📝 从回答中提取代码文件: %s\n", status)
+			fmt.Println("用法: /extract on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			cfg.Response.ExtractCodeFiles = true
+			ui.Println("✅ 回答中标注了文件路径的代码块将询问是否写入文件")
+		case "off":
+			cfg.Response.ExtractCodeFiles = false
+			ui.Println("✅ 已关闭从回答中提取代码文件")
+		default:
+			fmt.Println("用法: /extract on|off")
+		}
+		return true
+
+	case "/docs":
+		if len(parts) < 2 {
+			status := "关闭"
+			if a.DocLookupEnabled() {
+				status = "开启"
+			}
+			ui.Printf("📚 库文档自动检索: %s\n", status)
+			fmt.Println("用法: /docs on|off")
+			return true
+		}
+
+		switch strings.ToLower(parts[1]) {
+		case "on":
+			a.SetDocLookup(true)
+			ui.Println("✅ 已开启库文档自动检索")
+		case "off":
+			a.SetDocLookup(false)
+			ui.Println("✅ 已关闭库文档自动检索")
+		default:
+			fmt.Println("用法: /docs on|off")
+		}
+		return true
+
 	default:
 		return false
 	}
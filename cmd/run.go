@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"agentcli/internal/history"
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// maxPipedInput 从标准输入读取的内容上限
+const maxPipedInput = 1 << 20
+
+// answerOut run命令输出最终回答的位置（执行期间 os.Stdout 被重定向到标准错误）
+var answerOut = os.Stdout
+
+// runCmd 非交互地执行单个请求
+var runCmd = &cobra.Command{
+	Use:   "run [prompt]",
+	Short: "执行单个请求并输出回答（用于脚本和CI）",
+	Long: `非交互地执行一个请求：最终回答输出到标准输出，执行过程、工具输出和诊断信息输出到标准错误，
+请求失败时以非零状态码退出，便于在shell管道和CI任务中组合使用。
+
+未给出prompt时从标准输入读取请求；同时给出prompt和管道输入时，管道内容作为附加材料跟在prompt之后。
+非交互模式下无法询问确认，需要确认的命令（见 tools.execute_command.require_confirmation）不会执行，可加 --yes 跳过确认。
+
+示例:
+  agentcli run "列出当前目录下最大的5个文件"
+  echo "总结这个仓库的目录结构" | agentcli run
+  go test ./... 2>&1 | agentcli run "解释失败原因" > report.md`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true, // 错误由main输出到标准错误
+	// 执行期间的所有提示输出到标准错误，标准输出只保留最终回答
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		answerOut = os.Stdout
+		os.Stdout = os.Stderr
+		return rootCmd.PersistentPreRunE(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initTelemetry()
+		prompt, err := runPrompt(args)
+		if err != nil {
+			return err
+		}
+		return runOnce(prompt)
+	},
+}
+
+// runPrompt 组合命令行参数和标准输入中的请求
+func runPrompt(args []string) (string, error) {
+	prompt := ""
+	if len(args) > 0 {
+		prompt = strings.TrimSpace(args[0])
+	}
+
+	if !isTerminal(os.Stdin) {
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, maxPipedInput))
+		if err != nil {
+			return "", fmt.Errorf("读取标准输入失败: %w", err)
+		}
+		if piped := strings.TrimSpace(string(data)); piped != "" {
+			if prompt == "" {
+				prompt = piped
+			} else {
+				prompt += "\n\n" + piped
+			}
+		}
+	}
+
+	if prompt == "" {
+		return "", fmt.Errorf("缺少请求内容：请以参数给出prompt或通过标准输入传入")
+	}
+	return prompt, nil
+}
+
+// runOnce 执行一轮请求，输出回答并保存对话
+func runOnce(prompt string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	model := cfg.API.Model
+	a := newSessionAgent()
+	defer a.CloseMCP()
+	if chatModel != "" {
+		model = chatModel
+		a.UpdateModel(model)
+	}
+	if err := a.SetAutoApprove(assumeYes); err != nil {
+		ui.Printf("⚠️  %v\n", err)
+	}
+	if err := precheckModel(ctx, a, model); err != nil {
+		return err
+	}
+
+	// 标准输出被重定向（管道、文件）时，执行过程实时输出到标准错误；标准输出是终端时只在结束时输出回答，避免重复显示
+	streamProgress := !isTerminal(answerOut)
+	onChunk := func(chunk string) error {
+		if streamProgress {
+			fmt.Fprint(os.Stderr, chunk)
+		}
+		return nil
+	}
+
+	log.UserInput(prompt)
+	result, err := a.RunTask(ctx, prompt, nil, onChunk)
+	if streamProgress {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		log.Error("处理请求失败", err, nil)
+		return fmt.Errorf("处理请求失败: %w", err)
+	}
+	log.AgentOutput(result.Answer)
+
+	fmt.Fprintln(answerOut, strings.TrimSpace(result.Answer))
+
+	// 非交互模式无法确认，计划模式下的修改只输出diff，不写入文件
+	if changes := a.Changes().Changes(); len(changes) > 0 {
+		ui.Fprintf(os.Stderr, "\n📝 计划模式：以下 %d 个文件的修改未写入\n", len(changes))
+		for _, c := range changes {
+			fmt.Fprint(os.Stderr, c.Diff())
+		}
+	}
+
+	conv := history.NewConversation(userID, model)
+	conv.AddMessage("user", prompt)
+	conv.AddTurnMessages(a.ConsumeTurnMessages())
+	conv.AddMessage("assistant", result.Answer)
+	conv.AddArtifacts(result.Artifacts)
+	saveConversationOnExit(conv)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownGrace 退出时等待正在执行的工具停止的默认时长
+const defaultShutdownGrace = 5 * time.Second
+
+// shutdown 交互模式的退出协调：收到 Ctrl+C 或 SIGTERM 时取消正在执行的轮次，
+// 在宽限期内等待工具停止，然后自动保存对话、刷新日志后退出
+type shutdown struct {
+	ctx     context.Context // 每轮请求使用的上下文，收到退出信号时取消
+	cancel  context.CancelFunc
+	grace   time.Duration
+	signals chan os.Signal
+	save    func() // 自动保存当前对话
+	saved   sync.Once
+
+	mu        sync.Mutex
+	busy      bool // 是否正在处理一轮输入
+	requested bool // 是否已收到退出信号
+}
+
+// newShutdown 创建退出协调器并开始监听退出信号
+func newShutdown(save func()) *shutdown {
+	ctx, cancel := context.WithCancel(context.Background())
+	grace := time.Duration(cfg.Tools.ShutdownGrace) * time.Second
+	if grace <= 0 {
+		grace = defaultShutdownGrace
+	}
+	s := &shutdown{ctx: ctx, cancel: cancel, grace: grace, signals: make(chan os.Signal, 2), save: save}
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	go s.watch()
+	return s
+}
+
+// watch 等待退出信号：空闲时直接退出；正在执行时取消本轮，由主循环在工具停止后保存并退出，
+// 超过宽限期或再次收到信号时强制退出
+func (s *shutdown) watch() {
+	sig := <-s.signals
+	s.mu.Lock()
+	s.requested = true
+	busy := s.busy
+	s.mu.Unlock()
+	s.cancel()
+	log.Info("收到退出信号", map[string]interface{}{"signal": sig.String(), "busy": busy})
+
+	if busy {
+		ui.Printf("\n\n⏹  收到退出信号，正在停止当前任务（最多等待 %s，再次按 Ctrl+C 立即退出）...\n", s.grace)
+		select {
+		case <-time.After(s.grace):
+			ui.Println("⚠️  当前任务未在宽限期内停止，强制退出")
+		case <-s.signals:
+			ui.Println("⚠️  强制退出")
+		}
+	} else {
+		fmt.Println()
+	}
+
+	// 持有锁直到进程结束，主循环无法再开始新的一轮
+	s.mu.Lock()
+	s.quit()
+	closeSession()
+	os.Exit(130)
+}
+
+// quit 保存对话（只保存一次）并道别
+func (s *shutdown) quit() {
+	s.saved.Do(func() {
+		s.save()
+		ui.Println("\n👋 再见!")
+	})
+}
+
+// begin 开始处理一轮输入
+func (s *shutdown) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = true
+}
+
+// end 本轮处理结束，返回是否已收到退出信号
+func (s *shutdown) end() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy = false
+	return s.requested
+}
+
+// stopping 是否已收到退出信号
+func (s *shutdown) stopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requested
+}
+
+// stop 停止监听退出信号
+func (s *shutdown) stop() {
+	signal.Stop(s.signals)
+	s.cancel()
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"agentcli/internal/history"
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// replPrompt 交互模式每次读取输入前输出的提示符，用于按轮次切分输出
+const replPrompt = "👤 你: "
+
+// simulateScript 模拟对话脚本
+type simulateScript struct {
+	Cassette string         `yaml:"cassette"` // 录制/回放文件，相对于脚本所在目录；为空时直接访问真实API
+	Turns    []simulateTurn `yaml:"turns"`
+}
+
+// simulateTurn 一轮模拟输入及对输出的期望
+type simulateTurn struct {
+	Input   string   `yaml:"input"`
+	Answers []string `yaml:"answers"` // 本轮中交互式提问的回答（如 /model 的模型编号、影子工作区的确认）
+	Expect  struct {
+		Contains    []string `yaml:"contains"`
+		NotContains []string `yaml:"not_contains"`
+		Matches     []string `yaml:"matches"` // 正则表达式
+	} `yaml:"expect"`
+}
+
+var (
+	simulateRecord bool
+	simulateShow   bool
+)
+
+// simulateCmd 使用脚本驱动交互模式，进行端到端回归测试
+var simulateCmd = &cobra.Command{
+	Use:   "simulate <script.yaml>",
+	Short: "按脚本模拟用户输入，检查每轮输出并生成测试报告",
+	Long: `按脚本依次向交互模式输入消息和命令，检查每轮输出是否符合期望，生成通过/失败报告。
+脚本中指定 cassette 时默认回放录制的LLM响应（不访问网络），使用 --record 重新录制。
+
+脚本示例:
+  cassette: session.jsonl
+  turns:
+    - input: "/set project=demo"
+      expect:
+        contains: ["project"]
+    - input: "读取 main.go 并总结"
+      expect:
+        contains: ["main"]
+        not_contains: ["❌"]`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("读取脚本失败: %w", err)
+		}
+		var script simulateScript
+		if err := yaml.Unmarshal(data, &script); err != nil {
+			return fmt.Errorf("解析脚本失败: %w", err)
+		}
+		if len(script.Turns) == 0 {
+			return fmt.Errorf("脚本中没有任何轮次")
+		}
+
+		// 录制/回放LLM请求
+		var cassette *llm.Cassette
+		if script.Cassette != "" {
+			path := script.Cassette
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(args[0]), path)
+			}
+			mode := llm.CassetteReplay
+			if simulateRecord {
+				mode = llm.CassetteRecord
+			}
+			if cassette, err = llm.NewCassette(path, mode); err != nil {
+				return err
+			}
+			llmTransport = cassette
+			ui.Printf("📼 %s: %s\n", mode, path)
+		}
+
+		// 模拟的对话保存到临时目录，不影响真实历史记录
+		tmpDir, err := os.MkdirTemp("", "agentcli-simulate-")
+		if err != nil {
+			return fmt.Errorf("创建临时目录失败: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		historyMgr = history.NewManager(tmpDir)
+
+		var input strings.Builder
+		for _, turn := range script.Turns {
+			input.WriteString(turn.Input + "\n")
+			for _, answer := range turn.Answers {
+				input.WriteString(answer + "\n")
+			}
+		}
+		input.WriteString("exit\n")
+		replReader = bufio.NewReader(strings.NewReader(input.String()))
+
+		output, runErr := captureStdout(runInteractive)
+		if simulateShow {
+			fmt.Println(output)
+		}
+
+		// 按提示符切分输出：第i段是第i轮输入之后的输出
+		segments := strings.Split(output, ui.Text(replPrompt))
+		failed := 0
+		ui.Println("\n🧪 模拟结果:")
+		for i, turn := range script.Turns {
+			segment := ""
+			if i+1 < len(segments) {
+				segment = segments[i+1]
+			}
+			problems := checkTurn(turn, segment, i+1 < len(segments))
+			if len(problems) == 0 {
+				ui.Printf("  ✅ %d. %s\n", i+1, turn.Input)
+				continue
+			}
+			failed++
+			ui.Printf("  ❌ %d. %s\n", i+1, turn.Input)
+			for _, p := range problems {
+				fmt.Printf("       - %s\n", p)
+			}
+		}
+
+		if cassette != nil && !simulateRecord {
+			if remaining := cassette.Remaining(); remaining > 0 {
+				ui.Printf("  ⚠️  有 %d 条录制的响应未被使用，对话流程可能已变化\n", remaining)
+			}
+		}
+		if runErr != nil {
+			ui.Printf("  ⚠️  交互模式异常退出: %v\n", runErr)
+		}
+
+		fmt.Printf("\n通过 %d/%d\n", len(script.Turns)-failed, len(script.Turns))
+		if failed > 0 {
+			return fmt.Errorf("%d 轮未通过", failed)
+		}
+		return nil
+	},
+}
+
+// checkTurn 检查一轮输出是否符合期望，返回不符合的原因
+func checkTurn(turn simulateTurn, output string, ran bool) []string {
+	if !ran {
+		return []string{"未执行（交互模式提前结束）"}
+	}
+
+	var problems []string
+	for _, s := range turn.Expect.Contains {
+		if !strings.Contains(output, s) {
+			problems = append(problems, fmt.Sprintf("输出中缺少 %q", s))
+		}
+	}
+	for _, s := range turn.Expect.NotContains {
+		if strings.Contains(output, s) {
+			problems = append(problems, fmt.Sprintf("输出中不应包含 %q", s))
+		}
+	}
+	for _, pattern := range turn.Expect.Matches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("无效的正则表达式 %q: %v", pattern, err))
+			continue
+		}
+		if !re.MatchString(output) {
+			problems = append(problems, fmt.Sprintf("输出不匹配 %q", pattern))
+		}
+	}
+	return problems
+}
+
+// captureStdout 执行fn并捕获其标准输出
+func captureStdout(fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", fmt.Errorf("创建输出管道失败: %w", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	runErr := fn()
+	os.Stdout = stdout
+	w.Close()
+	output := <-done
+	r.Close()
+	return output, runErr
+}
+
+func init() {
+	simulateCmd.Flags().BoolVar(&simulateRecord, "record", false, "访问真实API并重新录制cassette文件")
+	simulateCmd.Flags().BoolVar(&simulateShow, "show-output", false, "显示完整的对话输出")
+	rootCmd.AddCommand(simulateCmd)
+}
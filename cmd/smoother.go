@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// smoothTick 平滑输出的最短刷新间隔，速度较快时每次刷新输出多个字符
+const smoothTick = 20 * time.Millisecond
+
+// outputSmoother 平滑输出：部分中转服务把整段回答放在一个分块里返回，失去了流式输出的效果；
+// 开启后已接收的内容按固定速度（字符/秒）逐步显示。只影响显示节奏，网络读取不受影响，rate<=0 时直接输出
+type outputSmoother struct {
+	rate int
+
+	mu      sync.Mutex
+	pending []rune
+	done    chan struct{} // 正在显示时非nil，显示完已接收的内容后关闭
+}
+
+var smoother = &outputSmoother{}
+
+// Write 追加要显示的内容
+func (s *outputSmoother) Write(text string) {
+	if s.rate <= 0 {
+		fmt.Print(text)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, []rune(text)...)
+	if s.done == nil {
+		s.done = make(chan struct{})
+		go s.render(s.done)
+	}
+}
+
+// render 按速度逐步输出，已接收的内容显示完后退出
+func (s *outputSmoother) render(done chan struct{}) {
+	interval := time.Second / time.Duration(s.rate)
+	batch := 1
+	if interval < smoothTick {
+		batch = int(smoothTick / interval)
+		interval = smoothTick
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.done = nil
+			s.mu.Unlock()
+			close(done)
+			return
+		}
+		n := min(batch, len(s.pending))
+		fmt.Print(string(s.pending[:n]))
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		<-ticker.C
+	}
+}
+
+// Busy 是否还有未显示的内容
+func (s *outputSmoother) Busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending) > 0
+}
+
+// Drain 立即输出所有未显示的内容（询问用户或展示其他信息之前调用，避免输出交错）
+func (s *outputSmoother) Drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) > 0 {
+		fmt.Print(string(s.pending))
+		s.pending = nil
+	}
+}
+
+// Flush 等待已接收的内容显示完，ctx取消时立即输出剩余内容
+func (s *outputSmoother) Flush(ctx context.Context) {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Drain()
+	}
+}
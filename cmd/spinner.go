@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// spinnerFrames 等待动画的帧
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// waitSpinner 流式回答暂时没有数据时，在当前输出位置之后显示等待状态，收到数据后清除，
+// 让用户区分“模型在思考”和“程序卡住”；输出不是终端时不显示
+type waitSpinner struct {
+	mu      sync.Mutex
+	shown   bool
+	frame   int
+	enabled bool
+}
+
+var streamSpinner = &waitSpinner{enabled: isTerminal(os.Stdout)}
+
+// update 显示或刷新等待状态（保存光标位置，之后在原位置刷新，不覆盖已输出的内容）
+func (s *waitSpinner) update(waited time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return
+	}
+	if s.shown {
+		fmt.Print("\0338\033[K")
+	} else {
+		fmt.Print("\0337")
+		s.shown = true
+	}
+	fmt.Printf(" %s 等待模型响应 %s", spinnerFrames[s.frame%len(spinnerFrames)], waited.Round(time.Second))
+	s.frame++
+}
+
+// clear 清除等待状态并把光标恢复到原位置
+func (s *waitSpinner) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.shown {
+		return
+	}
+	fmt.Print("\0338\033[K")
+	s.shown = false
+}
+
+// isTerminal 判断文件是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"agentcli/internal/telemetry"
+	"agentcli/internal/ui"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// appVersion 当前版本，用于 version 命令和匿名使用统计
+const appVersion = "2.0.0"
+
+// telemetryCollector 本次会话的匿名使用统计，未开启时为nil
+var telemetryCollector *telemetry.Collector
+
+// telemetryCmd 管理匿名使用统计
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry status|on|off",
+	Short: "查看或开关匿名使用统计（默认关闭）",
+	Long: `匿名使用统计帮助维护者了解哪些功能最常用、哪些工具最容易出错，默认关闭。
+开启后，每次会话结束时向 telemetry.endpoint 上报一次聚合数据：
+  - 使用过的交互命令名称及次数（如 /model，不含参数）
+  - 各工具的调用次数、失败率和耗时分位数
+  - LLM请求次数、失败率和响应耗时分位数
+  - 版本号、操作系统、CPU架构和随机生成的匿名安装ID
+从不上报提示词、回答、文件内容、工具参数、用户名或API Key。`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"status", "on", "off"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		action := "status"
+		if len(args) > 0 {
+			action = args[0]
+		}
+
+		switch action {
+		case "on", "off":
+			state, err := telemetry.SetEnabled(telemetry.DefaultDir, action == "on")
+			if err != nil {
+				return err
+			}
+			if state.Enabled {
+				ui.Printf("✅ 已开启匿名使用统计（匿名ID: %s）\n", state.InstallID)
+				if cfg.Telemetry.Endpoint == "" {
+					ui.Println("💡 尚未配置 telemetry.endpoint，配置上报地址后才会上报")
+				}
+			} else {
+				ui.Println("✅ 已关闭匿名使用统计")
+			}
+			return nil
+		case "status":
+			state, err := telemetry.LoadState(telemetry.DefaultDir)
+			if err != nil {
+				return err
+			}
+			status := "关闭"
+			if state.Enabled {
+				status = "开启"
+			}
+			endpoint := cfg.Telemetry.Endpoint
+			if endpoint == "" {
+				endpoint = "(未配置，不会上报)"
+			}
+			ui.Printf("📊 匿名使用统计: %s\n", status)
+			fmt.Printf("   上报地址: %s\n", endpoint)
+			if state.InstallID != "" {
+				fmt.Printf("   匿名ID: %s\n", state.InstallID)
+			}
+
+			// 展示上报数据的格式，便于确认不包含任何对话内容
+			example := telemetry.NewCollector()
+			example.RecordCommand("/model")
+			example.RecordTool("read_file", 12*time.Millisecond, nil)
+			data, _ := json.MarshalIndent(example.Report(state.InstallID, appVersion), "", "  ")
+			fmt.Printf("\n上报数据示例:\n%s\n", data)
+			return nil
+		default:
+			return fmt.Errorf("未知操作: %s（可选 status/on/off）", action)
+		}
+	},
+}
+
+// initTelemetry 用户已开启且配置了上报地址时创建使用统计（只统计真实的交互会话，simulate等命令不统计）
+func initTelemetry() {
+	if cfg.Telemetry.Endpoint == "" {
+		return
+	}
+	state, err := telemetry.LoadState(telemetry.DefaultDir)
+	if err != nil || !state.Enabled {
+		return
+	}
+	telemetryCollector = telemetry.NewCollector()
+}
+
+// flushTelemetry 会话结束时上报聚合数据，失败时只记录日志
+func flushTelemetry() {
+	if telemetryCollector == nil {
+		return
+	}
+	state, err := telemetry.LoadState(telemetry.DefaultDir)
+	if err != nil || !state.Enabled {
+		return
+	}
+	report := telemetryCollector.Report(state.InstallID, appVersion)
+	telemetryCollector = nil
+	if report.Empty() {
+		return
+	}
+
+	timeout := time.Duration(cfg.Telemetry.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if err := telemetry.Send(context.Background(), cfg.Telemetry.Endpoint, report, timeout); err != nil && log != nil {
+		log.Error("上报匿名使用统计失败", err, nil)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(telemetryCmd)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"agentcli/internal/ui"
+	"agentcli/internal/usage"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// usageCmd 用量相关命令
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "查看和导出token用量与费用",
+}
+
+// usageReportCmd 按天、按模型汇总持久化的用量记录
+var usageReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "按天、按模型汇总token用量和预估费用（可导出CSV）",
+	Long: `汇总 usage/ 目录下跨会话持久化的每日用量，按日期和模型输出请求数、token数和预估费用，
+便于与模型提供商的账单核对。费用按记录时 budget.prices 中的单价估算，未配置单价的模型费用为0。
+
+示例:
+  agentcli usage report                                   # 本月用量
+  agentcli usage report --from 2024-05-01 --to 2024-05-31 --format csv -o may.csv
+  agentcli usage report --all-users --format csv          # 团队所有用户`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		allUsers, _ := cmd.Flags().GetBool("all-users")
+
+		now := time.Now()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		to := now
+		var err error
+		if fromFlag != "" {
+			if from, err = time.ParseInLocation("2006-01-02", fromFlag, time.Local); err != nil {
+				return fmt.Errorf("无效的开始日期 %q（格式: YYYY-MM-DD）", fromFlag)
+			}
+		}
+		if toFlag != "" {
+			if to, err = time.ParseInLocation("2006-01-02", toFlag, time.Local); err != nil {
+				return fmt.Errorf("无效的结束日期 %q（格式: YYYY-MM-DD）", toFlag)
+			}
+		}
+		if to.Before(from) {
+			return fmt.Errorf("结束日期不能早于开始日期")
+		}
+
+		user := userID
+		if allUsers {
+			user = ""
+		}
+		rows, err := usage.Report("usage", user, from, to)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("创建输出文件失败: %w", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		switch format {
+		case "csv":
+			err = usage.WriteCSV(w, rows)
+		case "table":
+			err = usage.WriteTable(w, rows)
+		default:
+			return fmt.Errorf("不支持的格式: %s（可选 table/csv）", format)
+		}
+		if err != nil {
+			return err
+		}
+		if output != "" {
+			ui.Printf("✅ 已导出 %d 条用量记录到: %s\n", len(rows), output)
+		}
+		return nil
+	},
+}
+
+func init() {
+	usageReportCmd.Flags().String("from", "", "开始日期 YYYY-MM-DD（默认本月1日）")
+	usageReportCmd.Flags().String("to", "", "结束日期 YYYY-MM-DD（默认今天）")
+	usageReportCmd.Flags().String("format", "table", "输出格式: table 或 csv")
+	usageReportCmd.Flags().StringP("output", "o", "", "输出到文件（默认标准输出）")
+	usageReportCmd.Flags().Bool("all-users", false, "汇总所有用户的用量")
+	usageCmd.AddCommand(usageReportCmd)
+	rootCmd.AddCommand(usageCmd)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"agentcli/internal/agent"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/ui"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// whyPreviewRunes /why 列表中消息和参数的预览长度，/why <n> 显示完整内容
+const whyPreviewRunes = 160
+
+// handleWhyCommand /why 根据事件日志中记录的LLM请求解释上一轮为什么调用这些工具、参数从何而来；
+// 只展示实际发送给模型的内容和模型的原始回答，不重新请求模型生成理由
+func handleWhyCommand(a *agent.Agent, args []string) {
+	events, err := a.LastTurnEvents()
+	if err != nil {
+		ui.Printf("❌ %v\n", err)
+		return
+	}
+	decisions := eventlog.Explain(events)
+	if len(decisions) == 0 {
+		ui.Println("📭 上一轮没有调用工具")
+		return
+	}
+
+	if len(args) > 0 {
+		n, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil || n < 1 || n > len(decisions) {
+			ui.Printf("❌ 无效的编号: %s (范围: 1-%d)\n", args[0], len(decisions))
+			return
+		}
+		fmt.Println()
+		printDecision(n, decisions[n-1], 0)
+		return
+	}
+
+	llmCalls := 0
+	for _, e := range events {
+		if e.Kind == eventlog.KindLLM {
+			llmCalls++
+		}
+	}
+	ui.Printf("\n🔎 上一轮的工具调用依据（取自事件日志，共 %d 次LLM请求、%d 次工具调用）:\n\n", llmCalls, len(decisions))
+	for i, d := range decisions {
+		printDecision(i+1, d, whyPreviewRunes)
+	}
+	ui.Println("💡 使用 /why <编号> 查看完整的输入和参数")
+	fmt.Println()
+}
+
+// printDecision 输出一次工具调用的依据；maxRunes 为0时不截断
+func printDecision(n int, d eventlog.Decision, maxRunes int) {
+	clip := func(text string) string {
+		if maxRunes == 0 {
+			// 完整内容的多行文本保持缩进
+			return strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n       ")
+		}
+		return preview(text, maxRunes)
+	}
+
+	status := "成功"
+	if d.Tool.Error != "" {
+		status = "失败: " + clip(d.Tool.Error)
+	}
+	fmt.Printf("%d. %s（事件 #%d，%s）\n", n, d.Tool.Tool, d.Tool.Seq, status)
+	if args, err := json.Marshal(d.Args); err == nil {
+		fmt.Printf("   参数: %s\n", clip(string(args)))
+	}
+
+	if d.Source == nil {
+		fmt.Println("   来源: 事件日志中没有返回该调用的LLM请求（如 /run-tool 手动执行）")
+		fmt.Println()
+		return
+	}
+	call := d.Source.LLM
+	if d.Call != nil {
+		fmt.Printf("   来源: LLM请求 #%d（模型 %s）返回的工具调用，本次请求提供了 %d 个工具\n", d.Source.Seq, call.Model, len(d.Offered))
+		if content := strings.TrimSpace(call.Content); content != "" {
+			fmt.Printf("   模型随调用给出的说明: %s\n", clip(content))
+		}
+	} else {
+		fmt.Printf("   来源: LLM请求 #%d（模型 %s）的回答文本中提到该工具（文本工具调用或任务规划）\n", d.Source.Seq, call.Model)
+		if line, ok := quoteLine(call.Content, d.Tool.Tool); ok {
+			fmt.Printf("   回答原文: %s\n", clip(line))
+		}
+	}
+
+	if len(d.Trigger) > 0 {
+		fmt.Println("   模型做出该决定前看到的新输入:")
+		for _, msg := range d.Trigger {
+			fmt.Printf("     [%s] %s\n", msg.Role, clip(msg.Content))
+		}
+	}
+	if len(d.Evidence) > 0 {
+		fmt.Println("   参数出处:")
+		for _, e := range d.Evidence {
+			if e.Index < 0 {
+				fmt.Printf("     %s = %s ← 未出现在提示词中（由模型生成）\n", e.Param, clip(e.Value))
+				continue
+			}
+			fmt.Printf("     %s = %s ← 消息 %d [%s]: %s\n", e.Param, clip(e.Value), e.Index+1, e.Role, clip(e.Quote))
+		}
+	}
+	fmt.Println()
+}
+
+// quoteLine 回答文本中包含关键字的那一行
+func quoteLine(content, keyword string) (string, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, keyword) {
+			return strings.TrimSpace(line), true
+		}
+	}
+	return "", false
+}
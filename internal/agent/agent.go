@@ -1,15 +1,27 @@
 package agent
 
 import (
+	"agentcli/internal/audit"
+	"agentcli/internal/clock"
 	"agentcli/internal/config"
 	"agentcli/internal/dag"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/events"
+	"agentcli/internal/history"
 	"agentcli/internal/llm"
 	"agentcli/internal/logger"
+	"agentcli/internal/mcp"
+	"agentcli/internal/memory"
+	"agentcli/internal/telemetry"
 	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"agentcli/internal/usage"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -21,57 +33,225 @@ type Agent struct {
 	toolRegistry   *tools.ToolRegistry
 	config         *config.Config
 	logger         *logger.Logger
-	memory         string // 定制化记忆
+	memory         string            // 定制化记忆
+	teamMemory     string            // 团队共享指令
+	projectMemory  string            // 工作区各级目录的指令文件（AGENTS.md）
+	projectFiles   []string          // 已加载的指令文件路径
+	local          bool              // 本地模型模式
+	outputDir      string            // 保存完整命令输出的目录
+	dryRun         bool              // 演练模式：只展示计划的工具调用，不执行
+	changes        *tools.ChangeSet  // 计划模式的变更集：write_code、edit_file 的修改确认后才写入
+	filePicker     FilePicker        // 目标文件不存在时的交互式选择
+	confirmer      CommandConfirmer  // 执行命令前的确认
+	autoApprove    bool              // 跳过执行命令前的确认（--yes）
+	docLookup      bool              // 库文档自动检索模式
+	variables      map[string]string // 对话级变量
+	workdir        string            // 工具的默认执行目录（/cd）
+	env            map[string]string // 对话级环境变量（/env），注入到命令执行中
+	verbosity      string            // 回答详细程度
+	consensus      bool              // 双模型共识模式
+	usage          *usage.Tracker    // 用量追踪与预算限制
+	audit          *audit.Logger     // 工具调用审计日志
+	telemetry      *telemetry.Collector
+	eventLog       *eventlog.Recorder      // 会话事件日志，记录LLM请求和工具执行
+	turnStartSeq   int64                   // 本轮开始前的事件序号，/why 据此从事件日志中取出本轮的记录
+	recall         *memory.Recall          // 长期记忆（向量检索），未开启时为nil
+	recalled       string                  // 本轮召回的长期记忆
+	toolNotes      []string                // 本轮的工具结果，轮次结束后写入长期记忆
+	limiter        *llm.ConcurrencyLimiter // LLM请求并发限制
+	confirmMu      sync.Mutex              // 串行化并行任务的命令确认
 	contextMu      sync.Mutex
 	contextEntries []string
+	turnCommands   []string // 本轮执行的命令及结果
+	lastCommands   []string // 上一轮执行的命令及结果，写入下一轮的提示词
+	artifactMu     sync.Mutex
+	artifacts      []history.Artifact // 本轮生成的文件
+	assembler      contextAssembler   // 本轮已提供的文件内容
+	noToolsMu      sync.Mutex
+	noTools        map[string]bool // 运行时检测到不支持函数调用的模型
+	checkpointFile string          // 进行中轮次的检查点文件
+	turnMessages   []llm.Message   // 本轮工具循环中的助手工具调用和工具结果，轮次结束后保存到对话
+	calls          callCache       // 本轮工具调用去重缓存
+	citations      citationLog     // 本轮工具调用编号，用于回答引用
+	toolLog        toolCallLog     // 本轮工具调用记录
+	envOnce        sync.Once
+	envProfile     string                   // 本机环境概况（发行版、包管理器、shell、工具链）
+	mcpClients     []*mcp.Client            // 已连接的MCP服务
+	builtinTools   []string                 // 已注册的内置工具，配置修改后重新注册
+	configApprover ConfigApprover           // 代理修改配置前的确认
+	configOverride func(cfg *config.Config) // 重新加载配置时覆盖配置文件的设置
 }
 
 // NewAgent 创建代理
 func NewAgent(cfg *config.Config, log *logger.Logger) *Agent {
 	// 创建LLM客户端
+	apiKey, baseURL := cfg.API.OpenAIKey, cfg.API.BaseURL
+	switch {
+	case cfg.API.UsesAnthropic():
+		apiKey = cfg.API.AnthropicKey
+		if baseURL == "" {
+			baseURL = llm.AnthropicBaseURL
+		}
+	case cfg.API.UsesOllama():
+		baseURL = llm.OllamaNativeURL(baseURL)
+	}
 	llmClient := llm.NewClient(
-		cfg.API.OpenAIKey,
-		cfg.API.BaseURL,
+		apiKey,
+		baseURL,
 		cfg.API.Model,
 		time.Duration(cfg.API.Timeout)*time.Second,
 	)
+	llmClient.Backend = cfg.API.Provider
+	llmClient.ContextWindow = cfg.Context.Window
+	llmClient.MaxTokens = cfg.Response.MaxTokens
+	llmClient.PromptCache = cfg.API.PromptCache
+	llmClient.LegacyFunctions = cfg.API.LegacyFunctions
+	llmClient.MaxContinuations = cfg.Response.MaxContinuations
+	if llmClient.MaxContinuations <= 0 {
+		llmClient.MaxContinuations = 3
+	}
+	llmClient.StreamReconnects = cfg.API.StreamReconnects
+	llmClient.StreamIdleTimeout = time.Duration(cfg.API.StreamIdleTimeout) * time.Second
+	llmClient.Retry = llm.RetryPolicy{
+		MaxAttempts: cfg.API.Retry.MaxAttempts,
+		Backoff:     time.Duration(cfg.API.Retry.Backoff) * time.Second,
+		MaxBackoff:  time.Duration(cfg.API.Retry.MaxBackoff) * time.Second,
+		Jitter:      cfg.API.Retry.Jitter,
+	}
+	limiter := llm.NewConcurrencyLimiter(cfg.API.MaxConcurrentRequests)
+	llmClient.SetTransport(limiter.Transport(nil))
 
-	// 创建工具注册表
+	// 本地模型服务（Ollama、LM Studio等）通常不支持图片识别和原生函数调用
+	local := isLocalMode(cfg)
+
+	// 创建工具注册表（组织策略禁止的工具不会注册）
 	toolRegistry := tools.NewToolRegistry()
+	if cfg.Policy != nil {
+		toolRegistry.SetForbidden(cfg.Policy.ForbidsTool)
+	}
+
+	// 计划模式下 write_code 和 edit_file 的修改先记录到变更集
+	changes := tools.NewChangeSet()
+	changes.SetActive(cfg.Tools.WriteCode.RequireApproval)
+
+	a := &Agent{
+		llmClient:    llmClient,
+		limiter:      limiter,
+		toolRegistry: toolRegistry,
+		changes:      changes,
+		config:       cfg,
+		logger:       log,
+		memory:       "",
+		docLookup:    cfg.DocLookup.Enabled,
+		verbosity:    normalizeVerbosity(cfg.Response.Verbosity),
+		local:        local,
+		turnStartSeq: clock.Session.Current(),
+	}
+	a.registerTools()
+	return a
+}
+
+// registerTools 按配置注册内置工具，记录已注册的工具名以便配置修改后重新注册
+func (a *Agent) registerTools() {
+	cfg, toolRegistry, changes, llmClient, local := a.config, a.toolRegistry, a.changes, a.llmClient, a.local
+	register := func(tool tools.Tool) {
+		toolRegistry.Register(tool)
+		a.builtinTools = append(a.builtinTools, tool.Name())
+	}
 
-	// 注册工具
 	if contains(cfg.Tools.Enabled, "write_code") {
-		toolRegistry.Register(tools.NewWriteCodeTool(
+		register(tools.NewWriteCodeTool(
 			cfg.Tools.WriteCode.MaxLines,
 			cfg.Tools.WriteCode.SupportedLanguages,
+			cfg.Tools.WriteCode.Formatters,
+			cfg.Tools.WriteCode.Headers,
+			changes,
 		))
 	}
 
+	if contains(cfg.Tools.Enabled, "write_file") {
+		register(tools.NewWriteFileTool(cfg.Tools.WriteFile.MaxSizeKB))
+	}
+
+	if contains(cfg.Tools.Enabled, "apply_patch") {
+		register(tools.NewApplyPatchTool())
+	}
+
+	if contains(cfg.Tools.Enabled, "list_files") {
+		register(tools.NewListFilesTool())
+	}
+
+	if contains(cfg.Tools.Enabled, "search_files") {
+		register(tools.NewSearchFilesTool())
+	}
+
+	if contains(cfg.Tools.Enabled, "edit_file") {
+		register(tools.NewEditFileTool(changes))
+	}
+
 	if contains(cfg.Tools.Enabled, "read_file") {
-		toolRegistry.Register(tools.NewReadFileTool(
+		register(tools.NewReadFileTool(
 			cfg.Tools.ReadFile.MaxSizeMB,
 			cfg.Tools.ReadFile.AllowedExtensions,
 		))
 	}
 
-	if contains(cfg.Tools.Enabled, "recognize_image") {
-		toolRegistry.Register(tools.NewRecognizeImageTool(
+	// 本地模式下只有单独配置了识图模型（如 llava）时才注册
+	if contains(cfg.Tools.Enabled, "recognize_image") && (!local || cfg.Tools.RecognizeImage.Model != "") {
+		register(tools.NewRecognizeImageTool(
 			cfg.Tools.RecognizeImage.MaxSizeMB,
 			cfg.Tools.RecognizeImage.SupportedFormats,
-			nil, // 图片识别API客户端可以后续实现
+			&visionClient{client: llmClient, model: cfg.Tools.RecognizeImage.Model},
 		))
 	}
 
 	if contains(cfg.Tools.Enabled, "execute_command") {
-		toolRegistry.Register(tools.NewExecuteCommandTool(30 * time.Second))
+		executeCommand := tools.NewExecuteCommandTool(30 * time.Second)
+		executeCommand.SetPolicy(commandPolicy(cfg))
+		register(executeCommand)
 	}
 
-	return &Agent{
-		llmClient:    llmClient,
-		toolRegistry: toolRegistry,
-		config:       cfg,
-		logger:       log,
-		memory:       "",
+	// 未配置搜索服务时不注册 web_search（配置在加载时已校验）
+	if contains(cfg.Tools.Enabled, "web_search") && cfg.Tools.Web.Provider != "" {
+		provider, err := tools.NewSearchProvider(cfg.Tools.Web.Provider, cfg.Tools.Web.APIKey, cfg.Tools.Web.BaseURL,
+			time.Duration(cfg.Tools.Web.Timeout)*time.Second)
+		if err == nil {
+			register(tools.NewWebSearchTool(provider, cfg.Tools.Web.MaxResults))
+		}
+	}
+
+	if contains(cfg.Tools.Enabled, "fetch_url") {
+		register(tools.NewFetchURLTool(
+			time.Duration(cfg.Tools.Web.Timeout)*time.Second,
+			cfg.Tools.Web.MaxSizeKB,
+			cfg.Tools.Web.MaxChars,
+			cfg.Tools.Web.AllowedDomains,
+		))
+	}
+
+	if contains(cfg.Tools.Enabled, "browser") {
+		timeout := cfg.Tools.Browser.Timeout
+		if timeout <= 0 {
+			timeout = 60
+		}
+		register(tools.NewBrowserTool(
+			cfg.Tools.Browser.AllowedDomains,
+			cfg.Tools.Browser.Headless,
+			time.Duration(timeout)*time.Second,
+		))
+	}
+
+	if contains(cfg.Tools.Enabled, "reminders") {
+		register(tools.NewRemindersTool(tools.NewReminderStore(tools.DefaultReminderFile)))
+	}
+
+	if contains(cfg.Tools.Enabled, "go_inspect") {
+		register(tools.NewGoInspectTool(200))
+	}
+
+	// 修改的是当前加载的配置文件
+	if path := config.FileUsed(); contains(cfg.Tools.Enabled, "update_config") && path != "" {
+		register(tools.NewUpdateConfigTool(path, a.approveConfigEdit, a.ApplyConfig))
 	}
 }
 
@@ -83,6 +263,180 @@ func (a *Agent) SetMemory(mem string) {
 	}
 }
 
+// SetTeamMemory 设置团队共享指令
+func (a *Agent) SetTeamMemory(instructions string) {
+	a.teamMemory = instructions
+	if a.logger != nil {
+		a.logger.Info("设置团队共享指令", map[string]interface{}{"chars": len(instructions)})
+	}
+}
+
+// memoryPrompt 组合团队共享指令、项目指令文件和个人记忆，越具体的内容越靠后以便细化前面的规范
+func (a *Agent) memoryPrompt() string {
+	if a.teamMemory == "" && a.projectMemory == "" {
+		return a.memory
+	}
+	var sections []string
+	if a.teamMemory != "" {
+		sections = append(sections, "团队共享规范（所有成员都必须遵守）：\n"+a.teamMemory)
+	}
+	if a.projectMemory != "" {
+		sections = append(sections, "项目指令（按从仓库根目录到当前目录的顺序）：\n"+a.projectMemory)
+	}
+	if a.memory != "" {
+		sections = append(sections, "个人定制：\n"+a.memory)
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// SetVariables 设置对话级变量（用于展开工具参数中的 {{name}}）
+func (a *Agent) SetVariables(vars map[string]string) {
+	a.variables = vars
+}
+
+// SetWorkdir 设置对话的默认执行目录（相对于工作区根目录），工具调用未指定 workdir 时使用
+func (a *Agent) SetWorkdir(dir string) {
+	a.workdir = dir
+}
+
+// SetEnv 设置对话级环境变量，execute_command 执行时注入到子进程
+func (a *Agent) SetEnv(env map[string]string) {
+	a.env = env
+}
+
+// envHint 告知模型已设置的环境变量名（不包含值）
+func (a *Agent) envHint() string {
+	if len(a.env) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(a.env))
+	for name := range a.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("\n\nexecute_command 执行时已设置以下环境变量，可直接在命令中使用，不要输出或打印其中的密钥：%s。", strings.Join(names, ", "))
+}
+
+// applyWorkdir 支持执行目录的工具调用未指定 workdir 时，使用对话的默认执行目录
+func (a *Agent) applyWorkdir(tool tools.Tool, params map[string]interface{}) {
+	if a.workdir == "" {
+		return
+	}
+	if _, ok := tool.GetParams()[tools.WorkdirParam]; !ok {
+		return
+	}
+	if dir, _ := params[tools.WorkdirParam].(string); dir == "" {
+		params[tools.WorkdirParam] = a.workdir
+	}
+}
+
+// expandParams 展开工具参数中的对话级变量
+func (a *Agent) expandParams(params map[string]interface{}) map[string]interface{} {
+	if len(a.variables) == 0 {
+		return params
+	}
+	for key, value := range params {
+		switch v := value.(type) {
+		case string:
+			params[key] = history.ExpandVariables(v, a.variables)
+		case []interface{}:
+			for i, item := range v {
+				if s, ok := item.(string); ok {
+					v[i] = history.ExpandVariables(s, a.variables)
+				}
+			}
+		}
+	}
+	return params
+}
+
+// SetVerbosity 设置回答详细程度（concise/normal/detailed）
+func (a *Agent) SetVerbosity(verbosity string) error {
+	v := normalizeVerbosity(verbosity)
+	if !strings.EqualFold(v, strings.TrimSpace(verbosity)) {
+		return fmt.Errorf("不支持的详细程度: %s (可选: concise, normal, detailed)", verbosity)
+	}
+	a.verbosity = v
+	if a.logger != nil {
+		a.logger.Info("设置回答详细程度", map[string]interface{}{"verbosity": v})
+	}
+	return nil
+}
+
+// Verbosity 获取回答详细程度
+func (a *Agent) Verbosity() string {
+	return a.verbosity
+}
+
+// SetUsageTracker 设置用量追踪器（LLM请求和工具调用都会计入预算）
+func (a *Agent) SetUsageTracker(tracker *usage.Tracker) {
+	a.usage = tracker
+	if tracker != nil {
+		a.llmClient.Usage = tracker
+	}
+}
+
+// SetEventBus 设置事件总线（LLM请求的限流、超时、重试等状态会发布到总线上）
+func (a *Agent) SetEventBus(bus *events.Bus) {
+	a.llmClient.Events = bus
+}
+
+// SetTransport 设置LLM请求的HTTP传输层（如录制/回放）
+func (a *Agent) SetTransport(transport http.RoundTripper) {
+	a.llmClient.SetTransport(a.limiter.Transport(transport))
+}
+
+// SetAuditLogger 设置工具调用审计日志
+func (a *Agent) SetAuditLogger(l *audit.Logger) {
+	a.audit = l
+}
+
+// SetTelemetry 设置匿名使用统计（nil表示未开启）
+func (a *Agent) SetTelemetry(c *telemetry.Collector) {
+	a.telemetry = c
+}
+
+// SetEventLog 设置会话事件日志（nil表示不记录）
+func (a *Agent) SetEventLog(r *eventlog.Recorder) {
+	a.eventLog = r
+	a.llmClient.Tracer = nil
+	if r != nil { // 避免接口持有nil指针
+		a.llmClient.Tracer = r
+	}
+}
+
+// RerunCall 用指定模型（为空时使用当前模型）重新发送记录的LLM请求，用于对比不同模型的回答
+// 重新执行的请求计入用量，但不写入事件日志；模型需在组织策略允许的范围内
+func (a *Agent) RerunCall(ctx context.Context, record llm.CallRecord, model string) (llm.CallRecord, error) {
+	if model == "" {
+		model = a.llmClient.Model
+	}
+	if err := a.config.Policy.CheckModel(model); err != nil {
+		return llm.CallRecord{Model: model, Error: err.Error()}, err
+	}
+	client := a.llmClient.WithModel(model)
+	client.Tracer = nil
+
+	result := llm.CallRecord{
+		Model:      model,
+		Messages:   record.Messages,
+		Tools:      record.Tools,
+		ToolChoice: record.ToolChoice,
+		Started:    time.Now(),
+	}
+	resp, err := client.Chat(ctx, record.Messages, record.Tools, record.ToolChoice)
+	result.Duration = time.Since(result.Started)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	usage := resp.Usage
+	result.Usage = &usage
+	result.Content = resp.Choices[0].Message.Content
+	result.ToolCalls = resp.Choices[0].Message.ToolCalls
+	return result, nil
+}
+
 // UpdateModel 更新模型
 func (a *Agent) UpdateModel(model string) {
 	a.llmClient.Model = model
@@ -94,15 +448,24 @@ func (a *Agent) UpdateModel(model string) {
 // ProcessRequest 处理用户请求（带对话历史）
 func (a *Agent) ProcessRequest(ctx context.Context, userInput string, conversationHistory []llm.Message) (string, error) {
 	a.resetContextLog()
-	fmt.Printf("\n🤔 开始深度思考用户意图...\n")
-
-	// 第一步：分析用户意图（带历史上下文）
-	intention, err := a.analyzeIntention(ctx, userInput, conversationHistory)
-	if err != nil {
-		return "", fmt.Errorf("分析意图失败: %w", err)
+	a.assembler.reset()
+	a.calls.reset()
+	a.citations.reset()
+	ui.Printf("\n🤔 开始深度思考用户意图...\n")
+
+	// 第一步：分析用户意图（带历史上下文），简单的后续回复跳过该阶段
+	var intention string
+	if a.isSimpleFollowUp(ctx, userInput, conversationHistory) {
+		intention = followUpIntention(userInput)
+	} else {
+		var err error
+		intention, err = a.analyzeIntention(ctx, userInput, conversationHistory)
+		if err != nil {
+			return "", fmt.Errorf("分析意图失败: %w", err)
+		}
 	}
 
-	fmt.Printf("📊 意图分析: %s\n", intention)
+	ui.Printf("📊 意图分析: %s\n", intention)
 
 	// 第二步：使用DAG进行深度思考和规划（带历史上下文）
 	result, err := a.executeWithDAG(ctx, userInput, intention, conversationHistory)
@@ -110,7 +473,7 @@ func (a *Agent) ProcessRequest(ctx context.Context, userInput string, conversati
 		return "", fmt.Errorf("执行失败: %w", err)
 	}
 
-	return result, nil
+	return result + a.citationFooter(result), nil
 }
 
 // analyzeIntention 分析用户意图（带对话历史）
@@ -124,15 +487,15 @@ func (a *Agent) analyzeIntention(ctx context.Context, userInput string, conversa
 可用工具：
 %s
 
-请用一句话简洁地描述用户意图和需要执行的操作。`, a.osHint(), a.toolUsagePolicy(), toolsList)
+请用一句话简洁地描述用户意图和需要执行的操作。%s`, a.osHint(), a.toolUsagePolicy(), toolsList, a.lastTurnHint())
 
 	// 构建消息列表：系统提示 + 对话历史 + 当前用户输入
 	messages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
 	}
 
-	// 添加对话历史（如果有）
-	messages = append(messages, conversationHistory...)
+	// 添加对话历史（如果有），只保留最近窗口
+	messages = append(messages, a.intentHistory(conversationHistory)...)
 
 	// 添加当前用户输入
 	messages = append(messages, llm.Message{
@@ -155,7 +518,7 @@ func (a *Agent) analyzeIntention(ctx context.Context, userInput string, conversa
 // analyzeIntentionWithContext 分析用户意图并智能读取相关文件（带对话历史）
 func (a *Agent) analyzeIntentionWithContext(ctx context.Context, userInput string, conversationHistory []llm.Message) (string, error) {
 	// 显示思考过程
-	fmt.Print("\n💭 thinking: ")
+	ui.Print("\n💭 thinking: ")
 
 	// 第一步：分析用户意图 - 先获取完整的JSON响应
 	promptTemplate := `分析用户意图并判断需要什么操作。
@@ -175,22 +538,29 @@ func (a *Agent) analyzeIntentionWithContext(ctx context.Context, userInput strin
   "need_code_analysis": true/false,
   "need_image_analysis": true/false,
   "target_files": ["如果需要分析代码，列出可能相关的文件路径或模式"],
-  "target_images": ["如果需要分析图片，列出图片路径"]
+  "target_images": ["如果需要分析图片，列出图片路径"],
+  "constraints": ["用户明确提出的限制或要求（如不要修改某个文件、必须兼容某个版本），没有则为空数组"]%s
 }
 ` + "```"
 
+	docField := ""
+	if a.docLookup {
+		docField = `,
+  "doc_packages": ["如果问题涉及第三方库/框架的API，列出相关的包名或导入路径（如 github.com/spf13/cobra）"]`
+	}
+
 	// 构建消息列表：系统提示 + 对话历史 + 当前用户输入
 	messages := []llm.Message{
-		{Role: "system", Content: "你是一个智能助手，擅长分析用户意图并确定需要的操作。\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy()},
+		{Role: "system", Content: "你是一个智能助手，擅长分析用户意图并确定需要的操作。\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy() + a.lastTurnHint()},
 	}
 
-	// 添加对话历史
-	messages = append(messages, conversationHistory...)
+	// 添加对话历史（只保留最近窗口，更早的消息压缩为摘要）
+	messages = append(messages, a.intentHistory(conversationHistory)...)
 
 	// 添加当前用户输入
 	messages = append(messages, llm.Message{
 		Role:    "user",
-		Content: fmt.Sprintf(promptTemplate, userInput),
+		Content: fmt.Sprintf(promptTemplate, userInput, docField),
 	})
 
 	resp, err := a.llmClient.Chat(ctx, messages, nil, "")
@@ -230,11 +600,12 @@ func (a *Agent) analyzeIntentionWithContext(ctx context.Context, userInput strin
 		NeedImageAnalysis bool     `json:"need_image_analysis"`
 		TargetFiles       []string `json:"target_files"`
 		TargetImages      []string `json:"target_images"`
+		Constraints       []string `json:"constraints"`
+		DocPackages       []string `json:"doc_packages"`
 	}
 
-	// 尝试从响应中提取JSON
-	jsonStr := extractJSON(response)
-	if err := json.Unmarshal([]byte(jsonStr), &analysisResult); err != nil {
+	// 尝试从响应中提取JSON，格式错误时要求模型修正
+	if err := a.parseJSONWithRepair(ctx, "意图分析结果", response, &analysisResult); err != nil {
 		if thinking != "" {
 			a.appendContextEntry("deep_thinking", thinking)
 		} else if strings.TrimSpace(response) != "" {
@@ -257,14 +628,7 @@ func (a *Agent) analyzeIntentionWithContext(ctx context.Context, userInput strin
 		}
 		fmt.Print("\n\n")
 	} else {
-		fmt.Printf("\n🎯 意图: %s\n\n", analysisResult.Intent)
-	}
-
-	// 构建意图摘要
-	intentSummary := analysisResult.Intent
-	// 将思考过程也加入到摘要中，提供更多上下文
-	if thinking != "" {
-		intentSummary = fmt.Sprintf("思考过程：%s\n\n意图：%s", thinking, intentSummary)
+		ui.Printf("\n🎯 意图: %s\n\n", analysisResult.Intent)
 	}
 
 	thinkingForContext := strings.TrimSpace(thinking)
@@ -273,106 +637,122 @@ func (a *Agent) analyzeIntentionWithContext(ctx context.Context, userInput strin
 	}
 	a.appendContextEntry("deep_thinking", thinkingForContext)
 
-	// 如果需要分析代码文件，将文件信息融入到意图描述中
+	// 构建本轮摘要：意图、候选文件和约束，文件内容由模型通过工具按需读取
+	brief := &turnBrief{
+		Intent:      analysisResult.Intent,
+		Constraints: cleanList(analysisResult.Constraints),
+	}
+
 	if analysisResult.NeedCodeAnalysis && len(analysisResult.TargetFiles) > 0 {
-		// 过滤掉空字符串
+		// 过滤掉空字符串和重复路径
 		var validFiles []string
+		seenFiles := make(map[string]bool)
 		for _, f := range analysisResult.TargetFiles {
-			if f != "" {
+			if f != "" && !seenFiles[normalizeFilePath(f)] {
+				seenFiles[normalizeFilePath(f)] = true
 				validFiles = append(validFiles, f)
 			}
 		}
 
-		if len(validFiles) > 0 {
-			intentSummary += "，需要分析以下代码文件: " + strings.Join(validFiles, ", ")
-
-			// 实际读取文件
-			readFileTool, err := a.toolRegistry.Get("read_file")
-			if err == nil {
-				for _, filePath := range validFiles {
-					result, err := readFileTool.Execute(ctx, map[string]interface{}{
-						"filepath": filePath,
-					})
-					if err == nil {
-						if a.logger != nil {
-							a.logger.ThinkingProcess("读取代码文件", fmt.Sprintf("文件: %s", filePath))
-						}
-
-						// 提取文件内容
-						if resultMap, ok := result.(map[string]interface{}); ok {
-							if content, ok := resultMap["content"].(string); ok {
-								// 简单的截断保护，避免上下文溢出 (例如保留前20000字符)
-								if len(content) > 20000 {
-									content = content[:20000] + "\n... (文件内容过长，已截断)"
-								}
-								intentSummary += fmt.Sprintf("\n\n文件 %s 的内容:\n```\n%s\n```\n", filePath, content)
-							}
-						} else {
-							intentSummary += fmt.Sprintf("\n  - 已读取: %s (但无法获取内容)", filePath)
-						}
-					}
-				}
-			}
+		// 猜测的文件不存在时，从工作区中模糊匹配并让用户确认
+		brief.Files, brief.Notes = a.resolveTargetFiles(validFiles)
+
+		// 共识模式下模型无法调用工具，仍需预读取文件内容
+		if a.consensus && len(brief.Files) > 0 {
+			brief.Context += a.prefetchFiles(ctx, brief.Files)
 		}
 	}
 
-	// 如果需要分析图片，将图片信息融入到意图描述中
 	if analysisResult.NeedImageAnalysis && len(analysisResult.TargetImages) > 0 {
-		// 过滤掉空字符串
-		var validImages []string
-		for _, img := range analysisResult.TargetImages {
-			if img != "" {
-				validImages = append(validImages, img)
-			}
-		}
+		brief.Images = cleanList(analysisResult.TargetImages)
+	}
 
-		if len(validImages) > 0 {
-			intentSummary += "，需要分析以下图片: " + strings.Join(validImages, ", ")
-
-			// 实际识别图片
-			recognizeTool, err := a.toolRegistry.Get("recognize_image")
-			if err == nil {
-				for _, imagePath := range validImages {
-					result, err := recognizeTool.Execute(ctx, map[string]interface{}{
-						"filepath": imagePath,
-					})
-					if err == nil {
-						if a.logger != nil {
-							a.logger.ThinkingProcess("识别图片", fmt.Sprintf("图片: %s", imagePath))
-						}
-						intentSummary += fmt.Sprintf("\n  - 已识别: %s", imagePath)
-					}
-					_ = result
-				}
-			}
+	// 如果开启了文档检索模式，先检索相关库的官方文档
+	if a.docLookup && len(analysisResult.DocPackages) > 0 {
+		ui.Printf("📚 检索文档: %s\n", strings.Join(analysisResult.DocPackages, ", "))
+		if sources := a.lookupDocs(ctx, analysisResult.DocPackages); len(sources) > 0 {
+			brief.Context += formatDocSources(sources)
 		}
 	}
 
-	return intentSummary, nil
+	return brief.String(), nil
 }
 
 // executeWithDAG 使用DAG执行任务（带对话历史）
+// 由模型将任务规划为任务图并编译为DAG执行，互不依赖的步骤并行执行；规划失败时退回固定的 思考→决策→工具→总结 流程
 func (a *Agent) executeWithDAG(ctx context.Context, userInput, intention string, conversationHistory []llm.Message) (string, error) {
-	// 创建DAG
-	d := dag.NewDAG(
+	d := a.newDAG()
+	plan, err := a.planTaskGraph(ctx, userInput, intention, conversationHistory)
+	if err == nil {
+		err = a.compilePlan(d, plan, userInput)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		ui.Printf("⚠️  任务图规划无效，改用固定流程: %v\n", err)
+		if a.logger != nil {
+			a.logger.Error("任务图规划无效，改用固定流程", err, nil)
+		}
+		d = a.newDAG()
+		a.buildFixedPipeline(d, userInput, intention, conversationHistory)
+	} else {
+		printPlan(plan)
+	}
+
+	// 执行DAG
+	ui.Printf("\n🔄 开始执行DAG工作流...\n")
+	if err := d.Execute(ctx); err != nil {
+		return "", err
+	}
+
+	// 获取结果
+	results := d.GetResults()
+	if summary, ok := results["summary"]["result"].(string); ok {
+		return summary, nil
+	}
+
+	return "执行完成，但未能获取结果", nil
+}
+
+// newDAG 按配置创建DAG
+func (a *Agent) newDAG() *dag.DAG {
+	return dag.NewDAG(
 		a.config.DAG.MaxDepth,
 		a.config.DAG.ParallelNodes,
 		time.Duration(a.config.DAG.Timeout)*time.Second,
 		a.config.DAG.Verbose,
 	)
+}
 
+// nodeRetry 模型推理节点的重试策略（dag.node_max_attempts、dag.node_retry_backoff）
+func (a *Agent) nodeRetry() dag.RetryPolicy {
+	backoff := time.Duration(a.config.DAG.NodeRetryBackoff) * time.Second
+	return dag.RetryPolicy{
+		MaxAttempts: a.config.DAG.NodeMaxAttempts,
+		Backoff:     backoff,
+		MaxBackoff:  8 * backoff,
+	}
+}
+
+// buildFixedPipeline 构建固定的 思考→决策→工具→总结 流程
+func (a *Agent) buildFixedPipeline(d *dag.DAG, userInput, intention string, conversationHistory []llm.Message) {
 	// 创建思考节点
 	thinkNode := dag.NewNode("think", "深度思考", dag.NodeTypeThink)
 	thinkNode.SetInput("user_input", userInput)
 	thinkNode.SetInput("intention", intention)
 	thinkNode.SetInput("conversation_history", conversationHistory)
 	thinkNode.SetHandler(&ThinkHandler{agent: a})
+	thinkNode.Retry = a.nodeRetry()
+	// 深度思考失败时跳过思考，由决策节点直接根据用户请求规划
+	thinkNode.OnFailure = &ThinkFallbackHandler{}
 	d.AddNode(thinkNode)
 
 	// 创建决策节点
 	decisionNode := dag.NewNode("decision", "决策执行", dag.NodeTypeDecision)
 	decisionNode.AddDependency("think")
 	decisionNode.SetHandler(&DecisionHandler{agent: a})
+	decisionNode.Retry = a.nodeRetry()
 	d.AddNode(decisionNode)
 
 	// 创建工具执行节点
@@ -385,21 +765,8 @@ func (a *Agent) executeWithDAG(ctx context.Context, userInput, intention string,
 	summaryNode := dag.NewNode("summary", "总结结果", dag.NodeTypeEnd)
 	summaryNode.AddDependency("tool")
 	summaryNode.SetHandler(&SummaryHandler{agent: a})
+	summaryNode.Retry = a.nodeRetry()
 	d.AddNode(summaryNode)
-
-	// 执行DAG
-	fmt.Printf("\n🔄 开始执行DAG工作流...\n")
-	if err := d.Execute(ctx); err != nil {
-		return "", err
-	}
-
-	// 获取结果
-	results := d.GetResults()
-	if summary, ok := results["summary"]["result"].(string); ok {
-		return summary, nil
-	}
-
-	return "执行完成，但未能获取结果", nil
 }
 
 // getToolsDescription 获取工具描述
@@ -413,20 +780,55 @@ func (a *Agent) getToolsDescription() string {
 }
 
 func (a *Agent) osHint() string {
+	var hint string
 	switch runtime.GOOS {
 	case "windows":
-		return "Windows（使用 PowerShell 命令）"
+		hint = "Windows（使用 PowerShell 命令）"
 	case "darwin":
-		return "macOS（使用 sh 语法）"
+		hint = "macOS（使用 sh 语法）"
 	default:
-		return "Linux（使用 sh 语法）"
+		hint = "Linux（使用 sh 语法）"
+	}
+
+	// 附加本机环境概况，使生成的命令匹配已安装的包管理器和工具链
+	if a.config.Context.EnvironmentProfile {
+		if profile := a.environmentProfile(); profile != "" {
+			hint += "（" + profile + "）"
+		}
 	}
+	return hint
 }
 
 func (a *Agent) toolUsagePolicy() string {
 	return "当任务可通过工具完成时，必须调用工具执行；不要让用户手动运行命令。仅在确实无法使用工具时才向用户提问或解释限制。"
 }
 
+func (a *Agent) verbosityHint() string {
+	var hint string
+	switch a.verbosity {
+	case "concise":
+		hint = "回答风格：简洁。只给出结论和必要的步骤，避免铺垫和重复。"
+	case "detailed":
+		hint = "回答风格：详细。给出完整的解释、背景和示例。"
+	default:
+		hint = "回答风格：适中。在清晰的前提下保持简洁。"
+	}
+	if language := strings.TrimSpace(a.config.Response.Language); language != "" {
+		hint += "\n请使用" + language + "回答。"
+	}
+	return hint
+}
+
+// normalizeVerbosity 规范化详细程度，无法识别时返回normal
+func normalizeVerbosity(verbosity string) string {
+	switch v := strings.ToLower(strings.TrimSpace(verbosity)); v {
+	case "concise", "normal", "detailed":
+		return v
+	default:
+		return "normal"
+	}
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -508,6 +910,18 @@ func (h *ThinkHandler) Execute(ctx context.Context, input map[string]interface{}
 	}, nil
 }
 
+// ThinkFallbackHandler 深度思考失败时的降级处理：输出空的思考结果
+type ThinkFallbackHandler struct{}
+
+func (h *ThinkFallbackHandler) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	ui.Printf("⚠️  深度思考失败，跳过思考直接规划: %v\n", input[dag.FailureErrorKey])
+	return map[string]interface{}{
+		"thinking":             "",
+		"user_input":           input["user_input"],
+		"conversation_history": input["conversation_history"],
+	}, nil
+}
+
 // DecisionHandler 决策处理器
 type DecisionHandler struct {
 	agent *Agent
@@ -540,6 +954,18 @@ func (h *DecisionHandler) Execute(ctx context.Context, input map[string]interfac
 
 如果不需要使用工具，返回空数组 []`, h.agent.osHint(), h.agent.toolUsagePolicy(), thinking, userInput)
 
+	// 思考阶段已确定只需要一个工具时，强制模型调用该工具，直接得到结构化参数
+	if toolName := h.agent.singleNeededTool(thinking); toolName != "" {
+		if plan, err := h.agent.planForcedTool(ctx, prompt, toolName); err == nil {
+			return map[string]interface{}{
+				"plan":       plan,
+				"user_input": userInput,
+			}, nil
+		} else if h.agent.logger != nil {
+			h.agent.logger.Error("强制工具调用规划失败，改用文本规划", err, map[string]interface{}{"tool": toolName})
+		}
+	}
+
 	response, err := h.agent.llmClient.SimpleQuery(ctx, prompt)
 	if err != nil {
 		return nil, err
@@ -551,6 +977,52 @@ func (h *DecisionHandler) Execute(ctx context.Context, input map[string]interfac
 	}, nil
 }
 
+// singleNeededTool 从思考结果中获取唯一需要的工具（工具存在且模型支持函数调用时）
+func (a *Agent) singleNeededTool(thinking string) string {
+	var result struct {
+		ToolsNeeded []string `json:"tools_needed"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(thinking)), &result); err != nil || len(result.ToolsNeeded) != 1 {
+		return ""
+	}
+	name := result.ToolsNeeded[0]
+	if _, err := a.toolRegistry.Get(name); err != nil || !a.supportsFunctionCalling() {
+		return ""
+	}
+	return name
+}
+
+// planForcedTool 使用tool_choice强制调用指定工具，返回与文本规划相同格式的工具调用计划
+func (a *Agent) planForcedTool(ctx context.Context, prompt, toolName string) (string, error) {
+	messages := []llm.Message{{Role: "user", Content: prompt}}
+	resp, err := a.llmClient.Chat(ctx, messages, a.convertToolsToOpenAIFormat(), toolName)
+	if err != nil {
+		return "", err
+	}
+
+	type plannedCall struct {
+		Tool   string                 `json:"tool"`
+		Params map[string]interface{} `json:"params"`
+	}
+	var plan []plannedCall
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
+			return "", fmt.Errorf("参数解析失败: %w", err)
+		}
+		plan = append(plan, plannedCall{Tool: call.Function.Name, Params: params})
+	}
+	if len(plan) == 0 {
+		return "", fmt.Errorf("模型未返回工具调用")
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // ToolHandler 工具处理器
 type ToolHandler struct {
 	agent *Agent
@@ -559,16 +1031,14 @@ type ToolHandler struct {
 func (h *ToolHandler) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
 	planStr := input["plan"].(string)
 
-	// 提取JSON部分
-	planStr = extractJSON(planStr)
-
 	var toolCalls []struct {
 		Tool   string                 `json:"tool"`
 		Params map[string]interface{} `json:"params"`
 	}
 
-	if err := json.Unmarshal([]byte(planStr), &toolCalls); err != nil {
-		// 如果无法解析，可能不需要调用工具
+	// 提取JSON部分，格式错误时要求模型修正
+	if err := h.agent.parseJSONWithRepair(ctx, "工具调用计划", planStr, &toolCalls); err != nil {
+		// 修正后仍无法解析，按不需要调用工具处理
 		return map[string]interface{}{
 			"results": []string{},
 		}, nil
@@ -578,18 +1048,25 @@ func (h *ToolHandler) Execute(ctx context.Context, input map[string]interface{})
 	for _, call := range toolCalls {
 		tool, err := h.agent.toolRegistry.Get(call.Tool)
 		if err != nil {
-			results = append(results, fmt.Sprintf("❌ 工具 %s 不存在: %v", call.Tool, err))
+			results = append(results, ui.Sprintf("❌ 工具 %s 不存在: %v", call.Tool, err))
 			continue
 		}
 
-		fmt.Printf("⚙️  执行工具: %s\n", call.Tool)
-		result, err := tool.Execute(ctx, call.Params)
-		h.agent.recordToolCallContext(call.Tool, call.Params, result, err)
+		if h.agent.usage != nil {
+			if err := h.agent.usage.CheckToolCall(); err != nil {
+				return nil, err
+			}
+			h.agent.usage.RecordToolCall()
+		}
+
+		ui.Printf("⚙️  执行工具: %s\n", call.Tool)
+		result, err := h.agent.invokeTool(ctx, tool, call.Params)
+		label := h.agent.citeLabel(call.Tool)
 		if err != nil {
-			results = append(results, fmt.Sprintf("❌ 工具 %s 执行失败: %v", call.Tool, err))
+			results = append(results, ui.Sprintf("%s❌ 工具 %s 执行失败: %v", label, call.Tool, err))
 		} else {
 			resultJSON, _ := json.MarshalIndent(result, "", "  ")
-			results = append(results, fmt.Sprintf("✅ 工具 %s 执行成功:\n%s", call.Tool, string(resultJSON)))
+			results = append(results, ui.Sprintf("%s✅ 工具 %s 执行成功:\n%s", label, call.Tool, string(resultJSON)))
 		}
 	}
 
@@ -612,7 +1089,7 @@ func (h *SummaryHandler) Execute(ctx context.Context, input map[string]interface
 
 	if len(results) == 0 {
 		// 如果没有工具调用，直接回答
-		prompt := fmt.Sprintf("当前系统：%s。请仅给出匹配该系统的命令与操作。\n%s\n\n用户请求：%s", h.agent.osHint(), h.agent.toolUsagePolicy(), userInput)
+		prompt := fmt.Sprintf("当前系统：%s。请仅给出匹配该系统的命令与操作。\n%s%s\n%s\n\n用户请求：%s", h.agent.osHint(), h.agent.toolUsagePolicy(), h.agent.lastTurnHint(), h.agent.verbosityHint(), userInput)
 		response, err := h.agent.llmClient.SimpleQuery(ctx, prompt)
 		if err != nil {
 			return nil, err
@@ -632,7 +1109,8 @@ func (h *SummaryHandler) Execute(ctx context.Context, input map[string]interface
 工具执行结果：
 %s
 
-请用自然语言总结执行结果，告诉用户任务是否完成以及具体的结果。`, h.agent.osHint(), h.agent.toolUsagePolicy(), userInput, resultsStr)
+请用自然语言总结执行结果，告诉用户任务是否完成以及具体的结果。
+%s%s`, h.agent.osHint(), h.agent.toolUsagePolicy(), userInput, resultsStr, h.agent.verbosityHint(), h.agent.citationHint())
 
 	response, err := h.agent.llmClient.SimpleQuery(ctx, prompt)
 	if err != nil {
@@ -646,20 +1124,18 @@ func (h *SummaryHandler) Execute(ctx context.Context, input map[string]interface
 
 // extractJSON 从文本中提取JSON部分
 func extractJSON(text string) string {
-	// 查找 [ 或 { 开头的部分
-	start := strings.Index(text, "[")
-	if start == -1 {
-		start = strings.Index(text, "{")
-	}
+	// 查找最先出现的 [ 或 { 作为开头（对象中可能包含数组，反之亦然）
+	start := strings.IndexAny(text, "[{")
 	if start == -1 {
 		return text
 	}
 
 	// 查找对应的结束符
-	end := strings.LastIndex(text, "]")
-	if end == -1 {
-		end = strings.LastIndex(text, "}")
+	closer := "]"
+	if text[start] == '{' {
+		closer = "}"
 	}
+	end := strings.LastIndex(text, closer)
 	if end == -1 || end <= start {
 		return text
 	}
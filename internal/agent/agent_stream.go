@@ -2,29 +2,44 @@ package agent
 
 import (
 	"agentcli/internal/llm"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 )
 
 // convertToolsToOpenAIFormat 将工具转换为OpenAI函数调用格式
 func (a *Agent) convertToolsToOpenAIFormat() []llm.Tool {
-	tools := make([]llm.Tool, 0)
+	openAITools := make([]llm.Tool, 0)
 
 	for _, tool := range a.toolRegistry.List() {
 		// 构建参数schema
 		properties := make(map[string]interface{})
-		required := make([]string, 0)
+		required := tools.RequiredParams(tool)
 
+		var schema map[string]interface{}
+		if p, ok := tool.(tools.SchemaProvider); ok {
+			schema = p.ParamSchema()
+		}
 		for paramName, paramDesc := range tool.GetParams() {
+			if prop, ok := schema[paramName]; ok {
+				properties[paramName] = prop
+				continue
+			}
 			properties[paramName] = map[string]interface{}{
 				"type":        "string",
 				"description": paramDesc,
 			}
-			required = append(required, paramName)
+		}
+		properties[repeatParam] = map[string]interface{}{
+			"type":        "string",
+			"description": repeatParamDescription,
 		}
 
-		tools = append(tools, llm.Tool{
+		openAITools = append(openAITools, llm.Tool{
 			Type: "function",
 			Function: llm.FunctionDef{
 				Name:        tool.Name(),
@@ -38,24 +53,95 @@ func (a *Agent) convertToolsToOpenAIFormat() []llm.Tool {
 		})
 	}
 
-	return tools
+	return openAITools
 }
 
 // ProcessRequestStream 处理用户请求（流式输出，带对话历史）
 func (a *Agent) ProcessRequestStream(ctx context.Context, userInput string, conversationHistory []llm.Message, onChunk func(string) error) (string, error) {
+	result, err := a.RunTask(ctx, userInput, conversationHistory, onChunk)
+	return result.Answer, err
+}
+
+// RunTask 处理一轮用户请求，返回包含回答、完成状态、工具调用记录、生成的文件、用量和耗时的结构化结果
+// 出错时结果的 Status 为 TaskFailed，同时返回该错误
+func (a *Agent) RunTask(ctx context.Context, userInput string, conversationHistory []llm.Message, onChunk func(string) error) (*TaskResult, error) {
+	started := time.Now()
+	usageBefore := a.sessionUsage()
+	artifactsBefore := a.artifactCount()
+
+	answer, err := a.runTask(ctx, userInput, conversationHistory, onChunk)
+	if err == nil {
+		a.rememberTurn(ctx, userInput, answer)
+	}
+
+	result := &TaskResult{
+		Answer:    answer,
+		Status:    TaskSucceeded,
+		ToolCalls: a.toolLog.list(),
+		Artifacts: a.artifactsSince(artifactsBefore),
+		Usage:     a.sessionUsage().Sub(usageBefore),
+		Duration:  time.Since(started),
+	}
+	if err != nil {
+		result.Status = TaskFailed
+		result.Err = err
+	}
+	return result, err
+}
+
+// runTask 意图分析、DAG执行和引用核对
+func (a *Agent) runTask(ctx context.Context, userInput string, conversationHistory []llm.Message, onChunk func(string) error) (string, error) {
 	a.resetContextLog()
+	a.assembler.reset()
+	a.calls.reset()
+	a.citations.reset()
+	a.toolLog.reset()
+	a.turnMessages = nil
+	// 开始新的轮次，丢弃之前未完成轮次的检查点
+	a.clearCheckpoint()
+	a.recallMemories(ctx, userInput)
 	// 记录开始处理
 	if a.logger != nil {
 		a.logger.ThinkingProcess("开始处理", "用户输入: "+userInput)
 	}
 
 	// 第一步：分析用户意图（带思考过程显示和对话历史）
-	intention, err := a.analyzeIntentionWithContext(ctx, userInput, conversationHistory)
-	if err != nil {
+	// 简单的后续回复（确认、致谢等）跳过意图分析，直接进入工具循环
+	// 闲聊和通用知识问题不带工具直接回答，模型表示需要工具时再转入完整流程
+	var intention string
+	followUp := a.isSimpleFollowUp(ctx, userInput, conversationHistory)
+	if !followUp && a.isChatOnly(ctx, userInput, conversationHistory) {
+		ui.Print("\n💬 直接回答，跳过意图分析和工具\n")
+		answer, escalated, err := a.answerChatOnly(ctx, userInput, conversationHistory, onChunk)
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Error("直接回答失败", err, nil)
+			}
+			return "", fmt.Errorf("执行失败: %w", err)
+		}
+		if !escalated {
+			if a.logger != nil {
+				a.logger.ThinkingProcess("完成处理", "直接回答，输出长度: "+fmt.Sprintf("%d", len(answer)))
+			}
+			return a.checkAnswerCommands(ctx, answer, onChunk), nil
+		}
+		ui.Print("\n↪️ 需要使用工具，转入完整流程\n")
 		if a.logger != nil {
-			a.logger.Error("分析意图失败", err, nil)
+			a.logger.ThinkingProcess("直接回答升级", "模型表示需要工具")
+		}
+	}
+	if followUp {
+		ui.Print("\n⚡ 简单后续回复，跳过意图分析\n")
+		intention = followUpIntention(userInput)
+	} else {
+		var err error
+		intention, err = a.analyzeIntentionWithContext(ctx, userInput, conversationHistory)
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Error("分析意图失败", err, nil)
+			}
+			return "", fmt.Errorf("分析意图失败: %w", err)
 		}
-		return "", fmt.Errorf("分析意图失败: %w", err)
 	}
 
 	if a.logger != nil {
@@ -75,22 +161,25 @@ func (a *Agent) ProcessRequestStream(ctx context.Context, userInput string, conv
 		a.logger.ThinkingProcess("完成处理", "输出长度: "+fmt.Sprintf("%d", len(result)))
 	}
 
+	// 核对回答中的命令是否匹配当前系统
+	result = a.checkAnswerCommands(ctx, result, onChunk)
+
+	// 核对回答中的引用
+	if footer := a.citationFooter(result); footer != "" {
+		onChunk(footer)
+	}
+
 	return result, nil
 }
 
 // executeWithDAGStream 使用DAG执行任务（流式输出，带对话历史）
 func (a *Agent) executeWithDAGStream(ctx context.Context, userInput, intention string, conversationHistory []llm.Message, onChunk func(string) error) (string, error) {
 	// 构建系统提示词，包含定制化记忆
-	systemPrompt := "你是一个智能助手。\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy()
-	if a.memory != "" {
-		systemPrompt = a.memory + "\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy()
-		if a.logger != nil {
-			a.logger.ThinkingProcess("应用定制化记忆", a.memory)
-		}
+	systemPrompt := a.systemPrompt()
+	if memory := a.memoryPrompt(); memory != "" && a.logger != nil {
+		a.logger.ThinkingProcess("应用定制化记忆", memory)
 	}
 
-	systemPrompt += "\n\n你可以使用提供的工具来完成任务。当需要使用工具时，系统会自动调用它们。"
-
 	// 构建消息列表：系统提示 + 对话历史 + 当前任务
 	messages := []llm.Message{
 		{Role: "system", Content: systemPrompt},
@@ -102,9 +191,32 @@ func (a *Agent) executeWithDAGStream(ctx context.Context, userInput, intention s
 	// 添加当前任务
 	messages = append(messages, llm.Message{
 		Role:    "user",
-		Content: fmt.Sprintf("前置分析：%s\n\n用户请求：%s", intention, userInput),
+		Content: fmt.Sprintf("本轮摘要：\n%s\n\n用户请求：%s", intention, userInput),
 	})
 
+	// 共识模式：两个模型分别回答，由评审模型合并并报告分歧
+	if a.consensus {
+		return a.executeConsensus(ctx, messages, onChunk)
+	}
+
+	// 任务图模式：由模型规划任务图并编译为DAG，互不依赖的步骤并行执行（演练模式仍使用工具循环展示计划）
+	if a.config.DAG.Planner && !a.dryRun {
+		result, err := a.executeWithDAG(ctx, userInput, intention, conversationHistory)
+		if err != nil {
+			return "", err
+		}
+		ui.Printf("\n🤖 Agent: ")
+		if err := onChunk(result); err != nil {
+			return "", err
+		}
+		return result, nil
+	}
+
+	// 模型不支持原生函数调用时，使用文本形式的工具调用
+	if !a.supportsFunctionCalling() {
+		return a.executeReAct(ctx, messages, onChunk)
+	}
+
 	// 转换工具为OpenAI格式
 	tools := a.convertToolsToOpenAIFormat()
 
@@ -112,16 +224,71 @@ func (a *Agent) executeWithDAGStream(ctx context.Context, userInput, intention s
 		a.logger.ThinkingProcess("准备工具", fmt.Sprintf("可用工具数量: %d", len(tools)))
 	}
 
-	// 执行函数调用循环
+	return a.runToolLoop(ctx, userInput, messages, tools, 0, onChunk)
+}
+
+// systemPrompt 构建执行阶段的系统提示词，包含定制化记忆
+func (a *Agent) systemPrompt() string {
+	systemPrompt := "你是一个智能助手。\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy()
+	if memory := a.memoryPrompt(); memory != "" {
+		systemPrompt = memory + "\n当前系统：" + a.osHint() + "。请仅给出匹配该系统的命令与操作。\n" + a.toolUsagePolicy()
+	}
+
+	systemPrompt += "\n\n你可以使用提供的工具来完成任务。当需要使用工具时，系统会自动调用它们。"
+	systemPrompt += "\n" + a.verbosityHint()
+	systemPrompt += a.citationHint()
+	systemPrompt += a.envHint()
+	systemPrompt += a.lastTurnHint()
+	systemPrompt += a.recallHint()
+	systemPrompt += a.planHint()
+	if a.workdir != "" {
+		systemPrompt += fmt.Sprintf("\n\n当前默认执行目录为工作区下的 %s，未指定 workdir 的 execute_command、read_file、write_code、write_file、apply_patch、edit_file、list_files、search_files 调用在该目录下执行，相对路径也基于该目录。", a.workdir)
+	}
+	return systemPrompt
+}
+
+// runToolLoop 执行函数调用循环（从第start次迭代开始）
+// 每次迭代的工具执行完成后保存检查点，失败后可通过 ResumeRequestStream 从最后一次成功的工具调用处继续
+func (a *Agent) runToolLoop(ctx context.Context, userInput string, messages []llm.Message, tools []llm.Tool, start int, onChunk func(string) error) (string, error) {
 	maxIterations := 10
-	for i := 0; i < maxIterations; i++ {
+	var loops loopDetector
+	task, compaction := taskIndex(messages), 0
+	for i := start; i < maxIterations; i++ {
 		if a.logger != nil {
 			a.logger.ThinkingProcess("LLM调用", fmt.Sprintf("迭代 %d/%d", i+1, maxIterations))
 		}
 
 		// 调用LLM（带工具）
-		response, err := a.llmClient.Chat(ctx, messages, tools, "auto")
+		response, err := a.chatWithTools(ctx, messages, tools, onChunk)
+		var rejected *llm.ToolCallRejectedError
+		if errors.As(err, &rejected) {
+			// 工具调用在接收过程中被提前拒绝，提示模型修正后重试
+			onChunk(ui.Sprintf("\n❌ %v，正在重试\n", rejected))
+			if a.logger != nil {
+				a.logger.ThinkingProcess("工具调用被拒绝", rejected.Error())
+			}
+			messages = append(messages, llm.Message{
+				Role:    "user",
+				Content: fmt.Sprintf("你刚才的工具调用无效：%v。请检查工具名称和必填参数后重新调用。", rejected),
+			})
+			continue
+		}
 		if err != nil {
+			// 上下文超限时压缩较早的工具结果后重试，不计入迭代次数
+			if llm.IsContextOverflow(err) {
+				var ok bool
+				if messages, ok = a.recoverContextOverflow(messages, task, &compaction, onChunk); ok {
+					i--
+					continue
+				}
+				return "", fmt.Errorf("上下文超出模型限制且无法继续压缩，请使用 /new 开始新对话: %w", err)
+			}
+			// 模型拒绝tools字段时，改用文本形式的工具调用
+			if i == 0 && llm.IsToolsUnsupported(err) {
+				a.markNoFunctionCalling(a.llmClient.Model)
+				onChunk(ui.Text("\n⚠️ 当前模型不支持原生函数调用，改用文本工具调用模式\n"))
+				return a.executeReAct(ctx, messages, onChunk)
+			}
 			return "", fmt.Errorf("LLM调用失败: %w", err)
 		}
 
@@ -134,9 +301,16 @@ func (a *Agent) executeWithDAGStream(ctx context.Context, userInput, intention s
 
 		// 如果没有工具调用，说明LLM给出了最终答案
 		if len(choice.Message.ToolCalls) == 0 {
+			a.clearCheckpoint()
+
+			// 流式模式下内容已经在接收过程中输出
+			if a.streamToolCalls() {
+				return choice.Message.Content, nil
+			}
+
 			// 流式输出最终答案
 			if a.logger != nil {
-				fmt.Printf("\n🤖 Agent: ")
+				ui.Printf("\n🤖 Agent: ")
 			}
 
 			// 直接输出内容（因为已经从Chat获取了完整响应）
@@ -154,93 +328,221 @@ func (a *Agent) executeWithDAGStream(ctx context.Context, userInput, intention s
 			a.logger.ThinkingProcess("工具调用", fmt.Sprintf("需要执行 %d 个工具", len(choice.Message.ToolCalls)))
 		}
 
+		// 演练模式下只展示计划的工具调用
+		if a.dryRun {
+			return a.dryRunReport(choice.Message.ToolCalls, onChunk)
+		}
+
+		// 检测是否陷入循环（反复相同调用或来回切换）
+		if err := loops.observeCalls(choice.Message.ToolCalls); err != nil {
+			return "", a.stopLoop(err)
+		}
+
 		// 将助手的消息（包含工具调用）添加到历史
 		messages = append(messages, llm.Message{
 			Role:      "assistant",
 			Content:   choice.Message.Content,
 			ToolCalls: choice.Message.ToolCalls,
 		})
+		a.saveCheckpoint(userInput, messages, task, i)
 
-		// 执行每个工具调用
-		for _, toolCall := range choice.Message.ToolCalls {
-			if toolCall.Type != "function" {
-				continue
+		// 按批次执行工具调用（相邻的只读调用并发执行），结果按调用顺序加入消息，每完成一批就保存检查点
+		for _, batch := range a.toolBatches(choice.Message.ToolCalls) {
+			results, err := a.executeToolBatch(ctx, batch, onChunk)
+			if err != nil {
+				return "", err
+			}
+			for j, result := range results {
+				messages = append(messages, result.message)
+				if !result.ok {
+					if err := loops.observeError(batch[j].Function.Name, result.message.Content); err != nil {
+						return "", a.stopLoop(err)
+					}
+				}
 			}
+			a.saveCheckpoint(userInput, messages, task, i)
+		}
 
-			funcName := toolCall.Function.Name
-			funcArgs := toolCall.Function.Arguments
+		onChunk("\n")
+	}
 
-			if a.logger != nil {
-				onChunk(fmt.Sprintf("\n⚙️ 执行工具: %s\n", funcName))
-				a.logger.ThinkingProcess("执行工具", fmt.Sprintf("%s(%s)", funcName, funcArgs))
-			} else {
-				onChunk(fmt.Sprintf("\n⚙️ 执行工具: %s\n", funcName))
-			}
+	return "", fmt.Errorf("达到最大迭代次数 (%d)，任务未完成", maxIterations)
+}
 
-			// 解析参数
-			var params map[string]interface{}
-			if err := json.Unmarshal([]byte(funcArgs), &params); err != nil {
-				errMsg := fmt.Sprintf("参数解析失败: %v", err)
-				onChunk(fmt.Sprintf("❌ %s\n", errMsg))
-
-				// 将错误结果添加到消息历史
-				messages = append(messages, llm.Message{
-					Role:       "tool",
-					Content:    errMsg,
-					ToolCallID: toolCall.ID,
-				})
-				continue
-			}
+// stopLoop 检测到循环时终止本轮：记录日志并删除检查点（恢复只会再次进入同一循环）
+func (a *Agent) stopLoop(err error) error {
+	if a.logger != nil {
+		a.logger.Error("检测到函数调用循环", err, nil)
+	}
+	a.clearCheckpoint()
+	return err
+}
 
-			// 获取并执行工具
-			tool, err := a.toolRegistry.Get(funcName)
-			if err != nil {
-				errMsg := fmt.Sprintf("工具不存在: %v", err)
-				onChunk(fmt.Sprintf("❌ %s\n", errMsg))
-
-				messages = append(messages, llm.Message{
-					Role:       "tool",
-					Content:    errMsg,
-					ToolCallID: toolCall.ID,
-				})
-				continue
-			}
+// executeToolCall 执行单个工具调用，返回工具结果消息以及工具是否执行成功（预算超出时返回错误以终止本轮）
+func (a *Agent) executeToolCall(ctx context.Context, toolCall llm.ToolCall, onChunk func(string) error) (llm.Message, bool, error) {
+	content, ok, err := a.runToolCall(ctx, toolCall, onChunk)
+	if err != nil {
+		return llm.Message{}, false, err
+	}
+	return a.toolResultMessage(toolCall, content), ok, nil
+}
 
-			// 执行工具
-			result, err := tool.Execute(ctx, params)
-			a.recordToolCallContext(funcName, params, result, err)
-			if err != nil {
-				errMsg := fmt.Sprintf("执行失败: %v", err)
-				onChunk(fmt.Sprintf("❌ %s\n", errMsg))
-
-				messages = append(messages, llm.Message{
-					Role:       "tool",
-					Content:    errMsg,
-					ToolCallID: toolCall.ID,
-				})
-				continue
-			}
+// toolResultMessage 工具结果消息，开启引用时带上调用编号
+func (a *Agent) toolResultMessage(toolCall llm.ToolCall, content string) llm.Message {
+	return llm.Message{
+		Role:       "tool",
+		Content:    a.citeLabel(toolCall.Function.Name) + content,
+		ToolCallID: toolCall.ID,
+	}
+}
+
+// runToolCall 解析参数并执行工具，返回结果内容（失败时为错误说明）以及工具是否执行成功
+func (a *Agent) runToolCall(ctx context.Context, toolCall llm.ToolCall, onChunk func(string) error) (string, bool, error) {
+	funcName := toolCall.Function.Name
+	funcArgs := toolCall.Function.Arguments
+
+	if a.logger != nil {
+		onChunk(ui.Sprintf("\n⚙️ 执行工具: %s\n", funcName))
+		a.logger.ThinkingProcess("执行工具", fmt.Sprintf("%s(%s)", funcName, funcArgs))
+	} else {
+		onChunk(ui.Sprintf("\n⚙️ 执行工具: %s\n", funcName))
+	}
+
+	// 解析参数
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(funcArgs), &params); err != nil {
+		errMsg := fmt.Sprintf("参数解析失败: %v", err)
+		onChunk(ui.Sprintf("❌ %s\n", errMsg))
+		return errMsg, false, nil
+	}
+
+	// 获取并执行工具
+	tool, err := a.toolRegistry.Get(funcName)
+	if err != nil {
+		errMsg := fmt.Sprintf("工具不存在: %v", err)
+		onChunk(ui.Sprintf("❌ %s\n", errMsg))
+		return errMsg, false, nil
+	}
+
+	// 检查工具调用预算，超出时终止本轮
+	if a.usage != nil {
+		if err := a.usage.CheckToolCall(); err != nil {
+			return "", false, err
+		}
+		a.usage.RecordToolCall()
+	}
+
+	// 执行工具
+	result, err := a.invokeTool(ctx, tool, params)
+	if err != nil {
+		errMsg := fmt.Sprintf("执行失败: %v", err)
+		onChunk(ui.Sprintf("❌ %s\n", errMsg))
+		return errMsg, false, nil
+	}
+
+	// 格式化结果
+	resultJSON, _ := json.Marshal(result)
+	resultStr := string(resultJSON)
+
+	onChunk(ui.Sprintf("✅ 执行成功\n"))
+
+	if a.logger != nil {
+		a.logger.ThinkingProcess("工具结果", resultStr)
+	}
+
+	return resultStr, true, nil
+}
+
+// invokeTool 执行工具并记录上下文、审计日志和产物
+// 本轮已用相同参数成功执行过的调用直接返回上次结果（除非设置了repeat参数）
+func (a *Agent) invokeTool(ctx context.Context, tool tools.Tool, params map[string]interface{}) (interface{}, error) {
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	repeat := popRepeatFlag(params)
+	params = a.expandParams(params)
+	a.applyWorkdir(tool, params)
+
+	// 演练模式下不执行任何工具（工具循环会在此之前输出计划，这里兜底其他执行路径）
+	if a.dryRun {
+		return nil, fmt.Errorf("演练模式下不执行工具 %s", tool.Name())
+	}
+
+	key := callKey(tool.Name(), params)
+	if previous, ok := a.calls.get(key); ok && !repeat {
+		if a.logger != nil {
+			a.logger.ThinkingProcess("跳过重复调用", tool.Name())
+		}
+		a.recordToolCall(tool.Name(), params, time.Now(), true, nil)
+		return dedupedResult(tool, previous), nil
+	}
+
+	started := time.Now()
+	if err := a.confirmCommand(ctx, tool.Name(), params); err != nil {
+		a.auditToolCall(tool.Name(), params, nil, err)
+		a.recordToolCall(tool.Name(), params, started, false, err)
+		return nil, err
+	}
 
-			// 格式化结果
-			resultJSON, _ := json.Marshal(result)
-			resultStr := string(resultJSON)
+	started = time.Now()
+	result, err := tool.Execute(tools.WithEnv(ctx, a.env), params)
+	a.telemetry.RecordTool(tool.Name(), time.Since(started), err)
+	a.recordToolCall(tool.Name(), params, started, false, err)
+	a.recordToolCallContext(tool.Name(), params, result, err)
+	a.auditToolCall(tool.Name(), params, result, err)
+	a.eventLog.RecordTool(tool.Name(), params, result, started, err)
+	if err != nil {
+		return result, err
+	}
+	a.noteToolResult(tool.Name(), params, result)
 
-			onChunk(fmt.Sprintf("✅ 执行成功\n"))
+	result = a.summarizeCommandOutput(tool.Name(), params, result)
+	a.calls.put(key, result, tools.IsReadOnly(tool))
+	a.recordArtifacts(tool, params, result)
+	return a.dedupeFileResult(tool.Name(), result), nil
+}
+
+// streamToolCalls 是否以流式方式接收工具调用
+func (a *Agent) streamToolCalls() bool {
+	return a.config.API.StreamToolCalls
+}
 
+// chatWithTools 调用带工具的LLM：流式模式下实时输出内容，并在参数接收过程中提前校验工具调用
+func (a *Agent) chatWithTools(ctx context.Context, messages []llm.Message, openAITools []llm.Tool, onChunk func(string) error) (*llm.ChatResponse, error) {
+	if !a.streamToolCalls() {
+		return a.llmClient.Chat(ctx, messages, openAITools, "auto")
+	}
+
+	started := false
+	return a.llmClient.ChatStreamValidated(ctx, messages, openAITools, "auto", func(content string) error {
+		if !started {
+			started = true
 			if a.logger != nil {
-				a.logger.ThinkingProcess("工具结果", resultStr)
+				ui.Printf("\n🤖 Agent: ")
 			}
-
-			// 将工具结果添加到消息历史
-			messages = append(messages, llm.Message{
-				Role:       "tool",
-				Content:    resultStr,
-				ToolCallID: toolCall.ID,
-			})
 		}
+		return onChunk(content)
+	}, a.validateToolCall)
+}
 
-		onChunk("\n")
+// validateToolCall 校验工具调用：工具名到达后检查工具是否存在，参数完整后检查JSON格式和必填参数
+func (a *Agent) validateToolCall(call llm.ToolCall, complete bool) error {
+	tool, err := a.toolRegistry.Get(call.Function.Name)
+	if err != nil {
+		return err
+	}
+	if !complete {
+		return nil
 	}
 
-	return "", fmt.Errorf("达到最大迭代次数 (%d)，任务未完成", maxIterations)
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
+		return fmt.Errorf("参数不是有效的JSON对象: %w", err)
+	}
+	for _, name := range tools.RequiredParams(tool) {
+		if value, ok := params[name]; !ok || value == nil || value == "" {
+			return fmt.Errorf("缺少必填参数 %s", name)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"agentcli/internal/config"
+	"agentcli/internal/llm"
+)
+
+// 重新执行记录的请求时使用的模型同样受组织策略限制，不允许时不发送请求
+func TestRerunCallChecksOrgPolicy(t *testing.T) {
+	cfg := &config.Config{Policy: &config.OrgPolicy{Models: []string{"gpt-4o"}}}
+	cfg.API.Model = "gpt-4o"
+	cfg.API.BaseURL = "http://127.0.0.1:0" // 不应发出请求
+	a := NewAgent(cfg, nil)
+
+	record := llm.CallRecord{Messages: []llm.Message{{Role: "user", Content: "hi"}}}
+	result, err := a.RerunCall(context.Background(), record, "o1")
+	if err == nil || !strings.Contains(err.Error(), "组织策略") {
+		t.Fatalf("RerunCall error = %v, want 组织策略拒绝", err)
+	}
+	if result.Model != "o1" || result.Usage != nil {
+		t.Fatalf("result = %+v", result)
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"agentcli/internal/history"
+	"agentcli/internal/llm"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 生成项目总结时对话记录的长度限制：单条消息和总字符数，超出时优先保留最早的目标和最近的进展
+const (
+	summaryMessageChars    = 3000
+	summaryTranscriptChars = 60000
+)
+
+// SummarizeProject 为已完成的项目对话生成总结（目标、关键决策、产出文件、后续事项），用于归档
+func (a *Agent) SummarizeProject(ctx context.Context, conv *history.Conversation) (string, error) {
+	var files strings.Builder
+	for _, art := range conv.Artifacts {
+		fmt.Fprintf(&files, "- %s（%s，%s）\n", art.Path, art.Tool, art.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	if files.Len() == 0 {
+		files.WriteString("（没有记录到生成的文件）\n")
+	}
+
+	prompt := fmt.Sprintf(`以下是一个已经结束的项目对话，请为它写一份结项总结，供以后回顾时快速了解来龙去脉。
+使用Markdown，严格按以下四节输出，不要添加其他开场白：
+
+## 目标
+用户最初想完成什么，过程中目标有何变化。
+
+## 关键决策
+做出的重要技术选择及原因，包括放弃的方案。
+
+## 产出文件
+列出生成或修改的文件及其作用（参考下方的文件清单，对话中提到的其他改动也列出）。
+
+## 后续事项
+尚未完成的工作、已知问题和建议的下一步；没有则写“无”。
+
+对话中记录的生成文件:
+%s
+对话记录:
+%s`, files.String(), projectTranscript(conv.Messages))
+
+	messages := []llm.Message{
+		{Role: "system", Content: "你是一名严谨的技术文档撰写者，只根据对话记录中的事实写总结，不编造内容。"},
+		{Role: "user", Content: prompt},
+	}
+	resp, err := a.llmClient.Chat(ctx, messages, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("生成项目总结失败: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("生成项目总结失败: 响应中没有消息")
+	}
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("生成项目总结失败: 模型返回了空内容")
+	}
+	return summary, nil
+}
+
+// projectTranscript 将对话整理为文本记录，总长度超出限制时省略中间部分
+func projectTranscript(messages []history.Message) string {
+	var entries []string
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		role := map[string]string{"user": "用户", "assistant": "助手", "tool": "工具"}[msg.Role]
+		if role == "" {
+			role = msg.Role
+		}
+		if msg.Role == "tool" && msg.ToolName != "" {
+			role += " " + msg.ToolName
+		}
+		if runes := []rune(content); len(runes) > summaryMessageChars {
+			content = string(runes[:summaryMessageChars]) + "\n...（已截断）"
+		}
+		entries = append(entries, fmt.Sprintf("[%s] %s\n%s", role, msg.Timestamp.Format("2006-01-02 15:04"), content))
+	}
+
+	// 保留开头的若干条（最初的目标）和尽可能多的最近消息
+	total := 0
+	for _, entry := range entries {
+		total += len([]rune(entry))
+	}
+	if total <= summaryTranscriptChars {
+		return strings.Join(entries, "\n\n")
+	}
+	head := min(2, len(entries))
+	budget := summaryTranscriptChars
+	for _, entry := range entries[:head] {
+		budget -= len([]rune(entry))
+	}
+	tail := len(entries)
+	for tail > head && budget-len([]rune(entries[tail-1])) >= 0 {
+		budget -= len([]rune(entries[tail-1]))
+		tail--
+	}
+	kept := append([]string(nil), entries[:head]...)
+	kept = append(kept, fmt.Sprintf("...（省略中间的 %d 条消息）", tail-head))
+	kept = append(kept, entries[tail:]...)
+	return strings.Join(kept, "\n\n")
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"agentcli/internal/history"
+	"agentcli/internal/tools"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// recordArtifacts 记录工具生成的文件（路径、哈希和产生它的工具调用）
+func (a *Agent) recordArtifacts(tool tools.Tool, params map[string]interface{}, result interface{}) {
+	producer, ok := tool.(tools.ArtifactProducer)
+	if !ok {
+		return
+	}
+
+	argsJSON, _ := json.Marshal(params)
+	for _, path := range producer.ProducedFiles(params, result) {
+		a.recordArtifactFile(tool.Name(), string(argsJSON), path)
+	}
+}
+
+// recordArtifactFile 记录单个产物文件
+func (a *Agent) recordArtifactFile(toolName, toolArgs, path string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	hash, size, err := hashFile(absPath)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("记录产物失败", err, map[string]interface{}{"path": absPath})
+		}
+		return
+	}
+
+	a.artifactMu.Lock()
+	a.artifacts = append(a.artifacts, history.Artifact{
+		Path:      absPath,
+		SHA256:    hash,
+		Size:      size,
+		Tool:      toolName,
+		ToolArgs:  toolArgs,
+		CreatedAt: time.Now(),
+	})
+	a.artifactMu.Unlock()
+}
+
+// ConsumeArtifacts 取出本轮记录的产物
+func (a *Agent) ConsumeArtifacts() []history.Artifact {
+	a.artifactMu.Lock()
+	defer a.artifactMu.Unlock()
+	artifacts := a.artifacts
+	a.artifacts = nil
+	return artifacts
+}
+
+// hashFile 计算文件的SHA256和大小
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
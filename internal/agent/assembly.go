@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultIntentWindow       = 6    // 意图分析阶段保留的最近消息数
+	defaultIntentSummaryChars = 2000 // 较早对话摘要的最大字符数
+	intentMessageMaxChars     = 4000 // 最近窗口内单条消息的最大字符数
+	summarySnippetChars       = 150  // 摘要中每条较早消息保留的字符数
+)
+
+// contextAssembler 上下文组装器：记录本轮已提供给模型的文件内容，避免在不同阶段重复发送
+type contextAssembler struct {
+	mu    sync.Mutex
+	files map[string]string // 绝对路径 -> 内容哈希
+}
+
+// reset 开始新一轮对话时清空记录
+func (c *contextAssembler) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = nil
+}
+
+// provide 记录文件内容已提供给模型，若相同内容此前已提供过则返回false
+func (c *contextAssembler) provide(path, content string) bool {
+	key := normalizeFilePath(path)
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = make(map[string]string)
+	}
+	if c.files[key] == hash {
+		return false
+	}
+	c.files[key] = hash
+	return true
+}
+
+func normalizeFilePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// intentHistory 为意图分析阶段组装对话历史：只保留最近的消息窗口，更早的消息压缩为摘要
+func (a *Agent) intentHistory(conversationHistory []llm.Message) []llm.Message {
+	window := a.config.Context.IntentWindow
+	if window <= 0 {
+		window = defaultIntentWindow
+	}
+	summaryChars := a.contextBudget(a.config.Context.IntentSummaryChars, defaultIntentSummaryChars)
+
+	if len(conversationHistory) <= window {
+		return truncateMessages(conversationHistory)
+	}
+
+	// 不拆开工具调用与其结果：窗口以工具消息开头时向前包含发起调用的助手消息
+	split := len(conversationHistory) - window
+	for split > 0 && conversationHistory[split].Role == "tool" {
+		split--
+	}
+	older := conversationHistory[:split]
+	recent := conversationHistory[split:]
+
+	// 固定的消息完整保留，其余较早的消息压缩为摘要
+	var pinned []llm.Message
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("较早的对话摘要（共 %d 条消息，仅保留开头片段）：\n", len(older)))
+	full := false
+	for _, msg := range older {
+		if msg.Pinned {
+			pinned = append(pinned, msg)
+			continue
+		}
+		if full {
+			continue
+		}
+		line := fmt.Sprintf("- %s: %s\n", msg.Role, snippet(msg.Content, summarySnippetChars))
+		if sb.Len()+len(line) > summaryChars {
+			sb.WriteString("- ...\n")
+			full = true
+			continue
+		}
+		sb.WriteString(line)
+	}
+
+	messages := []llm.Message{{Role: "system", Content: sb.String()}}
+	messages = append(messages, pinned...)
+	return append(messages, truncateMessages(recent)...)
+}
+
+// truncateMessages 截断过长的消息，避免意图分析阶段上下文过大
+func truncateMessages(messages []llm.Message) []llm.Message {
+	result := make([]llm.Message, 0, len(messages))
+	for _, msg := range messages {
+		if !msg.Pinned && len(msg.Content) > intentMessageMaxChars {
+			msg.Content = msg.Content[:intentMessageMaxChars] + "\n... (消息过长，已截断)"
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+// snippet 取文本开头的片段（按字符截断，压缩空白）
+func snippet(text string, maxChars int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars]) + "..."
+}
+
+// dedupeFileResult 若read_file读取的内容在本轮已提供给模型，则用简短说明替换，避免重复占用上下文
+func (a *Agent) dedupeFileResult(toolName string, result interface{}) interface{} {
+	if toolName != "read_file" {
+		return result
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return result
+	}
+	path, _ := resultMap["filepath"].(string)
+	content, ok := resultMap["content"].(string)
+	if path == "" || !ok {
+		return result
+	}
+
+	if a.assembler.provide(path, content) {
+		return result
+	}
+
+	deduped := make(map[string]interface{}, len(resultMap))
+	for k, v := range resultMap {
+		deduped[k] = v
+	}
+	deduped["content"] = "(文件内容未变化，已在前面的上下文中提供，此处省略)"
+	return deduped
+}
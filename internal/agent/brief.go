@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// turnBrief 每轮意图分析的结构化摘要，代替原始的分析文本和文件全文写入当前任务；
+// 文件内容由模型按需通过工具读取，避免与工具循环重复读取
+type turnBrief struct {
+	Intent      string
+	Files       []string // 候选文件
+	Images      []string // 候选图片
+	Constraints []string // 用户提出的限制和要求
+	Notes       []string // 文件模糊匹配等提示
+	Context     string   // 无法通过工具获取、需要直接附带的内容（检索到的文档、无工具时预读取的文件）
+}
+
+// String 按固定结构输出摘要
+func (b *turnBrief) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "意图：%s", b.Intent)
+	if len(b.Files) > 0 {
+		sb.WriteString("\n候选文件（内容未附带，需要时通过工具读取，不要凭猜测回答文件内容）：")
+		for _, f := range b.Files {
+			sb.WriteString("\n  - " + describeFile(f))
+		}
+	}
+	if len(b.Images) > 0 {
+		sb.WriteString("\n候选图片：" + strings.Join(b.Images, ", "))
+	}
+	if len(b.Constraints) > 0 {
+		sb.WriteString("\n约束：")
+		for _, c := range b.Constraints {
+			sb.WriteString("\n  - " + c)
+		}
+	}
+	if len(b.Notes) > 0 {
+		sb.WriteString("\n提示：")
+		for _, note := range b.Notes {
+			sb.WriteString("\n  - " + note)
+		}
+	}
+	sb.WriteString(b.Context)
+	return sb.String()
+}
+
+// describeFile 文件路径及大小，文件不存在时注明
+func describeFile(path string) string {
+	if strings.ContainsAny(path, "*?[") {
+		return path + "（通配符，可用 list_files 匹配）"
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return path + "（不存在）"
+	case info.IsDir():
+		return path + "/（目录）"
+	case info.Size() >= 1024:
+		return fmt.Sprintf("%s（%.1fKB）", path, float64(info.Size())/1024)
+	default:
+		return fmt.Sprintf("%s（%dB）", path, info.Size())
+	}
+}
+
+// cleanList 去掉空白项和重复项
+func cleanList(items []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			cleaned = append(cleaned, item)
+		}
+	}
+	return cleaned
+}
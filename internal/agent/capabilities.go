@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DescribeCapabilities 描述Agent当前的能力：模型、可用工具及参数、工作区、权限策略和记忆概况
+func (a *Agent) DescribeCapabilities(sandboxEnabled bool) string {
+	var sb strings.Builder
+	onOff := func(enabled bool) string {
+		if enabled {
+			return "开启"
+		}
+		return "关闭"
+	}
+
+	// 模型
+	sb.WriteString(ui.Text("🤖 模型\n"))
+	fmt.Fprintf(&sb, "  名称: %s\n", a.llmClient.Model)
+	if a.supportsFunctionCalling() {
+		sb.WriteString("  工具调用: 原生函数调用\n")
+	} else {
+		sb.WriteString("  工具调用: 文本工具调用（ReAct）\n")
+	}
+	fmt.Fprintf(&sb, "  本地模型模式: %s\n", onOff(a.local))
+	fmt.Fprintf(&sb, "  流式工具调用: %s\n", onOff(a.streamToolCalls()))
+	if a.config.Response.MaxTokens > 0 {
+		fmt.Fprintf(&sb, "  单次回答上限: %d tokens\n", a.config.Response.MaxTokens)
+	}
+
+	// 工具
+	registered := a.toolRegistry.List()
+	sort.Slice(registered, func(i, j int) bool { return registered[i].Name() < registered[j].Name() })
+	sb.WriteString(ui.Sprintf("\n🛠️  工具（%d 个）\n", len(registered)))
+	for _, tool := range registered {
+		mode := "有副作用"
+		if tools.IsReadOnly(tool) {
+			mode = "只读"
+		}
+		fmt.Fprintf(&sb, "  • %s [%s]\n    %s\n", tool.Name(), mode, tool.Description())
+
+		required := make(map[string]bool)
+		for _, name := range tools.RequiredParams(tool) {
+			required[name] = true
+		}
+		params := tool.GetParams()
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			flag := "可选"
+			if required[name] {
+				flag = "必填"
+			}
+			fmt.Fprintf(&sb, "      - %s (%s): %s\n", name, flag, params[name])
+		}
+	}
+
+	// 工作区
+	sb.WriteString(ui.Text("\n📁 工作区\n"))
+	if cwd, err := os.Getwd(); err == nil {
+		fmt.Fprintf(&sb, "  当前目录: %s\n", cwd)
+		if root := workspaceRoot(cwd); root != "" {
+			fmt.Fprintf(&sb, "  仓库根目录: %s\n", root)
+		}
+	}
+	fmt.Fprintf(&sb, "  系统: %s\n", a.osHint())
+
+	// 权限策略
+	cfg := a.config
+	sb.WriteString(ui.Text("\n🔐 权限策略\n"))
+	fmt.Fprintf(&sb, "  演练模式（只展示不执行）: %s\n", onOff(a.dryRun))
+	fmt.Fprintf(&sb, "  影子工作区（修改需确认）: %s\n", onOff(sandboxEnabled))
+	fmt.Fprintf(&sb, "  计划模式（代码修改需 /apply 确认）: %s\n", onOff(a.changes.Active()))
+	fmt.Fprintf(&sb, "  审计日志: %s\n", onOff(a.audit != nil))
+	if _, err := a.toolRegistry.Get("read_file"); err == nil {
+		fmt.Fprintf(&sb, "  read_file: 最大 %d MB，允许扩展名 %s\n", cfg.Tools.ReadFile.MaxSizeMB, listOrAny(cfg.Tools.ReadFile.AllowedExtensions))
+	}
+	if _, err := a.toolRegistry.Get("write_code"); err == nil {
+		fmt.Fprintf(&sb, "  write_code: 最多 %d 行，语言 %s\n", cfg.Tools.WriteCode.MaxLines, listOrAny(cfg.Tools.WriteCode.SupportedLanguages))
+	}
+	if _, err := a.toolRegistry.Get("write_file"); err == nil {
+		fmt.Fprintf(&sb, "  write_file: 最大 %d KB，不限文件类型\n", cfg.Tools.WriteFile.MaxSizeKB)
+	}
+	if _, err := a.toolRegistry.Get("execute_command"); err == nil {
+		policy := commandPolicy(cfg)
+		confirm := map[string]string{tools.ConfirmDestructive: "破坏性命令", tools.ConfirmAlways: "所有命令", tools.ConfirmNever: "关闭"}[policy.ConfirmMode()]
+		if a.autoApprove {
+			confirm += "（已被 --yes 跳过）"
+		}
+		if cfg.Policy != nil && cfg.Policy.RequireConfirmation != "" {
+			confirm += "（组织策略）"
+		}
+		fmt.Fprintf(&sb, "  execute_command: 单条命令超时 30 秒，允许 %s，禁止 %s，执行前确认 %s\n",
+			listOrAny(policy.Allowlist), listOrNone(policy.Denylist), confirm)
+	}
+	if _, err := a.toolRegistry.Get("browser"); err == nil {
+		fmt.Fprintf(&sb, "  browser: 允许域名 %s\n", listOrAny(cfg.Tools.Browser.AllowedDomains))
+	}
+	if limits := cfg.Budget.Session; limits.MaxTokens > 0 || limits.MaxCost > 0 || limits.MaxToolCalls > 0 {
+		fmt.Fprintf(&sb, "  会话预算: tokens %s，费用 %s，工具调用 %s\n",
+			limitString(float64(limits.MaxTokens), "%.0f"), limitString(limits.MaxCost, "$%.2f"), limitString(float64(limits.MaxToolCalls), "%.0f"))
+	}
+
+	// 组织策略
+	if policy := cfg.Policy; policy != nil {
+		sb.WriteString(ui.Sprintf("\n🏢 组织策略（%s，用户配置无法覆盖）\n", policy.Path))
+		for _, item := range policy.Restrictions() {
+			fmt.Fprintf(&sb, "  %s\n", item)
+		}
+	}
+
+	// 记忆
+	sb.WriteString(ui.Text("\n🧠 记忆\n"))
+	if a.memory != "" {
+		fmt.Fprintf(&sb, "  个人记忆: %s\n", snippet(a.memory, 80))
+	} else {
+		sb.WriteString("  个人记忆: 无\n")
+	}
+	if a.teamMemory != "" {
+		fmt.Fprintf(&sb, "  团队共享指令: %d 字（来源 %s）\n", len([]rune(a.teamMemory)), cfg.Team.Source)
+	}
+	if len(a.projectFiles) > 0 {
+		fmt.Fprintf(&sb, "  项目指令文件: %s\n", strings.Join(a.projectFiles, ", "))
+	}
+	if len(a.variables) > 0 {
+		fmt.Fprintf(&sb, "  对话变量: %d 个\n", len(a.variables))
+	}
+
+	return sb.String()
+}
+
+// listOrAny 格式化允许列表，为空时表示不限制
+func listOrAny(items []string) string {
+	if len(items) == 0 {
+		return "不限"
+	}
+	return strings.Join(items, ", ")
+}
+
+// listOrNone 格式化名单，空名单显示为“无”
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "无"
+	}
+	return strings.Join(items, ", ")
+}
+
+// limitString 格式化预算限额，0表示不限制
+func limitString(value float64, format string) string {
+	if value <= 0 {
+		return "不限"
+	}
+	return fmt.Sprintf(format, value)
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// chatPathMaxChars 走闲聊快速路径的最大输入长度，更长的输入通常是具体任务
+const chatPathMaxChars = 200
+
+// chatEscalateMarker 闲聊快速路径下模型认为需要工具时输出的标记，检测到后转入完整流程
+const chatEscalateMarker = "[[NEED_TOOLS]]"
+
+// errChatEscalate 模型输出了升级标记，中止流式回答
+var errChatEscalate = errors.New("需要使用工具")
+
+// taskKeywords 输入中出现这些词时通常需要操作文件、命令或查询最新信息，不走闲聊快速路径
+var taskKeywords = []string{
+	"文件", "目录", "项目", "仓库", "代码库", "当前", "这个", "最新", "今天", "现在",
+	"写", "创建", "新建", "修改", "删除", "运行", "执行", "安装", "读取", "打开", "搜索", "查找", "下载", "部署", "提交", "测试", "修复", "重构", "生成",
+	"file", "folder", "directory", "repo", "project", "this ", "my ", "latest", "today", "current",
+	"write", "create", "edit", "modify", "delete", "remove", "run ", "execute", "install", "read ", "open ", "search", "find ", "download", "deploy", "commit", "fix ", "refactor", "generate",
+}
+
+// isChatOnly 判断输入是否为闲聊或只凭通用知识即可回答的问题（先用启发式规则排除，再由分类模型判断）
+func (a *Agent) isChatOnly(ctx context.Context, userInput string, conversationHistory []llm.Message) bool {
+	if !a.config.Intent.ChatPath || a.consensus || a.dryRun {
+		return false
+	}
+
+	text := strings.ToLower(strings.TrimSpace(userInput))
+	if text == "" || utf8.RuneCountInString(text) > chatPathMaxChars || fileReferencePattern.MatchString(text) {
+		return false
+	}
+	for _, keyword := range taskKeywords {
+		if strings.Contains(text+" ", keyword) {
+			return false
+		}
+	}
+	return a.classifyChat(ctx, userInput, conversationHistory)
+}
+
+// classifyChat 由分类模型（未配置时使用当前模型）判断输入是否只需直接回答
+func (a *Agent) classifyChat(ctx context.Context, userInput string, conversationHistory []llm.Message) bool {
+	lastAssistant := ""
+	for i := len(conversationHistory) - 1; i >= 0; i-- {
+		if conversationHistory[i].Role == "assistant" {
+			lastAssistant = snippet(conversationHistory[i].Content, 500)
+			break
+		}
+	}
+
+	prompt := `判断用户的最新输入能否只凭通用知识直接回答（闲聊、概念解释、一般性的技术问题），不需要读取或修改文件、执行命令、搜索网页或查看用户的项目和环境。
+只回答 CHAT 或 TASK。
+
+助手上一轮回答：` + lastAssistant + `
+
+用户最新输入：` + userInput
+
+	model := a.config.Intent.ClassifierModel
+	if model == "" {
+		model = a.llmClient.Model
+	}
+	classifier := a.llmClient.WithModel(model)
+	classifier.MaxContinuations = 0
+	answer, err := classifier.SimpleQuery(ctx, prompt)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("闲聊分类失败", err, nil)
+		}
+		return false
+	}
+	answer = strings.ToUpper(answer)
+	return strings.Contains(answer, "CHAT") && !strings.Contains(answer, "TASK")
+}
+
+// answerChatOnly 不带工具、一次流式调用直接回答；模型输出升级标记时返回 escalated=true，由调用方转入完整流程
+func (a *Agent) answerChatOnly(ctx context.Context, userInput string, conversationHistory []llm.Message, onChunk func(string) error) (answer string, escalated bool, err error) {
+	systemPrompt := "你是一个智能助手。"
+	if memory := a.memoryPrompt(); memory != "" {
+		systemPrompt = memory
+	}
+	systemPrompt += "\n当前系统：" + a.osHint() + "。\n" + a.verbosityHint()
+	systemPrompt += "\n\n本轮没有提供工具，请直接回答。如果回答必须读取或修改文件、执行命令、查询最新信息或查看用户的项目，不要猜测，只输出 " + chatEscalateMarker + "。"
+
+	messages := []llm.Message{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, conversationHistory...)
+	messages = append(messages, llm.Message{Role: "user", Content: userInput})
+
+	// 开头的内容可能是升级标记，确认不是之后再输出
+	var pending strings.Builder
+	started := false
+	flush := func(content string) error {
+		if !started {
+			started = true
+			ui.Printf("\n🤖 Agent: ")
+		}
+		return onChunk(content)
+	}
+	answer, err = a.llmClient.ChatStream(ctx, messages, func(content string) error {
+		if started {
+			return onChunk(content)
+		}
+		pending.WriteString(content)
+		head := strings.TrimLeft(pending.String(), " \t\r\n")
+		switch {
+		case strings.HasPrefix(head, chatEscalateMarker):
+			return errChatEscalate
+		case strings.HasPrefix(chatEscalateMarker, head):
+			return nil
+		}
+		return flush(pending.String())
+	})
+	if errors.Is(err, errChatEscalate) {
+		return "", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if !started && pending.Len() > 0 {
+		if err := flush(pending.String()); err != nil {
+			return "", false, err
+		}
+	}
+	return answer, false, nil
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"agentcli/internal/fsutil"
+	"agentcli/internal/history"
+	"agentcli/internal/llm"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// turnCheckpoint 进行中轮次的函数调用消息列表
+type turnCheckpoint struct {
+	UserInput string        `json:"user_input"`
+	Model     string        `json:"model"`
+	Iteration int           `json:"iteration"`
+	Task      int           `json:"task,omitempty"` // 当前任务消息在 Messages 中的位置，之后的消息属于本轮
+	Messages  []llm.Message `json:"messages"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// SetCheckpointFile 设置当前对话的轮次检查点文件（为空时不保存检查点）
+func (a *Agent) SetCheckpointFile(path string) {
+	a.checkpointFile = path
+}
+
+// saveCheckpoint 保存当前轮次的消息列表，task 为当前任务消息的位置
+func (a *Agent) saveCheckpoint(userInput string, messages []llm.Message, task, iteration int) {
+	a.turnMessages = append([]llm.Message(nil), messages[task+1:]...)
+	if a.checkpointFile == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(turnCheckpoint{
+		UserInput: userInput,
+		Model:     a.llmClient.Model,
+		Iteration: iteration,
+		Task:      task,
+		Messages:  messages,
+		UpdatedAt: time.Now(),
+	}, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(a.checkpointFile), 0755); err == nil {
+			err = fsutil.WriteFileAtomic(a.checkpointFile, data, 0644)
+		}
+	}
+	if err != nil && a.logger != nil {
+		a.logger.Error("保存轮次检查点失败", err, nil)
+	}
+}
+
+// loadCheckpoint 读取轮次检查点
+func (a *Agent) loadCheckpoint() (*turnCheckpoint, error) {
+	if a.checkpointFile == "" {
+		return nil, os.ErrNotExist
+	}
+	var cp turnCheckpoint
+	if _, err := fsutil.ReadJSONWithBackup(a.checkpointFile, &cp); err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("解析轮次检查点失败: %w", err)
+	}
+	return &cp, nil
+}
+
+// clearCheckpoint 轮次完成后删除检查点
+func (a *Agent) clearCheckpoint() {
+	if a.checkpointFile != "" {
+		fsutil.RemoveWithBackup(a.checkpointFile)
+	}
+}
+
+// PendingTurn 返回未完成轮次的用户输入（没有可恢复的轮次时返回false）
+func (a *Agent) PendingTurn() (string, bool) {
+	cp, err := a.loadCheckpoint()
+	if err != nil || len(cp.Messages) == 0 {
+		return "", false
+	}
+	return cp.UserInput, true
+}
+
+// ConsumeTurnMessages 返回并清空本轮工具循环中的助手工具调用和工具结果（轮次失败时为已完成的部分），用于保存到对话
+func (a *Agent) ConsumeTurnMessages() []llm.Message {
+	messages := a.turnMessages
+	a.turnMessages = nil
+	return messages
+}
+
+// ResumeRequestStream 从检查点恢复未完成的轮次：补全最后一次助手消息中尚未执行的工具调用，然后继续函数调用循环
+// 已成功执行的工具不会重新执行
+func (a *Agent) ResumeRequestStream(ctx context.Context, onChunk func(string) error) (string, error) {
+	cp, err := a.loadCheckpoint()
+	if err != nil {
+		return "", fmt.Errorf("没有可恢复的轮次: %w", err)
+	}
+	return a.resumeTurn(ctx, cp, onChunk)
+}
+
+// ResumeSession 继续重新加载的对话中未完成的多步任务：有该对话的轮次检查点时从检查点恢复，
+// 否则根据对话中保存的工具调用和结果重建本轮的消息列表后继续
+func (a *Agent) ResumeSession(ctx context.Context, conv *history.Conversation, onChunk func(string) error) (string, error) {
+	if cp, err := a.loadCheckpoint(); err == nil && len(cp.Messages) > 0 {
+		return a.resumeTurn(ctx, cp, onChunk)
+	}
+
+	input, before, turn, ok := conv.UnfinishedTurn()
+	if !ok {
+		return "", fmt.Errorf("对话中没有未完成的任务")
+	}
+	messages := []llm.Message{{Role: "system", Content: a.systemPrompt()}}
+	messages = append(messages, before...)
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: "本轮摘要：\n该任务在之前的会话中未完成，以下是已执行的工具调用和结果，请在此基础上继续，不要重复已完成的步骤。\n\n用户请求：" + input,
+	})
+	task := len(messages) - 1
+	messages = append(messages, turn...)
+
+	iteration := -1
+	for _, msg := range turn {
+		if len(msg.ToolCalls) > 0 {
+			iteration++
+		}
+	}
+	return a.resumeTurn(ctx, &turnCheckpoint{
+		UserInput: input,
+		Model:     a.llmClient.Model,
+		Iteration: iteration,
+		Task:      task,
+		Messages:  messages,
+	}, onChunk)
+}
+
+// resumeTurn 从检查点继续轮次
+func (a *Agent) resumeTurn(ctx context.Context, cp *turnCheckpoint, onChunk func(string) error) (string, error) {
+	a.resetContextLog()
+	a.assembler.reset()
+	a.calls.reset()
+	a.citations.reset()
+	a.toolLog.reset()
+	if a.logger != nil {
+		a.logger.ThinkingProcess("恢复轮次", fmt.Sprintf("用户输入: %s, 从第 %d 次迭代继续", cp.UserInput, cp.Iteration+1))
+	}
+
+	messages := cp.Messages
+	// 旧版本的检查点没有记录任务位置
+	task := cp.Task
+	if task == 0 {
+		task = taskIndex(messages)
+	}
+	a.turnMessages = append([]llm.Message(nil), messages[task+1:]...)
+
+	// 找到最后一条带工具调用的助手消息，执行其中尚无结果的调用
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" && len(messages[i].ToolCalls) > 0 {
+			last = i
+			break
+		}
+	}
+	if last >= 0 {
+		done := make(map[string]bool)
+		for _, msg := range messages[last+1:] {
+			if msg.Role == "tool" {
+				done[msg.ToolCallID] = true
+			}
+		}
+		for _, toolCall := range messages[last].ToolCalls {
+			if toolCall.Type != "function" || done[toolCall.ID] {
+				continue
+			}
+			toolMessage, _, err := a.executeToolCall(ctx, toolCall, onChunk)
+			if err != nil {
+				return "", err
+			}
+			messages = append(messages, toolMessage)
+			a.saveCheckpoint(cp.UserInput, messages, task, cp.Iteration)
+		}
+	}
+
+	return a.runToolLoop(ctx, cp.UserInput, messages, a.convertToolsToOpenAIFormat(), cp.Iteration+1, onChunk)
+}
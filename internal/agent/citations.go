@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"agentcli/internal/ui"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// citationPattern 回答中的引用标记，如 [#3]
+var citationPattern = regexp.MustCompile(`\[#(\d+)\]`)
+
+// citationInstructions 引用格式说明（结构化回答格式）
+const citationInstructions = `回答格式要求（引用工具结果）：
+- 每个工具结果开头都带有编号标签，如 [#3 execute_command]。
+- 回答中凡是依据工具输出得出的结论，都在句末标注来源编号，如 "构建成功 [#3]"；多个来源写作 [#1][#4]。
+- 回答末尾添加"引用："一节，每行一个来源，格式为 "[#编号] 工具名：实际观察到的内容（简要）"。
+- 没有工具输出直接支持、属于你推断的结论，不要标注编号，并在"推断："一节中单独列出。`
+
+// citationLog 本轮工具调用编号
+type citationLog struct {
+	mu    sync.Mutex
+	tools []string
+}
+
+func (c *citationLog) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = nil
+}
+
+// add 记录一次工具调用，返回编号（从1开始）
+func (c *citationLog) add(toolName string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = append(c.tools, toolName)
+	return len(c.tools)
+}
+
+func (c *citationLog) lookup(n int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 1 || n > len(c.tools) {
+		return "", false
+	}
+	return c.tools[n-1], true
+}
+
+func (c *citationLog) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tools)
+}
+
+// citationsEnabled 是否要求回答引用工具结果
+func (a *Agent) citationsEnabled() bool {
+	return a.config.Response.Citations
+}
+
+// citeLabel 为工具结果生成编号标签（未开启引用时返回空字符串）
+func (a *Agent) citeLabel(toolName string) string {
+	if !a.citationsEnabled() {
+		return ""
+	}
+	return fmt.Sprintf("[#%d %s] ", a.citations.add(toolName), toolName)
+}
+
+// citationHint 引用格式说明（未开启引用时返回空字符串）
+func (a *Agent) citationHint() string {
+	if !a.citationsEnabled() {
+		return ""
+	}
+	return "\n" + citationInstructions
+}
+
+// citationFooter 核对回答中的引用是否对应本轮实际执行过的工具调用
+func (a *Agent) citationFooter(answer string) string {
+	if !a.citationsEnabled() || a.citations.count() == 0 {
+		return ""
+	}
+
+	seen := make(map[int]bool)
+	var checks []string
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		if tool, ok := a.citations.lookup(n); ok {
+			checks = append(checks, ui.Sprintf("✓ #%d %s", n, tool))
+		} else {
+			checks = append(checks, ui.Sprintf("✗ #%d (本轮没有该工具调用)", n))
+		}
+	}
+
+	if len(checks) == 0 {
+		return ui.Sprintf("\n\n📎 引用核对: 本轮执行了 %d 次工具调用，但回答未引用任何工具结果", a.citations.count())
+	}
+	return ui.Text("\n\n📎 引用核对: ") + strings.Join(checks, ", ")
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// compactedMarker 标记已被压缩的内容，避免重复压缩
+const compactedMarker = "（为节省上下文已压缩"
+
+// compactionLevel 上下文超限后的一级压缩策略
+type compactionLevel struct {
+	keepRecent int  // 保留最近N条工具结果不压缩
+	maxChars   int  // 每条被压缩的内容保留的字符数
+	history    bool // 是否同时压缩本轮之前的对话历史
+}
+
+// compactionLevels 逐级加大压缩力度，每次上下文超限使用下一级
+var compactionLevels = []compactionLevel{
+	{keepRecent: 2, maxChars: 800},
+	{keepRecent: 0, maxChars: 300, history: true},
+	{keepRecent: 0, maxChars: 80, history: true},
+}
+
+// taskIndex 返回当前任务消息（最后一条用户消息）的位置，之后的消息都是本轮工具循环产生的
+func taskIndex(messages []llm.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return len(messages) - 1
+}
+
+// recoverContextOverflow 上下文超出模型限制时按下一级策略压缩消息，返回压缩后的消息以及是否可以重试
+func (a *Agent) recoverContextOverflow(messages []llm.Message, task int, level *int, onChunk func(string) error) ([]llm.Message, bool) {
+	for *level < len(compactionLevels) {
+		compacted, saved := compactMessages(messages, task, compactionLevels[*level])
+		*level++
+		if saved == 0 {
+			continue
+		}
+
+		onChunk(ui.Sprintf("\n⚠️ 上下文超出模型限制，已压缩较早的工具结果（节省约 %d 字符）后重试\n", saved))
+		if a.logger != nil {
+			a.logger.ThinkingProcess("上下文压缩", fmt.Sprintf("级别 %d，节省 %d 字符", *level, saved))
+		}
+		return compacted, true
+	}
+	return messages, false
+}
+
+// compactMessages 将较早的工具结果替换为摘要，返回新的消息列表和节省的字符数
+// task之后的工具结果（原生工具消息或文本工具调用的观察结果）和工具调用参数会被压缩，
+// 按策略还可压缩task之前的对话历史；系统提示词、当前任务消息和用户固定的消息始终保留
+func compactMessages(messages []llm.Message, task int, level compactionLevel) ([]llm.Message, int) {
+	compacted := make([]llm.Message, len(messages))
+	copy(compacted, messages)
+
+	// 本轮的工具结果，最近的keepRecent条保持原样
+	var results []int
+	for i := task + 1; i < len(compacted); i++ {
+		if role := compacted[i].Role; role == "tool" || role == "function" || role == "user" {
+			results = append(results, i)
+		}
+	}
+	if len(results) > level.keepRecent {
+		results = results[:len(results)-level.keepRecent]
+	} else {
+		results = nil
+	}
+
+	saved := 0
+	for _, i := range results {
+		content, n := summarizeContent(compacted[i].Content, level.maxChars)
+		compacted[i].Content = content
+		saved += n
+	}
+
+	// 工具调用参数中较长的字符串（如写入的文件内容）
+	for i := task + 1; i < len(compacted); i++ {
+		if len(compacted[i].ToolCalls) == 0 {
+			continue
+		}
+		calls := make([]llm.ToolCall, len(compacted[i].ToolCalls))
+		copy(calls, compacted[i].ToolCalls)
+		for j := range calls {
+			args, n := compactArguments(calls[j].Function.Arguments, level.maxChars)
+			calls[j].Function.Arguments = args
+			saved += n
+		}
+		compacted[i].ToolCalls = calls
+	}
+
+	if level.history {
+		for i := 1; i < task && i < len(compacted); i++ {
+			if compacted[i].Role == "system" || compacted[i].Pinned {
+				continue
+			}
+			content, n := summarizeContent(compacted[i].Content, level.maxChars*2)
+			compacted[i].Content = content
+			saved += n
+		}
+	}
+
+	return compacted, saved
+}
+
+// summarizeContent 保留内容开头并注明原始规模，返回压缩后的内容和节省的字符数
+func summarizeContent(content string, maxChars int) (string, int) {
+	runes := []rune(content)
+	if len(runes) <= maxChars || strings.Contains(content, compactedMarker) {
+		return content, 0
+	}
+
+	lines := strings.Count(content, "\n") + 1
+	summary := fmt.Sprintf("%s\n...%s：原内容共 %d 行、%d 字符）", string(runes[:maxChars]), compactedMarker, lines, len(runes))
+	saved := len(runes) - len([]rune(summary))
+	if saved <= 0 {
+		return content, 0
+	}
+	return summary, saved
+}
+
+// compactArguments 截断工具调用参数中较长的字符串值，保持参数为合法JSON
+func compactArguments(arguments string, maxChars int) (string, int) {
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return arguments, 0
+	}
+
+	changed := false
+	for key, value := range params {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if summary, n := summarizeContent(s, maxChars); n > 0 {
+			params[key] = summary
+			changed = true
+		}
+	}
+	if !changed {
+		return arguments, 0
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return arguments, 0
+	}
+	return string(data), len([]rune(arguments)) - len([]rune(string(data)))
+}
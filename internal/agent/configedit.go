@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"agentcli/internal/config"
+	"fmt"
+)
+
+// ConfigApprover 代理修改自身配置前展示diff和修改理由，返回用户是否同意
+type ConfigApprover func(diff, reason string) bool
+
+// SetConfigApprover 设置代理修改配置前的确认方式；未设置时视为非交互模式，update_config 不会修改配置
+func (a *Agent) SetConfigApprover(approver ConfigApprover) {
+	a.configApprover = approver
+}
+
+// SetConfigOverride 设置重新加载配置时覆盖配置文件的设置（如用户偏好设置），保证其优先级不因配置修改而改变
+func (a *Agent) SetConfigOverride(override func(cfg *config.Config)) {
+	a.configOverride = override
+}
+
+// approveConfigEdit 请用户确认配置修改；--yes 只跳过命令确认，配置修改始终需要确认
+func (a *Agent) approveConfigEdit(diff, reason string) (bool, error) {
+	if a.configApprover == nil {
+		return false, fmt.Errorf("修改配置需要用户确认，非交互模式下不会修改")
+	}
+	// 任务图中并行的步骤逐个询问
+	a.confirmMu.Lock()
+	defer a.confirmMu.Unlock()
+	return a.configApprover(diff, reason), nil
+}
+
+// ApplyConfig 使写入配置文件的新配置在当前会话中生效：更新共享的配置，按新配置重新注册内置工具（MCP工具保留）
+// 会话中通过命令切换过的设置（/verbosity、/docs、/plan）只在对应配置项被修改时覆盖；
+// 长期记忆、终端输出风格等启动时初始化的配置在下次启动时生效
+func (a *Agent) ApplyConfig(cfg *config.Config) {
+	if a.configOverride != nil {
+		a.configOverride(cfg)
+	}
+	previous := *a.config
+	*a.config = *cfg
+
+	a.llmClient.MaxTokens = cfg.Response.MaxTokens
+	a.llmClient.MaxContinuations = cfg.Response.MaxContinuations
+	if a.llmClient.MaxContinuations <= 0 {
+		a.llmClient.MaxContinuations = 3
+	}
+	if previous.Response.Verbosity != cfg.Response.Verbosity {
+		a.verbosity = normalizeVerbosity(cfg.Response.Verbosity)
+	}
+	if previous.DocLookup.Enabled != cfg.DocLookup.Enabled {
+		a.docLookup = cfg.DocLookup.Enabled
+	}
+	if previous.Tools.WriteCode.RequireApproval != cfg.Tools.WriteCode.RequireApproval {
+		a.changes.SetActive(cfg.Tools.WriteCode.RequireApproval)
+	}
+
+	for _, name := range a.builtinTools {
+		a.toolRegistry.Unregister(name)
+	}
+	a.builtinTools = nil
+	a.registerTools()
+
+	if a.logger != nil {
+		a.logger.Info("应用修改后的配置", map[string]interface{}{"tools": a.builtinTools})
+	}
+}
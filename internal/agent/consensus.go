@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SetConsensus 开启或关闭双模型共识模式
+func (a *Agent) SetConsensus(enabled bool) error {
+	if enabled && len(a.config.Consensus.Models) < 2 {
+		return fmt.Errorf("共识模式需要在配置中设置至少两个模型 (consensus.models)")
+	}
+	a.consensus = enabled
+	if a.logger != nil {
+		a.logger.Info("设置共识模式", map[string]interface{}{"enabled": enabled})
+	}
+	return nil
+}
+
+// ConsensusEnabled 是否开启了共识模式
+func (a *Agent) ConsensusEnabled() bool {
+	return a.consensus
+}
+
+// consensusAnswer 模型回答
+type consensusAnswer struct {
+	model   string
+	content string
+	err     error
+}
+
+// executeConsensus 将同一请求发送给两个模型，由评审模型比较并合并回答
+// 共识模式只生成方案，不执行工具，避免同一操作被执行两次
+func (a *Agent) executeConsensus(ctx context.Context, messages []llm.Message, onChunk func(string) error) (string, error) {
+	models := a.config.Consensus.Models[:2]
+	onChunk(ui.Sprintf("\n⚖️ 共识模式: %s vs %s\n", models[0], models[1]))
+
+	answers := make([]consensusAnswer, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func(i int, model string) {
+			defer wg.Done()
+			resp, err := a.llmClient.WithModel(model).Chat(ctx, messages, nil, "")
+			answers[i] = consensusAnswer{model: model, err: err}
+			if err == nil {
+				answers[i].content = resp.Choices[0].Message.Content
+			}
+		}(i, model)
+	}
+	wg.Wait()
+
+	for _, ans := range answers {
+		if ans.err != nil {
+			if a.logger != nil {
+				a.logger.Error("共识模式模型调用失败", ans.err, map[string]interface{}{"model": ans.model})
+			}
+			return "", fmt.Errorf("模型 %s 调用失败: %w", ans.model, ans.err)
+		}
+		onChunk(ui.Sprintf("✅ %s 已回答 (%d 字符)\n", ans.model, len(ans.content)))
+		if a.logger != nil {
+			a.logger.ThinkingProcess("共识模式回答", fmt.Sprintf("[%s] %s", ans.model, ans.content))
+		}
+	}
+
+	userRequest := ""
+	if len(messages) > 0 {
+		userRequest = messages[len(messages)-1].Content
+	}
+
+	var sb strings.Builder
+	for i, ans := range answers {
+		sb.WriteString(fmt.Sprintf("\n\n回答%c（模型 %s）：\n%s", 'A'+i, ans.model, ans.content))
+	}
+
+	judgePrompt := fmt.Sprintf(`你是一名严谨的评审。两个模型针对同一请求分别给出了回答，请比较它们并输出最终结论。
+
+用户请求：
+%s
+%s
+
+请按以下结构输出：
+1. 合并后的最终回答（取两者中正确且更稳妥的部分）
+2. 分歧点：逐条列出两个回答不一致的地方，说明哪一方更可信及原因；如果没有分歧，请明确说明“无分歧”
+3. 风险提示：如果涉及不可逆操作（删除、迁移、生产环境变更等），列出执行前需要确认的事项`, userRequest, sb.String())
+
+	judge := a.llmClient
+	if a.config.Consensus.JudgeModel != "" {
+		judge = a.llmClient.WithModel(a.config.Consensus.JudgeModel)
+	}
+
+	onChunk(ui.Sprintf("🧑‍⚖️ 评审模型: %s\n\n", judge.Model))
+	result, err := judge.SimpleQuery(ctx, judgePrompt)
+	if err != nil {
+		return "", fmt.Errorf("评审失败: %w", err)
+	}
+
+	if err := onChunk(result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -3,8 +3,20 @@ package agent
 import (
 	"fmt"
 	"strings"
+
+	"agentcli/internal/clock"
 )
 
+// lastTurnMaxCommands 下一轮提示词中最多列出的上一轮命令数
+const lastTurnMaxCommands = 10
+
+// lastTurnOutputLines 上一轮失败命令在提示词中保留的输出末尾行数
+const lastTurnOutputLines = 15
+
+// lastTurnOutputChars 上一轮失败命令在提示词中保留的输出末尾字符数
+const lastTurnOutputChars = 1000
+
+// resetContextLog 开始新的轮次：清空上下文日志，并把本轮执行的命令转为上一轮的命令
 func (a *Agent) resetContextLog() {
 	if a == nil {
 		return
@@ -12,6 +24,23 @@ func (a *Agent) resetContextLog() {
 	a.contextMu.Lock()
 	defer a.contextMu.Unlock()
 	a.contextEntries = nil
+	a.lastCommands = a.turnCommands
+	a.turnCommands = nil
+	a.turnStartSeq = clock.Session.Current()
+}
+
+// lastTurnHint 上一轮执行过的命令及结果（失败的命令附带输出末尾），用于回答“刚才为什么失败”之类的追问
+func (a *Agent) lastTurnHint() string {
+	a.contextMu.Lock()
+	defer a.contextMu.Unlock()
+	if len(a.lastCommands) == 0 {
+		return ""
+	}
+	commands := a.lastCommands
+	if len(commands) > lastTurnMaxCommands {
+		commands = commands[len(commands)-lastTurnMaxCommands:]
+	}
+	return "\n\n上一轮执行过的命令及结果（供用户追问时参考）：\n" + strings.Join(commands, "\n")
 }
 
 func (a *Agent) appendContextEntry(kind, content string) {
@@ -63,6 +92,44 @@ func (a *Agent) recordToolCallContext(toolName string, params map[string]interfa
 	}
 
 	a.appendContextEntry("execute_command", entry)
+
+	detail := "- " + entry
+	if tail := failedOutputTail(result, err); tail != "" {
+		detail += "\n  输出末尾:\n    " + strings.ReplaceAll(tail, "\n", "\n    ")
+	}
+	a.contextMu.Lock()
+	a.turnCommands = append(a.turnCommands, detail)
+	a.contextMu.Unlock()
+}
+
+// failedOutputTail 失败命令输出的末尾部分，成功的命令返回空字符串
+func failedOutputTail(result interface{}, err error) string {
+	resultMap, ok := result.(map[string]interface{})
+	if err != nil || !ok {
+		return ""
+	}
+	if success, _ := resultMap["success"].(bool); success {
+		return ""
+	}
+	output, _ := resultMap["output"].(string)
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > lastTurnOutputLines {
+		lines = lines[len(lines)-lastTurnOutputLines:]
+	}
+	tail := strings.TrimSpace(strings.Join(lines, "\n"))
+	if runes := []rune(tail); len(runes) > lastTurnOutputChars {
+		tail = "..." + string(runes[len(runes)-lastTurnOutputChars:])
+	}
+	return tail
+}
+
+func (a *Agent) auditToolCall(toolName string, params map[string]interface{}, result interface{}, err error) {
+	if a == nil || a.audit == nil {
+		return
+	}
+	if auditErr := a.audit.RecordToolCall(toolName, params, result, err); auditErr != nil && a.logger != nil {
+		a.logger.Error("写入审计日志失败", auditErr, map[string]interface{}{"tool": toolName})
+	}
 }
 
 func formatExecuteCommand(params map[string]interface{}) string {
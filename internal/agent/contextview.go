@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// contextBarWidth /context 占用条的宽度（字符数）
+const contextBarWidth = 40
+
+// contextWarnRatio 占用超过该比例时提示即将触发压缩
+const contextWarnRatio = 0.8
+
+// ContextSection 下一轮请求中的一部分上下文及其估算token数
+type ContextSection struct {
+	Name   string
+	Tokens int
+	Detail string
+}
+
+// ContextUsage 下一轮请求的上下文组成
+type ContextUsage struct {
+	Model    string
+	Window   int
+	Sections []ContextSection
+}
+
+// Total 所有部分的token合计
+func (u *ContextUsage) Total() int {
+	total := 0
+	for _, section := range u.Sections {
+		total += section.Tokens
+	}
+	return total
+}
+
+// ContextUsage 估算下一轮请求将发送给模型的上下文：系统提示词、记忆、固定消息、对话历史和工具定义
+func (a *Agent) ContextUsage(conversationHistory []llm.Message) *ContextUsage {
+	window := a.config.Context.Window
+	if window <= 0 {
+		window = llm.ContextWindow(a.llmClient.Model)
+	}
+	usage := &ContextUsage{Model: a.llmClient.Model, Window: window}
+
+	memory := a.memoryPrompt()
+	memoryTokens := llm.EstimateTokens(memory)
+	usage.Sections = append(usage.Sections, ContextSection{
+		Name:   "系统提示词",
+		Tokens: llm.EstimateTokens(a.systemPrompt()) - memoryTokens,
+		Detail: "系统信息、工具使用策略和回答风格",
+	})
+
+	var parts []string
+	for _, part := range []struct {
+		name string
+		text string
+	}{
+		{"团队规范", a.teamMemory},
+		{"项目指令", a.projectMemory},
+		{"个人定制", a.memory},
+	} {
+		if part.text != "" {
+			parts = append(parts, fmt.Sprintf("%s %d", part.name, llm.EstimateTokens(part.text)))
+		}
+	}
+	detail := "无"
+	if len(parts) > 0 {
+		detail = strings.Join(parts, " · ")
+	}
+	usage.Sections = append(usage.Sections, ContextSection{Name: "记忆", Tokens: memoryTokens, Detail: detail})
+
+	pinnedTokens, pinnedCount, historyTokens, historyCount := 0, 0, 0, 0
+	for _, msg := range conversationHistory {
+		if msg.Pinned {
+			pinnedTokens += llm.EstimateMessageTokens(msg)
+			pinnedCount++
+		} else {
+			historyTokens += llm.EstimateMessageTokens(msg)
+			historyCount++
+		}
+	}
+	usage.Sections = append(usage.Sections, ContextSection{
+		Name:   "固定消息",
+		Tokens: pinnedTokens,
+		Detail: fmt.Sprintf("%d 条，始终完整保留", pinnedCount),
+	})
+
+	intentWindow := a.config.Context.IntentWindow
+	if intentWindow <= 0 {
+		intentWindow = defaultIntentWindow
+	}
+	usage.Sections = append(usage.Sections, ContextSection{
+		Name:   "对话历史",
+		Tokens: historyTokens,
+		Detail: fmt.Sprintf("%d 条（意图分析阶段只发送最近 %d 条，更早的压缩为摘要）", historyCount, intentWindow),
+	})
+
+	if a.supportsFunctionCalling() {
+		tools := a.convertToolsToOpenAIFormat()
+		data, _ := json.Marshal(tools)
+		usage.Sections = append(usage.Sections, ContextSection{
+			Name:   "工具定义",
+			Tokens: llm.EstimateTokens(string(data)),
+			Detail: fmt.Sprintf("%d 个工具（原生函数调用）", len(tools)),
+		})
+	} else {
+		usage.Sections = append(usage.Sections, ContextSection{
+			Name:   "工具定义",
+			Tokens: llm.EstimateTokens(a.reactInstructions()),
+			Detail: "文本工具调用说明（ReAct）",
+		})
+	}
+	return usage
+}
+
+// String 以每部分的token估算和总占用条展示上下文组成
+func (u *ContextUsage) String() string {
+	var sb strings.Builder
+	sb.WriteString(ui.Sprintf("🧮 下一轮上下文预估（模型: %s，窗口 %d tokens）\n", u.Model, u.Window))
+	for _, section := range u.Sections {
+		fmt.Fprintf(&sb, "  %8d tokens %6.1f%%  %s — %s\n", section.Tokens, percentOf(section.Tokens, u.Window), section.Name, section.Detail)
+	}
+
+	total := u.Total()
+	ratio := float64(total) / float64(u.Window)
+	filled := int(ratio * contextBarWidth)
+	if filled > contextBarWidth {
+		filled = contextBarWidth
+	}
+	fmt.Fprintf(&sb, "  %8d tokens %6.1f%%  合计\n", total, percentOf(total, u.Window))
+	fmt.Fprintf(&sb, "  [%s%s] %d / %d\n", strings.Repeat("█", filled), strings.Repeat("░", contextBarWidth-filled), total, u.Window)
+
+	sb.WriteString(ui.Text("  💡 token数为估算值，不含本轮输入、意图分析结果和工具执行结果\n"))
+	if ratio >= contextWarnRatio {
+		sb.WriteString(ui.Text("  ⚠️  接近上下文上限：超限时将依次压缩较早的工具结果和对话历史（系统提示词和固定消息保持完整），建议 /new 开始新对话\n"))
+	} else {
+		sb.WriteString(ui.Text("  💡 超出窗口时将依次压缩较早的工具结果和对话历史，系统提示词和固定消息保持完整\n"))
+	}
+	return sb.String()
+}
+
+func percentOf(tokens, window int) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(tokens) / float64(window) * 100
+}
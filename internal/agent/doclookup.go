@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"agentcli/internal/tools"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// docSource 检索到的文档来源
+type docSource struct {
+	Package string
+	Source  string
+	Content string
+}
+
+// SetDocLookup 开启或关闭库文档自动检索
+func (a *Agent) SetDocLookup(enabled bool) {
+	a.docLookup = enabled
+	if a.logger != nil {
+		a.logger.Info("设置文档检索模式", map[string]interface{}{"enabled": enabled})
+	}
+}
+
+// DocLookupEnabled 是否开启了库文档自动检索
+func (a *Agent) DocLookupEnabled() bool {
+	return a.docLookup
+}
+
+// lookupDocs 检索库/框架的文档（Go包优先使用本地go doc，其他使用配置的搜索地址）
+func (a *Agent) lookupDocs(ctx context.Context, packages []string) []docSource {
+	maxChars := a.contextBudget(a.config.DocLookup.MaxChars, 8000)
+
+	goModule := inGoModule()
+
+	var sources []docSource
+	for _, pkg := range packages {
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" {
+			continue
+		}
+
+		// json、http 等裸名称在非Go项目中通常指其他语言的库，只在Go模块中或名称是Go导入路径时使用 go doc
+		var content, source string
+		var err error
+		if goModule || isGoImportPath(pkg) {
+			content, source, err = lookupGoDoc(ctx, pkg)
+		} else {
+			err = fmt.Errorf("%s 不是Go导入路径且当前目录不在Go模块中，需配置 doc_lookup.search_url 检索网页文档", pkg)
+		}
+		if err != nil && a.config.DocLookup.SearchURL != "" {
+			content, source, err = a.searchDocs(ctx, pkg, maxChars)
+		}
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Error("文档检索失败", err, map[string]interface{}{"package": pkg})
+			}
+			continue
+		}
+
+		if len(content) > maxChars {
+			content = content[:maxChars] + "\n... (文档过长，已截断)"
+		}
+		sources = append(sources, docSource{Package: pkg, Source: source, Content: content})
+
+		if a.logger != nil {
+			a.logger.ThinkingProcess("文档检索", fmt.Sprintf("%s -> %s", pkg, source))
+		}
+	}
+	return sources
+}
+
+// isGoImportPath 名称是否为带域名的Go导入路径（如 github.com/spf13/viper）
+func isGoImportPath(pkg string) bool {
+	first, _, found := strings.Cut(pkg, "/")
+	return found && strings.Contains(first, ".")
+}
+
+// inGoModule 当前目录是否在Go模块中（当前目录或上级目录有 go.mod）
+func inGoModule() bool {
+	dir, err := filepath.Abs(".")
+	if err != nil {
+		return false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// lookupGoDoc 使用本地go doc读取Go包文档（包括模块缓存中的依赖）
+func lookupGoDoc(ctx context.Context, pkg string) (string, string, error) {
+	if strings.ContainsAny(pkg, " \t") {
+		return "", "", fmt.Errorf("不是Go包路径: %s", pkg)
+	}
+
+	docCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(docCtx, "go", "doc", pkg).CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("go doc %s 失败: %w", pkg, err)
+	}
+	return string(output), "go doc " + pkg, nil
+}
+
+// searchDocs 通过配置的搜索地址检索文档：从搜索结果中选出官方文档页面，读取并提取正文，来源为该页面地址
+// 搜索结果页本身只有标题和摘要，不作为文档使用
+func (a *Agent) searchDocs(ctx context.Context, pkg string, maxChars int) (string, string, error) {
+	searchURL := a.config.DocLookup.SearchURL
+	if strings.Contains(searchURL, "%s") {
+		searchURL = fmt.Sprintf(searchURL, url.QueryEscape(pkg+" documentation"))
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", searchURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("User-Agent", "agentcli")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("文档搜索失败 (status %d)", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return "", "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	domains := a.config.DocLookup.Domains
+	if len(domains) == 0 {
+		domains = defaultDocDomains
+	}
+	candidates := officialDocLinks(resp.Request.URL, string(body), pkg, domains)
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("搜索结果中没有 %s 的官方文档页面", pkg)
+	}
+
+	// 依次读取排名靠前的官方页面，第一个读取成功的作为文档来源
+	fetcher := tools.NewFetchURLTool(20*time.Second, 0, maxChars, nil)
+	var lastErr error
+	for _, link := range candidates[:min(len(candidates), maxDocPages)] {
+		result, err := fetcher.Execute(ctx, map[string]interface{}{"url": link})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		page, _ := result.(map[string]interface{})
+		content, _ := page["content"].(string)
+		if strings.TrimSpace(content) == "" {
+			lastErr = fmt.Errorf("%s 没有可读的内容", link)
+			continue
+		}
+		source, _ := page["url"].(string)
+		if source == "" {
+			source = link
+		}
+		return content, source, nil
+	}
+	return "", "", fmt.Errorf("读取 %s 的文档页面失败: %w", pkg, lastErr)
+}
+
+// maxDocPages 每个库最多尝试读取的文档页面数
+const maxDocPages = 3
+
+// defaultDocDomains 未配置 doc_lookup.domains 时使用的官方文档域名
+var defaultDocDomains = []string{
+	"pkg.go.dev", "go.dev", "docs.python.org", "developer.mozilla.org", "docs.rs", "doc.rust-lang.org",
+	"nodejs.org", "react.dev", "vuejs.org", "docs.oracle.com", "learn.microsoft.com", "kotlinlang.org",
+	"docs.docker.com", "kubernetes.io", "readthedocs.io",
+}
+
+var htmlLinkPattern = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+
+// officialDocLinks 从搜索结果页中按出现顺序提取官方文档链接：先是配置的官方域名，其次是 docs.* 子域名或包含库名的域名
+// 搜索引擎的跳转链接（如 DuckDuckGo 的 uddg 参数）会解析为目标地址，搜索引擎自身的链接会被忽略
+func officialDocLinks(base *url.URL, page, pkg string, domains []string) []string {
+	name := strings.ToLower(strings.Trim(path.Base(pkg), "-_."))
+	seen := make(map[string]bool)
+	var official, likely []string
+	for _, m := range htmlLinkPattern.FindAllStringSubmatch(page, -1) {
+		u, err := base.Parse(html.UnescapeString(m[1]))
+		if err != nil {
+			continue
+		}
+		if target := u.Query().Get("uddg"); target != "" {
+			if u, err = url.Parse(target); err != nil {
+				continue
+			}
+		} else if u.Path == "/url" && u.Query().Get("q") != "" {
+			if u, err = url.Parse(u.Query().Get("q")); err != nil {
+				continue
+			}
+		}
+		host := strings.ToLower(u.Hostname())
+		searchHost := strings.ToLower(base.Hostname())
+		if (u.Scheme != "http" && u.Scheme != "https") || host == "" ||
+			hostInDomains(host, []string{searchHost}) || hostInDomains(searchHost, []string{host}) {
+			continue
+		}
+		u.Fragment = ""
+		link := u.String()
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+
+		switch {
+		case hostInDomains(host, domains):
+			official = append(official, link)
+		case strings.HasPrefix(host, "docs.") || (len(name) > 2 && strings.Contains(host, name)):
+			likely = append(likely, link)
+		}
+	}
+	return append(official, likely...)
+}
+
+// hostInDomains 主机名是否属于域名列表（包含子域名）
+func hostInDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDocSources 将检索到的文档格式化为上下文
+func formatDocSources(sources []docSource) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n参考文档（回答时请基于以下文档，并在末尾注明引用的来源）:")
+	for i, s := range sources {
+		sb.WriteString(fmt.Sprintf("\n\n[%d] %s（来源: %s）\n```\n%s\n```", i+1, s.Package, s.Source, s.Content))
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOfficialDocLinks(t *testing.T) {
+	base, _ := url.Parse("https://html.duckduckgo.com/html/?q=requests+documentation")
+	page := `
+<a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fstackoverflow.com%2Fquestions%2F1&amp;rut=x">SO</a>
+<a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Frequests.readthedocs.io%2Fen%2Flatest%2F%23top&amp;rut=y">Requests</a>
+<a class="result__a" href="https://docs.python-requests.org/en/latest/">Docs</a>
+<a href="/html/?q=next">下一页</a>
+<a href="https://duckduckgo.com/settings">设置</a>
+<a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Frequests.readthedocs.io%2Fen%2Flatest%2F&amp;rut=z">Requests again</a>
+<a class="result__a" href="https://pypi.org/project/requests/">PyPI</a>`
+
+	got := officialDocLinks(base, page, "requests", []string{"readthedocs.io"})
+	want := []string{
+		"https://requests.readthedocs.io/en/latest/",
+		"https://docs.python-requests.org/en/latest/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("officialDocLinks = %v, want %v", got, want)
+	}
+}
+
+func TestIsGoImportPath(t *testing.T) {
+	for pkg, want := range map[string]bool{
+		"github.com/spf13/viper": true,
+		"gopkg.in/yaml.v3":       true,
+		"json":                   false,
+		"http":                   false,
+		"net/http":               false,
+		"lodash/fp":              false,
+		"@types/node":            false,
+	} {
+		if got := isGoImportPath(pkg); got != want {
+			t.Errorf("isGoImportPath(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+}
+
+func TestInGoModule(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "pkg", "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	if inGoModule() {
+		t.Fatalf("没有 go.mod 的目录不应视为Go模块")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !inGoModule() {
+		t.Fatalf("上级目录有 go.mod 时应视为Go模块")
+	}
+}
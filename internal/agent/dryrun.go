@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SetDryRun 开启或关闭演练模式：只展示计划的工具调用，不实际执行
+func (a *Agent) SetDryRun(enabled bool) {
+	a.dryRun = enabled
+	if a.logger != nil {
+		a.logger.Info("设置演练模式", map[string]interface{}{"enabled": enabled})
+	}
+}
+
+// DryRunEnabled 是否开启了演练模式
+func (a *Agent) DryRunEnabled() bool {
+	return a.dryRun
+}
+
+// dryRunReport 输出模型计划的工具调用及完整参数，并结束本轮（不执行任何工具）
+func (a *Agent) dryRunReport(calls []llm.ToolCall, onChunk func(string) error) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(ui.Text("\n🧪 演练模式：以下工具调用未实际执行\n"))
+	for i, call := range calls {
+		fmt.Fprintf(&sb, "\n%d. %s\n", i+1, call.Function.Name)
+
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &params); err != nil {
+			fmt.Fprintf(&sb, "   参数（无法解析: %v）: %s\n", err, call.Function.Arguments)
+			continue
+		}
+		pretty, _ := json.MarshalIndent(a.expandParams(params), "   ", "  ")
+		fmt.Fprintf(&sb, "   参数: %s\n", pretty)
+	}
+	sb.WriteString("\n使用 /dryrun off 关闭演练模式后重新发送请求即可实际执行。\n")
+
+	if a.logger != nil {
+		a.logger.ThinkingProcess("演练模式", sb.String())
+	}
+	a.clearCheckpoint()
+
+	report := sb.String()
+	if err := onChunk(report); err != nil {
+		return "", err
+	}
+	return report, nil
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionPattern 从版本命令输出中提取版本号
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// packageManagers 按常见程度排列的包管理器
+var packageManagers = []string{"apt", "dnf", "yum", "pacman", "zypper", "apk", "brew", "port", "nix", "winget", "choco", "scoop"}
+
+// shells 检测的shell
+var shells = []string{"bash", "zsh", "fish", "sh", "pwsh", "powershell"}
+
+// toolchains 检测的工具链及获取版本的参数
+var toolchains = []struct {
+	name string
+	args []string
+}{
+	{"go", []string{"version"}},
+	{"node", []string{"--version"}},
+	{"python3", []string{"--version"}},
+	{"python", []string{"--version"}},
+	{"java", []string{"-version"}},
+	{"rustc", []string{"--version"}},
+	{"docker", []string{"--version"}},
+	{"git", []string{"--version"}},
+}
+
+// environmentProfile 返回本机环境概况（发行版、包管理器、shell、工具链版本），首次调用时检测并缓存
+func (a *Agent) environmentProfile() string {
+	a.envOnce.Do(func() {
+		a.envProfile = detectEnvironment()
+		if a.logger != nil {
+			a.logger.Info("检测本机环境", map[string]interface{}{"profile": a.envProfile})
+		}
+	})
+	return a.envProfile
+}
+
+// detectEnvironment 检测本机环境，生成一行紧凑的描述
+func detectEnvironment() string {
+	var parts []string
+	if distro := detectDistro(); distro != "" {
+		parts = append(parts, "系统版本: "+distro)
+	}
+	if found := lookPaths(packageManagers); len(found) > 0 {
+		parts = append(parts, "包管理器: "+strings.Join(found, ", "))
+	}
+
+	shellList := lookPaths(shells)
+	if current := filepath.Base(os.Getenv("SHELL")); current != "." && current != "" {
+		for i, s := range shellList {
+			if s == current {
+				shellList[i] = s + "(当前)"
+			}
+		}
+	}
+	if len(shellList) > 0 {
+		parts = append(parts, "shell: "+strings.Join(shellList, ", "))
+	}
+
+	if versions := toolchainVersions(); len(versions) > 0 {
+		parts = append(parts, "已安装: "+strings.Join(versions, ", "))
+	}
+	return strings.Join(parts, "；")
+}
+
+// detectDistro 检测操作系统发行版和版本
+func detectDistro() string {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/etc/os-release")
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+				return strings.Trim(value, `"'`)
+			}
+		}
+	case "darwin":
+		if out := commandOutput("sw_vers", "-productVersion"); out != "" {
+			return "macOS " + out
+		}
+	case "windows":
+		if out := commandOutput("cmd", "/c", "ver"); out != "" {
+			return out
+		}
+	}
+	return ""
+}
+
+// lookPaths 返回PATH中存在的命令
+func lookPaths(names []string) []string {
+	var found []string
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// toolchainVersions 并发获取已安装工具链的版本
+func toolchainVersions() []string {
+	versions := make([]string, len(toolchains))
+	var wg sync.WaitGroup
+	for i, tc := range toolchains {
+		if _, err := exec.LookPath(tc.name); err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string, args []string) {
+			defer wg.Done()
+			version := versionPattern.FindString(commandOutput(name, args...))
+			if version == "" {
+				versions[i] = name
+				return
+			}
+			versions[i] = name + " " + version
+		}(i, tc.name, tc.args)
+	}
+	wg.Wait()
+
+	var result []string
+	python3 := ""
+	for i, v := range versions {
+		if v == "" {
+			continue
+		}
+		// python 与 python3 版本相同时只保留 python3
+		switch toolchains[i].name {
+		case "python3":
+			python3 = strings.TrimPrefix(v, "python3 ")
+		case "python":
+			if python3 != "" && strings.TrimPrefix(v, "python ") == python3 {
+				continue
+			}
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// commandOutput 执行命令并返回去除首尾空白的第一行输出（超时或失败时返回空字符串）
+func commandOutput(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"agentcli/internal/config"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// commandExplainPrompt 要求模型解释将要执行的命令
+const commandExplainPrompt = `请解释下面这条将在 %s 上执行的命令，不要执行它。
+
+命令：
+%s
+
+只输出JSON，格式如下：
+{
+  "summary": "一句话说明命令做什么",
+  "flags": [{"flag": "-r", "meaning": "递归处理子目录"}],
+  "risk": "low|medium|high",
+  "risk_reason": "风险评级的理由（删除/覆盖文件、修改系统配置、网络下载执行等属于high）"
+}`
+
+// CommandFlag 命令中一个参数的说明
+type CommandFlag struct {
+	Flag    string `json:"flag"`
+	Meaning string `json:"meaning"`
+}
+
+// CommandExplanation 模型对将要执行的命令的解释和破坏性评级
+type CommandExplanation struct {
+	Summary    string        `json:"summary"`
+	Flags      []CommandFlag `json:"flags"`
+	Risk       string        `json:"risk"` // low/medium/high
+	RiskReason string        `json:"risk_reason"`
+}
+
+// HighRisk 是否被评为高风险
+func (e *CommandExplanation) HighRisk() bool {
+	return e != nil && strings.EqualFold(e.Risk, "high")
+}
+
+// String 多行展示命令解释
+func (e *CommandExplanation) String() string {
+	if e == nil {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  说明: %s\n", e.Summary)
+	for _, flag := range e.Flags {
+		fmt.Fprintf(&sb, "    %s  %s\n", flag.Flag, flag.Meaning)
+	}
+	badge := ui.Text(map[string]string{"low": "🟢 低", "medium": "🟡 中", "high": "🔴 高"}[strings.ToLower(e.Risk)])
+	if badge == "" {
+		badge = e.Risk
+	}
+	fmt.Fprintf(&sb, "  破坏性: %s", badge)
+	if e.RiskReason != "" {
+		fmt.Fprintf(&sb, "（%s）", e.RiskReason)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// CommandConfirmer 执行命令前展示命令及其解释（解释失败或未开启解释时为nil），返回是否执行
+// reason 为执行策略要求确认的原因（如包含破坏性命令），仅因开启解释而询问时为空
+type CommandConfirmer func(command string, explanation *CommandExplanation, reason string) bool
+
+// SetCommandConfirmer 设置执行命令前的确认方式；未设置时视为非交互模式，策略要求确认的命令不会执行
+func (a *Agent) SetCommandConfirmer(confirmer CommandConfirmer) {
+	a.confirmer = confirmer
+}
+
+// SetAutoApprove 跳过执行命令前的确认（--yes），执行策略的白名单和黑名单仍然生效；
+// 组织策略禁止跳过确认时返回错误，确认照常进行
+func (a *Agent) SetAutoApprove(enabled bool) error {
+	if enabled && a.config.Policy != nil && a.config.Policy.DisableAutoApprove {
+		a.autoApprove = false
+		return fmt.Errorf("组织策略禁止使用 --yes 跳过命令确认（%s）", a.config.Policy.Path)
+	}
+	a.autoApprove = enabled
+	return nil
+}
+
+// commandPolicy 根据配置构建命令执行策略
+func commandPolicy(cfg *config.Config) tools.CommandPolicy {
+	return tools.CommandPolicy{
+		Allowlist:           cfg.Tools.ExecuteCommand.Allowlist,
+		Denylist:            cfg.Tools.ExecuteCommand.Denylist,
+		RequireConfirmation: cfg.Tools.ExecuteCommand.RequireConfirmation,
+	}
+}
+
+// confirmCommand 执行命令前检查执行策略：策略禁止的命令直接拒绝；破坏性命令（或 require_confirmation: always 时的所有命令）
+// 以及开启 tools.execute_command.explain 时，先让模型解释命令再请用户确认
+func (a *Agent) confirmCommand(ctx context.Context, toolName string, params map[string]interface{}) error {
+	if toolName != "execute_command" {
+		return nil
+	}
+	command := formatExecuteCommand(params)
+	if command == "" {
+		return nil
+	}
+
+	policy := commandPolicy(a.config)
+	if err := policy.Check(command); err != nil {
+		return err
+	}
+	reason := policy.ConfirmReason(command)
+	explain := a.config.Tools.ExecuteCommand.Explain
+	if reason == "" && !explain {
+		return nil
+	}
+
+	var explanation *CommandExplanation
+	if explain {
+		var err error
+		explanation, err = a.explainCommand(ctx, command)
+		if err != nil && a.logger != nil {
+			a.logger.Error("解释命令失败", err, map[string]interface{}{"command": command})
+		}
+	}
+
+	if a.autoApprove || (a.confirmer == nil && reason == "") {
+		ui.Printf("\n🔎 即将执行: %s\n%s", command, explanation)
+		return nil
+	}
+	if a.confirmer == nil {
+		return fmt.Errorf("命令需要确认（%s），非交互模式下不会执行，可使用 --yes 跳过确认: %s", reason, command)
+	}
+	// 任务图中并行的步骤逐个询问
+	a.confirmMu.Lock()
+	defer a.confirmMu.Unlock()
+	if !a.confirmer(command, explanation, reason) {
+		return fmt.Errorf("用户拒绝执行命令: %s", command)
+	}
+	return nil
+}
+
+// explainCommand 使用 tools.execute_command.explain_model（默认当前模型）解释命令
+func (a *Agent) explainCommand(ctx context.Context, command string) (*CommandExplanation, error) {
+	client := a.llmClient
+	if model := a.config.Tools.ExecuteCommand.ExplainModel; model != "" {
+		client = client.WithModel(model)
+	}
+
+	output, err := client.SimpleQuery(ctx, fmt.Sprintf(commandExplainPrompt, a.osHint(), command))
+	if err != nil {
+		return nil, fmt.Errorf("请求命令解释失败: %w", err)
+	}
+	var explanation CommandExplanation
+	if err := json.Unmarshal([]byte(extractJSON(output)), &explanation); err != nil {
+		return nil, fmt.Errorf("解析命令解释失败: %w", err)
+	}
+	return &explanation, nil
+}
@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"agentcli/internal/sandbox"
+)
+
+const (
+	maxIndexedFiles    = 20000 // 工作区索引的最大文件数
+	maxFileSuggestions = 5     // 每个不存在的文件最多给出的候选数
+)
+
+// FilePicker 意图分析猜测的目标文件不存在时，让用户从候选文件中选择
+// 返回选中的路径；ok为false表示跳过该文件
+type FilePicker func(missing string, candidates []string) (choice string, ok bool)
+
+// SetFilePicker 设置交互式文件选择器，未设置时自动采用唯一的高置信度候选
+func (a *Agent) SetFilePicker(picker FilePicker) {
+	a.filePicker = picker
+}
+
+// fileMatch 模糊匹配的候选文件
+type fileMatch struct {
+	path  string
+	score int
+}
+
+// resolveTargetFiles 将意图分析给出的目标文件与工作区索引比对，不存在的文件替换为用户选择或最可能的候选
+// 返回解析后的文件列表和需要告知模型的说明
+func (a *Agent) resolveTargetFiles(paths []string) ([]string, []string) {
+	var resolved, notes []string
+	var index []string
+	for _, path := range paths {
+		// 已存在的文件和通配符模式保持原样
+		if _, err := os.Stat(path); err == nil || strings.ContainsAny(path, "*?[") {
+			resolved = append(resolved, path)
+			continue
+		}
+
+		if index == nil {
+			index = workspaceIndex(".")
+		}
+		matches := matchFiles(path, index)
+		if len(matches) == 0 {
+			notes = append(notes, "文件不存在: "+path)
+			continue
+		}
+
+		candidates := make([]string, len(matches))
+		for i, m := range matches {
+			candidates[i] = m.path
+		}
+
+		if a.filePicker != nil {
+			if choice, ok := a.filePicker(path, candidates); ok {
+				resolved = append(resolved, choice)
+				notes = append(notes, "文件不存在: "+path+"，用户选择了 "+choice)
+			} else {
+				notes = append(notes, "文件不存在: "+path+"，用户未选择替代文件")
+			}
+			continue
+		}
+
+		// 非交互模式：只有一个明显更匹配的候选时才自动替换
+		if len(matches) == 1 || matches[0].score > matches[1].score {
+			resolved = append(resolved, candidates[0])
+			notes = append(notes, "文件不存在: "+path+"，已改用最接近的 "+candidates[0])
+			continue
+		}
+		notes = append(notes, "文件不存在: "+path+"，可能是: "+strings.Join(candidates, ", "))
+	}
+	return resolved, notes
+}
+
+// workspaceIndex 列出工作区内的文件（相对路径），跳过隐藏目录和影子工作区默认排除的目录
+func workspaceIndex(root string) []string {
+	excluded := make(map[string]bool, len(sandbox.DefaultExcludes))
+	for _, name := range sandbox.DefaultExcludes {
+		excluded[name] = true
+	}
+
+	var files []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (excluded[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(files) >= maxIndexedFiles {
+			return filepath.SkipAll
+		}
+		files = append(files, filepath.ToSlash(path))
+		return nil
+	})
+	return files
+}
+
+// matchFiles 在索引中模糊查找与path相近的文件，按匹配度从高到低返回
+// 同名文件优先（路径末尾重合的部分越多越好），其次是包含该路径的文件，最后是文件名拼写相近的文件
+func matchFiles(path string, index []string) []fileMatch {
+	query := strings.ToLower(filepath.ToSlash(filepath.Clean(path)))
+	query = strings.TrimPrefix(query, "./")
+	base := filepath.Base(query)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	var matches []fileMatch
+	for _, candidate := range index {
+		lower := strings.ToLower(candidate)
+		candidateBase := filepath.Base(lower)
+
+		score := 0
+		switch {
+		case candidateBase == base:
+			score = 100 + 10*commonSuffixParts(query, lower)
+		case strings.Contains(lower, query):
+			score = 80
+		case strings.TrimSuffix(candidateBase, filepath.Ext(candidateBase)) == stem:
+			score = 60 // 扩展名不同
+		default:
+			if d := levenshtein(base, candidateBase); d <= maxTypoDistance(base) {
+				score = 40 - d
+			}
+		}
+		if score > 0 {
+			matches = append(matches, fileMatch{path: candidate, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return len(matches[i].path) < len(matches[j].path)
+	})
+	if len(matches) > maxFileSuggestions {
+		matches = matches[:maxFileSuggestions]
+	}
+	return matches
+}
+
+// commonSuffixParts 计算两个路径末尾相同的目录层级数（不含文件名）
+func commonSuffixParts(a, b string) int {
+	pa := strings.Split(a, "/")
+	pb := strings.Split(b, "/")
+	n := 0
+	for i, j := len(pa)-2, len(pb)-2; i >= 0 && j >= 0 && pa[i] == pb[j]; i, j = i-1, j-1 {
+		n++
+	}
+	return n
+}
+
+// maxTypoDistance 文件名允许的最大编辑距离
+func maxTypoDistance(name string) int {
+	if d := len([]rune(name)) / 4; d > 1 {
+		return d
+	}
+	return 1
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
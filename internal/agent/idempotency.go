@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"agentcli/internal/audit"
+	"agentcli/internal/tools"
+	"strings"
+	"sync"
+)
+
+// repeatParam 有意重复执行相同调用时使用的参数
+const (
+	repeatParam            = "repeat"
+	repeatParamDescription = "可选，设为true表示有意使用相同参数重复执行（默认本轮内相同调用只执行一次）"
+)
+
+// callCache 本轮的工具调用去重缓存（工具名+参数哈希 -> 结果）
+// 有副作用的工具执行后清空缓存，之后的相同调用（如修改文件后重新构建）会正常执行
+type callCache struct {
+	mu      sync.Mutex
+	results map[string]interface{}
+}
+
+func (c *callCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = nil
+}
+
+func (c *callCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	return result, ok
+}
+
+func (c *callCache) put(key string, result interface{}, readOnly bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil || !readOnly {
+		c.results = make(map[string]interface{})
+	}
+	c.results[key] = result
+}
+
+// callKey 工具调用的去重键
+func callKey(toolName string, params map[string]interface{}) string {
+	return toolName + ":" + audit.HashParams(params)
+}
+
+// popRepeatFlag 取出并移除repeat参数，返回是否要求重复执行
+func popRepeatFlag(params map[string]interface{}) bool {
+	value, ok := params[repeatParam]
+	if !ok {
+		return false
+	}
+	delete(params, repeatParam)
+
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true") || v == "1" || strings.EqualFold(v, "yes")
+	}
+	return false
+}
+
+// dedupedResult 重复调用时返回的结果
+func dedupedResult(tool tools.Tool, previous interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"deduplicated":    true,
+		"note":            "本轮已使用相同参数调用过 " + tool.Name() + "，未重新执行，以下为上次结果；如确需重新执行，请设置参数 repeat=true",
+		"previous_result": previous,
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultInstructionFile     = "AGENTS.md"
+	defaultInstructionMaxChars = 8000  // 单个指令文件保留的最大字符数
+	defaultInstructionTotal    = 24000 // 所有指令文件合计的最大字符数
+	instructionTruncated       = "\n... (指令文件过长，已截断)"
+)
+
+// projectInstruction 从工作区加载的指令文件
+type projectInstruction struct {
+	path    string
+	content string
+}
+
+// workspaceRoot 从dir向上查找工作区根目录（包含.git的目录），未找到时返回空字符串
+func workspaceRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
+
+// LoadProjectInstructions 从工作区根目录到dir逐级收集指令文件（如 AGENTS.md），返回已加载的文件路径
+// 越靠近dir的文件越具体：总长度超限时优先保留深层目录的文件，拼接时仍按从根到子目录的顺序
+func (a *Agent) LoadProjectInstructions(dir string) ([]string, error) {
+	name := a.config.Context.InstructionFile
+	if name == "" {
+		name = defaultInstructionFile
+	}
+	fileMax := a.contextBudget(a.config.Context.InstructionFileMaxChars, defaultInstructionMaxChars)
+	budget := a.contextBudget(a.config.Context.InstructionMaxChars, defaultInstructionTotal)
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("解析工作目录失败: %w", err)
+	}
+
+	// dirs 从dir向上到工作区根目录，未找到根目录时只使用dir
+	dirs := []string{dir}
+	if root := workspaceRoot(dir); root != "" {
+		for current := dir; current != root; {
+			current = filepath.Dir(current)
+			dirs = append(dirs, current)
+		}
+	}
+
+	var loaded []projectInstruction
+	for _, d := range dirs {
+		if budget <= 0 {
+			break
+		}
+		path := filepath.Join(d, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("读取指令文件失败: %w", err)
+		}
+
+		content := strings.TrimSpace(string(data))
+		if content == "" {
+			continue
+		}
+		limit := fileMax
+		if budget < limit {
+			limit = budget
+		}
+		if runes := []rune(content); len(runes) > limit {
+			content = string(runes[:limit]) + instructionTruncated
+		}
+		budget -= len([]rune(content))
+		loaded = append(loaded, projectInstruction{path: path, content: content})
+	}
+
+	var sb strings.Builder
+	paths := make([]string, 0, len(loaded))
+	for i := len(loaded) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[len(dirs)-1], loaded[i].path)
+		if err != nil {
+			rel = loaded[i].path
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "[%s]\n%s", filepath.ToSlash(rel), loaded[i].content)
+		paths = append(paths, loaded[i].path)
+	}
+
+	a.projectMemory = sb.String()
+	a.projectFiles = paths
+	if a.logger != nil && len(paths) > 0 {
+		a.logger.Info("加载项目指令文件", map[string]interface{}{"files": paths})
+	}
+	return paths, nil
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	followUpMaxChars   = 20 // 启发式判断的最大输入长度
+	classifierMaxChars = 60 // 调用小模型分类的最大输入长度
+)
+
+// followUpPhrases 常见的简单后续回复（确认、致谢、催促继续）
+var followUpPhrases = []string{
+	"谢谢", "多谢", "感谢", "好的", "好", "行", "可以", "是", "是的", "对", "对的", "没问题",
+	"继续", "接着", "开始吧", "执行吧", "就这样", "照做", "做吧", "嗯", "嗯嗯", "不用了", "算了",
+	"thanks", "thank you", "thx", "ok", "okay", "yes", "yep", "sure", "go ahead", "do it",
+	"continue", "go on", "sounds good", "great", "nice", "no", "nope",
+}
+
+// fileReferencePattern 输入中包含文件路径或扩展名时，可能需要读取文件，不走快速路径
+var fileReferencePattern = regexp.MustCompile(`[\\/]|\.[A-Za-z0-9]{1,5}\b`)
+
+// followUpTrimChars 判断前去除的标点和空白
+const followUpTrimChars = " \t\r\n,.!?~，。！？～、…"
+
+// isSimpleFollowUp 判断输入是否为无需意图分析的简单后续回复（先用启发式规则，必要时调用小模型）
+func (a *Agent) isSimpleFollowUp(ctx context.Context, userInput string, conversationHistory []llm.Message) bool {
+	if !a.config.Intent.FastPath || len(conversationHistory) == 0 {
+		return false
+	}
+
+	text := strings.ToLower(strings.Trim(userInput, followUpTrimChars))
+	if text == "" || fileReferencePattern.MatchString(text) {
+		return false
+	}
+
+	length := utf8.RuneCountInString(text)
+	if length <= followUpMaxChars && isFollowUpPhrase(text) {
+		return true
+	}
+
+	if a.config.Intent.ClassifierModel == "" || length > classifierMaxChars {
+		return false
+	}
+	return a.classifyFollowUp(ctx, userInput, conversationHistory)
+}
+
+// classifyFollowUp 使用小模型判断输入是否为简单后续回复
+func (a *Agent) classifyFollowUp(ctx context.Context, userInput string, conversationHistory []llm.Message) bool {
+	lastAssistant := ""
+	for i := len(conversationHistory) - 1; i >= 0; i-- {
+		if conversationHistory[i].Role == "assistant" {
+			lastAssistant = snippet(conversationHistory[i].Content, 500)
+			break
+		}
+	}
+
+	prompt := `判断用户的最新输入是否只是对上一轮回答的简单后续回复（如确认、致谢、让助手继续执行刚才提出的方案），不需要重新分析意图或读取新的文件。
+只回答 FOLLOW_UP 或 NEW_TASK。
+
+助手上一轮回答：` + lastAssistant + `
+
+用户最新输入：` + userInput
+
+	classifier := a.llmClient.WithModel(a.config.Intent.ClassifierModel)
+	classifier.MaxContinuations = 0
+	answer, err := classifier.SimpleQuery(ctx, prompt)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("意图快速分类失败", err, nil)
+		}
+		return false
+	}
+	return strings.Contains(strings.ToUpper(answer), "FOLLOW_UP")
+}
+
+// isFollowUpPhrase 输入按标点拆分后，每一段都是常见的后续回复时返回true（如"好的，继续"）
+func isFollowUpPhrase(text string) bool {
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(followUpTrimChars, r) && r != ' '
+	})
+	if len(parts) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		matched := false
+		for _, phrase := range followUpPhrases {
+			if part == phrase {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// followUpIntention 快速路径下使用的意图描述
+func followUpIntention(userInput string) string {
+	return "这是对上一轮对话的简单后续回复（" + userInput + "），请直接结合对话历史继续处理，无需重新分析。"
+}
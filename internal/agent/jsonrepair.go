@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"agentcli/internal/ui"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonRepairPrompt 要求模型修正无法解析的JSON输出
+const jsonRepairPrompt = `你之前输出的%s无法被解析为JSON。
+
+解析错误：%v
+
+原始输出：
+%s
+
+请修正格式后重新输出。只输出JSON本身，不要包含解释、思考过程或代码块标记。`
+
+// parseJSONWithRepair 从模型输出中提取并解析JSON；解析失败时把错误和原始输出发回模型要求修正，
+// 最多重试 dag.json_repair_attempts 次，仍然失败时给出警告并返回最后一次的解析错误
+func (a *Agent) parseJSONWithRepair(ctx context.Context, stage, output string, v interface{}) error {
+	err := json.Unmarshal([]byte(extractJSON(output)), v)
+	if err == nil {
+		return nil
+	}
+
+	attempts := a.config.DAG.JSONRepairAttempts
+	for i := 1; i <= attempts; i++ {
+		if a.logger != nil {
+			a.logger.ThinkingProcess("修正JSON", fmt.Sprintf("%s 解析失败（第%d次修正）: %v", stage, i, err))
+		}
+
+		repaired, queryErr := a.llmClient.SimpleQuery(ctx, fmt.Sprintf(jsonRepairPrompt, stage, err, output))
+		if queryErr != nil {
+			return fmt.Errorf("请求修正%s失败: %w", stage, queryErr)
+		}
+		output = repaired
+		if err = json.Unmarshal([]byte(extractJSON(output)), v); err == nil {
+			return nil
+		}
+	}
+
+	ui.Printf("⚠️  %s的JSON无法解析（已尝试修正%d次）: %v\n", stage, attempts, err)
+	if a.logger != nil {
+		a.logger.Error(stage+"的JSON无法解析", err, map[string]interface{}{"attempts": attempts})
+	}
+	return err
+}
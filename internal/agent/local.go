@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"agentcli/internal/config"
+	"agentcli/internal/llm"
+	"strings"
+)
+
+// localBudgetDivisor 本地模式下默认上下文预算的缩小倍数（本地模型的上下文窗口通常较小）
+const localBudgetDivisor = 4
+
+// isLocalMode 根据配置判断是否使用本地模型模式：auto时根据api.base_url自动检测
+func isLocalMode(cfg *config.Config) bool {
+	switch strings.ToLower(cfg.API.Local) {
+	case "on", "true":
+		return true
+	case "off", "false":
+		return false
+	default:
+		return llm.IsLocalBaseURL(cfg.API.BaseURL) || cfg.API.UsesOllama() && cfg.API.BaseURL == ""
+	}
+}
+
+// LocalMode 是否处于本地模型模式（使用文本工具调用、关闭图片识别、缩小上下文预算）
+func (a *Agent) LocalMode() bool {
+	return a.local
+}
+
+// contextBudget 返回上下文预算：优先使用配置值，否则使用默认值（本地模式下缩小）
+func (a *Agent) contextBudget(configured, fallback int) int {
+	if configured > 0 {
+		return configured
+	}
+	if a.local {
+		return fallback / localBudgetDivisor
+	}
+	return fallback
+}
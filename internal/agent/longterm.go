@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"agentcli/internal/llm"
+	"agentcli/internal/memory"
+	"agentcli/internal/ui"
+)
+
+// rememberTimeout 每轮结束后保存长期记忆（向量化请求）的超时时间
+const rememberTimeout = 15 * time.Second
+
+// vectorStorePath 用户长期记忆的向量存储文件，与定制化记忆放在同一目录
+func vectorStorePath(userID string) string {
+	return filepath.Join(memoryDir, fmt.Sprintf("%s.vectors.jsonl", userID))
+}
+
+// EnableLongTermMemory 按 recall 配置开启长期记忆
+func (a *Agent) EnableLongTermMemory(userID string) error {
+	cfg := a.config.Recall
+	store, err := memory.Open(vectorStorePath(userID), cfg.MaxEntries)
+	if err != nil {
+		return err
+	}
+
+	baseURL, apiKey := cfg.BaseURL, cfg.APIKey
+	if baseURL == "" {
+		baseURL = a.config.API.BaseURL
+	}
+	if apiKey == "" {
+		apiKey = a.config.API.OpenAIKey
+	}
+	embedder := llm.NewClient(apiKey, baseURL, cfg.EmbeddingModel, time.Duration(a.config.API.Timeout)*time.Second)
+	embedder.SetTransport(a.limiter.Transport(nil))
+	embedder.Retry = a.llmClient.Retry
+	if a.usage != nil {
+		embedder.Usage = a.usage
+	}
+
+	a.recall = memory.NewRecall(store, embedder, cfg.TopK, cfg.MinScore)
+	return nil
+}
+
+// LongTermMemory 长期记忆（未开启时为nil）
+func (a *Agent) LongTermMemory() *memory.Recall {
+	return a.recall
+}
+
+// recallMemories 召回与本轮请求相关的长期记忆，写入系统提示词；失败时只记录日志
+func (a *Agent) recallMemories(ctx context.Context, userInput string) {
+	a.recalled = ""
+	a.contextMu.Lock()
+	a.toolNotes = nil
+	a.contextMu.Unlock()
+	if a.recall == nil {
+		return
+	}
+	results, err := a.recall.Search(ctx, userInput)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("召回长期记忆失败", err, nil)
+		}
+		return
+	}
+	if len(results) == 0 {
+		return
+	}
+	ui.Printf("🧠 召回 %d 条相关记忆\n", len(results))
+	a.recalled = memory.Format(results)
+	if a.logger != nil {
+		a.logger.ThinkingProcess("召回长期记忆", a.recalled)
+	}
+}
+
+// recallHint 本轮召回的长期记忆
+func (a *Agent) recallHint() string {
+	if a.recalled == "" {
+		return ""
+	}
+	return "\n\n" + a.recalled
+}
+
+// noteToolResult 记录本轮成功执行的工具结果，轮次结束后写入长期记忆
+func (a *Agent) noteToolResult(toolName string, params map[string]interface{}, result interface{}) {
+	if a.recall == nil || !a.config.Recall.RememberTools {
+		return
+	}
+	paramsJSON, _ := json.Marshal(params)
+	resultJSON, _ := json.Marshal(result)
+	note := fmt.Sprintf("工具 %s 参数 %s\n结果: %s", toolName, paramsJSON, resultJSON)
+	a.contextMu.Lock()
+	a.toolNotes = append(a.toolNotes, note)
+	a.contextMu.Unlock()
+}
+
+// rememberTurn 将本轮对话和工具结果写入长期记忆
+func (a *Agent) rememberTurn(ctx context.Context, userInput, answer string) {
+	a.contextMu.Lock()
+	notes := a.toolNotes
+	a.toolNotes = nil
+	a.contextMu.Unlock()
+	if a.recall == nil || answer == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rememberTimeout)
+	defer cancel()
+	turn := fmt.Sprintf("用户: %s\n回答: %s", userInput, answer)
+	if err := a.recall.Remember(ctx, memory.KindConversation, "", []string{turn}); err != nil && a.logger != nil {
+		a.logger.Error("保存长期记忆失败", err, nil)
+		return
+	}
+	if err := a.recall.Remember(ctx, memory.KindTool, "", notes); err != nil && a.logger != nil {
+		a.logger.Error("保存长期记忆失败", err, nil)
+	}
+}
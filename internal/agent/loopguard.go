@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// loopRepeatThreshold 相同调用或相同错误重复多少次视为陷入循环
+const loopRepeatThreshold = 3
+
+// loopSuggestions 检测到循环时给用户的建议
+const loopSuggestions = `建议：
+  - 补充更具体的信息（如准确的文件路径、期望的命令或输出）后重新提问
+  - 检查工具返回的错误是否需要手动处理（权限、依赖、网络等）
+  - 将任务拆分为更小的步骤，或使用 /model 切换模型后重试`
+
+// loopDetector 函数调用循环检测：发现相同调用反复出现、在两组调用间来回切换或同一错误反复出现时提前终止
+type loopDetector struct {
+	signatures []string
+	names      []string
+	errors     map[string]int
+}
+
+// LoopDetectedError 检测到函数调用循环
+type LoopDetectedError struct {
+	Reason string
+}
+
+func (e *LoopDetectedError) Error() string {
+	return fmt.Sprintf("检测到函数调用陷入循环，已提前终止：%s\n%s", e.Reason, loopSuggestions)
+}
+
+// observeCalls 记录一次迭代中的工具调用，发现循环时返回错误
+func (d *loopDetector) observeCalls(toolCalls []llm.ToolCall) error {
+	keys := make([]string, 0, len(toolCalls))
+	names := make([]string, 0, len(toolCalls))
+	for _, call := range toolCalls {
+		keys = append(keys, call.Function.Name+":"+strings.TrimSpace(call.Function.Arguments))
+		names = append(names, call.Function.Name)
+	}
+	sort.Strings(keys)
+	d.signatures = append(d.signatures, strings.Join(keys, "|"))
+	d.names = append(d.names, strings.Join(names, ", "))
+
+	n := len(d.signatures)
+	if n >= loopRepeatThreshold {
+		same := true
+		for i := n - loopRepeatThreshold; i < n-1; i++ {
+			if d.signatures[i] != d.signatures[n-1] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return &LoopDetectedError{Reason: fmt.Sprintf("连续 %d 次使用相同参数调用 %s", loopRepeatThreshold, d.names[n-1])}
+		}
+	}
+
+	if n >= 4 && d.signatures[n-1] == d.signatures[n-3] && d.signatures[n-2] == d.signatures[n-4] && d.signatures[n-1] != d.signatures[n-2] {
+		return &LoopDetectedError{Reason: fmt.Sprintf("在 %s 与 %s 两组调用之间来回切换", d.names[n-2], d.names[n-1])}
+	}
+	return nil
+}
+
+// observeError 记录一次工具错误，同一工具反复返回相同错误时返回错误
+func (d *loopDetector) observeError(toolName, message string) error {
+	if d.errors == nil {
+		d.errors = make(map[string]int)
+	}
+	key := toolName + ":" + message
+	d.errors[key]++
+	if d.errors[key] >= loopRepeatThreshold {
+		return &LoopDetectedError{Reason: fmt.Sprintf("工具 %s 已 %d 次返回相同错误：%s", toolName, d.errors[key], message)}
+	}
+	return nil
+}
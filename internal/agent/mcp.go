@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"agentcli/internal/mcp"
+	"context"
+	"time"
+)
+
+// MCPServerStatus MCP服务的连接结果
+type MCPServerStatus struct {
+	Name      string
+	Server    string // 服务端报告的名称和版本
+	Tools     int
+	Resources int
+	Err       error
+}
+
+// ConnectMCP 连接配置中的MCP服务，并将服务的工具和资源注册为可用工具；连接失败的服务不影响其他服务
+func (a *Agent) ConnectMCP(ctx context.Context) []MCPServerStatus {
+	var statuses []MCPServerStatus
+	for _, server := range a.config.MCP.Servers {
+		if server.Disabled {
+			continue
+		}
+		status := MCPServerStatus{Name: server.Name}
+		client, err := mcp.Connect(ctx, mcp.ServerConfig{
+			Name:      server.Name,
+			Transport: server.Transport,
+			Command:   server.Command,
+			Args:      server.Args,
+			Env:       server.Env,
+			URL:       server.URL,
+			Headers:   server.Headers,
+			Timeout:   time.Duration(a.config.MCP.Timeout) * time.Second,
+		})
+		if err != nil {
+			status.Err = err
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Server = client.Info.Name
+		if client.Info.Version != "" {
+			status.Server += " " + client.Info.Version
+		}
+		status.Tools, status.Resources, status.Err = mcp.Register(ctx, a.toolRegistry, client)
+		a.mcpClients = append(a.mcpClients, client)
+		statuses = append(statuses, status)
+
+		if a.logger != nil {
+			a.logger.Info("连接MCP服务", map[string]interface{}{
+				"name":      server.Name,
+				"server":    status.Server,
+				"tools":     status.Tools,
+				"resources": status.Resources,
+			})
+		}
+	}
+	return statuses
+}
+
+// CloseMCP 断开所有MCP服务
+func (a *Agent) CloseMCP() {
+	for _, client := range a.mcpClients {
+		client.Close()
+	}
+	a.mcpClients = nil
+}
@@ -1,11 +1,13 @@
 package agent
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"agentcli/internal/fsutil"
+	"agentcli/internal/ui"
 )
 
 // MemoryStore 记忆存储
@@ -15,17 +17,51 @@ type MemoryStore struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// SaveMemoryToFile 保存记忆到文件
+// memoryDir 记忆文件目录（当前目录下）
+const memoryDir = "memories"
+
+// maxMemoryVersions 每个用户保留的记忆历史版本数
+const maxMemoryVersions = 50
+
+// 记忆历史版本的变更类型
+const (
+	MemoryActionInitial = "initial" // 启用历史记录前已有的记忆
+	MemoryActionSet     = "set"
+	MemoryActionClear   = "clear"
+	MemoryActionRevert  = "revert"
+)
+
+// MemoryVersion 记忆的一个历史版本（变更后的内容）
+type MemoryVersion struct {
+	Version      int       `json:"version"`
+	Memory       string    `json:"memory"`
+	Action       string    `json:"action"`
+	RevertedFrom int       `json:"reverted_from,omitempty"` // 回退时恢复的版本号
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func memoryFilePath(userID string) string {
+	return filepath.Join(memoryDir, fmt.Sprintf("%s.json", userID))
+}
+
+// memoryHistoryPath 记忆历史与记忆文件存放在同一目录
+func memoryHistoryPath(userID string) string {
+	return filepath.Join(memoryDir, fmt.Sprintf("%s.history.json", userID))
+}
+
+// SaveMemoryToFile 保存记忆到文件，并记录一个历史版本
 func SaveMemoryToFile(userID, memory string) error {
+	return saveMemory(userID, memory, MemoryVersion{Action: MemoryActionSet})
+}
+
+func saveMemory(userID, memory string, version MemoryVersion) error {
+	previous, _ := LoadMemoryFromFile(userID)
+
 	// 创建memory目录
-	memoryDir := "memories"
 	if err := os.MkdirAll(memoryDir, 0755); err != nil {
 		return fmt.Errorf("创建memory目录失败: %w", err)
 	}
 
-	// 构建文件路径
-	filePath := filepath.Join(memoryDir, fmt.Sprintf("%s.json", userID))
-
 	// 创建记忆存储对象
 	store := MemoryStore{
 		UserID:    userID,
@@ -33,53 +69,106 @@ func SaveMemoryToFile(userID, memory string) error {
 		UpdatedAt: time.Now(),
 	}
 
-	// 序列化为JSON
-	data, err := json.MarshalIndent(store, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化记忆失败: %w", err)
-	}
-
-	// 写入文件
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	// 原子写入，避免写入中途崩溃损坏记忆文件
+	if err := fsutil.WriteJSONAtomic(memoryFilePath(userID), store, 0644); err != nil {
 		return fmt.Errorf("写入记忆文件失败: %w", err)
 	}
 
-	return nil
+	version.Memory = memory
+	return appendMemoryVersion(userID, previous, version)
 }
 
 // LoadMemoryFromFile 从文件加载记忆
 func LoadMemoryFromFile(userID string) (string, error) {
-	// 构建文件路径
-	filePath := filepath.Join("memory", fmt.Sprintf("%s.json", userID))
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", nil // 文件不存在，返回空字符串
-	}
+	filePath := memoryFilePath(userID)
 
-	// 读取文件
-	data, err := os.ReadFile(filePath)
+	// 读取文件，损坏时从备份恢复
+	var store MemoryStore
+	recovered, err := fsutil.ReadJSONWithBackup(filePath, &store)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil // 文件不存在，返回空字符串
+		}
 		return "", fmt.Errorf("读取记忆文件失败: %w", err)
 	}
-
-	// 反序列化
-	var store MemoryStore
-	if err := json.Unmarshal(data, &store); err != nil {
-		return "", fmt.Errorf("解析记忆文件失败: %w", err)
+	if recovered {
+		ui.Printf("⚠️  记忆文件 %s 已损坏，已从备份恢复\n", filePath)
 	}
 
 	return store.Memory, nil
 }
 
-// DeleteMemoryFromFile 删除记忆文件
+// DeleteMemoryFromFile 删除记忆文件，删除前的内容保留在历史版本中
 func DeleteMemoryFromFile(userID string) error {
-	filePath := filepath.Join("memory", fmt.Sprintf("%s.json", userID))
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	previous, _ := LoadMemoryFromFile(userID)
+	if err := fsutil.RemoveWithBackup(memoryFilePath(userID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除记忆文件失败: %w", err)
+	}
+	if previous == "" {
 		return nil
 	}
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("删除记忆文件失败: %w", err)
+	return appendMemoryVersion(userID, previous, MemoryVersion{Action: MemoryActionClear})
+}
+
+// LoadMemoryHistory 读取用户的记忆历史版本（按版本号从旧到新）
+func LoadMemoryHistory(userID string) ([]MemoryVersion, error) {
+	var versions []MemoryVersion
+	if _, err := fsutil.ReadJSONWithBackup(memoryHistoryPath(userID), &versions); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取记忆历史失败: %w", err)
+	}
+	return versions, nil
+}
+
+// RevertMemory 将记忆恢复为指定版本的内容，回退本身也记录为一个新版本
+func RevertMemory(userID string, version int) (string, error) {
+	versions, err := LoadMemoryHistory(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Version != version {
+			continue
+		}
+		if v.Memory == "" {
+			previous, _ := LoadMemoryFromFile(userID)
+			if err := fsutil.RemoveWithBackup(memoryFilePath(userID)); err != nil && !os.IsNotExist(err) {
+				return "", fmt.Errorf("删除记忆文件失败: %w", err)
+			}
+			return "", appendMemoryVersion(userID, previous, MemoryVersion{Action: MemoryActionRevert, RevertedFrom: version})
+		}
+		return v.Memory, saveMemory(userID, v.Memory, MemoryVersion{Action: MemoryActionRevert, RevertedFrom: version})
+	}
+	return "", fmt.Errorf("记忆版本不存在: %d（使用 /memory history 查看）", version)
+}
+
+// appendMemoryVersion 追加一个历史版本；首次记录时先保存启用历史前已有的记忆，避免被覆盖后无法找回
+func appendMemoryVersion(userID, previous string, version MemoryVersion) error {
+	versions, err := LoadMemoryHistory(userID)
+	if err != nil {
+		return err
+	}
+
+	next := func() int {
+		if len(versions) == 0 {
+			return 1
+		}
+		return versions[len(versions)-1].Version + 1
+	}
+	if len(versions) == 0 && previous != "" {
+		versions = append(versions, MemoryVersion{Version: next(), Memory: previous, Action: MemoryActionInitial, CreatedAt: time.Now()})
+	}
+	version.Version = next()
+	version.CreatedAt = time.Now()
+	versions = append(versions, version)
+	if len(versions) > maxMemoryVersions {
+		versions = versions[len(versions)-maxMemoryVersions:]
+	}
+
+	if err := os.MkdirAll(memoryDir, 0755); err != nil {
+		return fmt.Errorf("创建memory目录失败: %w", err)
+	}
+	if err := fsutil.WriteJSONAtomic(memoryHistoryPath(userID), versions, 0644); err != nil {
+		return fmt.Errorf("写入记忆历史失败: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"agentcli/internal/ui"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// 回答命令核对方式（response.os_check）
+const (
+	OSCheckOff  = "off"  // 不核对
+	OSCheckWarn = "warn" // 在回答末尾提示不匹配的命令
+	OSCheckFix  = "fix"  // 请模型按当前系统修正回答
+)
+
+// osCommandFixPrompt 请模型修正回答中不适用于当前系统的命令
+const osCommandFixPrompt = `下面的回答中有命令不适用于当前系统（%s）：
+%s
+
+请只修正这些命令（改为当前系统上等价的命令），其余内容保持不变，直接输出修正后的完整回答。
+
+原回答：
+%s`
+
+// OSMismatch 回答中一条与当前系统不匹配的命令
+type OSMismatch struct {
+	Command string
+	Reason  string
+}
+
+// osCommandRule 命令特征及其适用的系统
+type osCommandRule struct {
+	pattern *regexp.Regexp
+	systems []string // 适用的 runtime.GOOS
+	name    string
+	manager string // 包管理器命令名，仅在本机未安装时视为不匹配
+}
+
+var osCommandRules = []osCommandRule{
+	{regexp.MustCompile(`^(sudo\s+)?apt(-get)?\s+(install|update|upgrade|remove)\b`), []string{"linux"}, "apt", "apt"},
+	{regexp.MustCompile(`^(sudo\s+)?(dnf|yum)\s+(install|update|remove)\b`), []string{"linux"}, "dnf/yum", "dnf|yum"},
+	{regexp.MustCompile(`^(sudo\s+)?pacman\s+-S`), []string{"linux"}, "pacman", "pacman"},
+	{regexp.MustCompile(`^(sudo\s+)?zypper\s+(in|install)\b`), []string{"linux"}, "zypper", "zypper"},
+	{regexp.MustCompile(`^(sudo\s+)?apk\s+add\b`), []string{"linux"}, "apk", "apk"},
+	{regexp.MustCompile(`^brew\s+(install|upgrade|update)\b`), []string{"darwin", "linux"}, "brew", "brew"},
+	{regexp.MustCompile(`^(winget|choco|scoop)\s+install\b`), []string{"windows"}, "winget/choco/scoop", ""},
+	{regexp.MustCompile(`^(sudo|chmod|chown|systemctl|export\s+\w+=|source\s)`), []string{"linux", "darwin"}, "Unix shell", ""},
+	{regexp.MustCompile(`(?i)^(Set-ExecutionPolicy|Get-ChildItem|Get-Content|Set-Content|New-Item|Remove-Item|Invoke-WebRequest|Start-Process)\b`), []string{"windows"}, "PowerShell", ""},
+}
+
+// shellFencePattern 命令代码块（语言为空或shell类）
+var shellFencePattern = regexp.MustCompile("(?ms)^[ \t]*```(bash|sh|shell|zsh|console|terminal|powershell|pwsh|ps1|cmd|bat)?[ \t]*\n(.*?)^[ \t]*```")
+
+// inlineCodePattern 行内代码
+var inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+
+// promptPrefixPattern 命令前的提示符，如 "$ "、"PS> "、"C:\> "
+var promptPrefixPattern = regexp.MustCompile(`^(\$|#|>|PS[^>]*>|[A-Za-z]:\\[^>]*>)\s+`)
+
+// osCheckMode 回答命令核对方式，未配置或无法识别时为 warn
+func (a *Agent) osCheckMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(a.config.Response.OSCheck)); mode {
+	case OSCheckOff, OSCheckFix:
+		return mode
+	default:
+		return OSCheckWarn
+	}
+}
+
+// FindOSMismatches 扫描回答中的命令，找出明显不适用于 goos 的命令
+// checkManagers 为true时，本机未安装的包管理器命令也视为不匹配（依据本机环境概况）
+func FindOSMismatches(answer, goos string, checkManagers bool) []OSMismatch {
+	var mismatches []OSMismatch
+	seen := make(map[string]bool)
+	for _, line := range commandLines(answer) {
+		for _, rule := range osCommandRules {
+			if !rule.pattern.MatchString(line) || seen[line] {
+				continue
+			}
+			reason := ""
+			if !containsString(rule.systems, goos) {
+				reason = fmt.Sprintf("%s 命令不适用于 %s", rule.name, osName(goos))
+			} else if checkManagers && rule.manager != "" && !managerInstalled(rule.manager) {
+				reason = fmt.Sprintf("本机未安装 %s", rule.name)
+			}
+			if reason != "" {
+				seen[line] = true
+				mismatches = append(mismatches, OSMismatch{Command: line, Reason: reason})
+			}
+		}
+	}
+	return mismatches
+}
+
+// commandLines 提取回答中代码块和行内代码里的命令行
+func commandLines(answer string) []string {
+	var lines []string
+	add := func(line string) {
+		line = strings.TrimSpace(promptPrefixPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	for _, m := range shellFencePattern.FindAllStringSubmatch(answer, -1) {
+		for _, line := range strings.Split(m[2], "\n") {
+			add(line)
+		}
+	}
+	prose := shellFencePattern.ReplaceAllString(answer, "")
+	for _, m := range inlineCodePattern.FindAllStringSubmatch(prose, -1) {
+		add(m[1])
+	}
+	return lines
+}
+
+// managerInstalled 包管理器（多个候选用 | 分隔）是否已安装
+func managerInstalled(manager string) bool {
+	for _, name := range strings.Split(manager, "|") {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func osName(goos string) string {
+	switch goos {
+	case "windows":
+		return "Windows"
+	case "darwin":
+		return "macOS"
+	default:
+		return "Linux"
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAnswerCommands 核对最终回答中的命令是否匹配当前系统：warn 模式追加提示，fix 模式请模型修正并返回修正后的回答
+func (a *Agent) checkAnswerCommands(ctx context.Context, answer string, onChunk func(string) error) string {
+	mode := a.osCheckMode()
+	if mode == OSCheckOff {
+		return answer
+	}
+	mismatches := FindOSMismatches(answer, runtime.GOOS, a.config.Context.EnvironmentProfile)
+	if len(mismatches) == 0 {
+		return answer
+	}
+	if a.logger != nil {
+		a.logger.ThinkingProcess("命令系统核对", fmt.Sprintf("发现 %d 条不匹配的命令", len(mismatches)))
+	}
+
+	var items []string
+	for _, m := range mismatches {
+		items = append(items, fmt.Sprintf("  - `%s`：%s", m.Command, m.Reason))
+	}
+	list := strings.Join(items, "\n")
+
+	if mode == OSCheckFix {
+		fixed, err := a.llmClient.SimpleQuery(ctx, fmt.Sprintf(osCommandFixPrompt, a.osHint(), list, answer))
+		if err == nil && strings.TrimSpace(fixed) != "" {
+			onChunk(ui.Sprintf("\n\n🔧 回答中有命令不适用于当前系统，已修正：\n%s\n\n%s", list, fixed))
+			return fixed
+		}
+		if err != nil && a.logger != nil {
+			a.logger.Error("修正回答中的命令失败", err, nil)
+		}
+	}
+	onChunk(ui.Sprintf("\n\n⚠️  回答中的命令可能不适用于当前系统（%s）：\n%s", osName(runtime.GOOS), list))
+	return answer
+}
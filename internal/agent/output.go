@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOutputLimitKB  = 8  // 命令输出超过该大小时进行摘要
+	defaultOutputHead     = 40 // 摘要保留的开头行数
+	defaultOutputTail     = 60 // 摘要保留的结尾行数
+	maxOutputSummaryLines = 40 // 提取的错误/汇总行的最大数量
+)
+
+// outputSummaryPattern 命令输出中需要提取的错误和汇总行（编译错误、测试失败、panic等）
+var outputSummaryPattern = regexp.MustCompile(`(?i)(error|fail|panic:|fatal|exception|traceback|warning:|^ok\s|^---\s|^===\s|\.go:\d+:\d+:)`)
+
+// SetOutputDir 设置保存完整命令输出的目录（为空时使用当前目录下的outputs）
+func (a *Agent) SetOutputDir(dir string) {
+	a.outputDir = dir
+}
+
+// summarizeCommandOutput 对过长的execute_command输出保留开头、结尾和提取的错误/汇总行，
+// 完整输出保存为文件并记录为产物，结果中给出文件路径
+func (a *Agent) summarizeCommandOutput(toolName string, params map[string]interface{}, result interface{}) interface{} {
+	if toolName != "execute_command" {
+		return result
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return result
+	}
+	output, ok := resultMap["output"].(string)
+	if !ok {
+		return result
+	}
+
+	limitKB := a.config.Tools.ExecuteCommand.OutputLimitKB
+	if limitKB <= 0 {
+		limitKB = defaultOutputLimitKB
+	}
+	if len(output) <= limitKB*1024 {
+		return result
+	}
+
+	head := a.config.Tools.ExecuteCommand.HeadLines
+	if head <= 0 {
+		head = defaultOutputHead
+	}
+	tail := a.config.Tools.ExecuteCommand.TailLines
+	if tail <= 0 {
+		tail = defaultOutputTail
+	}
+
+	summarized := make(map[string]interface{}, len(resultMap)+3)
+	for k, v := range resultMap {
+		summarized[k] = v
+	}
+	summarized["output"] = headTail(output, head, tail)
+	summarized["output_truncated"] = true
+	if lines := extractSummaryLines(output, head, tail); len(lines) > 0 {
+		summarized["summary"] = strings.Join(lines, "\n")
+	}
+
+	path, err := a.saveFullOutput(output)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("保存完整命令输出失败", err, nil)
+		}
+		return summarized
+	}
+	summarized["full_output"] = path
+	summarized["note"] = fmt.Sprintf("输出共 %d 字节，已截断；完整输出已保存到 %s，可用 read_file 查看", len(output), path)
+
+	argsJSON, _ := json.Marshal(params)
+	a.recordArtifactFile(toolName, string(argsJSON), path)
+	return summarized
+}
+
+// headTail 保留开头head行和结尾tail行，中间用省略说明代替
+func headTail(output string, head, tail int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= head+tail {
+		// 行数不多但单行过长（如压缩后的JSON）时按字节截断
+		limit := (head + tail) * 200
+		if len(output) <= limit {
+			return output
+		}
+		return output[:limit/2] + fmt.Sprintf("\n... (省略 %d 字节) ...\n", len(output)-limit) + output[len(output)-limit/2:]
+	}
+
+	omitted := len(lines) - head - tail
+	return strings.Join(lines[:head], "\n") +
+		fmt.Sprintf("\n... (省略 %d 行) ...\n", omitted) +
+		strings.Join(lines[len(lines)-tail:], "\n")
+}
+
+// extractSummaryLines 从被省略的中间部分提取错误和汇总行（开头和结尾的行已保留，无需重复）
+func extractSummaryLines(output string, head, tail int) []string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= head+tail {
+		return nil
+	}
+
+	var summary []string
+	for _, line := range lines[head : len(lines)-tail] {
+		if !outputSummaryPattern.MatchString(line) {
+			continue
+		}
+		if len(summary) == maxOutputSummaryLines {
+			summary = append(summary, "...")
+			break
+		}
+		if len(line) > 300 {
+			line = line[:300] + "..."
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
+
+// saveFullOutput 将完整命令输出保存到输出目录，返回文件路径
+func (a *Agent) saveFullOutput(output string) (string, error) {
+	dir := a.outputDir
+	if dir == "" {
+		dir = "outputs"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(output))
+	name := fmt.Sprintf("%s_%s.txt", time.Now().Format("20060102_150405"), hex.EncodeToString(sum[:])[:8])
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		return "", fmt.Errorf("写入完整输出失败: %w", err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, nil
+}
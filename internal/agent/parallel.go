@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/tools"
+	"agentcli/internal/ui"
+	"context"
+	"strings"
+	"sync"
+)
+
+// toolCallResult 一个工具调用的结果消息及工具是否执行成功
+type toolCallResult struct {
+	message llm.Message
+	ok      bool
+}
+
+// toolBatches 把一次响应中的工具调用分成依次执行的批次：相邻的只读调用（读文件、搜索等）放在同一批并发执行，
+// 其他调用（写文件、执行命令等）单独成批，保证有副作用的调用仍按模型给出的顺序执行
+func (a *Agent) toolBatches(calls []llm.ToolCall) [][]llm.ToolCall {
+	var batches [][]llm.ToolCall
+	parallel := false // 最后一批是否为只读调用
+	for _, call := range calls {
+		if call.Type != "function" {
+			continue
+		}
+		readOnly := false
+		if tool, err := a.toolRegistry.Get(call.Function.Name); err == nil {
+			readOnly = tools.IsReadOnly(tool)
+		}
+		if readOnly && parallel {
+			batches[len(batches)-1] = append(batches[len(batches)-1], call)
+			continue
+		}
+		batches = append(batches, []llm.ToolCall{call})
+		parallel = readOnly
+	}
+	return batches
+}
+
+// executeToolBatch 执行一批工具调用，结果按调用顺序返回；多个调用时使用有界的协程池并发执行，
+// 各调用的过程输出先缓存，全部完成后按顺序输出，避免交错
+func (a *Agent) executeToolBatch(ctx context.Context, batch []llm.ToolCall, onChunk func(string) error) ([]toolCallResult, error) {
+	workers := a.config.Tools.ParallelCalls
+	if len(batch) == 1 || workers <= 1 {
+		results := make([]toolCallResult, 0, len(batch))
+		for _, call := range batch {
+			message, ok, err := a.executeToolCall(ctx, call, onChunk)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, toolCallResult{message: message, ok: ok})
+		}
+		return results, nil
+	}
+
+	names := make([]string, len(batch))
+	for i, call := range batch {
+		names[i] = call.Function.Name
+	}
+	onChunk(ui.Sprintf("\n⚡ 并发执行 %d 个只读工具调用: %s\n", len(batch), strings.Join(names, ", ")))
+	if a.logger != nil {
+		a.logger.ThinkingProcess("并发执行工具", strings.Join(names, ", "))
+	}
+
+	type outcome struct {
+		content string
+		ok      bool
+		err     error
+		output  strings.Builder
+	}
+	outcomes := make([]outcome, len(batch))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range batch {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			o := &outcomes[i]
+			o.content, o.ok, o.err = a.runToolCall(ctx, batch[i], func(chunk string) error {
+				o.output.WriteString(chunk)
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	results := make([]toolCallResult, 0, len(batch))
+	for i := range outcomes {
+		o := &outcomes[i]
+		onChunk(o.output.String())
+		if o.err != nil {
+			return nil, o.err
+		}
+		results = append(results, toolCallResult{message: a.toolResultMessage(batch[i], o.content), ok: o.ok})
+	}
+	return results, nil
+}
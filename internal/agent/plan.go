@@ -0,0 +1,31 @@
+package agent
+
+import "agentcli/internal/tools"
+
+// SetPlanMode 开启或关闭计划模式：write_code 和 edit_file 的修改记录到变更集，用户确认后才写入
+// 关闭时已记录的修改保留，仍可通过 /apply 应用
+func (a *Agent) SetPlanMode(enabled bool) {
+	a.changes.SetActive(enabled)
+	if a.logger != nil {
+		a.logger.Info("设置计划模式", map[string]interface{}{"enabled": enabled})
+	}
+}
+
+// PlanModeEnabled 是否开启了计划模式
+func (a *Agent) PlanModeEnabled() bool {
+	return a.changes.Active()
+}
+
+// Changes 计划模式下待确认的变更集
+func (a *Agent) Changes() *tools.ChangeSet {
+	return a.changes
+}
+
+// planHint 计划模式下提示模型修改不会立即写入
+func (a *Agent) planHint() string {
+	if !a.changes.Active() {
+		return ""
+	}
+	return "\n\n当前为计划模式：write_code 和 edit_file 的修改只会记录为待确认的变更，用户确认后才写入文件。" +
+		"同一文件的后续修改会基于待确认的内容；不要通过执行命令、write_file 或 apply_patch 绕过确认，也不要运行依赖这些修改的构建或测试，完成修改后简要说明改动即可。"
+}
@@ -0,0 +1,356 @@
+package agent
+
+import (
+	"agentcli/internal/dag"
+	"agentcli/internal/history"
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultMaxPlanNodes 任务图最多包含的节点数（dag.max_plan_nodes 未配置时）
+const defaultMaxPlanNodes = 8
+
+// planSummaryID 汇总节点的ID，依赖任务图中的所有节点
+const planSummaryID = "summary"
+
+// planNodeID 任务图节点ID允许的字符
+var planNodeID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// plannedNode 模型规划的任务图节点
+type plannedNode struct {
+	ID        string                 `json:"id"`
+	Task      string                 `json:"task"`       // 该步骤要完成的事情
+	Tool      string                 `json:"tool"`       // 绑定的工具，为空时由模型根据依赖步骤的结果完成
+	Params    map[string]interface{} `json:"params"`     // 工具参数，字符串中的 {{节点ID}} 替换为该依赖步骤的结果
+	DependsOn []string               `json:"depends_on"` // 依赖的节点ID
+}
+
+// taskPlan 模型规划的任务图
+type taskPlan struct {
+	Nodes []plannedNode `json:"nodes"`
+}
+
+// planPrompt 要求模型输出任务图
+const planPrompt = `基于用户请求和意图分析，把任务拆分为步骤并规划成任务图（DAG）。
+当前系统：%s。请确保涉及命令时与该系统匹配。
+%s
+
+可用工具：
+%s
+
+规划要求：
+1. 每个节点是一个步骤：需要调用工具时填写 tool 和 params；需要根据前面步骤的结果进行分析、判断时 tool 留空，只填写 task
+2. depends_on 列出必须先完成的节点ID；互不依赖的节点会并行执行，只有确实需要前一步结果时才添加依赖
+3. params 中的字符串可以用 {{节点ID}} 引用所依赖节点的结果
+4. 最多 %d 个节点，依赖链最长 %d 层；节点ID只能包含字母、数字、下划线和连字符，不能使用 "summary"
+5. 所有节点完成后会自动汇总结果回答用户，不需要规划总结步骤；不需要任何工具时返回空的 nodes
+
+只输出JSON，格式如下：
+{
+  "nodes": [
+    {"id": "read_config", "task": "读取配置文件", "tool": "read_file", "params": {"filepath": "config.yaml"}, "depends_on": []},
+    {"id": "analyze", "task": "找出配置中的问题", "tool": "", "params": {}, "depends_on": ["read_config"]}
+  ]
+}`
+
+// planTaskGraph 请求模型规划任务图
+func (a *Agent) planTaskGraph(ctx context.Context, userInput, intention string, conversationHistory []llm.Message) (*taskPlan, error) {
+	systemPrompt := fmt.Sprintf(planPrompt, a.osHint(), a.toolUsagePolicy(), a.getToolsDescription(), a.maxPlanNodes(), a.maxPlanDepth())
+
+	messages := []llm.Message{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, conversationHistory...)
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: fmt.Sprintf("用户请求：%s\n意图分析：%s", userInput, intention),
+	})
+
+	resp, err := a.llmClient.Chat(ctx, messages, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	response := ""
+	if len(resp.Choices) > 0 {
+		response = resp.Choices[0].Message.Content
+	}
+
+	var plan taskPlan
+	if err := a.parseJSONWithRepair(ctx, "任务图", response, &plan); err != nil {
+		return nil, err
+	}
+	if a.logger != nil {
+		a.logger.ThinkingProcess("规划任务图", response)
+	}
+	return &plan, nil
+}
+
+// maxPlanNodes 任务图最多包含的节点数
+func (a *Agent) maxPlanNodes() int {
+	if n := a.config.DAG.MaxPlanNodes; n > 0 {
+		return n
+	}
+	return defaultMaxPlanNodes
+}
+
+// maxPlanDepth 任务图依赖链的最大层数（dag.max_depth）
+func (a *Agent) maxPlanDepth() int {
+	if n := a.config.DAG.MaxDepth; n > 0 {
+		return n
+	}
+	return a.maxPlanNodes()
+}
+
+// validatePlan 校验任务图：节点ID、工具、依赖关系、节点数和依赖链长度
+func (a *Agent) validatePlan(plan *taskPlan) error {
+	if len(plan.Nodes) > a.maxPlanNodes() {
+		return fmt.Errorf("任务图有 %d 个节点，超过上限 %d", len(plan.Nodes), a.maxPlanNodes())
+	}
+
+	nodes := make(map[string]*plannedNode, len(plan.Nodes))
+	for i := range plan.Nodes {
+		node := &plan.Nodes[i]
+		switch {
+		case !planNodeID.MatchString(node.ID):
+			return fmt.Errorf("节点ID无效: %q", node.ID)
+		case node.ID == planSummaryID:
+			return fmt.Errorf("节点ID %q 为保留ID", node.ID)
+		case nodes[node.ID] != nil:
+			return fmt.Errorf("节点ID重复: %s", node.ID)
+		}
+		if node.Tool != "" {
+			if _, err := a.toolRegistry.Get(node.Tool); err != nil {
+				return fmt.Errorf("节点 %s 使用的工具不存在: %s", node.ID, node.Tool)
+			}
+		} else if strings.TrimSpace(node.Task) == "" {
+			return fmt.Errorf("节点 %s 既没有绑定工具也没有任务描述", node.ID)
+		}
+		nodes[node.ID] = node
+	}
+
+	for _, node := range plan.Nodes {
+		for _, dep := range node.DependsOn {
+			if dep == node.ID {
+				return fmt.Errorf("节点 %s 依赖自身", node.ID)
+			}
+			if nodes[dep] == nil {
+				return fmt.Errorf("节点 %s 依赖的节点 %s 不存在", node.ID, dep)
+			}
+		}
+	}
+
+	// 计算依赖链长度，同时检测循环依赖
+	depth := make(map[string]int, len(nodes))
+	visiting := make(map[string]bool, len(nodes))
+	var measure func(id string) (int, error)
+	measure = func(id string) (int, error) {
+		if d, ok := depth[id]; ok {
+			return d, nil
+		}
+		if visiting[id] {
+			return 0, fmt.Errorf("节点 %s 存在循环依赖", id)
+		}
+		visiting[id] = true
+		d := 1
+		for _, dep := range nodes[id].DependsOn {
+			depDepth, err := measure(dep)
+			if err != nil {
+				return 0, err
+			}
+			d = max(d, depDepth+1)
+		}
+		visiting[id] = false
+		depth[id] = d
+		return d, nil
+	}
+	for _, node := range plan.Nodes {
+		d, err := measure(node.ID)
+		if err != nil {
+			return err
+		}
+		if d > a.maxPlanDepth() {
+			return fmt.Errorf("依赖链长度 %d 超过上限 %d（dag.max_depth）", d, a.maxPlanDepth())
+		}
+	}
+	return nil
+}
+
+// compilePlan 将任务图编译为DAG：每个规划节点对应一个工具节点或思考节点，最后由汇总节点生成回答
+func (a *Agent) compilePlan(d *dag.DAG, plan *taskPlan, userInput string) error {
+	if err := a.validatePlan(plan); err != nil {
+		return err
+	}
+
+	order := make([]string, 0, len(plan.Nodes))
+	for i := range plan.Nodes {
+		planned := plan.Nodes[i]
+		var node *dag.Node
+		if planned.Tool != "" {
+			node = dag.NewNode(planned.ID, planned.Task, dag.NodeTypeTool)
+			node.SetHandler(&PlannedToolHandler{agent: a, node: planned})
+		} else {
+			node = dag.NewNode(planned.ID, planned.Task, dag.NodeTypeThink)
+			node.SetHandler(&PlannedStepHandler{agent: a, node: planned, userInput: userInput})
+		}
+		for _, dep := range planned.DependsOn {
+			node.AddDependency(dep)
+		}
+		// 单个步骤失败不中断任务图，依赖它的步骤和汇总会拿到失败原因
+		node.Optional = true
+		if planned.Tool == "" {
+			node.Retry = a.nodeRetry()
+		}
+		if err := d.AddNode(node); err != nil {
+			return err
+		}
+		order = append(order, planned.ID)
+	}
+
+	summaryNode := dag.NewNode(planSummaryID, "总结结果", dag.NodeTypeEnd)
+	for _, id := range order {
+		summaryNode.AddDependency(id)
+	}
+	summaryNode.SetHandler(&PlanSummaryHandler{agent: a, order: order, userInput: userInput})
+	summaryNode.Retry = a.nodeRetry()
+	return d.AddNode(summaryNode)
+}
+
+// printPlan 输出任务图
+func printPlan(plan *taskPlan) {
+	if len(plan.Nodes) == 0 {
+		ui.Printf("🗺️  任务图: 不需要调用工具，直接回答\n")
+		return
+	}
+	ui.Printf("🗺️  任务图（%d 个步骤）:\n", len(plan.Nodes))
+	for _, node := range plan.Nodes {
+		line := fmt.Sprintf("  - %s: %s", node.ID, node.Task)
+		if node.Tool != "" {
+			line += fmt.Sprintf(" [%s]", node.Tool)
+		}
+		if len(node.DependsOn) > 0 {
+			line += " ← " + strings.Join(node.DependsOn, ", ")
+		}
+		fmt.Println(line)
+	}
+}
+
+// planResultKey 节点结果在输出中的键名，避免多个依赖节点的输出合并时相互覆盖
+func planResultKey(id string) string {
+	return "node:" + id
+}
+
+// dependencyResults 收集依赖节点的结果，失败的节点给出失败原因
+func dependencyResults(input map[string]interface{}, deps []string) map[string]string {
+	failed, _ := input[dag.FailedDependenciesKey].(map[string]string)
+	results := make(map[string]string, len(deps))
+	for _, dep := range deps {
+		if result, ok := input[planResultKey(dep)].(string); ok {
+			results[dep] = result
+		} else if err, ok := failed[dep]; ok {
+			results[dep] = ui.Sprintf("❌ 步骤 %s 执行失败: %s", dep, err)
+		}
+	}
+	return results
+}
+
+// PlannedToolHandler 执行任务图中绑定了工具的节点
+type PlannedToolHandler struct {
+	agent *Agent
+	node  plannedNode
+}
+
+func (h *PlannedToolHandler) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	a := h.agent
+	tool, err := a.toolRegistry.Get(h.node.Tool)
+	if err != nil {
+		return nil, err
+	}
+	if a.usage != nil {
+		if err := a.usage.CheckToolCall(); err != nil {
+			return nil, err
+		}
+		a.usage.RecordToolCall()
+	}
+
+	// 引用依赖节点结果的参数
+	deps := dependencyResults(input, h.node.DependsOn)
+	params := make(map[string]interface{}, len(h.node.Params))
+	for key, value := range h.node.Params {
+		if s, ok := value.(string); ok {
+			value = history.ExpandVariables(s, deps)
+		}
+		params[key] = value
+	}
+
+	ui.Printf("⚙️  [%s] 执行工具: %s\n", h.node.ID, h.node.Tool)
+	result, err := a.invokeTool(ctx, tool, params)
+	label := a.citeLabel(h.node.Tool)
+	var text string
+	if err != nil {
+		// 工具失败不中断任务图，由依赖步骤和汇总根据失败信息处理
+		text = ui.Sprintf("%s❌ 工具 %s 执行失败: %v", label, h.node.Tool, err)
+	} else {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		text = ui.Sprintf("%s✅ 工具 %s 执行成功:\n%s", label, h.node.Tool, string(resultJSON))
+	}
+	return map[string]interface{}{planResultKey(h.node.ID): text}, nil
+}
+
+// PlannedStepHandler 由模型根据依赖节点的结果完成任务图中未绑定工具的步骤
+type PlannedStepHandler struct {
+	agent     *Agent
+	node      plannedNode
+	userInput string
+}
+
+func (h *PlannedStepHandler) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	deps := dependencyResults(input, h.node.DependsOn)
+	var sb strings.Builder
+	for _, dep := range h.node.DependsOn {
+		fmt.Fprintf(&sb, "\n[%s]\n%s\n", dep, deps[dep])
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("（无）")
+	}
+
+	prompt := fmt.Sprintf(`当前系统：%s。
+你正在执行一个多步骤任务中的一步，只完成这一步，结果会交给后续步骤使用。
+
+用户请求：%s
+
+当前步骤：%s
+
+前置步骤的结果：%s`, h.agent.osHint(), h.userInput, h.node.Task, sb.String())
+
+	ui.Printf("💭 [%s] %s\n", h.node.ID, h.node.Task)
+	response, err := h.agent.llmClient.SimpleQuery(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{planResultKey(h.node.ID): fmt.Sprintf("步骤 %s（%s）的结果:\n%s", h.node.ID, h.node.Task, response)}, nil
+}
+
+// PlanSummaryHandler 按规划顺序汇总所有节点的结果并生成回答
+type PlanSummaryHandler struct {
+	agent     *Agent
+	order     []string
+	userInput string
+}
+
+func (h *PlanSummaryHandler) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	deps := dependencyResults(input, h.order)
+	results := []string{}
+	for _, id := range h.order {
+		if result, ok := deps[id]; ok {
+			results = append(results, result)
+		}
+	}
+	summary := &SummaryHandler{agent: h.agent}
+	return summary.Execute(ctx, map[string]interface{}{
+		"results":    results,
+		"user_input": h.userInput,
+	})
+}
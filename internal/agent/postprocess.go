@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CodeFile 回答中标注了文件路径的代码块
+type CodeFile struct {
+	Path     string
+	Language string
+	Code     string
+}
+
+var (
+	// codeFencePattern 匹配代码块：info字符串 + 内容
+	codeFencePattern = regexp.MustCompile("(?ms)^[ \t]*```([^\n`]*)\n(.*?)^[ \t]*```[ \t]*$")
+	// pathLinePattern 代码块前单独一行的文件路径，如 "文件: main.go"、"**cmd/root.go**"、"`a.py`:"
+	pathLinePattern = regexp.MustCompile("^(?:#+\\s*)?(?:(?:文件名?|路径|File(?:name)?|Path)\\s*[:：]\\s*)?[*`]*([^\\s*`:：]+)[*`]*\\s*[:：]?$")
+	// pathCommentPattern 代码块第一行只包含文件路径的注释，如 "// main.go"、"# scripts/run.py"
+	pathCommentPattern = regexp.MustCompile(`^\s*(?://|#|--|;)\s*(?:(?:文件名?|File(?:name)?)\s*[:：]\s*)?([^\s]+)\s*$`)
+	// titleAttrPattern info字符串中的 title="path" 或 file=path
+	titleAttrPattern = regexp.MustCompile(`(?:title|file|filename|path)=["']?([^"'\s]+)`)
+)
+
+// ExtractCodeFiles 从回答中提取标注了文件路径的代码块，同一路径出现多次时以最后一个为准
+// 支持的标注方式：```go:main.go、```go title="main.go"、```main.go、代码块前一行的文件路径、代码块首行的路径注释
+func ExtractCodeFiles(answer string) []CodeFile {
+	var files []CodeFile
+	index := make(map[string]int)
+	for _, m := range codeFencePattern.FindAllStringSubmatchIndex(answer, -1) {
+		info := strings.TrimSpace(answer[m[2]:m[3]])
+		code := answer[m[4]:m[5]]
+
+		language, path := parseFenceInfo(info)
+		if path == "" {
+			path = pathBefore(answer[:m[0]])
+		}
+		if path == "" {
+			if firstLine, _, _ := strings.Cut(code, "\n"); firstLine != "" {
+				if sub := pathCommentPattern.FindStringSubmatch(firstLine); sub != nil && looksLikeFilePath(sub[1]) {
+					path = sub[1]
+				}
+			}
+		}
+		if path == "" || strings.TrimSpace(code) == "" {
+			continue
+		}
+
+		file := CodeFile{Path: filepath.Clean(path), Language: language, Code: code}
+		if i, ok := index[file.Path]; ok {
+			files[i] = file
+			continue
+		}
+		index[file.Path] = len(files)
+		files = append(files, file)
+	}
+	return files
+}
+
+// parseFenceInfo 解析代码块的info字符串，返回语言和标注的文件路径
+func parseFenceInfo(info string) (language, path string) {
+	if sub := titleAttrPattern.FindStringSubmatch(info); sub != nil && looksLikeFilePath(sub[1]) {
+		path = sub[1]
+	}
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", path
+	}
+
+	first := fields[0]
+	if lang, rest, ok := strings.Cut(first, ":"); ok && looksLikeFilePath(rest) {
+		return lang, rest
+	}
+	if looksLikeFilePath(first) {
+		return "", first
+	}
+	language = first
+	if path == "" && len(fields) > 1 && looksLikeFilePath(fields[1]) {
+		path = fields[1]
+	}
+	return language, path
+}
+
+// pathBefore 检查代码块前的最后一个非空行是否是单独的文件路径
+func pathBefore(text string) string {
+	lines := strings.Split(strings.TrimRight(text, " \t\n"), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if sub := pathLinePattern.FindStringSubmatch(last); sub != nil && looksLikeFilePath(sub[1]) {
+		return sub[1]
+	}
+	return ""
+}
+
+// looksLikeFilePath 判断文本是否像一个相对文件路径（包含扩展名，且不是URL或绝对路径）
+func looksLikeFilePath(path string) bool {
+	if path == "" || strings.Contains(path, "://") || filepath.IsAbs(path) || strings.HasPrefix(path, "..") {
+		return false
+	}
+	ext := filepath.Ext(path)
+	return len(ext) > 1 && len(ext) <= 10 && !strings.ContainsAny(path, " \t\"'<>|")
+}
+
+// DiffPreview 生成写入前后的统一diff（依赖系统diff命令，不可用时返回空字符串）
+func DiffPreview(path, content string) string {
+	oldPath := path
+	if _, err := os.Stat(path); err != nil {
+		oldPath = os.DevNull
+	}
+	cmd := exec.Command("diff", "-u", "--label", "a/"+path, "--label", "b/"+path, oldPath, "-")
+	cmd.Stdin = bytes.NewBufferString(content)
+	// diff 在有差异时返回退出码1
+	out, _ := cmd.Output()
+	return string(out)
+}
@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// Ping 向当前模型发送一个极小的请求，验证连通性、认证和模型可用性，返回请求耗时
+func (a *Agent) Ping(ctx context.Context) (time.Duration, error) {
+	latency, err := a.llmClient.Ping(ctx)
+	if a.logger != nil {
+		if err != nil {
+			a.logger.Error("模型服务预检失败", err, map[string]interface{}{"model": a.llmClient.Model})
+		} else {
+			a.logger.Info("模型服务预检通过", map[string]interface{}{"model": a.llmClient.Model, "latency_ms": latency.Milliseconds()})
+		}
+	}
+	return latency, err
+}
+
+// PingAdvice 预检失败时的处理建议
+func (a *Agent) PingAdvice(err error) string {
+	return a.llmClient.PingAdvice(err)
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	prefetchFileMaxChars     = 20000 // 单个文件保留的最大字符数
+	defaultPrefetchMaxChars  = 60000 // 所有文件合计的最大字符数
+	defaultPrefetchWorkers   = 4     // 并发读取的文件数
+	prefetchTruncatedMessage = "\n... (文件内容过长，已截断)"
+)
+
+// prefetchedFile 预读取的文件
+type prefetchedFile struct {
+	path    string
+	size    int64
+	content string
+	err     error
+	skipped bool // 超出总大小预算，未读取
+}
+
+// prefetchFiles 并发读取意图分析识别出的目标文件，小文件优先，并限制所有文件的总大小
+func (a *Agent) prefetchFiles(ctx context.Context, paths []string) string {
+	readFileTool, err := a.toolRegistry.Get("read_file")
+	if err != nil {
+		return ""
+	}
+
+	budget := a.contextBudget(a.config.Context.PrefetchMaxChars, defaultPrefetchMaxChars)
+	fileMax := a.contextBudget(0, prefetchFileMaxChars)
+	workers := a.config.Context.PrefetchWorkers
+	if workers <= 0 {
+		workers = defaultPrefetchWorkers
+	}
+
+	// 按文件大小排序，小文件优先；无法获取大小的文件放在最后
+	files := make([]*prefetchedFile, 0, len(paths))
+	for _, path := range paths {
+		f := &prefetchedFile{path: path, size: -1}
+		if info, err := os.Stat(path); err == nil {
+			f.size = info.Size()
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if (files[i].size < 0) != (files[j].size < 0) {
+			return files[j].size < 0
+		}
+		return files[i].size < files[j].size
+	})
+
+	// 预估总大小，超出预算的文件不再读取（跨越预算的那个文件仍读取并截断）
+	planned := 0
+	for _, f := range files {
+		if planned >= budget {
+			f.skipped = true
+			continue
+		}
+		size := int(f.size)
+		if size < 0 || size > fileMax {
+			size = fileMax
+		}
+		planned += size
+	}
+
+	// 使用有界的协程池并发读取
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, f := range files {
+		if f.skipped {
+			continue
+		}
+		wg.Add(1)
+		go func(f *prefetchedFile) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, err := readFileTool.Execute(ctx, map[string]interface{}{
+				"filepath": f.path,
+			})
+			if err != nil {
+				f.err = err
+				return
+			}
+			resultMap, ok := result.(map[string]interface{})
+			if !ok {
+				f.err = fmt.Errorf("无法获取内容")
+				return
+			}
+			content, ok := resultMap["content"].(string)
+			if !ok {
+				f.err = fmt.Errorf("无法获取内容")
+				return
+			}
+			f.content = content
+		}(f)
+	}
+	wg.Wait()
+
+	// 按排序后的顺序组装内容，并执行总大小预算
+	var sb strings.Builder
+	remaining := budget
+	var skipped []string
+	for _, f := range files {
+		if f.skipped || remaining <= 0 {
+			skipped = append(skipped, f.path)
+			continue
+		}
+		if f.err != nil {
+			if a.logger != nil {
+				a.logger.Error("预读取文件失败", f.err, map[string]interface{}{"file": f.path})
+			}
+			continue
+		}
+		if a.logger != nil {
+			a.logger.ThinkingProcess("读取代码文件", fmt.Sprintf("文件: %s", f.path))
+		}
+
+		content := f.content
+		limit := fileMax
+		if remaining < limit {
+			limit = remaining
+		}
+		if len(content) > limit {
+			content = content[:limit]
+			remaining -= len(content)
+			content += prefetchTruncatedMessage
+		} else {
+			// 记录完整内容，后续阶段再次读取相同文件时不重复发送
+			a.assembler.provide(f.path, content)
+			remaining -= len(content)
+		}
+		sb.WriteString(fmt.Sprintf("\n\n文件 %s 的内容:\n```\n%s\n```\n", f.path, content))
+	}
+
+	if len(skipped) > 0 {
+		sb.WriteString(fmt.Sprintf("\n\n以下文件超出上下文大小预算，未包含内容（如需要请使用read_file读取）: %s\n", strings.Join(skipped, ", ")))
+	}
+	return sb.String()
+}
@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"agentcli/internal/llm"
+	"agentcli/internal/ui"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// toolCallPattern 文本工具调用格式
+var toolCallPattern = regexp.MustCompile(`(?s)<tool_call>\s*(.*?)\s*</tool_call>`)
+
+// supportsFunctionCalling 判断当前模型是否支持原生函数调用
+// 本地模式下通过OpenAI兼容接口使用文本工具调用；ollama协议使用原生工具调用，模型不支持时在运行中检测并改用文本工具调用
+func (a *Agent) supportsFunctionCalling() bool {
+	model := a.llmClient.Model
+	if a.local && !a.config.API.UsesOllama() || contains(a.config.API.NoToolModels, model) {
+		return false
+	}
+
+	a.noToolsMu.Lock()
+	detected := a.noTools[model]
+	a.noToolsMu.Unlock()
+	if detected {
+		return false
+	}
+	return llm.SupportsFunctionCalling(model)
+}
+
+// markNoFunctionCalling 记录模型不支持原生函数调用，本次会话后续请求直接使用文本工具调用
+func (a *Agent) markNoFunctionCalling(model string) {
+	a.noToolsMu.Lock()
+	defer a.noToolsMu.Unlock()
+	if a.noTools == nil {
+		a.noTools = make(map[string]bool)
+	}
+	a.noTools[model] = true
+	if a.logger != nil {
+		a.logger.Info("模型不支持原生函数调用，改用文本工具调用", map[string]interface{}{"model": model})
+	}
+}
+
+// reactInstructions 将工具描述编码为文本指令
+func (a *Agent) reactInstructions() string {
+	var sb strings.Builder
+	sb.WriteString("\n\n可用工具：\n")
+	for _, tool := range a.toolRegistry.List() {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name(), tool.Description()))
+
+		params := tool.GetParams()
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sb.WriteString(fmt.Sprintf("    - %s: %s\n", name, params[name]))
+		}
+	}
+	sb.WriteString(`
+需要使用工具时，只输出一个工具调用，格式如下（arguments为JSON对象），然后等待工具结果：
+<tool_call>{"name": "工具名称", "arguments": {"参数名": "参数值"}}</tool_call>
+本轮内相同参数的调用只会执行一次，如确需重复执行，请在arguments中加入 "repeat": true。
+收到工具结果后可以继续调用工具；不需要工具时，直接给出最终答案，不要包含<tool_call>标签。`)
+	return sb.String()
+}
+
+// parseTextToolCall 从回答中解析文本形式的工具调用
+func parseTextToolCall(content string) (string, map[string]interface{}, bool, error) {
+	match := toolCallPattern.FindStringSubmatch(content)
+	if match == nil {
+		return "", nil, false, nil
+	}
+
+	var call struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(match[1])), &call); err != nil {
+		return "", nil, true, fmt.Errorf("工具调用格式错误: %w", err)
+	}
+	if call.Name == "" {
+		return "", nil, true, fmt.Errorf("工具调用缺少name")
+	}
+	if call.Arguments == nil {
+		call.Arguments = make(map[string]interface{})
+	}
+	return call.Name, call.Arguments, true, nil
+}
+
+// executeReAct 文本工具调用模式（ReAct）：工具以文本指令描述，从回答中解析工具调用
+func (a *Agent) executeReAct(ctx context.Context, messages []llm.Message, onChunk func(string) error) (string, error) {
+	// 将工具说明追加到系统提示词
+	messages = append([]llm.Message(nil), messages...)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += a.reactInstructions()
+	}
+
+	if a.logger != nil {
+		a.logger.ThinkingProcess("文本工具调用模式", fmt.Sprintf("模型: %s", a.llmClient.Model))
+	}
+
+	maxIterations := 10
+	var loops loopDetector
+	task, compaction := taskIndex(messages), 0
+	for i := 0; i < maxIterations; i++ {
+		response, err := a.llmClient.Chat(ctx, messages, nil, "")
+		if err != nil {
+			// 上下文超限时压缩较早的工具结果后重试，不计入迭代次数
+			if llm.IsContextOverflow(err) {
+				var ok bool
+				if messages, ok = a.recoverContextOverflow(messages, task, &compaction, onChunk); ok {
+					i--
+					continue
+				}
+				return "", fmt.Errorf("上下文超出模型限制且无法继续压缩，请使用 /new 开始新对话: %w", err)
+			}
+			return "", fmt.Errorf("LLM调用失败: %w", err)
+		}
+		content := response.Choices[0].Message.Content
+
+		name, params, found, parseErr := parseTextToolCall(content)
+		if !found {
+			if a.logger != nil {
+				ui.Printf("\n🤖 Agent: ")
+			}
+			if content != "" {
+				if err := onChunk(content); err != nil {
+					return "", err
+				}
+			}
+			return content, nil
+		}
+
+		messages = append(messages, llm.Message{Role: "assistant", Content: content})
+
+		var observation string
+		if parseErr != nil {
+			observation = parseErr.Error()
+			onChunk(ui.Sprintf("\n❌ %s\n", observation))
+		} else {
+			// 演练模式下只展示计划的工具调用
+			if a.dryRun {
+				args, _ := json.Marshal(params)
+				return a.dryRunReport([]llm.ToolCall{{Type: "function", Function: llm.FunctionCall{Name: name, Arguments: string(args)}}}, onChunk)
+			}
+
+			onChunk(ui.Sprintf("\n⚙️ 执行工具: %s\n", name))
+			if a.logger != nil {
+				a.logger.ThinkingProcess("执行工具", fmt.Sprintf("%s(%v)", name, params))
+			}
+			// 检测是否陷入循环（反复相同调用或来回切换）
+			args, _ := json.Marshal(params)
+			if err := loops.observeCalls([]llm.ToolCall{{Function: llm.FunctionCall{Name: name, Arguments: string(args)}}}); err != nil {
+				return "", a.stopLoop(err)
+			}
+
+			var ok bool
+			observation, ok, err = a.runTextToolCall(ctx, name, params)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				if err := loops.observeError(name, observation); err != nil {
+					return "", a.stopLoop(err)
+				}
+			}
+		}
+
+		messages = append(messages, llm.Message{
+			Role:    "user",
+			Content: fmt.Sprintf("%s工具 %s 的结果：\n%s", a.citeLabel(name), name, observation),
+		})
+	}
+
+	return "", fmt.Errorf("达到最大迭代次数 (%d)，任务未完成", maxIterations)
+}
+
+// runTextToolCall 执行文本工具调用，返回作为观察结果的文本以及工具是否执行成功（预算超出时返回错误以终止本轮）
+func (a *Agent) runTextToolCall(ctx context.Context, name string, params map[string]interface{}) (string, bool, error) {
+	tool, err := a.toolRegistry.Get(name)
+	if err != nil {
+		return fmt.Sprintf("工具不存在: %v", err), false, nil
+	}
+
+	if a.usage != nil {
+		if err := a.usage.CheckToolCall(); err != nil {
+			return "", false, err
+		}
+		a.usage.RecordToolCall()
+	}
+
+	result, err := a.invokeTool(ctx, tool, params)
+	if err != nil {
+		return fmt.Sprintf("执行失败: %v", err), false, nil
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	if a.logger != nil {
+		a.logger.ThinkingProcess("工具结果", string(resultJSON))
+	}
+	return string(resultJSON), true, nil
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// RunTool 手动执行已注册的工具（用于调试工具配置），与模型发起的调用走同一执行路径：
+// 变量展开、演练模式、用量限制和审计日志同样生效，但总是重新执行而不使用去重缓存
+func (a *Agent) RunTool(ctx context.Context, name string, params map[string]interface{}) (interface{}, error) {
+	tool, err := a.toolRegistry.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("工具不存在: %s", name)
+	}
+
+	if a.usage != nil {
+		if err := a.usage.CheckToolCall(); err != nil {
+			return nil, err
+		}
+		a.usage.RecordToolCall()
+	}
+
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	params[repeatParam] = true
+	return a.invokeTool(ctx, tool, params)
+}
+
+// ToolNames 返回已注册的工具名称
+func (a *Agent) ToolNames() []string {
+	var names []string
+	for _, tool := range a.toolRegistry.List() {
+		names = append(names, tool.Name())
+	}
+	sort.Strings(names)
+	return names
+}
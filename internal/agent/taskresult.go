@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"agentcli/internal/history"
+	"agentcli/internal/usage"
+)
+
+// TaskStatus 任务的完成状态
+type TaskStatus string
+
+const (
+	TaskSucceeded TaskStatus = "succeeded" // 得到了最终回答
+	TaskFailed    TaskStatus = "failed"    // 处理过程中出错，Err 为失败原因
+)
+
+// ToolCallRecord 本轮一次工具调用的记录
+type ToolCallRecord struct {
+	Tool     string                 `json:"tool"`
+	Params   map[string]interface{} `json:"params"`
+	Error    string                 `json:"error,omitempty"`
+	Cached   bool                   `json:"cached,omitempty"` // 复用了本轮相同参数的上次结果，未实际执行
+	Duration time.Duration          `json:"duration"`
+}
+
+// TaskResult 一轮请求的结构化结果
+type TaskResult struct {
+	Answer    string             `json:"answer"`
+	Status    TaskStatus         `json:"status"`
+	Err       error              `json:"-"`
+	ToolCalls []ToolCallRecord   `json:"tool_calls,omitempty"`
+	Artifacts []history.Artifact `json:"artifacts,omitempty"` // 本轮生成的文件
+	Usage     usage.Totals       `json:"usage"`               // 本轮的token、费用和工具调用用量
+	Duration  time.Duration      `json:"duration"`
+}
+
+// Succeeded 任务是否成功完成
+func (r *TaskResult) Succeeded() bool {
+	return r.Status == TaskSucceeded
+}
+
+// FailedToolCalls 执行失败的工具调用数
+func (r *TaskResult) FailedToolCalls() int {
+	failed := 0
+	for _, call := range r.ToolCalls {
+		if call.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// toolCallLog 本轮的工具调用记录
+type toolCallLog struct {
+	mu    sync.Mutex
+	calls []ToolCallRecord
+}
+
+func (l *toolCallLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
+
+func (l *toolCallLog) add(record ToolCallRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, record)
+}
+
+func (l *toolCallLog) list() []ToolCallRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]ToolCallRecord(nil), l.calls...)
+}
+
+// recordToolCall 记录一次工具调用（参数为展开变量后的副本）
+func (a *Agent) recordToolCall(name string, params map[string]interface{}, started time.Time, cached bool, err error) {
+	record := ToolCallRecord{
+		Tool:     name,
+		Params:   make(map[string]interface{}, len(params)),
+		Cached:   cached,
+		Duration: time.Since(started),
+	}
+	for k, v := range params {
+		record.Params[k] = v
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	a.toolLog.add(record)
+}
+
+// sessionUsage 当前会话的累计用量（未设置用量追踪时为零值）
+func (a *Agent) sessionUsage() usage.Totals {
+	if a.usage == nil {
+		return usage.Totals{}
+	}
+	return a.usage.Session()
+}
+
+// artifactCount 当前已记录但尚未取出的产物数
+func (a *Agent) artifactCount() int {
+	a.artifactMu.Lock()
+	defer a.artifactMu.Unlock()
+	return len(a.artifacts)
+}
+
+// artifactsSince 返回第from个之后记录的产物副本（不取出，调用方仍可通过 ConsumeArtifacts 获取）
+func (a *Agent) artifactsSince(from int) []history.Artifact {
+	a.artifactMu.Lock()
+	defer a.artifactMu.Unlock()
+	if from >= len(a.artifacts) {
+		return nil
+	}
+	return append([]history.Artifact(nil), a.artifacts[from:]...)
+}
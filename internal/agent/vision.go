@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"context"
+
+	"agentcli/internal/llm"
+)
+
+// visionClient 通过多模态模型识别图片，实现 tools.ImageAPIClient
+type visionClient struct {
+	client *llm.Client // 与 Agent 共用，未配置识图模型时跟随 /model 切换
+	model  string      // 识图模型，为空时使用当前模型
+}
+
+func (v *visionClient) RecognizeImage(ctx context.Context, mediaType, data, prompt string) (string, error) {
+	client := v.client
+	if v.model != "" {
+		client = client.WithModel(v.model)
+	}
+	return client.DescribeImages(ctx, prompt, llm.Image{MediaType: mediaType, Data: data})
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+
+	"agentcli/internal/eventlog"
+)
+
+// LastTurnEvents 从会话事件日志中读取最近一轮（进行中或刚结束）的LLM请求和工具执行记录，供 /why 解释工具调用
+func (a *Agent) LastTurnEvents() ([]eventlog.Event, error) {
+	if a.eventLog == nil {
+		return nil, fmt.Errorf("未开启会话事件日志（配置项 logging.event_log），无法解释工具调用")
+	}
+	events, err := eventlog.Load(a.eventLog.Path())
+	if err != nil {
+		return nil, fmt.Errorf("读取事件日志失败: %w", err)
+	}
+
+	a.contextMu.Lock()
+	start := a.turnStartSeq
+	a.contextMu.Unlock()
+
+	var turn []eventlog.Event
+	for _, event := range events {
+		if event.Seq > start {
+			turn = append(turn, event)
+		}
+	}
+	return turn, nil
+}
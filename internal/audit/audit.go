@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry 审计记录
+type Entry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Session    string    `json:"session"`
+	Tool       string    `json:"tool"`
+	ParamsHash string    `json:"params_hash"`
+	Outcome    string    `json:"outcome"` // success / failed / error
+	Error      string    `json:"error,omitempty"`
+}
+
+// Logger 审计日志（追加写入JSONL，可选同步到syslog），与对话历史分开保存
+type Logger struct {
+	user    string
+	session string
+	file    *os.File
+	syslog  io.WriteCloser
+	mu      sync.Mutex
+}
+
+// NewLogger 创建审计日志
+func NewLogger(path string, useSyslog bool, syslogTag, user, session string) (*Logger, error) {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("创建审计日志目录失败: %w", err)
+		}
+	}
+
+	// 仅追加写入，避免历史记录被覆盖
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %w", err)
+	}
+
+	l := &Logger{
+		user:    user,
+		session: session,
+		file:    file,
+	}
+
+	if useSyslog {
+		writer, err := newSyslogWriter(syslogTag)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("连接syslog失败: %w", err)
+		}
+		l.syslog = writer
+	}
+
+	return l, nil
+}
+
+// RecordToolCall 记录一次工具调用
+func (l *Logger) RecordToolCall(tool string, params map[string]interface{}, result interface{}, execErr error) error {
+	entry := Entry{
+		Time:       time.Now(),
+		User:       l.user,
+		Session:    l.session,
+		Tool:       tool,
+		ParamsHash: HashParams(params),
+		Outcome:    "success",
+	}
+
+	if execErr != nil {
+		entry.Outcome = "error"
+		entry.Error = execErr.Error()
+	} else if resultMap, ok := result.(map[string]interface{}); ok {
+		if success, ok := resultMap["success"].(bool); ok && !success {
+			entry.Outcome = "failed"
+		}
+	}
+
+	return l.Record(entry)
+}
+
+// Record 写入审计记录
+func (l *Logger) Record(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("同步审计日志失败: %w", err)
+	}
+
+	if l.syslog != nil {
+		if _, err := l.syslog.Write(data); err != nil {
+			return fmt.Errorf("写入syslog失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.syslog != nil {
+		l.syslog.Close()
+	}
+	return l.file.Close()
+}
+
+// HashParams 计算工具参数的SHA256（参数本身可能包含敏感信息，审计中只保存哈希）
+func HashParams(params map[string]interface{}) string {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
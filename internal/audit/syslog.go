@@ -0,0 +1,15 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+)
+
+func newSyslogWriter(tag string) (io.WriteCloser, error) {
+	if tag == "" {
+		tag = "agentcli"
+	}
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+func newSyslogWriter(tag string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("当前系统不支持syslog: %s", runtime.GOOS)
+}
@@ -0,0 +1,183 @@
+package bugreport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"agentcli/internal/clock"
+	"agentcli/internal/eventlog"
+	"agentcli/internal/llm"
+	"agentcli/internal/profile"
+	"agentcli/internal/redact"
+	"agentcli/internal/tools"
+)
+
+// maxEvents 报告中附带的会话事件条数（取最后几条）
+const maxEvents = 20
+
+// PanicError 处理请求时发生的panic，保留调用栈用于问题报告
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("内部错误: %v", e.Value)
+}
+
+// IsInternal 是否为程序内部错误（panic、服务响应无法解析），这类错误通常需要开发者排查
+func IsInternal(err error) bool {
+	var panicErr *PanicError
+	var parseErr *llm.ResponseParseError
+	return errors.As(err, &panicErr) || errors.As(err, &parseErr)
+}
+
+// Report 问题报告的原始内容，写入前统一脱敏
+type Report struct {
+	Version  string
+	Model    string
+	Provider string
+	Err      error
+	Input    string            // 出错时的用户输入
+	Config   []byte            // 配置文件原文
+	Events   []eventlog.Event  // 会话事件日志
+	Env      map[string]string // 对话级环境变量，其中密钥类变量的值会从报告中移除
+}
+
+// bundleFile 报告包中的一个文件
+type bundleFile struct {
+	name string
+	data string
+}
+
+// Write 将问题报告打包为 dir 下的zip文件，返回文件路径
+// 包内包含 report.md（版本、错误、调用栈、用户输入）、config.yaml（清空密钥的配置）和 events.jsonl（最后几条会话事件）
+func Write(dir string, r Report) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建报告目录失败: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("agentcli-bug-%s.zip", time.Now().Format("20060102-150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("创建报告文件失败: %w", err)
+	}
+	defer file.Close()
+
+	red := newRedactor(r.Config, r.Env)
+	files := []bundleFile{{"report.md", red.redact(r.markdown())}}
+	if len(r.Config) > 0 {
+		config, _, err := profile.RedactSecrets(r.Config)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, bundleFile{"config.yaml", red.redact(string(config))})
+	}
+	if len(r.Events) > 0 {
+		files = append(files, bundleFile{"events.jsonl", red.redact(eventsExcerpt(r.Events))})
+	}
+
+	zw := zip.NewWriter(file)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return "", fmt.Errorf("写入报告失败: %w", err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			return "", fmt.Errorf("写入报告失败: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("写入报告失败: %w", err)
+	}
+	return path, nil
+}
+
+// markdown 报告正文
+func (r Report) markdown() string {
+	var sb strings.Builder
+	sb.WriteString("# AgentCLI 问题报告\n\n")
+	fmt.Fprintf(&sb, "- 生成时间: %s\n", clock.Format(time.Now()))
+	fmt.Fprintf(&sb, "- 版本: %s\n", r.Version)
+	fmt.Fprintf(&sb, "- Go: %s\n", runtime.Version())
+	fmt.Fprintf(&sb, "- 系统: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	provider := r.Provider
+	if provider == "" {
+		provider = llm.ProviderOpenAI
+	}
+	fmt.Fprintf(&sb, "- 模型: %s（%s）\n", r.Model, provider)
+
+	fmt.Fprintf(&sb, "\n## 错误\n\n```\n%v\n```\n", r.Err)
+	var panicErr *PanicError
+	if errors.As(r.Err, &panicErr) && len(panicErr.Stack) > 0 {
+		fmt.Fprintf(&sb, "\n## 调用栈\n\n```\n%s\n```\n", panicErr.Stack)
+	}
+	if r.Input != "" {
+		fmt.Fprintf(&sb, "\n## 用户输入\n\n```\n%s\n```\n", r.Input)
+	}
+
+	sb.WriteString("\n## 附件\n\n")
+	if len(r.Config) > 0 {
+		sb.WriteString("- config.yaml: 配置文件（密钥已清空）\n")
+	}
+	if len(r.Events) > 0 {
+		fmt.Fprintf(&sb, "- events.jsonl: 会话事件日志的最后 %d 条（LLM请求和工具执行）\n", min(len(r.Events), maxEvents))
+	}
+	sb.WriteString("\n报告中的API Key、token、密码等密钥和用户主目录已替换为占位符，提交前请再检查一遍是否包含不希望公开的内容。\n")
+	return sb.String()
+}
+
+// eventsExcerpt 最后几条会话事件，每行一条JSON
+func eventsExcerpt(events []eventlog.Event) string {
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	var sb strings.Builder
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// redactor 从报告文本中移除密钥和用户主目录
+type redactor struct {
+	secrets []string
+	home    string
+}
+
+func newRedactor(config []byte, env map[string]string) *redactor {
+	r := &redactor{secrets: profile.SecretValues(config)}
+	for name, value := range env {
+		if tools.IsSecretEnv(name) {
+			r.secrets = append(r.secrets, value)
+		}
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && tools.IsSecretEnv(name) {
+			r.secrets = append(r.secrets, value)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		r.home = home
+	}
+	return r
+}
+
+func (r *redactor) redact(text string) string {
+	text = redact.Text(text, r.secrets)
+	if r.home != "" {
+		text = strings.ReplaceAll(text, r.home, "~")
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Layout 日志、事件和导出文件中时间戳的统一格式：RFC3339，毫秒精度，带时区
+const Layout = "2006-01-02T15:04:05.000Z07:00"
+
+// Format 按统一格式输出时间戳（本地时区）
+func Format(t time.Time) string {
+	return t.Local().Format(Layout)
+}
+
+// Sequence 单调递增的序号，用于在系统时钟被调整时仍能确定记录的先后顺序
+type Sequence struct {
+	n atomic.Int64
+}
+
+// Next 返回下一个序号（从1开始）
+func (s *Sequence) Next() int64 {
+	return s.n.Add(1)
+}
+
+// Current 最近一次分配的序号，还没有分配时返回0
+func (s *Sequence) Current() int64 {
+	return s.n.Load()
+}
+
+// Advance 保证之后的序号大于 n，用于恢复会话时接续之前的编号
+func (s *Sequence) Advance(n int64) {
+	for {
+		cur := s.n.Load()
+		if cur >= n || s.n.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// Session 当前会话的序号，会话日志和事件日志共用，两者的记录可以按序号交叉排序
+var Session Sequence
@@ -1,19 +1,61 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
 // Config 应用配置
 type Config struct {
-	API     APIConfig     `mapstructure:"api"`
-	Tools   ToolsConfig   `mapstructure:"tools"`
-	DAG     DAGConfig     `mapstructure:"dag"`
-	Logging LoggingConfig `mapstructure:"logging"`
+	API       APIConfig       `mapstructure:"api"`
+	Tools     ToolsConfig     `mapstructure:"tools"`
+	DAG       DAGConfig       `mapstructure:"dag"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	DocLookup DocLookupConfig `mapstructure:"doc_lookup"`
+	Response  ResponseConfig  `mapstructure:"response"`
+	Consensus ConsensusConfig `mapstructure:"consensus"`
+	Sandbox   SandboxConfig   `mapstructure:"sandbox"`
+	Budget    BudgetConfig    `mapstructure:"budget"`
+	Audit     AuditConfig     `mapstructure:"audit"`
+	Context   ContextConfig   `mapstructure:"context"`
+	Intent    IntentConfig    `mapstructure:"intent"`
+	Team      TeamConfig      `mapstructure:"team"`
+	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+	MCP       MCPConfig       `mapstructure:"mcp"`
+	UI        UIConfig        `mapstructure:"ui"`
+	Recall    RecallConfig    `mapstructure:"recall"`
+
+	// Policy 组织级策略（未配置时为nil），不从用户配置读取
+	Policy *OrgPolicy `mapstructure:"-"`
+}
+
+// RecallConfig 长期记忆配置：保存以往对话和工具结果的向量，每次请求召回最相关的几条写入系统提示词
+type RecallConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// EmbeddingModel 向量化模型，默认 text-embedding-3-small
+	EmbeddingModel string `mapstructure:"embedding_model"`
+	// BaseURL、APIKey 向量化服务（OpenAI embeddings 兼容接口），为空时使用 api.base_url 和 api.openai_key
+	BaseURL string `mapstructure:"base_url"`
+	APIKey  string `mapstructure:"api_key"`
+	// TopK 每次请求最多召回的记忆条数，默认3
+	TopK int `mapstructure:"top_k"`
+	// MinScore 召回的最低余弦相似度，默认0.35
+	MinScore float64 `mapstructure:"min_score"`
+	// MaxEntries 每个用户最多保存的记忆条数，超出后丢弃最早的记忆，默认5000
+	MaxEntries int `mapstructure:"max_entries"`
+	// RememberTools 是否保存工具执行结果（关闭时只保存对话）
+	RememberTools bool `mapstructure:"remember_tools"`
+}
+
+// UIConfig 终端输出配置
+type UIConfig struct {
+	// Style 状态和进度标记的风格: emoji(默认)/plain(ASCII标签，如[OK]、[ERROR])/minimal(不加标记)
+	Style string `mapstructure:"style"`
 }
 
 // APIConfig API配置
@@ -22,20 +64,71 @@ type APIConfig struct {
 	BaseURL   string `mapstructure:"base_url"`
 	Model     string `mapstructure:"model"`
 	Timeout   int    `mapstructure:"timeout"`
+	// Provider 服务协议: openai(默认，chat/completions及兼容接口)/anthropic(Claude原生messages接口)
+	Provider string `mapstructure:"provider"`
+	// AnthropicKey provider为anthropic时使用的API Key，也可通过环境变量ANTHROPIC_API_KEY设置
+	AnthropicKey string `mapstructure:"anthropic_key"`
+	// PromptCache 提示词缓存: auto(默认)/anthropic/openai/off
+	PromptCache string `mapstructure:"prompt_cache"`
+	// NoToolModels 不支持原生函数调用的模型（内置模型目录之外），使用文本工具调用
+	NoToolModels []string `mapstructure:"no_tool_models"`
+	// LegacyFunctions 使用旧版functions/function_call接口（部分服务尚不支持tools/tool_calls）
+	LegacyFunctions bool `mapstructure:"legacy_functions"`
+	// StreamToolCalls 以流式方式接收工具调用，参数到达过程中提前校验，默认关闭
+	StreamToolCalls bool `mapstructure:"stream_tool_calls"`
+	// Local 本地模型模式: auto(默认，base_url指向本机/局域网服务时启用)/on/off
+	Local string `mapstructure:"local"`
+	// MaxConcurrentRequests 同一服务同时进行中的LLM请求数上限（与DAG节点并行数 dag.parallel_nodes 相互独立），默认4，0表示不限制
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// StreamReconnects 流式响应连接中断时携带已接收内容重新请求续接的最大次数，默认2，0表示不续接
+	StreamReconnects int `mapstructure:"stream_reconnects"`
+	// StreamIdleTimeout 流式响应超过该秒数没有收到任何数据时视为卡住，中断连接后按stream_reconnects续接，默认120，0表示不限制
+	StreamIdleTimeout int `mapstructure:"stream_idle_timeout"`
+	// Precheck 会话开始时发送一个极小的请求，验证地址、认证和模型可用性并显示延迟，失败时直接退出，默认关闭
+	Precheck bool `mapstructure:"precheck"`
+	// PrecheckTimeout 预检请求的超时秒数，默认10
+	PrecheckTimeout int `mapstructure:"precheck_timeout"`
+	// Retry 限流（429）、服务端临时错误（5xx）和连接中断时的重试策略
+	Retry RetryConfig `mapstructure:"retry"`
+}
+
+// RetryConfig LLM请求的重试配置
+type RetryConfig struct {
+	MaxAttempts int  `mapstructure:"max_attempts"` // 包含首次请求在内的最大请求次数，默认3，1表示不重试
+	Backoff     int  `mapstructure:"backoff"`      // 首次重试前等待的秒数，之后每次翻倍，默认1
+	MaxBackoff  int  `mapstructure:"max_backoff"`  // 单次等待的上限（秒），默认30；Retry-After 要求等待更久时不再重试
+	Jitter      bool `mapstructure:"jitter"`       // 在等待时间上加随机抖动，默认开启
 }
 
 // ToolsConfig 工具配置
 type ToolsConfig struct {
 	Enabled        []string              `mapstructure:"enabled"`
 	WriteCode      WriteCodeConfig       `mapstructure:"write_code"`
+	WriteFile      WriteFileConfig       `mapstructure:"write_file"`
 	ReadFile       ReadFileConfig        `mapstructure:"read_file"`
 	RecognizeImage RecognizeImageConfig  `mapstructure:"recognize_image"`
+	Browser        BrowserConfig         `mapstructure:"browser"`
+	ExecuteCommand ExecuteCommandConfig  `mapstructure:"execute_command"`
+	Web            WebConfig             `mapstructure:"web"`
+	ShutdownGrace  int                   `mapstructure:"shutdown_grace"` // 退出时等待正在执行的工具停止的秒数，默认5
+	ParallelCalls  int                   `mapstructure:"parallel_calls"` // 一次响应中相邻的只读工具调用并发执行的数量上限，默认4，1表示逐个执行
 }
 
 // WriteCodeConfig 代码写入工具配置
 type WriteCodeConfig struct {
 	MaxLines           int      `mapstructure:"max_lines"`
 	SupportedLanguages []string `mapstructure:"supported_languages"`
+	// Formatters 写入后按语言运行的格式化命令，{file} 替换为文件路径（省略时追加到末尾），命令不存在时跳过
+	Formatters map[string]string `mapstructure:"formatters"`
+	// Headers 新建文件时按扩展名（不含点）插入的文件头，{year} 替换为当前年份
+	Headers map[string]string `mapstructure:"headers"`
+	// RequireApproval 启动时开启计划模式：write_code 和 edit_file 的修改先记录为待确认的变更，/apply 确认后才写入
+	RequireApproval bool `mapstructure:"require_approval"`
+}
+
+// WriteFileConfig 文件写入工具配置
+type WriteFileConfig struct {
+	MaxSizeKB int `mapstructure:"max_size_kb"` // 单次写入的最大大小，默认1024
 }
 
 // ReadFileConfig 文件读取工具配置
@@ -48,6 +141,51 @@ type ReadFileConfig struct {
 type RecognizeImageConfig struct {
 	MaxSizeMB        int      `mapstructure:"max_size_mb"`
 	SupportedFormats []string `mapstructure:"supported_formats"`
+	Model            string   `mapstructure:"model"` // 识图使用的多模态模型，为空时使用当前模型
+}
+
+// BrowserConfig 浏览器自动化工具配置
+type BrowserConfig struct {
+	AllowedDomains []string `mapstructure:"allowed_domains"`
+	Headless       bool     `mapstructure:"headless"`
+	Timeout        int      `mapstructure:"timeout"`
+}
+
+// WebConfig 联网工具（web_search、fetch_url）配置
+type WebConfig struct {
+	// Provider 搜索服务: bing、brave、searxng，为空时不注册 web_search
+	Provider string `mapstructure:"provider"`
+	// APIKey bing、brave 的订阅密钥
+	APIKey string `mapstructure:"api_key"`
+	// BaseURL searxng 的实例地址（必填）；bing、brave 为空时使用官方接口地址
+	BaseURL string `mapstructure:"base_url"`
+	// MaxResults 默认返回的搜索结果条数，默认5
+	MaxResults int `mapstructure:"max_results"`
+	// MaxSizeKB fetch_url 下载的最大大小，默认2048
+	MaxSizeKB int `mapstructure:"max_size_kb"`
+	// MaxChars fetch_url 每次返回的最多字符数，默认20000
+	MaxChars int `mapstructure:"max_chars"`
+	// Timeout 单次请求超时时间（秒），默认20
+	Timeout int `mapstructure:"timeout"`
+	// AllowedDomains fetch_url 允许访问的域名（包含子域名），为空表示不限制
+	AllowedDomains []string `mapstructure:"allowed_domains"`
+}
+
+// ExecuteCommandConfig 命令执行工具配置
+type ExecuteCommandConfig struct {
+	OutputLimitKB int `mapstructure:"output_limit_kb"` // 输出超过该大小时只保留开头、结尾和错误摘要，默认8
+	HeadLines     int `mapstructure:"head_lines"`      // 摘要保留的开头行数，默认40
+	TailLines     int `mapstructure:"tail_lines"`      // 摘要保留的结尾行数，默认60
+	// Explain 执行前让模型解释命令（参数含义、破坏性评级）并请用户确认
+	Explain bool `mapstructure:"explain"`
+	// ExplainModel 用于解释命令的模型（建议使用较快的小模型），为空时使用当前模型
+	ExplainModel string `mapstructure:"explain_model"`
+	// Allowlist 非空时只允许执行匹配的命令（匹配开头若干个词，如 "git"、"go test"）
+	Allowlist []string `mapstructure:"allowlist"`
+	// Denylist 禁止执行的命令，优先于Allowlist
+	Denylist []string `mapstructure:"denylist"`
+	// RequireConfirmation 执行前请用户确认: destructive(默认，只确认rm、format等破坏性命令)/always/never
+	RequireConfirmation string `mapstructure:"require_confirmation"`
 }
 
 // DAGConfig DAG思考引擎配置
@@ -56,20 +194,180 @@ type DAGConfig struct {
 	ParallelNodes int  `mapstructure:"parallel_nodes"`
 	Timeout       int  `mapstructure:"timeout"`
 	Verbose       bool `mapstructure:"verbose"`
+	// JSONRepairAttempts 意图分析或工具规划输出的JSON无法解析时，要求模型修正的最大次数，0表示不修正
+	JSONRepairAttempts int `mapstructure:"json_repair_attempts"`
+	// Planner 由模型将任务规划为任务图（步骤、依赖、工具）并编译为DAG执行，关闭时使用工具循环
+	Planner bool `mapstructure:"planner"`
+	// MaxPlanNodes 模型规划的任务图最多包含的步骤数，默认8
+	MaxPlanNodes int `mapstructure:"max_plan_nodes"`
+	// NodeMaxAttempts 模型推理节点（思考、规划、总结）失败时的最多执行次数（含首次），1表示不重试；工具节点不重试
+	NodeMaxAttempts int `mapstructure:"node_max_attempts"`
+	// NodeRetryBackoff 节点首次重试前的等待时间（秒），之后每次翻倍
+	NodeRetryBackoff int `mapstructure:"node_retry_backoff"`
 }
 
 // LoggingConfig 日志配置
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
+	// Level 会话日志的最低级别: debug、info、warn、error，默认 info
+	Level string `mapstructure:"level"`
+	// Output 会话日志的输出位置: file（logs/<日期>/<会话ID>.log）、stdout、stderr，默认 file
 	Output string `mapstructure:"output"`
+	// Format 会话日志的格式: text 或 json（每行一个JSON对象），默认 text
 	Format string `mapstructure:"format"`
+	// MaxSizeMB 单个日志文件的大小上限，超出后滚动为 <会话ID>.1.log 等，0表示不限，默认10
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// MaxBackups 每个会话每天保留的滚动文件数，默认3
+	MaxBackups int `mapstructure:"max_backups"`
+	// MaxAgeDays 启动时删除早于该天数的日志目录（包括事件日志），0表示不清理，默认30
+	MaxAgeDays int `mapstructure:"max_age_days"`
+	// EventLog 记录每次LLM请求和工具执行的输入、输出和耗时（logs/<日期>/<会话ID>.events.jsonl），供 agentcli debug 回看
+	EventLog bool `mapstructure:"event_log"`
+	// Content 会话日志中是否记录提示词、回答、思考过程和工具结果的完整内容: off（只记录长度）、sampled（按比例抽样轮次）、always，默认 always
+	Content string `mapstructure:"content"`
+	// SampleRate content 为 sampled 时记录完整内容的轮次比例（0~1），默认0.1
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// MaxContentChars 每条内容最多记录的字符数，超出部分截断，0表示不限，默认2000
+	MaxContentChars int `mapstructure:"max_content_chars"`
+	// Redact 记录前移除内容中的API Key、令牌等密钥，默认开启
+	Redact bool `mapstructure:"redact"`
+}
+
+// 日志内容记录模式
+const (
+	LogContentOff     = "off"
+	LogContentSampled = "sampled"
+	LogContentAlways  = "always"
+)
+
+// DocLookupConfig 库文档自动检索配置
+type DocLookupConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	SearchURL string `mapstructure:"search_url"` // 文档搜索地址模板，%s 替换为查询词
+	MaxChars  int    `mapstructure:"max_chars"`  // 每个文档来源保留的最大字符数
+	// Domains 官方文档域名（包含子域名），搜索结果中优先读取这些域名的页面；为空时使用内置列表
+	Domains []string `mapstructure:"domains"`
+}
+
+// ResponseConfig 回答风格与长度配置
+type ResponseConfig struct {
+	Verbosity        string `mapstructure:"verbosity"`          // concise/normal/detailed
+	Language         string `mapstructure:"language"`           // 回答语言，空表示跟随提问的语言
+	MaxTokens        int    `mapstructure:"max_tokens"`         // 单次回答的最大token数，0表示不限制
+	MaxContinuations int    `mapstructure:"max_continuations"`  // 回答因长度截断时自动续写的最大次数
+	Citations        bool   `mapstructure:"citations"`          // 回答中标注结论依据的工具调用编号
+	ExtractCodeFiles bool   `mapstructure:"extract_code_files"` // 回答中标注了文件路径的代码块，询问后通过write_code写入
+	OSCheck          string `mapstructure:"os_check"`           // 核对回答中的命令是否匹配当前系统：off/warn/fix，默认warn
+	SmoothOutput     int    `mapstructure:"smooth_output"`      // 按该速度（字符/秒）逐步显示已接收的回答，0表示关闭（默认）
+}
+
+// ConsensusConfig 双模型共识模式配置
+type ConsensusConfig struct {
+	Models     []string `mapstructure:"models"`      // 参与回答的两个模型
+	JudgeModel string   `mapstructure:"judge_model"` // 负责比较与合并的模型，为空时使用当前模型
+}
+
+// SandboxConfig 影子工作区配置
+type SandboxConfig struct {
+	Enabled bool     `mapstructure:"enabled"` // 默认是否开启影子工作区模式
+	Exclude []string `mapstructure:"exclude"` // 不复制到影子工作区的目录
+}
+
+// BudgetConfig 用量预算配置
+type BudgetConfig struct {
+	Session   BudgetLimits `mapstructure:"session"`    // 单次会话的限额
+	Daily     BudgetLimits `mapstructure:"daily"`      // 每个用户每天的限额
+	WarnRatio float64      `mapstructure:"warn_ratio"` // 达到限额的该比例时发出警告，默认0.8
+	Prices    []ModelPrice `mapstructure:"prices"`     // 模型价格，用于计算费用
+}
+
+// BudgetLimits 预算限额（0表示不限制）
+type BudgetLimits struct {
+	MaxTokens    int     `mapstructure:"max_tokens"`
+	MaxCost      float64 `mapstructure:"max_cost"`
+	MaxToolCalls int     `mapstructure:"max_tool_calls"`
+}
+
+// ModelPrice 模型价格（每1K tokens）
+type ModelPrice struct {
+	Model      string  `mapstructure:"model"`
+	Prompt     float64 `mapstructure:"prompt"`
+	Completion float64 `mapstructure:"completion"`
+	Cached     float64 `mapstructure:"cached"` // 命中缓存的prompt价格，为空时按prompt价格计算
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	File      string `mapstructure:"file"`       // JSONL文件路径，默认 audit/audit.jsonl
+	Syslog    bool   `mapstructure:"syslog"`     // 是否同时写入syslog
+	SyslogTag string `mapstructure:"syslog_tag"` // syslog标签，默认 agentcli
+}
+
+// ContextConfig 上下文组装配置
+type ContextConfig struct {
+	IntentWindow       int `mapstructure:"intent_window"`        // 意图分析阶段保留的最近消息数，默认6
+	IntentSummaryChars int `mapstructure:"intent_summary_chars"` // 更早消息压缩成摘要的最大字符数，默认2000
+	PrefetchMaxChars   int `mapstructure:"prefetch_max_chars"`   // 共识模式下意图分析阶段预读取文件的总字符数上限，默认60000
+	PrefetchWorkers    int `mapstructure:"prefetch_workers"`     // 并发预读取的文件数，默认4
+	// InstructionFile 从仓库根目录到当前目录逐级收集的指令文件名，默认 AGENTS.md
+	InstructionFile string `mapstructure:"instruction_file"`
+	// InstructionFileMaxChars 单个指令文件的最大字符数，默认8000
+	InstructionFileMaxChars int `mapstructure:"instruction_file_max_chars"`
+	// InstructionMaxChars 所有指令文件合计的最大字符数，默认24000（超出时优先保留深层目录的文件）
+	InstructionMaxChars int `mapstructure:"instruction_max_chars"`
+	// EnvironmentProfile 在系统提示词中附加本机环境概况（发行版、包管理器、shell、工具链版本），默认开启
+	EnvironmentProfile bool `mapstructure:"environment_profile"`
+	// Window 模型上下文窗口大小（tokens），用于 /context 展示；为0时使用内置模型目录中的值
+	Window int `mapstructure:"window"`
+}
+
+// IntentConfig 意图分析配置
+type IntentConfig struct {
+	FastPath        bool   `mapstructure:"fast_path"`        // 简单后续回复跳过意图分析，默认开启
+	ClassifierModel string `mapstructure:"classifier_model"` // 启发式规则无法判断时用于分类的小模型，为空则不调用
+	ChatPath        bool   `mapstructure:"chat_path"`        // 闲聊和通用知识问题不带工具直接回答，默认开启
+}
+
+// TeamConfig 团队共享指令配置
+type TeamConfig struct {
+	Source  string `mapstructure:"source"`  // git仓库或HTTPS地址，为空则不启用
+	Ref     string `mapstructure:"ref"`     // git分支或标签，为空则使用默认分支
+	Path    string `mapstructure:"path"`    // git仓库中的指令文件路径，默认 instructions.md
+	Timeout int    `mapstructure:"timeout"` // 启动时同步的超时时间（秒），默认15
+}
+
+// TelemetryConfig 匿名使用统计配置（是否上报由 agentcli telemetry on|off 决定，默认关闭）
+type TelemetryConfig struct {
+	Endpoint string `mapstructure:"endpoint"` // 上报地址，为空则不上报
+	Timeout  int    `mapstructure:"timeout"`  // 上报超时时间（秒），默认5
+}
+
+// MCPConfig MCP（Model Context Protocol）服务配置，服务的工具和资源会注册为Agent可用的工具
+type MCPConfig struct {
+	Servers []MCPServerConfig `mapstructure:"servers"`
+	Timeout int               `mapstructure:"timeout"` // 连接和单次调用的超时时间（秒），默认30
+}
+
+// MCPServerConfig 单个MCP服务
+type MCPServerConfig struct {
+	Name      string            `mapstructure:"name"`      // 服务名，作为工具名前缀
+	Transport string            `mapstructure:"transport"` // stdio(默认) / sse
+	Command   string            `mapstructure:"command"`   // stdio: 启动命令
+	Args      []string          `mapstructure:"args"`      // stdio: 命令参数
+	Env       []string          `mapstructure:"env"`       // stdio: 额外的环境变量，KEY=VALUE 格式
+	URL       string            `mapstructure:"url"`       // sse: SSE端点地址
+	Headers   map[string]string `mapstructure:"headers"`   // sse: 请求头（如 Authorization）
+	Disabled  bool              `mapstructure:"disabled"`  // 暂时停用
 }
 
-var globalConfig *Config
+var (
+	globalConfig   *Config
+	configFileUsed string
+)
 
 // Load 加载配置
 func Load(configPath string) (*Config, error) {
-	v := viper.New()
+	v := newViper()
 
 	// 设置配置文件
 	if configPath != "" {
@@ -87,23 +385,149 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
-	// 环境变量支持
-	v.SetEnvPrefix("AGENT")
-	v.AutomaticEnv()
-
 	// 读取配置文件
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
+	configFileUsed = v.ConfigFileUsed()
+
+	cfg, err := parse(v)
+	if err != nil {
+		return nil, err
+	}
+	globalConfig = cfg
+	return cfg, nil
+}
+
+// Parse 按与 Load 相同的默认值和校验规则解析YAML配置内容，不改变当前加载的配置，用于写入前校验
+func Parse(data []byte) (*Config, error) {
+	v := newViper()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	return parse(v)
+}
+
+// newViper 创建设置了默认值和环境变量的viper实例
+func newViper() *viper.Viper {
+	v := viper.New()
+
+	// 默认值
+	v.SetDefault("intent.fast_path", true)
+	v.SetDefault("intent.chat_path", true)
+	v.SetDefault("api.stream_tool_calls", false)
+	v.SetDefault("context.environment_profile", true)
+	v.SetDefault("dag.json_repair_attempts", 2)
+	v.SetDefault("dag.node_max_attempts", 2)
+	v.SetDefault("dag.node_retry_backoff", 1)
+	v.SetDefault("recall.embedding_model", "text-embedding-3-small")
+	v.SetDefault("recall.top_k", 3)
+	v.SetDefault("recall.min_score", 0.35)
+	v.SetDefault("recall.max_entries", 5000)
+	v.SetDefault("recall.remember_tools", true)
+	v.SetDefault("api.max_concurrent_requests", 4)
+	v.SetDefault("api.stream_reconnects", 2)
+	v.SetDefault("api.stream_idle_timeout", 120)
+	v.SetDefault("api.precheck_timeout", 10)
+	v.SetDefault("api.retry.max_attempts", 3)
+	v.SetDefault("api.retry.backoff", 1)
+	v.SetDefault("api.retry.max_backoff", 30)
+	v.SetDefault("api.retry.jitter", true)
+	v.SetDefault("mcp.timeout", 30)
+	v.SetDefault("tools.shutdown_grace", 5)
+	v.SetDefault("tools.parallel_calls", 4)
+	v.SetDefault("tools.write_file.max_size_kb", 1024)
+	v.SetDefault("tools.web.max_results", 5)
+	v.SetDefault("tools.web.max_size_kb", 2048)
+	v.SetDefault("tools.web.max_chars", 20000)
+	v.SetDefault("tools.web.timeout", 20)
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.output", "file")
+	v.SetDefault("logging.format", "text")
+	v.SetDefault("logging.max_size_mb", 10)
+	v.SetDefault("logging.max_backups", 3)
+	v.SetDefault("logging.max_age_days", 30)
+	v.SetDefault("logging.event_log", true)
+	v.SetDefault("logging.content", LogContentAlways)
+	v.SetDefault("logging.sample_rate", 0.1)
+	v.SetDefault("logging.max_content_chars", 2000)
+	v.SetDefault("logging.redact", true)
+
+	// 环境变量支持
+	v.SetEnvPrefix("AGENT")
+	v.AutomaticEnv()
+	return v
+}
+
+// parse 解析并校验配置，应用组织策略
+func parse(v *viper.Viper) (*Config, error) {
 	// 解析配置
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
 	}
 
+	switch cfg.Logging.Content {
+	case LogContentOff, LogContentSampled, LogContentAlways:
+	default:
+		return nil, fmt.Errorf("logging.content 只能是 off、sampled 或 always: %s", cfg.Logging.Content)
+	}
+	cfg.Logging.Level = strings.ToLower(cfg.Logging.Level)
+	switch cfg.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, fmt.Errorf("logging.level 只能是 debug、info、warn 或 error: %s", cfg.Logging.Level)
+	}
+	cfg.Logging.Output = strings.ToLower(cfg.Logging.Output)
+	switch cfg.Logging.Output {
+	case "file", "stdout", "stderr":
+	default:
+		return nil, fmt.Errorf("logging.output 只能是 file、stdout 或 stderr: %s", cfg.Logging.Output)
+	}
+	cfg.Logging.Format = strings.ToLower(cfg.Logging.Format)
+	switch cfg.Logging.Format {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("logging.format 只能是 text 或 json: %s", cfg.Logging.Format)
+	}
+	switch strings.ToLower(cfg.Tools.Web.Provider) {
+	case "", "searxng":
+		if cfg.Tools.Web.Provider != "" && cfg.Tools.Web.BaseURL == "" {
+			return nil, fmt.Errorf("tools.web.provider 为 searxng 时需要配置实例地址 tools.web.base_url")
+		}
+	case "bing", "brave":
+		if cfg.Tools.Web.APIKey == "" {
+			return nil, fmt.Errorf("tools.web.provider 为 %s 时需要配置 tools.web.api_key", cfg.Tools.Web.Provider)
+		}
+	default:
+		return nil, fmt.Errorf("tools.web.provider 只能是 bing、brave 或 searxng: %s", cfg.Tools.Web.Provider)
+	}
+	if cfg.Logging.SampleRate < 0 || cfg.Logging.SampleRate > 1 {
+		return nil, fmt.Errorf("logging.sample_rate 必须在0到1之间: %v", cfg.Logging.SampleRate)
+	}
+
+	// 组织策略在用户配置之后应用，用户配置无法覆盖
+	policy, err := LoadOrgPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := policy.Apply(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	// 验证必要配置
-	if cfg.API.OpenAIKey == "" {
+	if cfg.API.UsesAnthropic() {
+		if cfg.API.AnthropicKey == "" {
+			cfg.API.AnthropicKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if cfg.API.AnthropicKey == "" {
+			return nil, fmt.Errorf("未配置API Key，请在配置文件中设置api.anthropic_key或设置环境变量ANTHROPIC_API_KEY")
+		}
+	} else if cfg.API.OpenAIKey == "" && !cfg.API.UsesOllama() {
 		if key := os.Getenv("OPENAI_API_KEY"); key != "" {
 			cfg.API.OpenAIKey = key
 		} else {
@@ -111,10 +535,24 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
-	globalConfig = &cfg
 	return &cfg, nil
 }
 
+// UsesAnthropic 是否使用Anthropic原生接口
+func (a APIConfig) UsesAnthropic() bool {
+	return strings.EqualFold(strings.TrimSpace(a.Provider), "anthropic")
+}
+
+// UsesOllama 是否使用Ollama原生接口（本地服务不需要API Key）
+func (a APIConfig) UsesOllama() bool {
+	return strings.EqualFold(strings.TrimSpace(a.Provider), "ollama")
+}
+
+// FileUsed 返回最近一次加载的配置文件路径
+func FileUsed() string {
+	return configFileUsed
+}
+
 // Get 获取全局配置
 func Get() *Config {
 	return globalConfig
@@ -0,0 +1,499 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agentcli/internal/fsutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置修改的操作类型
+const (
+	EditSet    = "set"    // 设置配置项的值
+	EditAdd    = "add"    // 向列表配置项追加元素（如 tools.enabled）
+	EditRemove = "remove" // 从列表配置项删除元素；配置项不是列表时删除该项，恢复默认值
+)
+
+// 配置修改历史的记录类型
+const (
+	EditActionEdit   = "edit"
+	EditActionRevert = "revert"
+)
+
+// maxEditRecords 配置修改历史保留的记录数
+const maxEditRecords = 50
+
+// editablePrefixes 代理可以修改的配置项：工具开关与限制、回答风格、意图分析、任务图、上下文、文档检索、长期记忆和终端输出
+// API、日志、审计、预算、组织协作、沙箱和MCP服务等配置只能由用户手动修改
+var editablePrefixes = []string{"tools.", "response.", "intent.", "dag.", "context.", "doc_lookup.", "recall.", "ui."}
+
+// protectedPrefixes 即使经过确认也不允许代理修改的配置项：命令执行策略和格式化命令会决定本机执行哪些命令
+var protectedPrefixes = []string{"tools.execute_command.", "tools.write_code.formatters"}
+
+// Edit 对配置文件的一项修改，Key 为以点分隔的配置项路径
+type Edit struct {
+	Key    string      `json:"key"`
+	Action string      `json:"action"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+// String 修改的简短描述，用于确认提示和修改历史
+func (e Edit) String() string {
+	if e.Action == EditRemove && e.Value == nil {
+		return fmt.Sprintf("remove %s", e.Key)
+	}
+	return fmt.Sprintf("%s %s %v", e.Action, e.Key, e.Value)
+}
+
+// CheckEditable 检查配置项是否允许代理修改；密钥类配置项一律不允许，避免密钥经过模型
+func CheckEditable(key string) error {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return fmt.Errorf("配置项不能为空")
+	}
+	for _, part := range strings.Split(key, ".") {
+		if isSecretKey(part) {
+			return fmt.Errorf("配置项 %s 是密钥，需由用户手动修改配置文件", key)
+		}
+	}
+	for _, prefix := range protectedPrefixes {
+		if strings.HasPrefix(key+".", prefix) || strings.HasPrefix(key, prefix) {
+			return fmt.Errorf("配置项 %s 决定本机执行的命令，需由用户手动修改配置文件", key)
+		}
+	}
+	for _, prefix := range editablePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("配置项 %s 不允许由代理修改，只能修改 %s 开头的配置项", key, strings.Join(editablePrefixes, "、"))
+}
+
+// isSecretKey 判断配置项是否为密钥（与配置包导出时脱敏的规则一致）
+func isSecretKey(key string) bool {
+	if strings.HasPrefix(key, "max_") {
+		return false
+	}
+	return key == "key" || strings.HasSuffix(key, "_key") || strings.HasSuffix(key, "apikey") ||
+		strings.Contains(key, "secret") || strings.Contains(key, "password") ||
+		key == "token" || strings.HasSuffix(key, "_token")
+}
+
+// ApplyEdits 在YAML配置内容上应用修改；只改写修改涉及的行，其他行（注释、空行、对齐）保持原样
+func ApplyEdits(data []byte, edits []Edit) ([]byte, error) {
+	for _, edit := range edits {
+		if err := CheckEditable(edit.Key); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = applyEditText(data, edit); err != nil {
+			return nil, fmt.Errorf("%s: %w", edit.Key, err)
+		}
+	}
+	return data, nil
+}
+
+// applyEditText 在YAML节点树上应用一项修改，再把重新序列化后变化的行替换回原文
+// 序列化会去掉空行并重新对齐注释，因此按去掉空白后的内容把序列化结果的行对应到原文的行；无法对应时使用整个序列化结果
+func applyEditText(data []byte, edit Edit) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("配置文件的顶层不是映射")
+	}
+	before, err := encodeYAML(&root)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyEdit(root.Content[0], edit); err != nil {
+		return nil, err
+	}
+	after, err := encodeYAML(&root)
+	if err != nil {
+		return nil, err
+	}
+
+	original := strings.Split(string(data), "\n")
+	oldLines, newLines := nonBlankLines(before), nonBlankLines(after)
+	lineOf := make([]int, 0, len(oldLines)) // 序列化结果的第i行对应原文的行号
+	for i := range original {
+		if len(lineOf) < len(oldLines) && normalizeLine(original[i]) == normalizeLine(oldLines[len(lineOf)]) {
+			lineOf = append(lineOf, i)
+		} else if strings.TrimSpace(original[i]) != "" {
+			break
+		}
+	}
+	if len(oldLines) == 0 || len(lineOf) != len(oldLines) {
+		return []byte(after), nil
+	}
+
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	// 替换原文中 [from, to) 行；纯插入时插在前一行之后
+	var from, to int
+	switch {
+	case prefix < len(oldLines)-suffix:
+		from, to = lineOf[prefix], lineOf[len(oldLines)-suffix-1]+1
+	case prefix > 0:
+		from = lineOf[prefix-1] + 1
+		to = from
+	default:
+		from = lineOf[0]
+		to = from
+	}
+	result := make([]string, 0, len(original)+len(newLines)-len(oldLines))
+	result = append(result, original[:from]...)
+	result = append(result, newLines[prefix:len(newLines)-suffix]...)
+	result = append(result, original[to:]...)
+	return []byte(strings.Join(result, "\n")), nil
+}
+
+func encodeYAML(root *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return "", fmt.Errorf("序列化配置文件失败: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("序列化配置文件失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func nonBlankLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// normalizeLine 去掉行内多余的空白，用于比较原文与序列化结果的行
+func normalizeLine(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func applyEdit(mapping *yaml.Node, edit Edit) error {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(edit.Key)), ".")
+	for _, part := range parts[:len(parts)-1] {
+		child := mappingValue(mapping, part)
+		if child == nil {
+			if edit.Action == EditRemove {
+				return fmt.Errorf("配置项不存在")
+			}
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, child)
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s 不是配置分组", part)
+		}
+		mapping = child
+	}
+	last := parts[len(parts)-1]
+	current := mappingValue(mapping, last)
+
+	switch edit.Action {
+	case EditSet:
+		value, err := valueNode(edit.Value)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: last}, value)
+			return nil
+		}
+		// 保留原配置项上的注释
+		value.HeadComment, value.LineComment, value.FootComment = current.HeadComment, current.LineComment, current.FootComment
+		*current = *value
+		return nil
+
+	case EditAdd:
+		items, err := itemNodes(edit.Value)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			current = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: last}, current)
+		}
+		if current.Kind != yaml.SequenceNode {
+			return fmt.Errorf("不是列表，请使用 set")
+		}
+		for _, item := range items {
+			if indexOf(current, item.Value) < 0 {
+				current.Content = append(current.Content, item)
+			}
+		}
+		return nil
+
+	case EditRemove:
+		if current == nil {
+			return fmt.Errorf("配置项不存在")
+		}
+		if current.Kind != yaml.SequenceNode || edit.Value == nil {
+			for i := 0; i+1 < len(mapping.Content); i += 2 {
+				if mapping.Content[i].Value == last {
+					mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+					break
+				}
+			}
+			return nil
+		}
+		items, err := itemNodes(edit.Value)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			i := indexOf(current, item.Value)
+			if i < 0 {
+				return fmt.Errorf("列表中没有 %s", item.Value)
+			}
+			current.Content = append(current.Content[:i], current.Content[i+1:]...)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("不支持的操作 %q，只能是 set、add 或 remove", edit.Action)
+	}
+}
+
+// mappingValue 映射中指定键的值节点，不存在时返回nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func indexOf(sequence *yaml.Node, value string) int {
+	for i, item := range sequence.Content {
+		if item.Kind == yaml.ScalarNode && item.Value == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// valueNode 将修改的值（来自JSON参数）转换为YAML节点，整数形式的数字写为整数
+func valueNode(value interface{}) (*yaml.Node, error) {
+	if value == nil {
+		return nil, fmt.Errorf("缺少修改后的值")
+	}
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			value = int64(v)
+		}
+	case []interface{}:
+		items, err := itemNodes(v)
+		if err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}, nil
+	case map[string]interface{}:
+		return nil, fmt.Errorf("值不能是对象，请分别修改其中的配置项")
+	}
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, fmt.Errorf("无法转换修改后的值: %w", err)
+	}
+	return &node, nil
+}
+
+// itemNodes 列表修改的元素，值可以是单个元素或数组
+func itemNodes(value interface{}) ([]*yaml.Node, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	items := make([]*yaml.Node, 0, len(values))
+	for _, v := range values {
+		switch v.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, fmt.Errorf("列表元素只能是字符串、数字或布尔值")
+		}
+		node, err := valueNode(v)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, node)
+	}
+	return items, nil
+}
+
+// ProposeEdits 读取配置文件并计算应用修改后的内容，修改后的配置需通过校验；返回修改前后的内容
+func ProposeEdits(path string, edits []Edit) (before, after []byte, err error) {
+	if len(edits) == 0 {
+		return nil, nil, fmt.Errorf("没有要修改的配置项")
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".yaml" && ext != ".yml" {
+		return nil, nil, fmt.Errorf("只支持修改YAML格式的配置文件: %s", path)
+	}
+	before, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	after, err = ApplyEdits(before, edits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(before, after) {
+		return nil, nil, fmt.Errorf("配置没有变化")
+	}
+	if _, err := Parse(after); err != nil {
+		return nil, nil, fmt.Errorf("修改后的配置无效: %w", err)
+	}
+	return before, after, nil
+}
+
+// WriteFile 校验配置内容后原子写入配置文件（保留原文件权限），返回解析后的配置
+func WriteFile(path string, data []byte) (*Config, error) {
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("配置无效，未写入: %w", err)
+	}
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := fsutil.WriteFileAtomic(path, data, perm); err != nil {
+		return nil, fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// EditRecord 配置修改历史中的一条记录，保存修改前后的完整内容以便回退
+type EditRecord struct {
+	Version      int       `json:"version"`
+	Action       string    `json:"action"`
+	Edits        []string  `json:"edits,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Before       string    `json:"before"`
+	After        string    `json:"after"`
+	RevertedFrom int       `json:"reverted_from,omitempty"` // 回退时撤销的记录号
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// EditHistoryPath 配置修改历史与配置文件存放在同一目录（config.yaml -> config.history.json）
+func EditHistoryPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".history.json"
+}
+
+// LoadEditHistory 读取配置文件的修改历史（按记录号从旧到新）
+func LoadEditHistory(path string) ([]EditRecord, error) {
+	var records []EditRecord
+	if _, err := fsutil.ReadJSONWithBackup(EditHistoryPath(path), &records); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取配置修改历史失败: %w", err)
+	}
+	return records, nil
+}
+
+// CommitEdits 写入已确认的修改并记录到修改历史；配置文件在提出修改后被改动过时不覆盖
+func CommitEdits(path string, before, after []byte, edits []Edit, reason string) (*Config, error) {
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if !bytes.Equal(current, before) {
+		return nil, fmt.Errorf("配置文件 %s 在提出修改后已被改动，未覆盖", path)
+	}
+	cfg, err := WriteFile(path, after)
+	if err != nil {
+		return nil, err
+	}
+	summary := make([]string, len(edits))
+	for i, edit := range edits {
+		summary[i] = edit.String()
+	}
+	record := EditRecord{Action: EditActionEdit, Edits: summary, Reason: reason, Before: string(before), After: string(after)}
+	return cfg, appendEditRecord(path, record)
+}
+
+// RevertEdit 撤销指定记录（version 为0时撤销最近一条），配置文件恢复为该记录修改前的内容，回退本身也记录到历史
+// 只有配置文件仍是该记录修改后的内容时才能撤销，之后的修改需先撤销
+func RevertEdit(path string, version int) (*EditRecord, *Config, error) {
+	records, err := LoadEditHistory(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("配置没有修改记录")
+	}
+	record := &records[len(records)-1]
+	if version != 0 {
+		record = nil
+		for i := range records {
+			if records[i].Version == version {
+				record = &records[i]
+			}
+		}
+		if record == nil {
+			return nil, nil, fmt.Errorf("配置修改记录不存在: %d（使用 /config history 查看）", version)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if string(current) != record.After {
+		return nil, nil, fmt.Errorf("配置文件在记录 #%d 之后已被修改，请先撤销之后的修改", record.Version)
+	}
+	cfg, err := WriteFile(path, []byte(record.Before))
+	if err != nil {
+		return nil, nil, err
+	}
+	reverted := *record
+	return &reverted, cfg, appendEditRecord(path, EditRecord{
+		Action:       EditActionRevert,
+		Before:       record.After,
+		After:        record.Before,
+		RevertedFrom: record.Version,
+	})
+}
+
+// appendEditRecord 追加一条修改记录，超出保留数量时删除最早的记录
+func appendEditRecord(path string, record EditRecord) error {
+	records, err := LoadEditHistory(path)
+	if err != nil {
+		return err
+	}
+	record.Version = 1
+	if len(records) > 0 {
+		record.Version = records[len(records)-1].Version + 1
+	}
+	record.CreatedAt = time.Now()
+	records = append(records, record)
+	if len(records) > maxEditRecords {
+		records = records[len(records)-maxEditRecords:]
+	}
+	// 历史中包含完整的配置内容（可能有密钥），与配置文件一样只允许当前用户读取
+	if err := fsutil.WriteJSONAtomic(EditHistoryPath(path), records, 0600); err != nil {
+		return fmt.Errorf("写入配置修改历史失败: %w", err)
+	}
+	return nil
+}
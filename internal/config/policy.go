@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// OrgPolicyEnv 组织策略文件路径的环境变量
+const OrgPolicyEnv = "AGENTCLI_ORG_POLICY"
+
+// OrgPolicy 组织级策略：由管理员下发的只读策略文件（路径由环境变量 AGENTCLI_ORG_POLICY 指定），
+// 在用户配置之后应用，用户配置和交互命令都无法放宽其中的限制
+type OrgPolicy struct {
+	Path string `mapstructure:"-"` // 策略文件路径
+	// ForbiddenTools 禁止使用的工具，支持通配符，同样作用于MCP工具（如 "github_*" 禁止github服务的所有工具）
+	ForbiddenTools []string `mapstructure:"forbidden_tools"`
+	// Budget 预算上限，用户配置的限额更宽松或未设置时使用策略中的限额
+	Budget PolicyBudget `mapstructure:"budget"`
+	// RequireConfirmation 执行命令前的最低确认要求: destructive/always，用户配置更严格时保留用户配置
+	RequireConfirmation string `mapstructure:"require_confirmation"`
+	// DisableAutoApprove 禁止使用 --yes 跳过命令确认
+	DisableAutoApprove bool `mapstructure:"disable_auto_approve"`
+	// Provider、BaseURL 固定使用的服务协议和服务地址，覆盖用户配置；长期记忆的向量化服务同样固定为 BaseURL
+	Provider string `mapstructure:"provider"`
+	BaseURL  string `mapstructure:"base_url"`
+	// Models 允许使用的模型，为空时不限制
+	Models []string `mapstructure:"models"`
+}
+
+// PolicyBudget 组织策略中的预算上限
+type PolicyBudget struct {
+	Session BudgetLimits `mapstructure:"session"`
+	Daily   BudgetLimits `mapstructure:"daily"`
+}
+
+// LoadOrgPolicy 加载环境变量指定的组织策略，未设置时返回nil；
+// 设置了路径但文件无法读取或解析时返回错误，避免策略被绕过
+func LoadOrgPolicy() (*OrgPolicy, error) {
+	path := strings.TrimSpace(os.Getenv(OrgPolicyEnv))
+	if path == "" {
+		return nil, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if filepath.Ext(path) == "" {
+		v.SetConfigType("yaml")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取组织策略文件失败（%s=%s）: %w", OrgPolicyEnv, path, err)
+	}
+	var policy OrgPolicy
+	if err := v.Unmarshal(&policy); err != nil {
+		return nil, fmt.Errorf("解析组织策略文件失败: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(policy.RequireConfirmation)) {
+	case "", "destructive", "always":
+	default:
+		return nil, fmt.Errorf("解析组织策略文件失败: require_confirmation 只能是 destructive 或 always，当前为 %q", policy.RequireConfirmation)
+	}
+	policy.Path = path
+	return &policy, nil
+}
+
+// Apply 将策略应用到用户配置：固定服务、收紧预算和确认要求、移除禁止的工具；
+// 当前模型或共识、意图分类、命令解释、识图等配置的模型不在允许范围内时返回错误
+func (p *OrgPolicy) Apply(cfg *Config) error {
+	if p.Provider != "" {
+		cfg.API.Provider = p.Provider
+	}
+	if p.BaseURL != "" {
+		cfg.API.BaseURL = p.BaseURL
+		cfg.Recall.BaseURL = p.BaseURL
+	}
+	if err := p.CheckModel(cfg.API.Model); err != nil {
+		return err
+	}
+	for _, setting := range modelSettings(cfg) {
+		if err := p.CheckModel(setting[1]); err != nil {
+			return fmt.Errorf("%s: %w", setting[0], err)
+		}
+	}
+
+	cfg.Budget.Session = capLimits(cfg.Budget.Session, p.Budget.Session)
+	cfg.Budget.Daily = capLimits(cfg.Budget.Daily, p.Budget.Daily)
+
+	if p.RequireConfirmation != "" && confirmLevel(p.RequireConfirmation) > confirmLevel(cfg.Tools.ExecuteCommand.RequireConfirmation) {
+		cfg.Tools.ExecuteCommand.RequireConfirmation = strings.ToLower(strings.TrimSpace(p.RequireConfirmation))
+	}
+
+	enabled := cfg.Tools.Enabled[:0:0]
+	for _, name := range cfg.Tools.Enabled {
+		if !p.ForbidsTool(name) {
+			enabled = append(enabled, name)
+		}
+	}
+	cfg.Tools.Enabled = enabled
+	cfg.Policy = p
+	return nil
+}
+
+// ForbidsTool 工具是否被策略禁止（nil策略不禁止任何工具）
+func (p *OrgPolicy) ForbidsTool(name string) bool {
+	if p == nil {
+		return false
+	}
+	for _, pattern := range p.ForbiddenTools {
+		if pattern == name {
+			return true
+		}
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckModel 检查模型是否在策略允许的范围内（nil策略允许所有模型）
+func (p *OrgPolicy) CheckModel(model string) error {
+	if p == nil || len(p.Models) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Models {
+		if allowed == model {
+			return nil
+		}
+	}
+	return fmt.Errorf("模型 %s 不在组织策略允许的范围内（允许: %s）", model, strings.Join(p.Models, ", "))
+}
+
+// modelSettings 配置中另外指定的模型（配置项, 模型），未设置的配置项使用当前模型，不在其中
+func modelSettings(cfg *Config) [][2]string {
+	var settings [][2]string
+	for _, model := range cfg.Consensus.Models {
+		settings = append(settings, [2]string{"consensus.models", model})
+	}
+	for _, setting := range [][2]string{
+		{"consensus.judge_model", cfg.Consensus.JudgeModel},
+		{"intent.classifier_model", cfg.Intent.ClassifierModel},
+		{"tools.execute_command.explain_model", cfg.Tools.ExecuteCommand.ExplainModel},
+		{"tools.recognize_image.model", cfg.Tools.RecognizeImage.Model},
+	} {
+		if setting[1] != "" {
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+// Restrictions 策略中生效的限制，用于 /capabilities 展示
+func (p *OrgPolicy) Restrictions() []string {
+	if p == nil {
+		return nil
+	}
+	var items []string
+	if len(p.ForbiddenTools) > 0 {
+		items = append(items, "禁止的工具: "+strings.Join(p.ForbiddenTools, ", "))
+	}
+	if len(p.Models) > 0 {
+		items = append(items, "允许的模型: "+strings.Join(p.Models, ", "))
+	}
+	if p.Provider != "" {
+		items = append(items, "固定服务协议: "+p.Provider)
+	}
+	if p.BaseURL != "" {
+		items = append(items, "固定服务地址（含长期记忆）: "+p.BaseURL)
+	}
+	if limits := describeLimits(p.Budget.Session); limits != "" {
+		items = append(items, "会话预算上限: "+limits)
+	}
+	if limits := describeLimits(p.Budget.Daily); limits != "" {
+		items = append(items, "每日预算上限: "+limits)
+	}
+	if p.RequireConfirmation != "" {
+		items = append(items, "执行命令前至少确认: "+p.RequireConfirmation)
+	}
+	if p.DisableAutoApprove {
+		items = append(items, "禁止使用 --yes 跳过确认")
+	}
+	return items
+}
+
+// capLimits 取用户限额与策略上限中更严格的一个（0表示不限制）
+func capLimits(user, limit BudgetLimits) BudgetLimits {
+	user.MaxTokens = capInt(user.MaxTokens, limit.MaxTokens)
+	user.MaxToolCalls = capInt(user.MaxToolCalls, limit.MaxToolCalls)
+	if limit.MaxCost > 0 && (user.MaxCost <= 0 || user.MaxCost > limit.MaxCost) {
+		user.MaxCost = limit.MaxCost
+	}
+	return user
+}
+
+func capInt(user, limit int) int {
+	if limit > 0 && (user <= 0 || user > limit) {
+		return limit
+	}
+	return user
+}
+
+// confirmLevel 确认要求的严格程度：never < destructive（默认）< always
+func confirmLevel(mode string) int {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "never":
+		return 0
+	case "always":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// describeLimits 格式化预算限额，未设置任何限额时返回空字符串
+func describeLimits(limits BudgetLimits) string {
+	var parts []string
+	if limits.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("tokens %d", limits.MaxTokens))
+	}
+	if limits.MaxCost > 0 {
+		parts = append(parts, fmt.Sprintf("费用 $%.2f", limits.MaxCost))
+	}
+	if limits.MaxToolCalls > 0 {
+		parts = append(parts, fmt.Sprintf("工具调用 %d", limits.MaxToolCalls))
+	}
+	return strings.Join(parts, "，")
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrgPolicyApplyChecksEveryModel(t *testing.T) {
+	policy := &OrgPolicy{Models: []string{"gpt-4o"}}
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr string
+	}{
+		{"允许的模型", func(cfg *Config) {}, ""},
+		{"当前模型", func(cfg *Config) { cfg.API.Model = "o1" }, "o1"},
+		{"共识模型", func(cfg *Config) { cfg.Consensus.Models = []string{"gpt-4o", "o1"} }, "consensus.models"},
+		{"共识评审模型", func(cfg *Config) { cfg.Consensus.JudgeModel = "o1" }, "consensus.judge_model"},
+		{"意图分类模型", func(cfg *Config) { cfg.Intent.ClassifierModel = "o1" }, "intent.classifier_model"},
+		{"命令解释模型", func(cfg *Config) { cfg.Tools.ExecuteCommand.ExplainModel = "o1" }, "tools.execute_command.explain_model"},
+		{"识图模型", func(cfg *Config) { cfg.Tools.RecognizeImage.Model = "o1" }, "tools.recognize_image.model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.API.Model = "gpt-4o"
+			tt.modify(cfg)
+			err := policy.Apply(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Apply: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Apply error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 代理通过 update_config 修改的配置在写入前按组织策略解析：recall.base_url 固定为策略地址，不允许的模型被拒绝
+func TestOrgPolicyAppliesToConfigEdits(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.yaml")
+	if err := os.WriteFile(policyPath, []byte("base_url: https://llm.example.com/v1\nmodels: [gpt-4o]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(OrgPolicyEnv, policyPath)
+	t.Setenv("OPENAI_API_KEY", "test")
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("api:\n  model: gpt-4o\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, after, err := ProposeEdits(configPath, []Edit{{Key: "recall.base_url", Action: EditSet, Value: "https://evil.example.com"}})
+	if err != nil {
+		t.Fatalf("ProposeEdits: %v", err)
+	}
+	cfg, err := Parse(after)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Recall.BaseURL != "https://llm.example.com/v1" {
+		t.Fatalf("recall.base_url = %q, want 策略固定的地址", cfg.Recall.BaseURL)
+	}
+
+	_, _, err = ProposeEdits(configPath, []Edit{{Key: "intent.classifier_model", Action: EditSet, Value: "o1"}})
+	if err == nil || !strings.Contains(err.Error(), "intent.classifier_model") {
+		t.Fatalf("ProposeEdits error = %v, want 模型不在允许范围内", err)
+	}
+}
@@ -142,7 +142,7 @@ func (d *DAG) executeNodes(ctx context.Context) error {
 		// 获取可执行节点
 		executableNodes := d.getExecutableNodes()
 		if len(executableNodes) == 0 {
-			// 检查是否有失败的节点
+			// 检查是否有失败的必需节点
 			if d.hasFailedNodes() {
 				return fmt.Errorf("存在失败的节点")
 			}
@@ -164,6 +164,13 @@ func (d *DAG) executeNodes(ctx context.Context) error {
 				d.prepareDependencyOutputs(n)
 
 				if err := n.Execute(ctx); err != nil {
+					// 可选节点失败不中断执行，依赖它的节点照常执行
+					if n.Optional && ctx.Err() == nil {
+						if d.verbose {
+							fmt.Printf("可选节点失败，继续执行: %v\n", err)
+						}
+						return
+					}
 					errChan <- err
 				}
 			}(node)
@@ -199,13 +206,13 @@ func (d *DAG) getExecutableNodes() []*Node {
 	return executable
 }
 
-// hasFailedNodes 是否有失败的节点
+// hasFailedNodes 是否有失败的必需节点（可选节点失败不影响后续执行）
 func (d *DAG) hasFailedNodes() bool {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	for _, node := range d.nodes {
-		if node.IsFailed() {
+		if node.IsFailed() && !node.Optional {
 			return true
 		}
 	}
@@ -227,12 +234,19 @@ func (d *DAG) getCompletedCount() int {
 }
 
 // GetResults 获取所有节点结果
+// 执行中途失败时也可调用：已完成节点返回其输出，失败节点返回 {"error": 错误信息}，未执行的节点输出为空
 func (d *DAG) GetResults() map[string]map[string]interface{} {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	results := make(map[string]map[string]interface{})
 	for id, node := range d.nodes {
+		if node.IsFailed() {
+			if err := node.GetError(); err != nil {
+				results[id] = map[string]interface{}{FailureErrorKey: err.Error()}
+				continue
+			}
+		}
 		results[id] = node.Output
 	}
 	return results
@@ -244,12 +258,23 @@ func (d *DAG) prepareDependencyOutputs(node *Node) {
 	defer d.mu.RUnlock()
 
 	// 遍历所有依赖节点
+	failed := make(map[string]string)
 	for _, depID := range node.Dependencies {
 		if depNode, ok := d.nodes[depID]; ok {
+			// 失败的可选依赖没有输出，改为告知失败原因
+			if depNode.IsFailed() {
+				if err := depNode.GetError(); err != nil {
+					failed[depID] = err.Error()
+				}
+				continue
+			}
 			// 将依赖节点的输出合并到当前节点的输入
 			for key, value := range depNode.Output {
 				node.SetInput(key, value)
 			}
 		}
 	}
+	if len(failed) > 0 {
+		node.SetInput(FailedDependenciesKey, failed)
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // NodeType 节点类型
@@ -39,9 +40,39 @@ type Node struct {
 	Output      map[string]interface{} // 输出数据
 	Error       error                  // 错误信息
 	Handler     NodeHandler            // 节点处理器
+	Retry       RetryPolicy            // 失败重试策略
+	OnFailure   NodeHandler            // 重试耗尽后的降级处理器（可选），成功时节点视为完成
+	Optional    bool                   // 可选节点：失败时不中断DAG，依赖它的节点照常执行
+	Attempts    int                    // 处理器的实际执行次数
+	Recovered   bool                   // 是否由降级处理器完成
 	mu          sync.RWMutex           // 互斥锁
 }
 
+// RetryPolicy 节点失败重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多执行次数（含首次），<=1 表示不重试
+	Backoff     time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff  time.Duration // 等待时间上限，0表示不限制
+}
+
+// delay 第 attempt 次执行失败后、下一次执行前的等待时间
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// 框架写入节点输入的键
+const (
+	FailureErrorKey       = "error"               // 降级处理器的输入中，节点最后一次失败的错误信息
+	FailedDependenciesKey = "failed_dependencies" // 失败的可选依赖节点 ID→错误信息（map[string]string）
+)
+
 // NodeHandler 节点处理器接口
 type NodeHandler interface {
 	Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error)
@@ -103,28 +134,67 @@ func (n *Node) Execute(ctx context.Context) error {
 	}
 	n.mu.Unlock()
 
-	// 执行处理器
-	if n.Handler != nil {
-		output, err := n.Handler.Execute(ctx, inputCopy)
-		n.mu.Lock()
-		if err != nil {
-			n.Status = NodeStatusFailed
-			n.Error = err
-			n.mu.Unlock()
-			return fmt.Errorf("节点 %s 执行失败: %w", n.ID, err)
-		}
-		n.Output = output
-		n.Status = NodeStatusCompleted
-		n.mu.Unlock()
-	} else {
+	if n.Handler == nil {
 		n.mu.Lock()
 		n.Status = NodeStatusCompleted
 		n.mu.Unlock()
+		return nil
 	}
 
+	// 执行处理器，失败时按重试策略重试
+	output, attempts, err := n.runWithRetry(ctx, inputCopy)
+	recovered := false
+	if err != nil && n.OnFailure != nil && ctx.Err() == nil {
+		inputCopy[FailureErrorKey] = err.Error()
+		if fallback, fallbackErr := n.OnFailure.Execute(ctx, inputCopy); fallbackErr == nil {
+			output, err, recovered = fallback, nil, true
+		} else {
+			err = fmt.Errorf("%w（降级处理也失败: %v）", err, fallbackErr)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Attempts = attempts
+	if err != nil {
+		n.Status = NodeStatusFailed
+		n.Error = err
+		return fmt.Errorf("节点 %s 执行失败: %w", n.ID, err)
+	}
+	n.Output = output
+	n.Recovered = recovered
+	n.Status = NodeStatusCompleted
 	return nil
 }
 
+// runWithRetry 执行处理器，失败时按退避时间重试，上下文取消时不再重试
+func (n *Node) runWithRetry(ctx context.Context, input map[string]interface{}) (map[string]interface{}, int, error) {
+	maxAttempts := max(n.Retry.MaxAttempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		var output map[string]interface{}
+		output, err = n.Handler.Execute(ctx, input)
+		if err == nil {
+			return output, attempt, nil
+		}
+		if attempt >= maxAttempts || ctx.Err() != nil {
+			return nil, attempt, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, attempt, err
+		case <-time.After(n.Retry.delay(attempt)):
+		}
+	}
+}
+
+// GetError 获取节点失败的错误
+func (n *Node) GetError() error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.Error
+}
+
 // GetStatus 获取节点状态
 func (n *Node) GetStatus() NodeStatus {
 	n.mu.RLock()
@@ -147,10 +217,10 @@ func (n *Node) CanExecute(nodes map[string]*Node) bool {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
-	// 检查所有依赖是否已完成
+	// 检查所有依赖是否已完成（可选节点失败也视为已结束）
 	for _, depID := range n.Dependencies {
 		if depNode, ok := nodes[depID]; ok {
-			if !depNode.IsCompleted() {
+			if !depNode.IsCompleted() && !(depNode.Optional && depNode.IsFailed()) {
 				return false
 			}
 		}
@@ -0,0 +1,183 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"agentcli/internal/clock"
+	"agentcli/internal/llm"
+)
+
+// 事件类型
+const (
+	KindLLM  = "llm"
+	KindTool = "tool"
+)
+
+// Event 会话事件日志中的一条记录（一次LLM请求或一次工具执行）
+type Event struct {
+	Seq        int64                  `json:"seq"` // 会话内单调递增，与会话日志共用编号
+	Kind       string                 `json:"kind"`
+	Time       time.Time              `json:"time"`
+	DurationMS int64                  `json:"duration_ms"`
+	Error      string                 `json:"error,omitempty"`
+	LLM        *llm.CallRecord        `json:"llm,omitempty"`
+	Tool       string                 `json:"tool,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Result     interface{}            `json:"result,omitempty"`
+}
+
+// Recorder 将会话中的LLM请求和工具执行逐条写入 logs/<日期>/<会话ID>.events.jsonl
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// fileName 会话事件日志文件名
+func fileName(sessionID string) string {
+	return sessionID + ".events.jsonl"
+}
+
+// Open 创建（或追加到）会话的事件日志，与文本日志放在同一目录
+func Open(sessionID string) (*Recorder, error) {
+	dir := filepath.Join("logs", time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	path := filepath.Join(dir, fileName(sessionID))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("创建事件日志失败: %w", err)
+	}
+	return &Recorder{file: file, path: path}, nil
+}
+
+// Path 事件日志文件路径
+func (r *Recorder) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// RecordCall 记录一次LLM请求，实现 llm.CallTracer
+func (r *Recorder) RecordCall(record llm.CallRecord) {
+	r.write(Event{
+		Kind:       KindLLM,
+		Time:       record.Started,
+		DurationMS: record.Duration.Milliseconds(),
+		Error:      record.Error,
+		LLM:        &record,
+	})
+}
+
+// RecordTool 记录一次工具执行
+func (r *Recorder) RecordTool(name string, params map[string]interface{}, result interface{}, started time.Time, err error) {
+	event := Event{
+		Kind:       KindTool,
+		Time:       started,
+		DurationMS: time.Since(started).Milliseconds(),
+		Tool:       name,
+		Params:     params,
+		Result:     result,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.write(event)
+}
+
+func (r *Recorder) write(event Event) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.Seq = clock.Session.Next()
+	data, err := json.Marshal(event)
+	if err != nil {
+		// 工具结果无法序列化时只保留文本形式
+		event.Result = fmt.Sprintf("%v", event.Result)
+		if data, err = json.Marshal(event); err != nil {
+			return
+		}
+	}
+	r.file.Write(append(data, '\n'))
+}
+
+// Close 关闭事件日志
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// Load 读取事件日志文件
+func Load(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("解析事件日志第%d行失败: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取事件日志失败: %w", err)
+	}
+	return events, nil
+}
+
+// LoadSession 按会话ID读取事件日志，跨天恢复的会话按日期顺序合并
+func LoadSession(sessionID string) ([]Event, error) {
+	paths, err := filepath.Glob(filepath.Join("logs", "*", fileName(sessionID)))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("未找到会话 %s 的事件日志", sessionID)
+	}
+	sort.Strings(paths)
+
+	var events []Event
+	for _, path := range paths {
+		loaded, err := Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, loaded...)
+	}
+	// 按序号排序，不依赖可能被调整过的系统时钟
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	return events, nil
+}
+
+// LastSeq 会话已有事件的最大序号，恢复会话时用于接续编号；没有事件时返回0
+func LastSeq(sessionID string) int64 {
+	events, err := LoadSession(sessionID)
+	if err != nil || len(events) == 0 {
+		return 0
+	}
+	return events[len(events)-1].Seq
+}
@@ -0,0 +1,163 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"agentcli/internal/llm"
+)
+
+// evidenceMinLen 参数值少于该长度时不在提示词中查找来源，避免短值误匹配
+const evidenceMinLen = 3
+
+// evidenceMaxQuote 来源引用的最大字符数
+const evidenceMaxQuote = 120
+
+// Decision 一次工具执行及促成它的LLM请求，全部取自事件日志中实际记录的内容
+type Decision struct {
+	Tool     Event                  // 工具执行记录
+	Source   *Event                 // 返回该工具调用的LLM请求，没有找到时为nil
+	Call     *llm.ToolCall          // 对应的原生工具调用；文本工具调用（ReAct、任务规划）时为nil
+	Trigger  []llm.Message          // 请求中最后一条assistant消息之后的内容（用户消息或工具结果），即模型做出该决定前看到的新输入
+	Evidence []Evidence             // 各参数值在请求消息中的出处
+	Offered  []string               // 请求中提供给模型的工具
+	Args     map[string]interface{} // 模型给出的参数；没有原生调用时为实际执行的参数
+}
+
+// Evidence 参数值在LLM请求消息中的出处；Index<0 表示提示词中没有出现，由模型生成
+type Evidence struct {
+	Param string
+	Value string
+	Role  string
+	Index int    // 消息序号（从0开始）
+	Quote string // 包含该值的那一行
+}
+
+// Explain 将一轮的事件（按序号排列）整理为工具调用决定：为每次工具执行找到返回该调用的LLM请求，
+// 并在请求的消息中查找各参数值的出处
+func Explain(events []Event) []Decision {
+	var decisions []Decision
+	consumed := make(map[int64]map[int]bool) // LLM事件序号 -> 已匹配的工具调用下标
+	for i, event := range events {
+		if event.Kind != KindTool {
+			continue
+		}
+		d := Decision{Tool: event, Args: event.Params}
+		if source, call := findSource(events[:i], event.Tool, consumed); source != nil {
+			d.Source = source
+			d.Call = call
+			d.Trigger = trigger(source.LLM.Messages)
+			for _, tool := range source.LLM.Tools {
+				d.Offered = append(d.Offered, tool.Function.Name)
+			}
+			if call != nil {
+				var args map[string]interface{}
+				if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err == nil {
+					d.Args = args
+				}
+			}
+			d.Evidence = findEvidence(d.Args, source.LLM.Messages)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+// findSource 向前查找返回了该工具调用的LLM请求：优先匹配原生工具调用，其次匹配回答文本中提到该工具的请求（文本工具调用、任务规划）
+func findSource(events []Event, tool string, consumed map[int64]map[int]bool) (*Event, *llm.ToolCall) {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := &events[i]
+		if e.Kind != KindLLM || e.LLM == nil {
+			continue
+		}
+		for j := range e.LLM.ToolCalls {
+			if e.LLM.ToolCalls[j].Function.Name != tool || consumed[e.Seq][j] {
+				continue
+			}
+			if consumed[e.Seq] == nil {
+				consumed[e.Seq] = make(map[int]bool)
+			}
+			consumed[e.Seq][j] = true
+			return e, &e.LLM.ToolCalls[j]
+		}
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		e := &events[i]
+		if e.Kind == KindLLM && e.LLM != nil && strings.Contains(e.LLM.Content, tool) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// trigger 最后一条assistant消息之后的消息
+func trigger(messages []llm.Message) []llm.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" {
+			return messages[i+1:]
+		}
+	}
+	// 首次请求：系统提示词之后的消息
+	for i, msg := range messages {
+		if msg.Role != "system" {
+			return messages[i:]
+		}
+	}
+	return nil
+}
+
+// findEvidence 在请求消息中查找各参数值的出处，从最新的消息往前找
+func findEvidence(args map[string]interface{}, messages []llm.Message) []Evidence {
+	params := make([]string, 0, len(args))
+	for param := range args {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	var evidence []Evidence
+	for _, param := range params {
+		value := argString(args[param])
+		if len([]rune(value)) < evidenceMinLen || strings.Contains(value, "\n") {
+			continue // 过短或多行的值（如代码内容）无法逐行引用
+		}
+		e := Evidence{Param: param, Value: value, Index: -1}
+		for i := len(messages) - 1; i >= 0; i-- {
+			if line, ok := lineContaining(messages[i].Content, value); ok {
+				e.Role, e.Index, e.Quote = messages[i].Role, i, line
+				break
+			}
+		}
+		evidence = append(evidence, e)
+	}
+	return evidence
+}
+
+func argString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64, bool, int, int64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// lineContaining 返回内容中包含该值的第一行（截断到 evidenceMaxQuote）
+func lineContaining(content, value string) (string, bool) {
+	idx := strings.Index(content, value)
+	if idx < 0 {
+		return "", false
+	}
+	start := strings.LastIndex(content[:idx], "\n") + 1
+	end := len(content)
+	if n := strings.Index(content[idx:], "\n"); n >= 0 {
+		end = idx + n
+	}
+	line := []rune(strings.TrimSpace(content[start:end]))
+	if len(line) > evidenceMaxQuote {
+		line = append(line[:evidenceMaxQuote], []rune("...")...)
+	}
+	return string(line), true
+}
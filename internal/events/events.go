@@ -0,0 +1,70 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	LLMRetry         Type = "llm_retry"          // LLM请求失败，等待后重试
+	LLMRateLimited   Type = "llm_rate_limited"   // LLM请求被限流
+	LLMTimeout       Type = "llm_timeout"        // LLM请求超时
+	LLMStreamResumed Type = "llm_stream_resumed" // 流式响应连接中断，携带已接收的内容重新请求续接
+	LLMStreamWaiting Type = "llm_stream_waiting" // 流式响应暂时没有数据，Delay为已等待的时间
+	LLMStreamActive  Type = "llm_stream_active"  // 等待后重新收到数据（或流式响应结束）
+	LLMStreamStalled Type = "llm_stream_stalled" // 流式响应超过空闲超时（Delay）没有数据，已中断连接
+)
+
+// Event 运行过程中的事件，用于向用户展示进度
+type Event struct {
+	Type        Type
+	Time        time.Time
+	Model       string
+	Attempt     int           // 当前尝试次数（从1开始）
+	MaxAttempts int           // 最大尝试次数
+	Delay       time.Duration // 重试前的等待时间
+	Err         error
+}
+
+// Handler 事件处理函数
+type Handler func(Event)
+
+// Bus 事件总线
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 订阅事件
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish 发布事件（nil总线上发布不做任何事）
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := make([]Handler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
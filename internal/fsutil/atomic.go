@@ -0,0 +1,124 @@
+package fsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix 上一个完好版本的备份文件后缀
+const BackupSuffix = ".bak"
+
+// WriteFileAtomic 原子写入文件：先写入同目录下的临时文件并fsync，再重命名覆盖目标文件
+// 目标文件已存在时，会先将其保留为 .bak 备份，写入过程中崩溃不会损坏原文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeAtomic(path, data, perm, true)
+}
+
+// ReplaceFile 原子写入文件但不保留备份，用于工具写入用户的工作区文件：
+// 写入中途被中断时目标文件保持原样，不会留下只写了一半的文件
+func ReplaceFile(path string, data []byte, perm os.FileMode) error {
+	return writeAtomic(path, data, perm, false)
+}
+
+func writeAtomic(path string, data []byte, perm os.FileMode, backup bool) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // 重命名成功后该文件已不存在，删除失败可以忽略
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("同步临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("关闭临时文件失败: %w", err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+
+	// 仅在现有文件完好时才更新备份，避免用损坏的文件覆盖可用的备份
+	if backup {
+		if existing, err := os.ReadFile(path); err == nil && len(existing) > 0 && (filepath.Ext(path) != ".json" || json.Valid(existing)) {
+			if err := os.Rename(path, path+BackupSuffix); err != nil {
+				return fmt.Errorf("备份原文件失败: %w", err)
+			}
+		}
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("替换文件失败: %w", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// WriteJSONAtomic 序列化为带缩进的JSON并原子写入文件
+func WriteJSONAtomic(path string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化失败: %w", err)
+	}
+	return WriteFileAtomic(path, data, perm)
+}
+
+// ReadJSONWithBackup 读取JSON文件，主文件缺失或损坏时尝试从 .bak 备份恢复
+// recovered 为true表示数据来自备份，此时主文件已用备份内容修复
+// 主文件和备份都不存在时返回 os.ErrNotExist
+func ReadJSONWithBackup(path string, v interface{}) (recovered bool, err error) {
+	data, readErr := os.ReadFile(path)
+	if readErr == nil {
+		err := json.Unmarshal(data, v)
+		if err == nil {
+			return false, nil
+		}
+		readErr = fmt.Errorf("文件已损坏: %w", err)
+	} else if !os.IsNotExist(readErr) {
+		return false, readErr
+	}
+
+	backup, err := os.ReadFile(path + BackupSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, readErr
+		}
+		return false, fmt.Errorf("读取备份文件失败: %w", err)
+	}
+	if err := json.Unmarshal(backup, v); err != nil {
+		return false, fmt.Errorf("%v，备份文件同样无法解析: %w", readErr, err)
+	}
+
+	// 保留损坏的文件以便排查，再用备份修复主文件
+	if data != nil {
+		os.Rename(path, path+".corrupt")
+	}
+	// 修复失败不影响本次读取，下次保存时会重新写入主文件
+	WriteFileAtomic(path, backup, 0644)
+	return true, nil
+}
+
+// RemoveWithBackup 删除文件及其备份
+func RemoveWithBackup(path string) error {
+	err := os.Remove(path)
+	os.Remove(path + BackupSuffix)
+	return err
+}
+
+// syncDir fsync目录，确保重命名操作落盘（部分平台不支持，忽略错误）
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,160 @@
+package history
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agentcli/internal/clock"
+	"agentcli/internal/fsutil"
+)
+
+// archiveDir 归档对话所在的子目录，其中的对话不出现在 /history 列表中
+const archiveDir = "archive"
+
+// ArchivedConversation 归档对话的概要
+type ArchivedConversation struct {
+	ID           string
+	Title        string
+	SummaryPath  string // 项目总结文档
+	ArchivePath  string // 压缩的完整对话记录
+	ArchivedAt   time.Time
+	MessageCount int
+}
+
+// archivePaths 归档对话的完整记录和总结文档路径
+func (m *Manager) archivePaths(id string) (transcript, summary string) {
+	dir := filepath.Join(m.historyDir, archiveDir)
+	return filepath.Join(dir, id+".json.gz"), filepath.Join(dir, id+".md")
+}
+
+// ArchiveConversation 归档对话：总结写入对话并另存为 archive/<id>.md，完整记录压缩保存为 archive/<id>.json.gz，
+// 两者都写入成功后才从历史列表中删除原对话
+func (m *Manager) ArchiveConversation(conv *Conversation, summary string) (*ArchivedConversation, error) {
+	transcriptPath, summaryPath := m.archivePaths(conv.ID)
+	if err := os.MkdirAll(filepath.Dir(transcriptPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+
+	conv.Summary = summary
+	data, err := json.Marshal(conv)
+	if err != nil {
+		return nil, fmt.Errorf("序列化对话失败: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = conv.ID + ".json"
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("压缩对话失败: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("压缩对话失败: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(transcriptPath, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("保存归档失败: %w", err)
+	}
+
+	now := time.Now()
+	if err := fsutil.WriteFileAtomic(summaryPath, []byte(closingDocument(conv, now)), 0644); err != nil {
+		return nil, fmt.Errorf("保存项目总结失败: %w", err)
+	}
+
+	if err := m.DeleteConversation(conv.ID); err != nil {
+		return nil, err
+	}
+	return &ArchivedConversation{
+		ID:           conv.ID,
+		Title:        conv.Title,
+		SummaryPath:  summaryPath,
+		ArchivePath:  transcriptPath,
+		ArchivedAt:   now,
+		MessageCount: len(conv.Messages),
+	}, nil
+}
+
+// closingDocument 归档时保存的项目总结文档
+func closingDocument(conv *Conversation, archivedAt time.Time) string {
+	title := conv.Title
+	if title == "" {
+		title = conv.ID
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# 项目总结: %s\n\n", title)
+	fmt.Fprintf(&sb, "- 对话ID: %s\n", conv.ID)
+	fmt.Fprintf(&sb, "- 模型: %s\n", conv.Model)
+	fmt.Fprintf(&sb, "- 时间: %s ~ %s\n", clock.Format(conv.Created), clock.Format(conv.Updated))
+	fmt.Fprintf(&sb, "- 消息数: %d\n", len(conv.Messages))
+	fmt.Fprintf(&sb, "- 归档时间: %s\n\n", clock.Format(archivedAt))
+	sb.WriteString(strings.TrimSpace(conv.Summary))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// LoadArchivedConversation 读取归档对话的完整记录
+func (m *Manager) LoadArchivedConversation(id string) (*Conversation, error) {
+	transcriptPath, _ := m.archivePaths(id)
+	file, err := os.Open(transcriptPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("归档对话不存在: %s", id)
+		}
+		return nil, fmt.Errorf("读取归档失败: %w", err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("解压归档失败: %w", err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("解压归档失败: %w", err)
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("解析归档失败: %w", err)
+	}
+	conv.numberMessages()
+	return &conv, nil
+}
+
+// ListArchived 列出归档的对话，最近归档的在前
+func (m *Manager) ListArchived(userID string) ([]*ArchivedConversation, error) {
+	matches, err := filepath.Glob(filepath.Join(m.historyDir, archiveDir, "*.json.gz"))
+	if err != nil {
+		return nil, err
+	}
+	var archived []*ArchivedConversation
+	for _, path := range matches {
+		id := strings.TrimSuffix(filepath.Base(path), ".json.gz")
+		conv, err := m.LoadArchivedConversation(id)
+		if err != nil || (userID != "" && conv.UserID != userID) {
+			continue
+		}
+		entry := &ArchivedConversation{ID: id, Title: conv.Title, ArchivePath: path, MessageCount: len(conv.Messages)}
+		if info, err := os.Stat(path); err == nil {
+			entry.ArchivedAt = info.ModTime()
+		}
+		if _, summaryPath := m.archivePaths(id); fileExists(summaryPath) {
+			entry.SummaryPath = summaryPath
+		}
+		archived = append(archived, entry)
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].ArchivedAt.After(archived[j].ArchivedAt)
+	})
+	return archived, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
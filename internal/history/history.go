@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"agentcli/internal/fsutil"
 	"agentcli/internal/llm"
+	"agentcli/internal/ui"
 )
 
 // Message 消息
@@ -15,6 +20,19 @@ type Message struct {
 	Role      string    `json:"role"`
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
+	Seq       int64     `json:"seq,omitempty"`    // 对话内单调递增的序号，系统时钟被调整时仍能确定消息顺序
+	Pinned    bool      `json:"pinned,omitempty"` // 固定的消息始终完整保留在上下文中，不被截断或压缩
+
+	// 工具结果（role为tool）；手动执行的结果没有对应的助手工具调用，发送给模型时根据名称和参数还原
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolArgs   string `json:"tool_args,omitempty"`
+
+	// ToolCalls 助手在工具循环中发起的工具调用，与其后的工具结果一起保存，重新加载后可继续未完成的任务
+	ToolCalls []llm.ToolCall `json:"tool_calls,omitempty"`
+
+	// Changes 本轮回答对工作区文件的改动摘要（只记录在助手消息上，不发送给模型）
+	Changes string `json:"changes,omitempty"`
 }
 
 // Conversation 对话
@@ -22,11 +40,33 @@ type Conversation struct {
 	ID       string    `json:"id"`
 	UserID   string    `json:"user_id"`
 	Model    string    `json:"model"`
+	Title    string    `json:"title,omitempty"`
 	Messages []Message `json:"messages"`
 	Created  time.Time `json:"created"`
 	Updated  time.Time `json:"updated"`
+
+	Variables map[string]string `json:"variables,omitempty"` // 对话级变量，通过 {{name}} 引用
+	Artifacts []Artifact        `json:"artifacts,omitempty"` // 会话中Agent生成的文件
+	Workdir   string            `json:"workdir,omitempty"`   // 工具的默认执行目录（相对于工作区根目录），通过 /cd 设置
+	Summary   string            `json:"summary,omitempty"`   // 项目结束时生成的总结（agentcli archive）
+	Env       map[string]string `json:"-"`                   // 对话级环境变量（/env），可能包含密钥，不写入历史文件
 }
 
+// Artifact 会话产物（Agent通过工具生成的文件）
+type Artifact struct {
+	Path      string    `json:"path"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	Tool      string    `json:"tool"`
+	ToolArgs  string    `json:"tool_args,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// variableName 有效的变量名
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // Manager 历史记录管理器
 type Manager struct {
 	historyDir string
@@ -47,40 +87,49 @@ func (m *Manager) Init() error {
 // SaveConversation 保存对话
 func (m *Manager) SaveConversation(conv *Conversation) error {
 	conv.Updated = time.Now()
-	
-	filename := filepath.Join(m.historyDir, fmt.Sprintf("%s.json", conv.ID))
+	return m.writeConversation(conv)
+}
+
+// conversationPath 对话文件路径
+func (m *Manager) conversationPath(id string) string {
+	return filepath.Join(m.historyDir, fmt.Sprintf("%s.json", id))
+}
+
+// writeConversation 写入对话文件并更新索引，不修改更新时间
+func (m *Manager) writeConversation(conv *Conversation) error {
 	data, err := json.MarshalIndent(conv, "", "  ")
 	if err != nil {
 		return fmt.Errorf("序列化对话失败: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(m.conversationPath(conv.ID), data, 0644); err != nil {
 		return fmt.Errorf("保存对话失败: %w", err)
 	}
+	m.updateIndex(conv)
 
 	return nil
 }
 
 // LoadConversation 加载对话
 func (m *Manager) LoadConversation(id string) (*Conversation, error) {
-	filename := filepath.Join(m.historyDir, fmt.Sprintf("%s.json", id))
-	data, err := os.ReadFile(filename)
+	filename := m.conversationPath(id)
+	var conv Conversation
+	recovered, err := fsutil.ReadJSONWithBackup(filename, &conv)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("对话不存在: %s", id)
 		}
 		return nil, fmt.Errorf("读取对话失败: %w", err)
 	}
-
-	var conv Conversation
-	if err := json.Unmarshal(data, &conv); err != nil {
-		return nil, fmt.Errorf("解析对话失败: %w", err)
+	if recovered {
+		ui.Printf("⚠️  对话 %s 的文件已损坏，已从备份恢复\n", id)
 	}
+	conv.numberMessages()
 
 	return &conv, nil
 }
 
-// ListConversations 列出所有对话
+// ListConversations 读取并列出所有对话的完整内容；只需要标题、消息数等信息时使用 ListConversationInfo
 func (m *Manager) ListConversations(userID string) ([]*Conversation, error) {
 	files, err := os.ReadDir(m.historyDir)
 	if err != nil {
@@ -91,12 +140,19 @@ func (m *Manager) ListConversations(userID string) ([]*Conversation, error) {
 	}
 
 	var conversations []*Conversation
+	seen := make(map[string]bool)
 	for _, file := range files {
-		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+		// 主文件在保存过程中丢失时，仍可通过 .json.bak 备份找回对话
+		name := strings.TrimSuffix(file.Name(), fsutil.BackupSuffix)
+		if file.IsDir() || filepath.Ext(name) != ".json" || name == indexFile {
 			continue
 		}
 
-		id := file.Name()[:len(file.Name())-5] // 移除 .json
+		id := name[:len(name)-5] // 移除 .json
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
 		conv, err := m.LoadConversation(id)
 		if err != nil {
 			continue
@@ -112,13 +168,13 @@ func (m *Manager) ListConversations(userID string) ([]*Conversation, error) {
 
 // DeleteConversation 删除对话
 func (m *Manager) DeleteConversation(id string) error {
-	filename := filepath.Join(m.historyDir, fmt.Sprintf("%s.json", id))
-	if err := os.Remove(filename); err != nil {
+	if err := fsutil.RemoveWithBackup(m.conversationPath(id)); err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("对话不存在: %s", id)
 		}
 		return fmt.Errorf("删除对话失败: %w", err)
 	}
+	m.removeFromIndex(id)
 	return nil
 }
 
@@ -141,9 +197,236 @@ func (c *Conversation) AddMessage(role, content string) {
 		Role:      role,
 		Content:   content,
 		Timestamp: time.Now(),
+		Seq:       c.nextSeq(),
+	})
+}
+
+// nextSeq 下一条消息的序号
+func (c *Conversation) nextSeq() int64 {
+	c.numberMessages()
+	if n := len(c.Messages); n > 0 {
+		return c.Messages[n-1].Seq + 1
+	}
+	return 1
+}
+
+// numberMessages 保证消息序号按顺序递增，为旧版本保存的没有序号的消息补充序号
+func (c *Conversation) numberMessages() {
+	var last int64
+	for i := range c.Messages {
+		if c.Messages[i].Seq <= last {
+			c.Messages[i].Seq = last + 1
+		}
+		last = c.Messages[i].Seq
+	}
+}
+
+// SetLastChanges 在最后一条助手消息上记录本轮的文件改动摘要
+func (c *Conversation) SetLastChanges(changes string) {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "assistant" {
+			c.Messages[i].Changes = changes
+			return
+		}
+	}
+}
+
+// AddToolResult 将手动执行的工具结果作为工具消息加入对话
+func (c *Conversation) AddToolResult(name, args, result string) {
+	c.Messages = append(c.Messages, Message{
+		Role:       "tool",
+		Content:    result,
+		Timestamp:  time.Now(),
+		Seq:        c.nextSeq(),
+		ToolCallID: fmt.Sprintf("manual_%d", time.Now().UnixNano()),
+		ToolName:   name,
+		ToolArgs:   args,
 	})
 }
 
+// AddTurnMessages 保存本轮工具循环中的助手工具调用和工具结果，已保存过的调用（按调用ID）不会重复添加
+func (c *Conversation) AddTurnMessages(messages []llm.Message) {
+	saved := make(map[string]bool)
+	names := make(map[string]string)
+	for _, msg := range c.Messages {
+		for _, call := range msg.ToolCalls {
+			saved[call.ID] = true
+			names[call.ID] = call.Function.Name
+		}
+		if msg.Role == "tool" {
+			saved["result:"+msg.ToolCallID] = true
+		}
+	}
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			if saved[msg.ToolCalls[0].ID] {
+				continue
+			}
+			for _, call := range msg.ToolCalls {
+				saved[call.ID] = true
+				names[call.ID] = call.Function.Name
+			}
+			c.Messages = append(c.Messages, Message{
+				Role:      "assistant",
+				Content:   msg.Content,
+				Timestamp: time.Now(),
+				Seq:       c.nextSeq(),
+				ToolCalls: msg.ToolCalls,
+			})
+		case msg.Role == "tool":
+			if saved["result:"+msg.ToolCallID] {
+				continue
+			}
+			saved["result:"+msg.ToolCallID] = true
+			c.Messages = append(c.Messages, Message{
+				Role:       "tool",
+				Content:    msg.Content,
+				Timestamp:  time.Now(),
+				Seq:        c.nextSeq(),
+				ToolCallID: msg.ToolCallID,
+				ToolName:   names[msg.ToolCallID],
+			})
+		}
+	}
+}
+
+// UnfinishedTurn 最后一轮已执行过工具但还没有最终回答时，返回该轮的用户输入、之前的对话历史和该轮已保存的工具调用及结果
+func (c *Conversation) UnfinishedTurn() (input string, before, turn []llm.Message, ok bool) {
+	last := -1
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return "", nil, nil, false
+	}
+
+	for _, msg := range c.Messages[last+1:] {
+		switch {
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			turn = append(turn, llm.Message{Role: "assistant", Content: msg.Content, ToolCalls: msg.ToolCalls})
+		case msg.Role == "tool":
+			turn = append(turn, llm.Message{Role: "tool", Content: msg.Content, ToolCallID: msg.ToolCallID})
+		case msg.Role == "assistant" && !strings.HasPrefix(msg.Content, "[context]"):
+			// 已有最终回答
+			return "", nil, nil, false
+		}
+	}
+	if len(turn) == 0 {
+		return "", nil, nil, false
+	}
+	return c.Messages[last].Content, toLLMMessages(c.Messages[:last]), turn, true
+}
+
+// LastUserMessage 返回最后一条用户消息
+func (c *Conversation) LastUserMessage() (string, bool) {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			return c.Messages[i].Content, true
+		}
+	}
+	return "", false
+}
+
+// PopLastTurn 移除最后一条用户消息及其之后的回答，返回被移除的用户消息
+func (c *Conversation) PopLastTurn() (string, bool) {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			content := c.Messages[i].Content
+			c.Messages = c.Messages[:i]
+			return content, true
+		}
+	}
+	return "", false
+}
+
+// SetPinned 固定或取消固定第n条消息（从1开始）
+func (c *Conversation) SetPinned(n int, pinned bool) error {
+	if n < 1 || n > len(c.Messages) {
+		return fmt.Errorf("消息编号超出范围: %d (共 %d 条)", n, len(c.Messages))
+	}
+	c.Messages[n-1].Pinned = pinned
+	return nil
+}
+
+// PinnedMessages 返回已固定消息的编号（从1开始）
+func (c *Conversation) PinnedMessages() []int {
+	var pinned []int
+	for i, msg := range c.Messages {
+		if msg.Pinned {
+			pinned = append(pinned, i+1)
+		}
+	}
+	return pinned
+}
+
+// SetVariable 设置对话级变量
+func (c *Conversation) SetVariable(name, value string) error {
+	name = strings.TrimSpace(name)
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("无效的变量名: %s", name)
+	}
+	if c.Variables == nil {
+		c.Variables = make(map[string]string)
+	}
+	c.Variables[name] = value
+	return nil
+}
+
+// UnsetVariable 删除对话级变量
+func (c *Conversation) UnsetVariable(name string) bool {
+	if _, ok := c.Variables[name]; !ok {
+		return false
+	}
+	delete(c.Variables, name)
+	return true
+}
+
+// VariableNames 获取排序后的变量名列表
+func (c *Conversation) VariableNames() []string {
+	names := make([]string, 0, len(c.Variables))
+	for name := range c.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandVariables 将文本中的 {{name}} 替换为变量值，未定义的变量保持原样
+func ExpandVariables(text string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(text, "{{") {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// AddArtifacts 记录产物，同一路径的旧记录会被替换
+func (c *Conversation) AddArtifacts(artifacts []Artifact) {
+	for _, artifact := range artifacts {
+		replaced := false
+		for i := range c.Artifacts {
+			if c.Artifacts[i].Path == artifact.Path {
+				c.Artifacts[i] = artifact
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Artifacts = append(c.Artifacts, artifact)
+		}
+	}
+}
+
 // GetRecentMessages 获取最近N条消息
 func (c *Conversation) GetRecentMessages(n int) []Message {
 	if n <= 0 || n >= len(c.Messages) {
@@ -154,11 +437,71 @@ func (c *Conversation) GetRecentMessages(n int) []Message {
 
 // ToLLMMessages 转换消息为LLM格式
 func (c *Conversation) ToLLMMessages() []llm.Message {
-	messages := make([]llm.Message, 0, len(c.Messages))
-	for _, msg := range c.Messages {
+	return toLLMMessages(c.Messages)
+}
+
+// toLLMMessages 转换消息为LLM格式；未完成轮次中没有结果的工具调用会被去掉（接口要求每个调用都有对应的结果）
+func toLLMMessages(history []Message) []llm.Message {
+	answered := make(map[string]bool)
+	for _, msg := range history {
+		if msg.Role == "tool" {
+			answered[msg.ToolCallID] = true
+		}
+	}
+
+	called := make(map[string]bool)
+	messages := make([]llm.Message, 0, len(history))
+	for _, msg := range history {
+		if len(msg.ToolCalls) > 0 {
+			var calls []llm.ToolCall
+			for _, call := range msg.ToolCalls {
+				if answered[call.ID] {
+					calls = append(calls, call)
+					called[call.ID] = true
+				}
+			}
+			if len(calls) == 0 && msg.Content == "" {
+				continue
+			}
+			messages = append(messages, llm.Message{
+				Role:      msg.Role,
+				Content:   msg.Content,
+				ToolCalls: calls,
+				Pinned:    msg.Pinned,
+			})
+			continue
+		}
+		if msg.Role == "tool" && called[msg.ToolCallID] {
+			messages = append(messages, llm.Message{
+				Role:       "tool",
+				Content:    msg.Content,
+				ToolCallID: msg.ToolCallID,
+				Pinned:     msg.Pinned,
+			})
+			continue
+		}
+		if msg.Role == "tool" {
+			// 工具消息必须紧跟发起该调用的助手消息
+			messages = append(messages, llm.Message{
+				Role: "assistant",
+				ToolCalls: []llm.ToolCall{{
+					ID:       msg.ToolCallID,
+					Type:     "function",
+					Function: llm.FunctionCall{Name: msg.ToolName, Arguments: msg.ToolArgs},
+				}},
+				Pinned: msg.Pinned,
+			}, llm.Message{
+				Role:       "tool",
+				Content:    msg.Content,
+				ToolCallID: msg.ToolCallID,
+				Pinned:     msg.Pinned,
+			})
+			continue
+		}
 		messages = append(messages, llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Pinned:  msg.Pinned,
 		})
 	}
 	return messages
@@ -0,0 +1,29 @@
+package history
+
+import "testing"
+
+func TestSetVariableValidatesName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"project", false},
+		{"_dir2", false},
+		{" padded ", false},
+		{"a}} {{b", true},
+		{"x}}junk", true},
+		{"2fast", true},
+		{"with-dash", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		c := &Conversation{}
+		err := c.SetVariable(tt.name, "v")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetVariable(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && len(c.Variables) != 0 {
+			t.Errorf("SetVariable(%q) 失败后不应保存变量: %v", tt.name, c.Variables)
+		}
+	}
+}
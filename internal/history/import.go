@@ -0,0 +1,316 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 导入格式
+const (
+	ImportFormatAuto    = "auto"
+	ImportFormatChatGPT = "chatgpt" // ChatGPT 数据导出中的 conversations.json
+	ImportFormatClaude  = "claude"  // Claude 数据导出中的 conversations.json
+	ImportFormatText    = "text"    // "Human:/Assistant:" 形式的纯文本对话记录
+)
+
+// chatGPTConversation ChatGPT导出的对话
+type chatGPTConversation struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime float64 `json:"create_time"`
+		Content    struct {
+			ContentType string        `json:"content_type"`
+			Parts       []interface{} `json:"parts"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// claudeConversation Claude导出的对话
+type claudeConversation struct {
+	UUID         string    `json:"uuid"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ChatMessages []struct {
+		Sender    string    `json:"sender"`
+		Text      string    `json:"text"`
+		CreatedAt time.Time `json:"created_at"`
+		Content   []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"chat_messages"`
+}
+
+// ImportFile 从其他助手的导出文件导入对话
+func ImportFile(path, format, userID, model string) ([]*Conversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取导入文件失败: %w", err)
+	}
+
+	if format == "" || format == ImportFormatAuto {
+		format = detectImportFormat(data)
+	}
+
+	var conversations []*Conversation
+	switch format {
+	case ImportFormatChatGPT:
+		conversations, err = importChatGPT(data, userID, model)
+	case ImportFormatClaude:
+		conversations, err = importClaude(data, userID, model)
+	case ImportFormatText:
+		conversations, err = importText(data, userID, model)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s (可选: chatgpt, claude, text)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, fmt.Errorf("文件中没有可导入的对话")
+	}
+	return conversations, nil
+}
+
+// detectImportFormat 根据内容判断导入格式
+func detectImportFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '[' && trimmed[0] != '{') {
+		return ImportFormatText
+	}
+
+	var probe []map[string]json.RawMessage
+	if trimmed[0] == '{' {
+		var single map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return ImportFormatText
+		}
+		probe = append(probe, single)
+	} else if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return ImportFormatText
+	}
+
+	for _, item := range probe {
+		if _, ok := item["mapping"]; ok {
+			return ImportFormatChatGPT
+		}
+		if _, ok := item["chat_messages"]; ok {
+			return ImportFormatClaude
+		}
+	}
+	return ImportFormatText
+}
+
+// decodeList 解析JSON数组或单个对象
+func decodeList(data []byte, v interface{}) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		trimmed = append(append([]byte("["), trimmed...), ']')
+	}
+	return json.Unmarshal(trimmed, v)
+}
+
+func importChatGPT(data []byte, userID, model string) ([]*Conversation, error) {
+	var exports []chatGPTConversation
+	if err := decodeList(data, &exports); err != nil {
+		return nil, fmt.Errorf("解析ChatGPT导出文件失败: %w", err)
+	}
+
+	var conversations []*Conversation
+	for _, export := range exports {
+		// 从当前节点沿parent回溯到根节点，得到当前分支的消息
+		var chain []chatGPTNode
+		visited := make(map[string]bool)
+		for id := export.CurrentNode; id != "" && !visited[id]; {
+			visited[id] = true
+			node, ok := export.Mapping[id]
+			if !ok {
+				break
+			}
+			chain = append(chain, node)
+			id = node.Parent
+		}
+
+		conv := newImportedConversation(userID, model, "chatgpt", export.ID, export.Title, unixTime(export.CreateTime), unixTime(export.UpdateTime))
+		for i := len(chain) - 1; i >= 0; i-- {
+			msg := chain[i].Message
+			if msg == nil {
+				continue
+			}
+			role := msg.Author.Role
+			if role != "user" && role != "assistant" {
+				continue
+			}
+
+			var parts []string
+			for _, part := range msg.Content.Parts {
+				if text, ok := part.(string); ok && strings.TrimSpace(text) != "" {
+					parts = append(parts, text)
+				}
+			}
+			conv.appendImported(role, strings.Join(parts, "\n"), unixTime(msg.CreateTime))
+		}
+
+		if len(conv.Messages) > 0 {
+			conversations = append(conversations, conv)
+		}
+	}
+	return conversations, nil
+}
+
+func importClaude(data []byte, userID, model string) ([]*Conversation, error) {
+	var exports []claudeConversation
+	if err := decodeList(data, &exports); err != nil {
+		return nil, fmt.Errorf("解析Claude导出文件失败: %w", err)
+	}
+
+	var conversations []*Conversation
+	for _, export := range exports {
+		conv := newImportedConversation(userID, model, "claude", export.UUID, export.Name, export.CreatedAt, export.UpdatedAt)
+
+		messages := export.ChatMessages
+		sort.SliceStable(messages, func(i, j int) bool {
+			return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+		})
+		for _, msg := range messages {
+			role := "assistant"
+			if msg.Sender == "human" || msg.Sender == "user" {
+				role = "user"
+			}
+
+			text := msg.Text
+			if strings.TrimSpace(text) == "" {
+				var parts []string
+				for _, c := range msg.Content {
+					if c.Type == "text" && strings.TrimSpace(c.Text) != "" {
+						parts = append(parts, c.Text)
+					}
+				}
+				text = strings.Join(parts, "\n")
+			}
+			conv.appendImported(role, text, msg.CreatedAt)
+		}
+
+		if len(conv.Messages) > 0 {
+			conversations = append(conversations, conv)
+		}
+	}
+	return conversations, nil
+}
+
+// transcriptRoles 纯文本对话记录中的说话人前缀
+var transcriptRoles = map[string]string{
+	"human":     "user",
+	"user":      "user",
+	"h":         "user",
+	"you":       "user",
+	"assistant": "assistant",
+	"claude":    "assistant",
+	"chatgpt":   "assistant",
+	"ai":        "assistant",
+	"a":         "assistant",
+}
+
+func importText(data []byte, userID, model string) ([]*Conversation, error) {
+	sum := sha256.Sum256(data)
+	conv := newImportedConversation(userID, model, "text", hex.EncodeToString(sum[:]), "", time.Now(), time.Now())
+
+	role := ""
+	var content []string
+	flush := func() {
+		if role != "" {
+			conv.appendImported(role, strings.Join(content, "\n"), time.Time{})
+		}
+		content = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, ":"); idx > 0 && idx <= len("assistant") {
+			prefix := strings.ToLower(strings.TrimSpace(line[:idx]))
+			if r, ok := transcriptRoles[prefix]; ok {
+				flush()
+				role = r
+				line = strings.TrimSpace(line[idx+1:])
+			}
+		}
+		if role != "" {
+			content = append(content, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取对话记录失败: %w", err)
+	}
+	flush()
+
+	if len(conv.Messages) == 0 {
+		return nil, fmt.Errorf("未识别到对话内容（每段消息需以 Human:/Assistant: 开头）")
+	}
+	return []*Conversation{conv}, nil
+}
+
+// newImportedConversation 创建导入的对话（ID由来源和原始ID生成，重复导入会覆盖同一对话）
+func newImportedConversation(userID, model, source, sourceID, title string, created, updated time.Time) *Conversation {
+	sum := sha256.Sum256([]byte(source + ":" + sourceID))
+	if created.IsZero() {
+		created = time.Now()
+	}
+	if updated.IsZero() {
+		updated = created
+	}
+	return &Conversation{
+		ID:       fmt.Sprintf("%s_%s_%s", userID, source, hex.EncodeToString(sum[:])[:12]),
+		UserID:   userID,
+		Model:    model,
+		Title:    title,
+		Messages: []Message{},
+		Created:  created,
+		Updated:  updated,
+	}
+}
+
+func (c *Conversation) appendImported(role, content string, timestamp time.Time) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+	if timestamp.IsZero() {
+		timestamp = c.Created
+	}
+	c.Messages = append(c.Messages, Message{
+		Role:      role,
+		Content:   content,
+		Timestamp: timestamp,
+		Seq:       c.nextSeq(),
+	})
+}
+
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
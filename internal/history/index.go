@@ -0,0 +1,169 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// indexFile 历史目录中的对话索引，列出对话时不必逐个读取和解析对话文件
+const indexFile = ".index.json"
+
+// ConversationInfo 对话的索引信息
+type ConversationInfo struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"user_id"`
+	Model    string    `json:"model"`
+	Title    string    `json:"title,omitempty"`
+	Messages int       `json:"messages"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+
+	// 写入索引时对话文件的大小和修改时间，与磁盘上的文件不一致时（其他程序修改过）重新读取该对话
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// conversationIndex 索引文件内容
+type conversationIndex struct {
+	Conversations map[string]ConversationInfo `json:"conversations"`
+}
+
+// indexMu 串行化同一进程内对索引文件的读改写
+var indexMu sync.Mutex
+
+func (m *Manager) indexPath() string {
+	return filepath.Join(m.historyDir, indexFile)
+}
+
+// readIndex 读取索引，索引不存在或已损坏时返回空索引
+func (m *Manager) readIndex() *conversationIndex {
+	var index conversationIndex
+	if _, err := fsutil.ReadJSONWithBackup(m.indexPath(), &index); err != nil || index.Conversations == nil {
+		index.Conversations = make(map[string]ConversationInfo)
+	}
+	return &index
+}
+
+func (m *Manager) writeIndex(index *conversationIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化对话索引失败: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(m.indexPath(), data, 0644); err != nil {
+		return fmt.Errorf("保存对话索引失败: %w", err)
+	}
+	return nil
+}
+
+// infoOf 对话的索引信息，file 为已写入的对话文件信息
+func infoOf(conv *Conversation, file os.FileInfo) ConversationInfo {
+	info := ConversationInfo{
+		ID:       conv.ID,
+		UserID:   conv.UserID,
+		Model:    conv.Model,
+		Title:    conv.Title,
+		Messages: len(conv.Messages),
+		Created:  conv.Created,
+		Updated:  conv.Updated,
+	}
+	if file != nil {
+		info.Size, info.ModTime = file.Size(), file.ModTime()
+	}
+	return info
+}
+
+// updateIndex 对话写入后更新其索引项；索引写入失败不影响对话本身，下次列出时会重新建立
+func (m *Manager) updateIndex(conv *Conversation) {
+	file, err := os.Stat(m.conversationPath(conv.ID))
+	if err != nil {
+		return
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	index := m.readIndex()
+	index.Conversations[conv.ID] = infoOf(conv, file)
+	m.writeIndex(index)
+}
+
+// removeFromIndex 对话删除后移除其索引项
+func (m *Manager) removeFromIndex(id string) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	index := m.readIndex()
+	if _, ok := index.Conversations[id]; ok {
+		delete(index.Conversations, id)
+		m.writeIndex(index)
+	}
+}
+
+// ListConversationInfo 通过索引列出对话（按ID排序），userID为空时列出所有用户的对话
+// 只重新读取索引中缺失或文件已被修改的对话，并移除文件已不存在的索引项
+func (m *Manager) ListConversationInfo(userID string) ([]ConversationInfo, error) {
+	files, err := os.ReadDir(m.historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ConversationInfo{}, nil
+		}
+		return nil, fmt.Errorf("读取历史目录失败: %w", err)
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	index := m.readIndex()
+	changed := false
+	present := make(map[string]bool)
+	for _, file := range files {
+		// 主文件在保存过程中丢失时，仍可通过 .json.bak 备份找回对话
+		name := strings.TrimSuffix(file.Name(), fsutil.BackupSuffix)
+		if file.IsDir() || filepath.Ext(name) != ".json" || name == indexFile {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		if present[id] {
+			continue
+		}
+		present[id] = true
+
+		stat, statErr := os.Stat(m.conversationPath(id))
+		if cached, ok := index.Conversations[id]; ok && statErr == nil && cached.Size == stat.Size() && cached.ModTime.Equal(stat.ModTime()) {
+			continue
+		}
+		conv, err := m.LoadConversation(id)
+		if err != nil {
+			continue
+		}
+		if statErr != nil {
+			stat = nil
+		}
+		index.Conversations[id] = infoOf(conv, stat)
+		changed = true
+	}
+	for id := range index.Conversations {
+		if !present[id] {
+			delete(index.Conversations, id)
+			changed = true
+		}
+	}
+	if changed {
+		m.writeIndex(index)
+	}
+
+	conversations := []ConversationInfo{}
+	for _, info := range index.Conversations {
+		if userID == "" || info.UserID == userID {
+			conversations = append(conversations, info)
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].ID < conversations[j].ID
+	})
+	return conversations, nil
+}
@@ -0,0 +1,207 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMergeWindow 自动合并时，前一个对话结束到后一个对话开始的最大间隔
+const DefaultMergeWindow = 10 * time.Minute
+
+// DedupeResult 去重合并的结果
+type DedupeResult struct {
+	Merged     map[string][]string // 保留的对话ID -> 被合并进来的对话ID
+	Duplicates map[string][]string // 保留的对话ID -> 内容完全相同而被删除的对话ID
+	Empty      []string            // 没有助手回答而被删除的对话ID
+}
+
+// Removed 返回被删除的对话总数
+func (r *DedupeResult) Removed() int {
+	count := len(r.Empty)
+	for _, ids := range r.Merged {
+		count += len(ids)
+	}
+	for _, ids := range r.Duplicates {
+		count += len(ids)
+	}
+	return count
+}
+
+// HasAssistantMessages 对话中是否包含助手回答（只有用户输入的对话没有保存价值）
+func (c *Conversation) HasAssistantMessages() bool {
+	for _, msg := range c.Messages {
+		if msg.Role == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge 将other的消息、变量和产物按时间顺序合并到当前对话
+func (c *Conversation) Merge(other *Conversation) {
+	c.Messages = append(c.Messages, other.Messages...)
+	sort.SliceStable(c.Messages, func(i, j int) bool {
+		return c.Messages[i].Timestamp.Before(c.Messages[j].Timestamp)
+	})
+	// 合并后按新的顺序重新编号
+	for i := range c.Messages {
+		c.Messages[i].Seq = int64(i + 1)
+	}
+
+	for name, value := range other.Variables {
+		if _, ok := c.Variables[name]; !ok {
+			if c.Variables == nil {
+				c.Variables = make(map[string]string)
+			}
+			c.Variables[name] = value
+		}
+	}
+	c.AddArtifacts(other.Artifacts)
+
+	if c.Title == "" {
+		c.Title = other.Title
+	}
+	if other.Created.Before(c.Created) {
+		c.Created = other.Created
+	}
+	if other.Updated.After(c.Updated) {
+		c.Updated = other.Updated
+	}
+}
+
+// idPrefix 返回对话ID去掉最后一段（时间戳或哈希）后的前缀
+func idPrefix(id string) string {
+	if i := strings.LastIndex(id, "_"); i > 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// fingerprint 对话内容的指纹，用于识别完全相同的对话
+func fingerprint(conv *Conversation) string {
+	var b strings.Builder
+	for _, msg := range conv.Messages {
+		b.WriteString(msg.Role)
+		b.WriteByte(0)
+		b.WriteString(msg.Content)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// MergeConversations 将指定的多个对话合并为一个，保留最早创建的对话ID，其余对话被删除
+func (m *Manager) MergeConversations(ids []string) (*Conversation, error) {
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("至少需要两个对话才能合并")
+	}
+
+	var conversations []*Conversation
+	for _, id := range ids {
+		conv, err := m.LoadConversation(id)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conv)
+	}
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].Created.Before(conversations[j].Created)
+	})
+
+	target := conversations[0]
+	for _, conv := range conversations[1:] {
+		if conv.ID == target.ID {
+			continue
+		}
+		target.Merge(conv)
+	}
+	return target, m.replaceConversations(target, conversations[1:])
+}
+
+// Dedupe 清理用户的历史对话：删除没有助手回答的对话和内容完全相同的重复对话，
+// 并将ID前缀相同、模型相同且时间间隔在window内的相邻对话合并为一个
+// dryRun为true时只返回结果而不修改任何文件
+func (m *Manager) Dedupe(userID string, window time.Duration, dryRun bool) (*DedupeResult, error) {
+	conversations, err := m.ListConversations(userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].Created.Before(conversations[j].Created)
+	})
+
+	result := &DedupeResult{
+		Merged:     make(map[string][]string),
+		Duplicates: make(map[string][]string),
+	}
+	var remove []*Conversation
+
+	// 删除空对话和重复对话
+	var kept []*Conversation
+	seen := make(map[string]string)
+	for _, conv := range conversations {
+		if !conv.HasAssistantMessages() {
+			result.Empty = append(result.Empty, conv.ID)
+			remove = append(remove, conv)
+			continue
+		}
+		key := fingerprint(conv)
+		if original, ok := seen[key]; ok {
+			result.Duplicates[original] = append(result.Duplicates[original], conv.ID)
+			remove = append(remove, conv)
+			continue
+		}
+		seen[key] = conv.ID
+		kept = append(kept, conv)
+	}
+
+	// 合并时间相邻的对话
+	groups := make(map[string]*Conversation) // ID前缀 -> 该前缀下最近一个保留的对话
+	var changed []*Conversation
+	for _, conv := range kept {
+		prefix := idPrefix(conv.ID)
+		last, ok := groups[prefix]
+		if ok && last.Model == conv.Model && last.Title == conv.Title && conv.Created.Sub(last.Updated) <= window {
+			result.Merged[last.ID] = append(result.Merged[last.ID], conv.ID)
+			last.Merge(conv)
+			remove = append(remove, conv)
+			if len(result.Merged[last.ID]) == 1 {
+				changed = append(changed, last)
+			}
+			continue
+		}
+		groups[prefix] = conv
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	for _, conv := range changed {
+		if err := m.writeConversation(conv); err != nil {
+			return nil, err
+		}
+	}
+	for _, conv := range remove {
+		if err := m.DeleteConversation(conv.ID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// replaceConversations 保存合并后的对话并删除被合并的对话
+func (m *Manager) replaceConversations(target *Conversation, merged []*Conversation) error {
+	if err := m.writeConversation(target); err != nil {
+		return err
+	}
+	for _, conv := range merged {
+		if conv.ID == target.ID {
+			continue
+		}
+		if err := m.DeleteConversation(conv.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
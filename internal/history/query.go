@@ -0,0 +1,117 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 历史对话列表的排序字段
+const (
+	SortUpdated  = "updated"
+	SortCreated  = "created"
+	SortMessages = "messages"
+	SortID       = "id"
+)
+
+// DefaultPageSize 每页列出的对话数
+const DefaultPageSize = 20
+
+// Query 历史对话列表的筛选、排序和分页条件
+type Query struct {
+	Model     string    // 模型名包含该文本（不区分大小写），为空时不筛选
+	Since     time.Time // 更新时间不早于该时间，零值时不限
+	Until     time.Time // 更新时间早于该时间，零值时不限
+	Sort      string    // 排序字段，为空时按更新时间
+	Ascending bool      // 升序，默认降序（最近的在前）
+	Page      int       // 页码，从1开始
+	PageSize  int       // 每页对话数，为0时使用 DefaultPageSize
+}
+
+// Page 一页历史对话
+type Page struct {
+	Conversations []ConversationInfo
+	Number        int // 当前页码
+	Pages         int // 总页数
+	Total         int // 符合条件的对话数
+}
+
+// QueryConversations 通过索引按条件列出一页对话
+func (m *Manager) QueryConversations(userID string, q Query) (*Page, error) {
+	all, err := m.ListConversationInfo(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []ConversationInfo
+	model := strings.ToLower(q.Model)
+	for _, info := range all {
+		if model != "" && !strings.Contains(strings.ToLower(info.Model), model) {
+			continue
+		}
+		if !q.Since.IsZero() && info.Updated.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !info.Updated.Before(q.Until) {
+			continue
+		}
+		matched = append(matched, info)
+	}
+
+	less, err := sortLess(q.Sort, matched)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if q.Ascending {
+			return less(i, j)
+		}
+		return less(j, i)
+	})
+
+	size := q.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	page := &Page{Number: max(q.Page, 1), Total: len(matched), Pages: (len(matched) + size - 1) / size}
+	if page.Number > page.Pages && page.Pages > 0 {
+		return nil, fmt.Errorf("页码超出范围（共 %d 页）", page.Pages)
+	}
+	start := (page.Number - 1) * size
+	page.Conversations = matched[start:min(start+size, len(matched))]
+	return page, nil
+}
+
+// sortLess 按排序字段比较两个对话，字段相同时按ID
+func sortLess(field string, infos []ConversationInfo) (func(i, j int) bool, error) {
+	switch field {
+	case "", SortUpdated:
+		return func(i, j int) bool {
+			if !infos[i].Updated.Equal(infos[j].Updated) {
+				return infos[i].Updated.Before(infos[j].Updated)
+			}
+			return infos[i].ID < infos[j].ID
+		}, nil
+	case SortCreated:
+		return func(i, j int) bool {
+			if !infos[i].Created.Equal(infos[j].Created) {
+				return infos[i].Created.Before(infos[j].Created)
+			}
+			return infos[i].ID < infos[j].ID
+		}, nil
+	case SortMessages:
+		return func(i, j int) bool {
+			if infos[i].Messages != infos[j].Messages {
+				return infos[i].Messages < infos[j].Messages
+			}
+			return infos[i].ID < infos[j].ID
+		}, nil
+	case SortID:
+		return func(i, j int) bool {
+			return infos[i].ID < infos[j].ID
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的排序方式: %s（可选 updated、created、messages、id）", field)
+	}
+}
@@ -0,0 +1,385 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion Anthropic接口版本
+const anthropicVersion = "2023-06-01"
+
+// AnthropicBaseURL Anthropic官方接口地址，api.provider 为 anthropic 且未配置 base_url 时使用
+const AnthropicBaseURL = "https://api.anthropic.com/v1"
+
+// anthropicDefaultMaxTokens messages接口必须指定max_tokens，未配置时使用的默认值
+const anthropicDefaultMaxTokens = 8192
+
+// anthropicProvider Anthropic messages 接口，工具调用使用 tool_use/tool_result 内容块
+type anthropicProvider struct {
+	c *Client
+}
+
+// anthropicRequest messages接口请求
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	System     []anthropicBlock     `json:"system,omitempty"`
+	Messages   []anthropicMessage   `json:"messages"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Stream     bool                 `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock 内容块：text、image、tool_use 或 tool_result
+type anthropicBlock struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	Source       *imageSource    `json:"source,omitempty"`
+	CacheControl *cacheControl   `json:"cache_control,omitempty"`
+}
+
+// imageSource 图片块的base64数据
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// anthropicUsage messages接口的用量，input_tokens 不包含缓存读写的部分
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// toUsage 转换为统一格式的用量（prompt tokens 包含缓存命中的部分）
+func (u anthropicUsage) toUsage() Usage {
+	usage := Usage{
+		PromptTokens:     u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens,
+		CompletionTokens: u.OutputTokens,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.PromptTokensDetails.CachedTokens = u.CacheReadInputTokens
+	return usage
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+func (p *anthropicProvider) header(stream bool) http.Header {
+	header := make(http.Header)
+	header.Set("x-api-key", p.c.apiKey)
+	header.Set("anthropic-version", anthropicVersion)
+	if stream {
+		header.Set("Accept", "text/event-stream")
+	}
+	return header
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.c.post(ctx, "/messages", toAnthropicRequest(req), p.header(false), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	var anthropicResp anthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, &ResponseParseError{Body: string(body), Err: err}
+	}
+
+	message := ChatMessage{Role: "assistant"}
+	var text strings.Builder
+	for _, block := range anthropicResp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: block.Name, Arguments: toolArguments(block.Input)},
+			})
+		}
+	}
+	message.Content = text.String()
+
+	return &ChatResponse{
+		ID:      anthropicResp.ID,
+		Choices: []Choice{{Message: message, Finish: finishReason(anthropicResp.StopReason)}},
+		Usage:   anthropicResp.Usage.toUsage(),
+	}, nil
+}
+
+// anthropicStreamEvent 流式事件（按type区分）
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message"`
+	ContentBlock *anthropicBlock    `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *anthropicProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(*StreamResponse) error) error {
+	resp, err := p.c.post(ctx, "/messages", toAnthropicRequest(req), p.header(true), true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var usage anthropicUsage
+	toolIndex := make(map[int]int) // 内容块序号 -> 工具调用序号
+	argsSeen := make(map[int]bool) // 工具调用是否收到过参数
+	return readSSE(resp.Body, func(data []byte) (bool, error) {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return false, nil // 跳过无法解析的行
+		}
+
+		delta := &StreamResponse{Model: req.Model, Choices: make([]StreamChoice, 1)}
+		choice := &delta.Choices[0]
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage = event.Message.Usage
+			}
+			return false, nil
+		case "content_block_start":
+			if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+				return false, nil
+			}
+			index := len(toolIndex)
+			toolIndex[event.Index] = index
+			choice.Delta.ToolCalls = []ToolCallDelta{{
+				Index:    index,
+				ID:       event.ContentBlock.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: event.ContentBlock.Name},
+			}}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				choice.Delta.Content = event.Delta.Text
+			case "input_json_delta":
+				index, ok := toolIndex[event.Index]
+				if !ok || event.Delta.PartialJSON == "" {
+					return false, nil
+				}
+				argsSeen[index] = true
+				choice.Delta.ToolCalls = []ToolCallDelta{{Index: index, Function: FunctionCall{Arguments: event.Delta.PartialJSON}}}
+			default:
+				return false, nil
+			}
+		case "content_block_stop":
+			// 没有参数的工具调用不会收到参数增量，补一个空对象
+			index, ok := toolIndex[event.Index]
+			if !ok || argsSeen[index] {
+				return false, nil
+			}
+			argsSeen[index] = true
+			choice.Delta.ToolCalls = []ToolCallDelta{{Index: index, Function: FunctionCall{Arguments: "{}"}}}
+		case "message_delta":
+			choice.FinishReason = finishReason(event.Delta.StopReason)
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+			total := usage.toUsage()
+			delta.Usage = &total
+		case "message_stop":
+			return true, nil
+		case "error":
+			if event.Error != nil {
+				return false, fmt.Errorf("模型服务返回错误 (%s): %s", event.Error.Type, event.Error.Message)
+			}
+			return false, fmt.Errorf("模型服务返回错误: %s", string(data))
+		default:
+			return false, nil
+		}
+		return false, onDelta(delta)
+	})
+}
+
+// toAnthropicRequest 将统一格式的请求转换为messages接口请求
+func toAnthropicRequest(req *ChatRequest) *anthropicRequest {
+	out := &anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		Stream:    req.Stream,
+	}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = anthropicDefaultMaxTokens
+	}
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				out.System = append(out.System, textBlock(msg.Content, msg.CacheControl))
+			}
+		case "tool":
+			out.Messages = appendAnthropicMessage(out.Messages, "user", anthropicBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+		case "assistant":
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, textBlock(msg.Content, msg.CacheControl))
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: toolInput(call.Function.Arguments),
+				})
+			}
+			out.Messages = appendAnthropicMessage(out.Messages, "assistant", blocks...)
+		default:
+			var blocks []anthropicBlock
+			for _, img := range msg.Images {
+				blocks = append(blocks, anthropicBlock{
+					Type:   "image",
+					Source: &imageSource{Type: "base64", MediaType: img.MediaType, Data: img.Data},
+				})
+			}
+			if msg.Content != "" {
+				blocks = append(blocks, textBlock(msg.Content, msg.CacheControl))
+			}
+			out.Messages = appendAnthropicMessage(out.Messages, "user", blocks...)
+		}
+	}
+
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if len(out.Tools) > 0 {
+		out.ToolChoice = anthropicChoice(req.ToolChoice)
+	}
+	return out
+}
+
+// appendAnthropicMessage 追加消息内容块，与上一条消息角色相同时合并（如多个工具结果合并为一条user消息）
+func appendAnthropicMessage(messages []anthropicMessage, role string, blocks ...anthropicBlock) []anthropicMessage {
+	if len(blocks) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, anthropicMessage{Role: role, Content: blocks})
+}
+
+func textBlock(text string, cache bool) anthropicBlock {
+	block := anthropicBlock{Type: "text", Text: text}
+	if cache {
+		block.CacheControl = &cacheControl{Type: "ephemeral"}
+	}
+	return block
+}
+
+// anthropicChoice 将统一格式的tool_choice转换为messages接口的取值
+func anthropicChoice(choice interface{}) *anthropicToolChoice {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case ToolChoiceNone:
+			return &anthropicToolChoice{Type: "none"}
+		case ToolChoiceRequired:
+			return &anthropicToolChoice{Type: "any"}
+		case ToolChoiceAuto:
+			return &anthropicToolChoice{Type: "auto"}
+		}
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]string); ok && function["name"] != "" {
+			return &anthropicToolChoice{Type: "tool", Name: function["name"]}
+		}
+	}
+	return nil
+}
+
+// toolInput 工具调用参数转换为tool_use的input（必须是JSON对象）
+func toolInput(arguments string) json.RawMessage {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" || !json.Valid([]byte(arguments)) || !strings.HasPrefix(arguments, "{") {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// toolArguments tool_use的input转换为工具调用参数字符串
+func toolArguments(input json.RawMessage) string {
+	if len(input) == 0 {
+		return "{}"
+	}
+	return string(input)
+}
+
+// finishReason 将stop_reason转换为统一的finish_reason
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "":
+		return ""
+	default:
+		return "stop"
+	}
+}
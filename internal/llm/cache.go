@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// 提示词缓存模式
+const (
+	PromptCacheAuto      = "auto"      // 根据模型自动选择
+	PromptCacheAnthropic = "anthropic" // 使用cache_control标记静态前缀
+	PromptCacheOpenAI    = "openai"    // 使用prompt_cache_key提高前缀缓存命中率
+	PromptCacheOff       = "off"       // 不添加缓存标记
+)
+
+// cacheControl Anthropic缓存标记
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+// contentPart 分段消息内容：text 或 image_url
+type contentPart struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text,omitempty"`
+	ImageURL     *imageURL     `json:"image_url,omitempty"`
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+// Usage token用量
+type Usage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	// 部分兼容Anthropic的服务单独返回缓存读取的token数
+	CacheReadInputTokens int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// CachedTokens 命中缓存的prompt token数
+func (u Usage) CachedTokens() int {
+	if u.PromptTokensDetails.CachedTokens > 0 {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return u.CacheReadInputTokens
+}
+
+// MarshalJSON 带缓存标记或图片的消息以分段形式发送内容
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if (!m.CacheControl || m.Content == "") && len(m.Images) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	var parts []contentPart
+	if m.Content != "" {
+		part := contentPart{Type: "text", Text: m.Content}
+		if m.CacheControl {
+			part.CacheControl = &cacheControl{Type: "ephemeral"}
+		}
+		parts = append(parts, part)
+	}
+	for _, img := range m.Images {
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: img.DataURL()}})
+	}
+	return json.Marshal(struct {
+		plain
+		Content []contentPart `json:"content"`
+	}{
+		plain:   plain(m),
+		Content: parts,
+	})
+}
+
+// cacheMode 解析当前模型实际使用的缓存模式
+func (c *Client) cacheMode() string {
+	mode := strings.ToLower(strings.TrimSpace(c.PromptCache))
+	if mode == "" || mode == PromptCacheAuto {
+		// OpenAI对较长前缀自动缓存，无需额外标记；Claude模型需要显式标记
+		if strings.EqualFold(c.Backend, ProviderAnthropic) || strings.Contains(strings.ToLower(c.Model), "claude") {
+			return PromptCacheAnthropic
+		}
+		return PromptCacheOff
+	}
+	return mode
+}
+
+// applyPromptCache 为静态前缀（开头的系统提示词）添加缓存标记，返回新的消息列表和缓存键
+func (c *Client) applyPromptCache(messages []Message) ([]Message, string) {
+	mode := c.cacheMode()
+	if mode == PromptCacheOff {
+		return messages, ""
+	}
+
+	// 找到开头连续的系统消息，它们在多轮迭代中保持不变
+	prefix := -1
+	for i, msg := range messages {
+		if msg.Role != "system" {
+			break
+		}
+		prefix = i
+	}
+	if prefix < 0 {
+		return messages, ""
+	}
+
+	switch mode {
+	case PromptCacheAnthropic:
+		marked := make([]Message, len(messages))
+		copy(marked, messages)
+		marked[prefix].CacheControl = true
+		return marked, ""
+	case PromptCacheOpenAI:
+		h := sha256.New()
+		for _, msg := range messages[:prefix+1] {
+			h.Write([]byte(msg.Content))
+		}
+		return messages, "agentcli-" + hex.EncodeToString(h.Sum(nil))[:16]
+	}
+	return messages, ""
+}
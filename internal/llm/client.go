@@ -1,39 +1,95 @@
 package llm
 
 import (
-	"bytes"
+	"agentcli/internal/events"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // Client LLM客户端
 type Client struct {
-	apiKey  string
-	baseURL string
-	Model   string // 改为公开字段，允许外部修改
+	apiKey    string
+	baseURL   string
+	Model     string // 改为公开字段，允许外部修改
+	MaxTokens int    // 单次回答的最大token数，0表示不限制
+	// MaxContinuations 回答因长度截断（finish_reason=length）时自动续写的最大次数，0表示不续写
+	MaxContinuations int
+	// StreamReconnects 流式响应连接中断时，携带已接收内容重新请求续接的最大次数，0表示不续接
+	StreamReconnects int
+	// StreamIdleTimeout 流式响应超过该时间没有收到数据时中断连接（随后按 StreamReconnects 续接），0表示不限制
+	StreamIdleTimeout time.Duration
+	// Retry 限流、服务端临时错误和连接中断的重试策略，零值表示不重试
+	Retry RetryPolicy
+	// Usage 用量记录器，用于统计token并在请求前检查预算
+	Usage UsageRecorder
+	// PromptCache 提示词缓存模式: auto/anthropic/openai/off
+	PromptCache string
+	// LegacyFunctions 使用旧版functions/function_call接口代替tools/tool_calls
+	LegacyFunctions bool
+	// Backend 服务协议: openai(默认，chat/completions及兼容接口)/anthropic(messages接口)/ollama(/api/chat接口)
+	Backend string
+	// ContextWindow 模型上下文窗口大小，ollama协议下作为 num_ctx 发送，0表示使用服务的默认值
+	ContextWindow int
+	// Events 事件总线，用于向用户展示限流、超时、重试等状态
+	Events *events.Bus
+	// Tracer 请求记录器，记录每次请求的输入、输出和耗时（会话事件日志）
+	Tracer  CallTracer
 	timeout time.Duration
 	client  *http.Client
 }
 
+// UsageRecorder 用量记录器
+type UsageRecorder interface {
+	Check() error
+	RecordTokens(model string, promptTokens, cachedTokens, completionTokens int)
+}
+
+// continuationPrompt 续写请求的提示词
+const continuationPrompt = "你的回答因长度限制被截断了，请从中断处直接继续输出，不要重复已输出的内容。"
+
+// resumePrompt 流式连接中断后续接请求的提示词
+const resumePrompt = "你的回答因网络连接中断没有传输完整，请从中断处直接继续输出，不要重复已输出的内容。"
+
 // Message 消息结构
 type Message struct {
 	Role       string     `json:"role"`
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// Name 旧版function消息对应的函数名
+	Name string `json:"name,omitempty"`
+	// FunctionCall 旧版接口的函数调用
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	// CacheControl 是否将该消息标记为可缓存前缀的结尾（Anthropic cache_control）
+	CacheControl bool `json:"-"`
+	// Pinned 用户固定的消息，上下文截断和压缩时保持完整
+	Pinned bool `json:"-"`
+	// Images 随消息发送的图片（多模态模型），发送时与文本一起组成分段内容
+	Images []Image `json:"-"`
 }
 
 // ChatRequest 聊天请求
 type ChatRequest struct {
-	Model      string    `json:"model"`
-	Messages   []Message `json:"messages"`
-	Tools      []Tool    `json:"tools,omitempty"`
-	ToolChoice string    `json:"tool_choice,omitempty"`
+	Model        string        `json:"model"`
+	Messages     []Message     `json:"messages"`
+	Tools        []Tool        `json:"tools,omitempty"`
+	ToolChoice   interface{}   `json:"tool_choice,omitempty"`   // auto/none/required，或指定函数名以强制调用
+	Functions    []FunctionDef `json:"functions,omitempty"`     // 旧版函数调用接口
+	FunctionCall interface{}   `json:"function_call,omitempty"` // 旧版函数调用接口
+	MaxTokens    int           `json:"max_tokens,omitempty"`
+	// PromptCacheKey OpenAI提示词缓存键，相同前缀的请求使用相同的键
+	PromptCacheKey string `json:"prompt_cache_key,omitempty"`
+	// Stream 流式请求
+	Stream bool `json:"stream,omitempty"`
+	// StreamOptions 流式请求选项（OpenAI接口需要 include_usage 才会在最后一个分块返回用量）
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions 流式请求选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // Tool 工具定义
@@ -68,6 +124,8 @@ type ChatMessage struct {
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// FunctionCall 旧版接口返回的函数调用，解析后会转换为ToolCalls
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
 }
 
 // ChatResponse 聊天响应
@@ -75,16 +133,15 @@ type ChatResponse struct {
 	ID      string `json:"id"`
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
-	Choices []struct {
-		Index   int         `json:"index"`
-		Message ChatMessage `json:"message"`
-		Finish  string      `json:"finish_reason"`
-	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+}
+
+// Choice 响应中的候选回答
+type Choice struct {
+	Index   int         `json:"index"`
+	Message ChatMessage `json:"message"`
+	Finish  string      `json:"finish_reason"`
 }
 
 // NewClient 创建LLM客户端
@@ -98,63 +155,116 @@ func NewClient(apiKey, baseURL, model string, timeout time.Duration) *Client {
 	}
 }
 
+// WithModel 复制一个使用指定模型的客户端
+func (c *Client) WithModel(model string) *Client {
+	clone := *c
+	clone.Model = model
+	return &clone
+}
+
 // Chat 发送聊天请求（带工具支持）
+// 如果回答因长度被截断，会自动发起续写请求并将各段内容拼接为一个完整回答
 func (c *Client) Chat(ctx context.Context, messages []Message, tools []Tool, toolChoice string) (*ChatResponse, error) {
-	// 构建请求
-	reqBody := ChatRequest{
-		Model:      c.Model,
-		Messages:   messages,
-		Tools:      tools,
-		ToolChoice: toolChoice,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+	resp, err := c.chatOnce(ctx, messages, tools, toolChoice)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, err
 	}
 
-	// 构建URL，确保正确处理斜杠
-	baseURL := strings.TrimRight(c.baseURL, "/")
-	url := fmt.Sprintf("%s/chat/completions", baseURL)
+	for i := 0; i < c.MaxContinuations; i++ {
+		choice := &resp.Choices[0]
+		if choice.Finish != "length" || len(choice.Message.ToolCalls) > 0 {
+			break
+		}
 
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		next, err := c.chatOnce(ctx, withContinuation(messages, choice.Message.Content), tools, toolChoice)
+		if err != nil {
+			return nil, fmt.Errorf("续写失败: %w", err)
+		}
+
+		nextChoice := next.Choices[0]
+		choice.Message.Content += nextChoice.Message.Content
+		choice.Message.ToolCalls = nextChoice.Message.ToolCalls
+		choice.Finish = nextChoice.Finish
+		resp.Usage.PromptTokens += next.Usage.PromptTokens
+		resp.Usage.CompletionTokens += next.Usage.CompletionTokens
+		resp.Usage.TotalTokens += next.Usage.TotalTokens
+		resp.Usage.PromptTokensDetails.CachedTokens += next.Usage.CachedTokens()
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	return resp, nil
+}
 
-	// 发送请求
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+// withContinuation 构建续写请求的消息列表
+func withContinuation(messages []Message, partial string) []Message {
+	return withPartial(messages, partial, continuationPrompt)
+}
+
+// withPartial 在消息列表后附加已输出的部分回答和继续输出的要求
+func withPartial(messages []Message, partial, prompt string) []Message {
+	next := make([]Message, 0, len(messages)+2)
+	next = append(next, messages...)
+	return append(next,
+		Message{Role: "assistant", Content: partial},
+		Message{Role: "user", Content: prompt},
+	)
+}
+
+// chatOnce 发送单次聊天请求
+func (c *Client) chatOnce(ctx context.Context, messages []Message, tools []Tool, toolChoice string) (*ChatResponse, error) {
+	// 检查预算
+	if c.Usage != nil {
+		if err := c.Usage.Check(); err != nil {
+			return nil, err
+		}
 	}
-	defer resp.Body.Close()
 
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
+	record := c.newCallRecord(messages, tools, toolChoice, false)
+	reqBody := c.buildRequest(messages, tools, toolChoice)
+	chatResp, err := c.provider().Chat(ctx, &reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		c.finishCall(record, err)
+		return nil, err
 	}
-
-	// 检查状态码
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API请求失败 (status %d): %s", resp.StatusCode, string(body))
+	if record != nil {
+		usage := chatResp.Usage
+		record.Usage = &usage
+		if len(chatResp.Choices) > 0 {
+			record.Content = chatResp.Choices[0].Message.Content
+			record.ToolCalls = chatResp.Choices[0].Message.ToolCalls
+		}
+		c.finishCall(record, nil)
 	}
 
-	// 解析响应
-	var chatResp ChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w\n响应内容: %s", err, string(body))
+	if c.Usage != nil {
+		c.Usage.RecordTokens(c.Model, chatResp.Usage.PromptTokens, chatResp.Usage.CachedTokens(), chatResp.Usage.CompletionTokens)
 	}
 
 	if len(chatResp.Choices) == 0 {
 		return nil, fmt.Errorf("响应中没有消息")
 	}
 
-	return &chatResp, nil
+	return chatResp, nil
+}
+
+// buildRequest 构建统一格式的请求（提示词缓存标记、工具字段），由服务协议转换为原生格式发送
+func (c *Client) buildRequest(messages []Message, tools []Tool, toolChoice string) ChatRequest {
+	messages, cacheKey := c.applyPromptCache(messages)
+	messages, toolFields := c.toolFields(messages, tools, toolChoice)
+	reqBody := ChatRequest{
+		Model:          c.Model,
+		Messages:       messages,
+		MaxTokens:      c.MaxTokens,
+		PromptCacheKey: cacheKey,
+	}
+	if v, ok := toolFields["tools"].([]Tool); ok {
+		reqBody.Tools = v
+	}
+	if v, ok := toolFields["functions"].([]FunctionDef); ok {
+		reqBody.Functions = v
+	}
+	reqBody.ToolChoice = toolFields["tool_choice"]
+	reqBody.FunctionCall = toolFields["function_call"]
+	return reqBody
 }
 
 // SimpleQuery 简单查询
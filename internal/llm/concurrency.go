@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ConcurrencyLimiter 限制同时进行中的LLM请求数，按服务地址（host）分别计数，
+// 避免并行的DAG节点、共识模式等同时发起过多请求而触发服务商限流
+type ConcurrencyLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewConcurrencyLimiter 创建并发限制器，limit<=0 表示不限制
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// slot 获取服务地址对应的并发槽位
+func (l *ConcurrencyLimiter) slot(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slot, ok := l.slots[host]
+	if !ok {
+		slot = make(chan struct{}, l.limit)
+		l.slots[host] = slot
+	}
+	return slot
+}
+
+// Transport 包装HTTP传输层：请求前等待空闲槽位，响应体读取完毕或关闭后释放（流式响应在整个流结束前占用槽位）
+func (l *ConcurrencyLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if l == nil || l.limit <= 0 {
+		return next
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		slot := l.slot(req.URL.Host)
+		select {
+		case slot <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		var once sync.Once
+		release := func() { once.Do(func() { <-slot }) }
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.Body == nil {
+			release()
+			return resp, err
+		}
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+		return resp, nil
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// releaseOnClose 响应体读到结尾或关闭时释放并发槽位
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// EmbeddingRequest 向量化请求（OpenAI embeddings 接口及兼容服务）
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse 向量化响应
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage Usage `json:"usage"`
+}
+
+// Embed 使用客户端的模型将文本转换为向量，返回的向量与输入一一对应
+// Anthropic 没有向量化接口，需要为向量化单独配置 OpenAI 兼容的服务
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	if c.Usage != nil {
+		if err := c.Usage.Check(); err != nil {
+			return nil, err
+		}
+	}
+
+	provider := &openAIProvider{c: c}
+	resp, err := c.post(ctx, "/embeddings", EmbeddingRequest{Model: c.Model, Input: inputs}, provider.header(false), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	var embResp EmbeddingResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, &ResponseParseError{Body: string(body), Err: err}
+	}
+	if len(embResp.Data) != len(inputs) {
+		return nil, fmt.Errorf("向量数量与输入不一致: %d != %d", len(embResp.Data), len(inputs))
+	}
+
+	if c.Usage != nil {
+		c.Usage.RecordTokens(c.Model, embResp.Usage.PromptTokens, 0, 0)
+	}
+
+	sort.Slice(embResp.Data, func(i, j int) bool {
+		return embResp.Data[i].Index < embResp.Data[j].Index
+	})
+	vectors := make([][]float32, len(embResp.Data))
+	for i, item := range embResp.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"agentcli/internal/events"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// publish 发布事件
+func (c *Client) publish(e events.Event) {
+	if c.Events == nil {
+		return
+	}
+	if e.Model == "" {
+		e.Model = c.Model
+	}
+	c.Events.Publish(e)
+}
+
+// publishFailure 请求失败时发布限流或超时事件
+func (c *Client) publishFailure(err error, header http.Header) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		c.publish(events.Event{Type: events.LLMRateLimited, Delay: retryAfter(header), Err: err})
+		return
+	}
+	if isTimeout(err) {
+		c.publish(events.Event{Type: events.LLMTimeout, Err: err})
+	}
+}
+
+// publishRetry 发布重试事件（重试前调用）
+func (c *Client) publishRetry(attempt, maxAttempts int, delay time.Duration, err error) {
+	c.publish(events.Event{
+		Type:        events.LLMRetry,
+		Attempt:     attempt,
+		MaxAttempts: maxAttempts,
+		Delay:       delay,
+		Err:         err,
+	})
+}
+
+// isTimeout 判断错误是否为超时
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter 解析Retry-After响应头（秒数或HTTP日期）
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
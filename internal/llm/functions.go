@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tool_choice 取值（其他取值视为要强制调用的函数名）
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// toolChoiceValue 构建tools接口的tool_choice字段
+func toolChoiceValue(choice string) interface{} {
+	switch choice {
+	case "":
+		return nil
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return choice
+	default:
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice},
+		}
+	}
+}
+
+// functionCallValue 构建旧版functions接口的function_call字段
+// 旧版接口没有required，只有一个函数时改为强制调用该函数
+func functionCallValue(choice string, tools []Tool) interface{} {
+	switch choice {
+	case "":
+		return nil
+	case ToolChoiceAuto, ToolChoiceNone:
+		return choice
+	case ToolChoiceRequired:
+		if len(tools) == 1 {
+			return map[string]string{"name": tools[0].Function.Name}
+		}
+		return ToolChoiceAuto
+	default:
+		return map[string]string{"name": choice}
+	}
+}
+
+// legacyFunctions 将工具转换为旧版functions列表
+func legacyFunctions(tools []Tool) []FunctionDef {
+	functions := make([]FunctionDef, 0, len(tools))
+	for _, tool := range tools {
+		functions = append(functions, tool.Function)
+	}
+	return functions
+}
+
+// toLegacyMessages 将tool_calls/tool消息转换为旧版function_call/function消息
+func toLegacyMessages(messages []Message) []Message {
+	names := make(map[string]string)
+	legacy := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			for _, call := range msg.ToolCalls {
+				names[call.ID] = call.Function.Name
+			}
+			call := msg.ToolCalls[0].Function
+			msg.FunctionCall = &call
+			msg.ToolCalls = nil
+		case msg.Role == "tool":
+			msg.Role = "function"
+			msg.Name = names[msg.ToolCallID]
+			msg.ToolCallID = ""
+		}
+		legacy = append(legacy, msg)
+	}
+	return legacy
+}
+
+// fromLegacyResponse 将旧版function_call响应转换为tool_calls，调用方无需区分两种接口
+func fromLegacyResponse(resp *ChatResponse) {
+	for i := range resp.Choices {
+		msg := &resp.Choices[i].Message
+		if msg.FunctionCall == nil || len(msg.ToolCalls) > 0 {
+			continue
+		}
+		msg.ToolCalls = []ToolCall{{
+			ID:       fmt.Sprintf("call_legacy_%d", i),
+			Type:     "function",
+			Function: *msg.FunctionCall,
+		}}
+		msg.FunctionCall = nil
+		if resp.Choices[i].Finish == "function_call" {
+			resp.Choices[i].Finish = "tool_calls"
+		}
+	}
+}
+
+// toolFields 根据接口类型构建请求中的工具相关字段
+func (c *Client) toolFields(messages []Message, tools []Tool, toolChoice string) ([]Message, map[string]interface{}) {
+	fields := make(map[string]interface{})
+	// Anthropic、Ollama 原生接口由 provider 自行转换工具定义，不使用旧版接口
+	if c.LegacyFunctions && !strings.EqualFold(c.Backend, ProviderAnthropic) && !strings.EqualFold(c.Backend, ProviderOllama) {
+		messages = toLegacyMessages(messages)
+		if len(tools) > 0 {
+			fields["functions"] = legacyFunctions(tools)
+			if v := functionCallValue(toolChoice, tools); v != nil {
+				fields["function_call"] = v
+			}
+		}
+		return messages, fields
+	}
+
+	if len(tools) > 0 {
+		fields["tools"] = tools
+		if v := toolChoiceValue(toolChoice); v != nil {
+			fields["tool_choice"] = v
+		}
+	}
+	return messages, fields
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"agentcli/internal/events"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrStreamStalled 流式响应超过空闲超时时间没有收到任何数据
+var ErrStreamStalled = errors.New("流式响应卡住")
+
+// streamWaitingAfter 流式响应空闲超过该时间时发布等待事件（界面据此显示等待状态）
+const streamWaitingAfter = 3 * time.Second
+
+// idleWatch 监视流式响应的数据到达：空闲一段时间后发布等待事件，超过空闲超时后调用abort中断连接
+type idleWatch struct {
+	c       *Client
+	timeout time.Duration
+	abort   func()
+
+	mu      sync.Mutex
+	last    time.Time
+	waiting bool
+	stalled bool
+	done    bool
+	stop    chan struct{}
+}
+
+// watchIdle 开始监视，timeout为0时只发布等待事件、不中断连接
+func (c *Client) watchIdle(timeout time.Duration, abort func()) *idleWatch {
+	w := &idleWatch{c: c, timeout: timeout, abort: abort, last: time.Now(), stop: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *idleWatch) run() {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-tick.C:
+		}
+
+		w.mu.Lock()
+		idle := time.Since(w.last)
+		if w.timeout > 0 && idle >= w.timeout {
+			w.stalled = true
+			w.mu.Unlock()
+			w.c.publish(events.Event{Type: events.LLMStreamStalled, Delay: w.timeout})
+			w.abort()
+			return
+		}
+		publish := idle >= streamWaitingAfter
+		if publish {
+			w.waiting = true
+		}
+		w.mu.Unlock()
+		if publish {
+			w.c.publish(events.Event{Type: events.LLMStreamWaiting, Delay: idle})
+		}
+	}
+}
+
+// received 收到数据，重新开始计时
+func (w *idleWatch) received() {
+	w.mu.Lock()
+	w.last = time.Now()
+	waiting := w.waiting
+	w.waiting = false
+	w.mu.Unlock()
+	if waiting {
+		w.c.publish(events.Event{Type: events.LLMStreamActive})
+	}
+}
+
+// isStalled 是否因空闲超时中断了连接
+func (w *idleWatch) isStalled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalled
+}
+
+// err 空闲超时的错误
+func (w *idleWatch) err() error {
+	return fmt.Errorf("%w（%s内未收到数据）", ErrStreamStalled, w.timeout)
+}
+
+// close 停止监视并释放请求上下文（可重复调用）
+func (w *idleWatch) close() {
+	w.mu.Lock()
+	if w.done {
+		w.mu.Unlock()
+		return
+	}
+	w.done = true
+	waiting := w.waiting
+	w.waiting = false
+	w.mu.Unlock()
+	close(w.stop)
+	w.abort()
+	if waiting {
+		w.c.publish(events.Event{Type: events.LLMStreamActive})
+	}
+}
+
+// idleBody 流式响应体：每次读到数据时重新计时，空闲超时中断后读取返回 ErrStreamStalled
+type idleBody struct {
+	io.ReadCloser
+	watch *idleWatch
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watch.received()
+	}
+	if err != nil && b.watch.isStalled() {
+		return n, b.watch.err()
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.watch.close()
+	return b.ReadCloser.Close()
+}
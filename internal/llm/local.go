@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// localPorts 常见本地模型服务的默认端口（Ollama、LM Studio、llama.cpp server）
+var localPorts = map[string]bool{
+	"11434": true,
+	"1234":  true,
+	"8080":  true,
+}
+
+// IsLocalBaseURL 判断API地址是否指向本机或局域网内的本地模型服务（Ollama、LM Studio等）
+func IsLocalBaseURL(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" || host == "host.docker.internal" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return true
+		}
+		// 局域网地址上的常见本地模型端口
+		return ip.IsPrivate() && localPorts[u.Port()]
+	}
+	return false
+}
+
+// requestError 包装发送请求的错误，本地服务无法连接时给出明确提示
+func (c *Client) requestError(err error) error {
+	if IsLocalBaseURL(c.baseURL) && errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("无法连接本地模型服务 %s，请确认Ollama/LM Studio等服务已启动: %w", c.baseURL, err)
+	}
+	return fmt.Errorf("发送请求失败: %w", err)
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelInfo 模型能力信息
+type ModelInfo struct {
+	Name            string
+	FunctionCalling bool // 是否支持原生函数调用（tools字段）
+	ContextWindow   int  // 上下文窗口大小（tokens），0表示未知
+}
+
+// DefaultContextWindow 目录中没有的模型使用的上下文窗口大小
+const DefaultContextWindow = 128000
+
+// Catalog 内置模型目录
+var Catalog = []ModelInfo{
+	{Name: "gpt-4", FunctionCalling: true, ContextWindow: 8192},
+	{Name: "gpt-5.2", FunctionCalling: true, ContextWindow: 400000},
+	{Name: "o4-mini", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "o3", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "o3-pro", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "sora_image", FunctionCalling: false},
+	{Name: "sora-2-pro", FunctionCalling: false},
+	{Name: "claude-opus-4-5-20251101-thinking", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "claude-sonnet-4-5-20250929", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "claude-sonnet-4-5-20250929-thinking", FunctionCalling: true, ContextWindow: 200000},
+	{Name: "gemini-3-pro-preview-thinking", FunctionCalling: true, ContextWindow: 1048576},
+	{Name: "gemini-3-pro-preview", FunctionCalling: true, ContextWindow: 1048576},
+	{Name: "gemini-3-pro-all", FunctionCalling: true, ContextWindow: 1048576},
+	{Name: "gemini-3-pro-image-preview", FunctionCalling: false},
+	{Name: "qwen-plus", FunctionCalling: true, ContextWindow: 131072},
+}
+
+// LookupModel 在模型目录中查找模型
+func LookupModel(name string) (ModelInfo, bool) {
+	for _, m := range Catalog {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// SupportsFunctionCalling 判断模型是否支持原生函数调用（目录中没有的模型默认支持）
+func SupportsFunctionCalling(name string) bool {
+	if m, ok := LookupModel(name); ok {
+		return m.FunctionCalling
+	}
+	return true
+}
+
+// ContextWindow 返回模型的上下文窗口大小，目录中没有或未知时返回 DefaultContextWindow
+func ContextWindow(name string) int {
+	if m, ok := LookupModel(name); ok && m.ContextWindow > 0 {
+		return m.ContextWindow
+	}
+	return DefaultContextWindow
+}
+
+// APIError API返回的错误响应
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 响应头 Retry-After 要求的等待时间，未指定时为0
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API请求失败 (status %d): %s", e.StatusCode, e.Body)
+}
+
+// ResponseParseError 服务返回了成功状态码，但响应内容无法解析
+type ResponseParseError struct {
+	Body string
+	Err  error
+}
+
+func (e *ResponseParseError) Error() string {
+	return fmt.Sprintf("解析响应失败: %v\n响应内容: %s", e.Err, e.Body)
+}
+
+func (e *ResponseParseError) Unwrap() error {
+	return e.Err
+}
+
+// contextOverflowPatterns 各服务商上下文超限错误的特征文本
+var contextOverflowPatterns = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context length",
+	"context window",
+	"prompt is too long",
+	"too many tokens",
+	"reduce the length",
+	"input is too long",
+}
+
+// IsContextOverflow 判断错误是否由请求超出模型上下文长度引起
+func IsContextOverflow(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	body := strings.ToLower(apiErr.Body)
+	for _, pattern := range contextOverflowPatterns {
+		if strings.Contains(body, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsToolsUnsupported 判断错误是否由模型不支持tools字段引起
+func IsToolsUnsupported(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body := strings.ToLower(apiErr.Body)
+	return strings.Contains(body, "tool") || strings.Contains(body, "function")
+}
@@ -0,0 +1,240 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaBaseURL Ollama默认的服务地址，api.provider 为 ollama 且未配置 base_url 时使用
+const OllamaBaseURL = "http://localhost:11434"
+
+// OllamaNativeURL Ollama原生接口的服务地址：未配置时使用默认地址，去掉OpenAI兼容接口的 /v1 后缀
+func OllamaNativeURL(baseURL string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return OllamaBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/v1")
+}
+
+// ollamaProvider Ollama原生 /api/chat 接口：工具参数为JSON对象，工具调用没有ID，流式响应为逐行JSON（NDJSON）
+type ollamaProvider struct {
+	c *Client
+}
+
+// ollamaRequest /api/chat 请求；stream 默认为true，需要显式传false
+type ollamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Tools    []Tool                 `json:"tools,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // base64编码的图片（多模态模型）
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // 工具结果对应的工具名
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaResponse 非流式响应，以及流式响应中的每一行
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (r *ollamaResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (p *ollamaProvider) header() http.Header {
+	header := make(http.Header)
+	// 经过反向代理等需要认证时使用 api.openai_key
+	if p.c.apiKey != "" {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", p.c.apiKey))
+	}
+	return header
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.c.post(ctx, "/api/chat", p.toOllamaRequest(req), p.header(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	var ollamaResp ollamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, &ResponseParseError{Body: string(body), Err: err}
+	}
+	if ollamaResp.Error != "" {
+		return nil, fmt.Errorf("模型服务返回错误: %s", ollamaResp.Error)
+	}
+
+	message := ChatMessage{Role: "assistant", Content: ollamaResp.Message.Content}
+	for i, call := range ollamaResp.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, fromOllamaToolCall(i, call))
+	}
+	return &ChatResponse{
+		Choices: []Choice{{Message: message, Finish: ollamaFinishReason(ollamaResp.DoneReason, len(message.ToolCalls) > 0)}},
+		Usage:   ollamaResp.usage(),
+	}, nil
+}
+
+func (p *ollamaProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(*StreamResponse) error) error {
+	resp, err := p.c.post(ctx, "/api/chat", p.toOllamaRequest(req), p.header(), true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	toolCalls := 0
+	return readNDJSON(resp.Body, func(line []byte) (bool, error) {
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return false, nil // 跳过无法解析的行
+		}
+		if chunk.Error != "" {
+			return false, fmt.Errorf("模型服务返回错误: %s", chunk.Error)
+		}
+
+		delta := &StreamResponse{Model: req.Model, Choices: make([]StreamChoice, 1)}
+		choice := &delta.Choices[0]
+		choice.Delta.Content = chunk.Message.Content
+		// 工具调用在一行中完整给出
+		for _, call := range chunk.Message.ToolCalls {
+			full := fromOllamaToolCall(toolCalls, call)
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, ToolCallDelta{
+				Index:    toolCalls,
+				ID:       full.ID,
+				Type:     full.Type,
+				Function: full.Function,
+			})
+			toolCalls++
+		}
+		if chunk.Done {
+			choice.FinishReason = ollamaFinishReason(chunk.DoneReason, toolCalls > 0)
+			usage := chunk.usage()
+			delta.Usage = &usage
+		}
+		if err := onDelta(delta); err != nil {
+			return false, err
+		}
+		return chunk.Done, nil
+	})
+}
+
+// toOllamaRequest 将统一格式的请求转换为 /api/chat 请求
+func (p *ollamaProvider) toOllamaRequest(req *ChatRequest) *ollamaRequest {
+	out := &ollamaRequest{Model: req.Model, Stream: req.Stream}
+	// Ollama默认的上下文窗口很小，超出的部分会被静默截断，按配置的窗口大小设置 num_ctx
+	options := make(map[string]interface{})
+	if p.c.ContextWindow > 0 {
+		options["num_ctx"] = p.c.ContextWindow
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(options) > 0 {
+		out.Options = options
+	}
+
+	toolNames := make(map[string]string) // 工具调用ID -> 工具名，用于工具结果消息
+	for _, msg := range req.Messages {
+		message := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		switch msg.Role {
+		case "assistant":
+			for _, call := range msg.ToolCalls {
+				var tc ollamaToolCall
+				tc.Function.Name = call.Function.Name
+				tc.Function.Arguments = toolInput(call.Function.Arguments)
+				message.ToolCalls = append(message.ToolCalls, tc)
+				toolNames[call.ID] = call.Function.Name
+			}
+		case "tool":
+			message.ToolName = toolNames[msg.ToolCallID]
+		}
+		for _, img := range msg.Images {
+			message.Images = append(message.Images, img.Data)
+		}
+		out.Messages = append(out.Messages, message)
+	}
+
+	// /api/chat 不支持 tool_choice，不调用工具时不发送工具定义
+	if choice, ok := req.ToolChoice.(string); !ok || choice != ToolChoiceNone {
+		out.Tools = req.Tools
+	}
+	return out
+}
+
+// fromOllamaToolCall 转换为统一格式的工具调用；Ollama不返回调用ID，按顺序生成
+func fromOllamaToolCall(index int, call ollamaToolCall) ToolCall {
+	return ToolCall{
+		ID:       fmt.Sprintf("call_%d", index),
+		Type:     "function",
+		Function: FunctionCall{Name: call.Function.Name, Arguments: toolArguments(call.Function.Arguments)},
+	}
+}
+
+// ollamaFinishReason 将done_reason转换为统一的finish_reason
+func ollamaFinishReason(doneReason string, toolCalls bool) string {
+	switch {
+	case toolCalls:
+		return "tool_calls"
+	case doneReason == "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
+
+// readNDJSON 逐行读取JSON，交给onLine，onLine返回done=true时结束读取；
+// onLine报告结束之前连接断开时返回 StreamInterruptedError
+func readNDJSON(body io.Reader, onLine func(line []byte) (done bool, err error)) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			// 最后一行可能没有换行符
+			done, lineErr := onLine(trimmed)
+			if lineErr != nil || done {
+				return lineErr
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return &StreamInterruptedError{Err: err}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// openAIProvider OpenAI chat/completions 接口及兼容服务
+type openAIProvider struct {
+	c *Client
+}
+
+func (p *openAIProvider) header(stream bool) http.Header {
+	header := make(http.Header)
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", p.c.apiKey))
+	if stream {
+		header.Set("Accept", "text/event-stream")
+	}
+	return header
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.c.post(ctx, "/chat/completions", req, p.header(false), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 解析响应
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, &ResponseParseError{Body: string(body), Err: err}
+	}
+	fromLegacyResponse(&chatResp)
+	return &chatResp, nil
+}
+
+func (p *openAIProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta func(*StreamResponse) error) error {
+	// 请求在最后一个分块中返回用量，否则流式请求无法计入预算
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	resp, err := p.c.post(ctx, "/chat/completions", req, p.header(true), true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	finished := false // 已收到结束原因
+	err = readSSE(resp.Body, func(data []byte) (bool, error) {
+		// 检查结束标记
+		if bytes.Equal(data, []byte("[DONE]")) {
+			return true, nil
+		}
+
+		// 解析JSON
+		var streamResp StreamResponse
+		if err := json.Unmarshal(data, &streamResp); err != nil {
+			return false, nil // 跳过无法解析的行
+		}
+		if len(streamResp.Choices) > 0 && streamResp.Choices[0].FinishReason != "" {
+			finished = true
+		}
+		return false, onDelta(&streamResp)
+	})
+
+	// 部分服务在发送结束原因后直接断开而不发送结束标记
+	var interrupted *StreamInterruptedError
+	if errors.As(err, &interrupted) && finished {
+		return nil
+	}
+	return err
+}
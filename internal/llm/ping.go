@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Ping 发送一个极小的请求，验证服务地址、认证和模型是否可用，返回请求耗时
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	_, err := c.chatOnce(ctx, []Message{{Role: "user", Content: "ping，只回复 OK"}}, nil, "")
+	return time.Since(start), err
+}
+
+// PingAdvice 根据预检失败的原因给出处理建议，无法判断原因时返回空字符串
+func (c *Client) PingAdvice(err error) string {
+	keyItem := "api.openai_key"
+	if strings.EqualFold(c.Backend, ProviderAnthropic) {
+		keyItem = "api.anthropic_key（或环境变量 ANTHROPIC_API_KEY）"
+	}
+
+	var apiErr *APIError
+	var parseErr *ResponseParseError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return fmt.Sprintf("API Key 无效、已过期或无权访问该模型，请检查配置项 %s", keyItem)
+		case apiErr.StatusCode == http.StatusNotFound:
+			if IsLocalBaseURL(c.baseURL) || strings.EqualFold(c.Backend, ProviderOllama) {
+				return fmt.Sprintf("服务中没有模型 %s，可先执行 ollama pull %s，或检查 api.model", c.Model, c.Model)
+			}
+			return fmt.Sprintf("模型 %s 不存在或接口地址有误，请检查 api.model 和 api.base_url（OpenAI兼容接口通常以 /v1 结尾）", c.Model)
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return "请求过于频繁或账户额度已用完，请检查账户余额和速率限制"
+		case apiErr.StatusCode >= 500:
+			return "模型服务暂时不可用，请稍后重试或更换 api.base_url"
+		default:
+			return fmt.Sprintf("请求被拒绝，请检查 api.model（当前 %s）和 api.provider 是否与服务匹配", c.Model)
+		}
+	case errors.As(err, &parseErr):
+		return fmt.Sprintf("%s 返回的不是模型接口的响应，请检查 api.base_url 和 api.provider", c.baseURL)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "请求超时，请检查网络和代理设置，或调大 api.precheck_timeout"
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("无法解析 %s 的域名，请检查 api.base_url 和网络", c.baseURL)
+	case errors.As(err, &opErr):
+		return fmt.Sprintf("无法连接 %s，请检查 api.base_url、网络和代理设置", c.baseURL)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 服务协议（api.provider）
+const (
+	ProviderOpenAI    = "openai"    // OpenAI chat/completions 及兼容接口（默认）
+	ProviderAnthropic = "anthropic" // Anthropic messages 接口
+	ProviderOllama    = "ollama"    // Ollama 原生 /api/chat 接口
+)
+
+// Provider 模型服务协议：将统一格式（OpenAI风格）的请求转换为服务的原生格式发送，并将响应转换回统一格式，
+// 预算检查、用量记录、续写和重连等逻辑由 Client 统一处理
+type Provider interface {
+	// Chat 发送单次请求
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	// ChatStream 发送流式请求，每个增量转换为 StreamResponse 交给onDelta，onDelta返回错误时立即终止读取
+	ChatStream(ctx context.Context, req *ChatRequest, onDelta func(*StreamResponse) error) error
+}
+
+// provider 返回客户端配置的服务协议
+func (c *Client) provider() Provider {
+	switch strings.ToLower(c.Backend) {
+	case ProviderAnthropic:
+		return &anthropicProvider{c: c}
+	case ProviderOllama:
+		return &ollamaProvider{c: c}
+	default:
+		return &openAIProvider{c: c}
+	}
+}
+
+// post 发送JSON请求，非200的响应转换为 APIError；stream 为true时不设置整体超时，
+// 而是在超过 StreamIdleTimeout 没有收到数据时中断连接并返回 ErrStreamStalled
+// 限流、服务端临时错误和连接中断按 Retry 策略等待后重试（收到响应之前的失败才会重试）
+func (c *Client) post(ctx context.Context, path string, body interface{}, header http.Header, stream bool) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, path, jsonData, header, stream)
+		if err == nil {
+			return resp, nil
+		}
+		delay, retry := c.retryDelay(ctx, attempt, err)
+		if !retry {
+			return nil, err
+		}
+		c.publishRetry(attempt, c.Retry.MaxAttempts-1, delay, err)
+		if !sleepContext(ctx, delay) {
+			return nil, err
+		}
+	}
+}
+
+// send 发送一次请求
+func (c *Client) send(ctx context.Context, path string, jsonData []byte, header http.Header, stream bool) (*http.Response, error) {
+	// 构建URL，确保正确处理斜杠
+	url := strings.TrimRight(c.baseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.client
+	var watch *idleWatch
+	if stream {
+		// 流式请求可能持续很长时间，创建一个没有超时的客户端副本，改为按数据到达的间隔判断是否卡住
+		streamClient := *c.client
+		streamClient.Timeout = 0
+		client = &streamClient
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		req = req.WithContext(streamCtx)
+		watch = c.watchIdle(c.StreamIdleTimeout, cancel)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if watch != nil {
+			watch.close()
+			if watch.isStalled() {
+				return nil, &StreamInterruptedError{Err: watch.err()}
+			}
+		}
+		c.publishFailure(err, nil)
+		return nil, c.requestError(err)
+	}
+	if watch != nil {
+		resp.Body = &idleBody{ReadCloser: resp.Body, watch: watch}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body), RetryAfter: retryAfter(resp.Header)}
+		c.publishFailure(apiErr, resp.Header)
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// readSSE 逐条读取SSE事件的data内容交给onData，onData返回done=true时结束读取；
+// onData报告结束之前连接断开时返回 StreamInterruptedError
+func readSSE(body io.Reader, onData func(data []byte) (done bool, err error)) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return &StreamInterruptedError{Err: err}
+		}
+
+		// SSE格式: data: {...}，跳过空行、event行和注释
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		done, err := onData(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:"))))
+		if err != nil || done {
+			return err
+		}
+	}
+}
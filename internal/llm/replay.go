@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 录制/回放模式
+const (
+	CassetteRecord = "record" // 转发到真实API并录制请求和响应
+	CassetteReplay = "replay" // 按顺序回放录制的响应，不访问网络
+)
+
+// cassetteEntry 录制的一次请求和响应
+type cassetteEntry struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Request     string `json:"request"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response"`
+}
+
+// Cassette 录制/回放LLM请求的HTTP传输层，用于端到端回归测试
+// 回放按请求顺序返回录制的响应（请求内容包含本机环境等信息，不适合按内容匹配）
+type Cassette struct {
+	mode    string
+	path    string
+	next    http.RoundTripper
+	mu      sync.Mutex
+	entries []cassetteEntry
+	pos     int
+}
+
+// NewCassette 创建录制/回放传输层：record模式清空并重新录制文件，replay模式加载已录制的文件
+func NewCassette(path, mode string) (*Cassette, error) {
+	c := &Cassette{mode: mode, path: path, next: http.DefaultTransport}
+	switch mode {
+	case CassetteRecord:
+		if dir := filepath.Dir(path); dir != "" && dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建录制目录失败: %w", err)
+			}
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return nil, fmt.Errorf("创建录制文件失败: %w", err)
+		}
+	case CassetteReplay:
+		entries, err := loadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		c.entries = entries
+	default:
+		return nil, fmt.Errorf("不支持的录制模式: %s (可选: record, replay)", mode)
+	}
+	return c, nil
+}
+
+// Remaining 回放模式下尚未使用的录制响应数
+func (c *Cassette) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries) - c.pos
+}
+
+// RoundTrip 实现http.RoundTripper
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取请求失败: %w", err)
+		}
+	}
+
+	if c.mode == CassetteReplay {
+		return c.replay(req)
+	}
+	return c.record(req, reqBody)
+}
+
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pos >= len(c.entries) {
+		return nil, fmt.Errorf("回放记录已用完（共 %d 条），请重新录制: %s", len(c.entries), c.path)
+	}
+	entry := c.entries[c.pos]
+	c.pos++
+
+	header := make(http.Header)
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	return &http.Response{
+		StatusCode:    entry.Status,
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(entry.Response))),
+		ContentLength: int64(len(entry.Response)),
+		Request:       req,
+	}, nil
+}
+
+func (c *Cassette) record(req *http.Request, reqBody []byte) (*http.Response, error) {
+	req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	entry := cassetteEntry{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Request:     string(reqBody),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Response:    string(respBody),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("序列化录制内容失败: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	return resp, nil
+}
+
+func loadCassette(path string) ([]cassetteEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var entries []cassetteEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry cassetteEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("解析录制文件失败: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	return entries, nil
+}
+
+// SetTransport 设置HTTP传输层（如录制/回放）
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.client.Transport = transport
+}
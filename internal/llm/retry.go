@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 临时性失败（限流、服务端错误、连接中断）的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 包含首次请求在内的最大请求次数，不大于1时不重试
+	Backoff     time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff  time.Duration // 单次等待的上限；服务通过 Retry-After 要求等待更久时不再重试，0表示不限制
+	Jitter      bool          // 在等待时间上加随机抖动，避免多个请求同时重试
+}
+
+// retryableStatus 可重试的HTTP状态码：请求超时、限流、网关和服务端临时错误（529为Anthropic的服务过载）
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	529:                            true,
+}
+
+// fatalPatterns 虽然是429等可重试状态码、但重试也无法恢复的错误（额度用完、账户欠费）
+var fatalPatterns = []string{
+	"insufficient_quota",
+	"billing",
+	"quota exceeded",
+	"credit balance",
+}
+
+// IsRetryable 判断错误是否为临时性失败，等待后重试可能成功；认证失败、参数错误、额度用完、用户取消等返回false
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !retryableStatus[apiErr.StatusCode] {
+			return false
+		}
+		body := strings.ToLower(apiErr.Body)
+		for _, pattern := range fatalPatterns {
+			if strings.Contains(body, pattern) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// 连接被重置、响应中途断开、网络超时；连接被拒绝和域名无法解析通常是配置问题，不重试
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryDelay 第 attempt 次请求失败后、下一次请求前的等待时间；返回false表示不再重试
+func (c *Client) retryDelay(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	policy := c.Retry
+	if attempt >= policy.MaxAttempts || ctx.Err() != nil || !IsRetryable(err) {
+		return 0, false
+	}
+
+	// 服务通过 Retry-After 指明了等待时间时以其为准
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if policy.MaxBackoff > 0 && apiErr.RetryAfter > policy.MaxBackoff {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	delay := policy.Backoff << (attempt - 1)
+	if delay <= 0 || policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	if policy.Jitter && delay > 0 {
+		// 在 [delay/2, delay) 之间随机取值
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+	return delay, true
+}
+
+// sleepContext 等待指定时间，上下文取消时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -1,31 +1,45 @@
 package llm
 
 import (
-	"bufio"
-	"bytes"
+	"agentcli/internal/events"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 )
 
 // StreamResponse 流式响应
 type StreamResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Model   string `json:"model"`
-	Choices []struct {
-		Index int `json:"index"`
-		Delta struct {
-			Role      string     `json:"role,omitempty"`
-			Content   string     `json:"content,omitempty"`
-			ToolCalls []ToolCall `json:"tool_calls,omitempty"`
-		} `json:"delta"`
-		FinishReason string `json:"finish_reason,omitempty"`
-	} `json:"choices"`
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
+}
+
+// StreamChoice 流式响应中候选回答的增量
+type StreamChoice struct {
+	Index        int         `json:"index"`
+	Delta        StreamDelta `json:"delta"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+}
+
+// StreamDelta 增量内容
+type StreamDelta struct {
+	Role         string          `json:"role,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	ToolCalls    []ToolCallDelta `json:"tool_calls,omitempty"`
+	FunctionCall *FunctionCall   `json:"function_call,omitempty"`
+}
+
+// ToolCallDelta 流式响应中的工具调用增量
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
 }
 
 // ChatStream 发送流式聊天请求
@@ -34,105 +48,160 @@ func (c *Client) ChatStream(ctx context.Context, messages []Message, onChunk fun
 }
 
 // ChatStreamWithTools 发送带工具的流式聊天请求
+// 如果回答因长度被截断，会自动续写并继续通过onChunk输出，返回拼接后的完整内容
 func (c *Client) ChatStreamWithTools(ctx context.Context, messages []Message, tools []Tool, toolChoice string, onChunk func(content string) error) (string, error) {
-	// 构建请求
-	reqBody := map[string]interface{}{
-		"model":    c.Model,
-		"messages": messages,
-		"stream":   true,
+	content, finishReason, err := c.streamOnce(ctx, messages, tools, toolChoice, onChunk)
+	if err != nil {
+		return "", err
 	}
-	
-	if len(tools) > 0 {
-		reqBody["tools"] = tools
-		if toolChoice != "" {
-			reqBody["tool_choice"] = toolChoice
+
+	for i := 0; i < c.MaxContinuations && finishReason == "length"; i++ {
+		var next string
+		next, finishReason, err = c.streamOnce(ctx, withContinuation(messages, content), tools, toolChoice, onChunk)
+		if err != nil {
+			return "", fmt.Errorf("续写失败: %w", err)
 		}
+		content += next
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
-	}
+	return content, nil
+}
 
-	// 构建URL
-	baseURL := strings.TrimRight(c.baseURL, "/")
-	url := fmt.Sprintf("%s/chat/completions", baseURL)
+// StreamInterruptedError 流式响应在结束前连接中断
+type StreamInterruptedError struct {
+	Err error
+}
 
-	// 创建HTTP请求
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
-	}
+func (e *StreamInterruptedError) Error() string {
+	return fmt.Sprintf("读取流失败: %v", e.Err)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-	req.Header.Set("Accept", "text/event-stream")
+func (e *StreamInterruptedError) Unwrap() error {
+	return e.Err
+}
 
-	// 发送请求
-	// 流式请求可能持续很长时间，创建一个没有超时的客户端副本
-	streamClient := *c.client
-	streamClient.Timeout = 0
-	resp, err := streamClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
-	}
-	defer resp.Body.Close()
+// streamOnce 发送单次流式请求，返回内容和结束原因
+// 连接中途断开时，携带已接收的内容重新请求，让模型从中断处继续输出（最多 StreamReconnects 次），已输出的内容不会丢失
+func (c *Client) streamOnce(ctx context.Context, messages []Message, tools []Tool, toolChoice string, onChunk func(content string) error) (string, string, error) {
+	var content string
+	request := messages
+	for attempt := 1; ; attempt++ {
+		part, finishReason, err := c.streamAttempt(ctx, request, tools, toolChoice, onChunk)
+		content += part
+		if err == nil {
+			return content, finishReason, nil
+		}
 
-	// 检查状态码
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API请求失败 (status %d): %s", resp.StatusCode, string(body))
+		var interrupted *StreamInterruptedError
+		if !errors.As(err, &interrupted) || attempt > c.StreamReconnects || ctx.Err() != nil {
+			return "", "", err
+		}
+		c.publish(events.Event{Type: events.LLMStreamResumed, Attempt: attempt, MaxAttempts: c.StreamReconnects, Err: err})
+		request = messages
+		if content != "" {
+			request = withPartial(messages, content, resumePrompt)
+		}
 	}
+}
 
-	// 读取流式响应
+// streamAttempt 发送一次流式请求，出错时仍返回已接收的内容
+func (c *Client) streamAttempt(ctx context.Context, messages []Message, tools []Tool, toolChoice string, onChunk func(content string) error) (string, string, error) {
 	var fullContent strings.Builder
-	reader := bufio.NewReader(resp.Body)
+	finishReason := ""
 
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
+	err := c.doStream(ctx, messages, tools, toolChoice, func(streamResp *StreamResponse) error {
+		if len(streamResp.Choices) == 0 {
+			return nil
+		}
+		if reason := streamResp.Choices[0].FinishReason; reason != "" {
+			finishReason = reason
+		}
+		content := streamResp.Choices[0].Delta.Content
+		if content != "" {
+			fullContent.WriteString(content)
+			// 调用回调函数
+			if onChunk != nil {
+				if err := onChunk(content); err != nil {
+					return err
+				}
 			}
-			return "", fmt.Errorf("读取流失败: %w", err)
 		}
+		return nil
+	})
+	return fullContent.String(), finishReason, err
+}
 
-		// 跳过空行
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
-			continue
+// doStream 发送流式请求，并对每个解析出的分块调用onDelta（onDelta返回错误时立即终止读取）
+func (c *Client) doStream(ctx context.Context, messages []Message, tools []Tool, toolChoice string, onDelta func(*StreamResponse) error) error {
+	// 检查预算
+	if c.Usage != nil {
+		if err := c.Usage.Check(); err != nil {
+			return err
 		}
+	}
 
-		// SSE格式: data: {...}
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			data := bytes.TrimPrefix(line, []byte("data: "))
-			
-			// 检查结束标记
-			if bytes.Equal(data, []byte("[DONE]")) {
-				break
-			}
+	trace := &streamCallTrace{record: c.newCallRecord(messages, tools, toolChoice, true), calls: map[int]*ToolCall{}}
+	reqBody := c.buildRequest(messages, tools, toolChoice)
+	reqBody.Stream = true
+	estimate := &streamUsageEstimate{}
+	err := c.provider().ChatStream(ctx, &reqBody, func(streamResp *StreamResponse) error {
+		// 服务在最后一个分块中返回用量
+		if streamResp.Usage != nil && c.Usage != nil {
+			c.Usage.RecordTokens(c.Model, streamResp.Usage.PromptTokens, streamResp.Usage.CachedTokens(), streamResp.Usage.CompletionTokens)
+		}
+		estimate.add(streamResp)
+		trace.add(streamResp)
+		return onDelta(streamResp)
+	})
+	// 服务没有返回用量时按估算值记录，避免流式请求绕过预算
+	if c.Usage != nil && !estimate.reported && (err == nil || estimate.received) {
+		c.Usage.RecordTokens(c.Model, estimateRequestTokens(&reqBody), 0, EstimateTokens(estimate.output.String()))
+	}
+	c.finishCall(trace.done(), err)
+	return err
+}
 
-			// 解析JSON
-			var streamResp StreamResponse
-			if err := json.Unmarshal(data, &streamResp); err != nil {
-				continue // 跳过无法解析的行
-			}
+// streamUsageEstimate 统计流式响应是否返回了用量，并估算已输出内容的token数
+type streamUsageEstimate struct {
+	reported bool // 服务返回了用量
+	received bool // 已收到输出内容
+	output   strings.Builder
+}
 
-			// 提取内容
-			if len(streamResp.Choices) > 0 {
-				content := streamResp.Choices[0].Delta.Content
-				if content != "" {
-					fullContent.WriteString(content)
-					// 调用回调函数
-					if onChunk != nil {
-						if err := onChunk(content); err != nil {
-							return "", err
-						}
-					}
-				}
-			}
+func (e *streamUsageEstimate) add(resp *StreamResponse) {
+	if resp.Usage != nil {
+		e.reported = true
+	}
+	for _, choice := range resp.Choices {
+		text := choice.Delta.Content
+		for _, call := range choice.Delta.ToolCalls {
+			text += call.Function.Name + call.Function.Arguments
+		}
+		if fc := choice.Delta.FunctionCall; fc != nil {
+			text += fc.Name + fc.Arguments
+		}
+		if text != "" {
+			e.received = true
+			e.output.WriteString(text)
 		}
 	}
+}
 
-	return fullContent.String(), nil
+// estimateRequestTokens 估算请求中消息和工具定义的token数
+func estimateRequestTokens(req *ChatRequest) int {
+	tokens := 0
+	for _, msg := range req.Messages {
+		tokens += EstimateMessageTokens(msg)
+	}
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			tokens += EstimateTokens(string(data))
+		}
+	}
+	if len(req.Functions) > 0 {
+		if data, err := json.Marshal(req.Functions); err == nil {
+			tokens += EstimateTokens(string(data))
+		}
+	}
+	return tokens
 }
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"agentcli/internal/events"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ToolCallValidator 工具调用校验函数
+// 参数开始到达时（此时工具名已完整）以complete=false调用一次，参数JSON完整后以complete=true再调用一次；
+// 返回错误时立即终止本次请求
+type ToolCallValidator func(call ToolCall, complete bool) error
+
+// ToolCallRejectedError 工具调用在流式接收过程中被提前拒绝
+type ToolCallRejectedError struct {
+	Call ToolCall
+	Err  error
+}
+
+func (e *ToolCallRejectedError) Error() string {
+	return fmt.Sprintf("工具调用 %s 无效: %v", e.Call.Function.Name, e.Err)
+}
+
+func (e *ToolCallRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// toolCallState 流式累积中的工具调用
+type toolCallState struct {
+	call      ToolCall
+	args      strings.Builder
+	started   bool // 已进行工具名校验
+	completed bool // 已进行完整参数校验
+}
+
+// ChatStreamValidated 以流式方式发送带工具的请求，增量累积工具调用参数并提前校验
+// 内容通过onChunk实时输出；明显无效的调用（未知工具、缺少必填参数等）会立即终止请求并返回ToolCallRejectedError，
+// 无需等待模型输出完整响应。成功时返回与Chat相同格式的响应
+func (c *Client) ChatStreamValidated(ctx context.Context, messages []Message, tools []Tool, toolChoice string, onChunk func(content string) error, validate ToolCallValidator) (*ChatResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var content strings.Builder
+	var calls []*toolCallState
+	finishReason := ""
+
+	stateAt := func(index int) *toolCallState {
+		for len(calls) <= index {
+			calls = append(calls, &toolCallState{call: ToolCall{Type: "function"}})
+		}
+		return calls[index]
+	}
+
+	check := func(state *toolCallState) error {
+		if validate == nil {
+			return nil
+		}
+		args := state.args.String()
+		if !state.started && args != "" {
+			state.started = true
+			if err := validate(state.call, false); err != nil {
+				return &ToolCallRejectedError{Call: state.call, Err: err}
+			}
+		}
+		if !state.completed && jsonValueComplete(args) {
+			state.completed = true
+			call := state.call
+			call.Function.Arguments = args
+			if err := validate(call, true); err != nil {
+				return &ToolCallRejectedError{Call: call, Err: err}
+			}
+		}
+		return nil
+	}
+
+	onDelta := func(streamResp *StreamResponse) error {
+		if len(streamResp.Choices) == 0 {
+			return nil
+		}
+		choice := streamResp.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+			if onChunk != nil {
+				if err := onChunk(choice.Delta.Content); err != nil {
+					return err
+				}
+			}
+		}
+
+		deltas := choice.Delta.ToolCalls
+		// 旧版接口的function_call增量视为第一个工具调用
+		if choice.Delta.FunctionCall != nil {
+			deltas = append(deltas, ToolCallDelta{Index: 0, Function: *choice.Delta.FunctionCall})
+		}
+		for _, delta := range deltas {
+			state := stateAt(delta.Index)
+			if delta.ID != "" {
+				state.call.ID = delta.ID
+			}
+			if delta.Type != "" {
+				state.call.Type = delta.Type
+			}
+			state.call.Function.Name += delta.Function.Name
+			state.args.WriteString(delta.Function.Arguments)
+			if err := check(state); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 连接中断时，如果还没有开始接收工具调用，携带已接收的内容重新请求续接
+	request := messages
+	for attempt := 1; ; attempt++ {
+		err := c.doStream(ctx, request, tools, toolChoice, onDelta)
+		if err == nil {
+			break
+		}
+		var interrupted *StreamInterruptedError
+		if !errors.As(err, &interrupted) || len(calls) > 0 || attempt > c.StreamReconnects || ctx.Err() != nil {
+			return nil, err
+		}
+		c.publish(events.Event{Type: events.LLMStreamResumed, Attempt: attempt, MaxAttempts: c.StreamReconnects, Err: err})
+		if content.Len() > 0 {
+			request = withPartial(messages, content.String(), resumePrompt)
+		}
+	}
+
+	resp := &ChatResponse{}
+	message := ChatMessage{Role: "assistant", Content: content.String()}
+	for i, state := range calls {
+		if state.call.ID == "" {
+			state.call.ID = fmt.Sprintf("call_stream_%d", i)
+		}
+		state.call.Function.Arguments = state.args.String()
+		// 流结束时参数仍不完整，按完整参数再校验一次
+		if !state.completed && validate != nil {
+			if err := validate(state.call, true); err != nil {
+				return nil, &ToolCallRejectedError{Call: state.call, Err: err}
+			}
+		}
+		message.ToolCalls = append(message.ToolCalls, state.call)
+	}
+
+	// 回答因长度被截断时自动续写（仅限没有工具调用的最终回答）
+	for i := 0; i < c.MaxContinuations && finishReason == "length" && len(message.ToolCalls) == 0; i++ {
+		var next string
+		var err error
+		next, finishReason, err = c.streamOnce(ctx, withContinuation(messages, message.Content), tools, toolChoice, onChunk)
+		if err != nil {
+			return nil, fmt.Errorf("续写失败: %w", err)
+		}
+		message.Content += next
+	}
+
+	resp.Choices = append(resp.Choices, Choice{Message: message, Finish: finishReason})
+	return resp, nil
+}
+
+// jsonValueComplete 判断增量接收的JSON参数是否已经完整（顶层对象或数组已闭合）
+// 参数不以 { 或 [ 开头时视为已完整，交由校验函数尽早报告格式错误
+func jsonValueComplete(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	if s[0] != '{' && s[0] != '[' {
+		return true
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
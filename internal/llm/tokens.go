@@ -0,0 +1,29 @@
+package llm
+
+import "unicode"
+
+// imageTokens 每张图片的估算token数（按中等分辨率图片计）
+const imageTokens = 1000
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符约每字1个token，其余字符约每4个1个token
+// 不依赖具体模型的分词器，只用于展示和预算估计
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessageTokens 估算一条消息的token数（内容、图片、工具调用参数和固定的消息格式开销）
+func EstimateMessageTokens(msg Message) int {
+	tokens := 4 + EstimateTokens(msg.Content) + len(msg.Images)*imageTokens
+	for _, call := range msg.ToolCalls {
+		tokens += EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return tokens
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"sort"
+	"time"
+)
+
+// CallRecord 一次LLM请求的输入、输出和耗时，用于回看会话或用其他模型重新执行
+// 续写、续接等自动追加的请求各自单独记录
+type CallRecord struct {
+	Model      string        `json:"model"`
+	Messages   []Message     `json:"messages"`
+	Tools      []Tool        `json:"tools,omitempty"`
+	ToolChoice string        `json:"tool_choice,omitempty"`
+	Stream     bool          `json:"stream,omitempty"`
+	Content    string        `json:"content"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	Usage      *Usage        `json:"usage,omitempty"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// CallTracer LLM请求记录器
+type CallTracer interface {
+	RecordCall(record CallRecord)
+}
+
+// newCallRecord 创建请求记录，没有设置记录器时返回nil
+func (c *Client) newCallRecord(messages []Message, tools []Tool, toolChoice string, stream bool) *CallRecord {
+	if c.Tracer == nil {
+		return nil
+	}
+	return &CallRecord{
+		Model:      c.Model,
+		Messages:   append([]Message(nil), messages...),
+		Tools:      tools,
+		ToolChoice: toolChoice,
+		Stream:     stream,
+		Started:    time.Now(),
+	}
+}
+
+// finishCall 补全耗时和错误后交给记录器
+func (c *Client) finishCall(record *CallRecord, err error) {
+	if record == nil {
+		return
+	}
+	record.Duration = time.Since(record.Started)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	c.Tracer.RecordCall(*record)
+}
+
+// streamCallTrace 按索引拼接流式响应中的工具调用增量
+type streamCallTrace struct {
+	record *CallRecord
+	calls  map[int]*ToolCall
+}
+
+func (t *streamCallTrace) add(resp *StreamResponse) {
+	if t.record == nil {
+		return
+	}
+	if resp.Usage != nil {
+		usage := *resp.Usage
+		t.record.Usage = &usage
+	}
+	for _, choice := range resp.Choices {
+		t.record.Content += choice.Delta.Content
+		for _, delta := range choice.Delta.ToolCalls {
+			call, ok := t.calls[delta.Index]
+			if !ok {
+				call = &ToolCall{Type: "function"}
+				t.calls[delta.Index] = call
+			}
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			call.Function.Name += delta.Function.Name
+			call.Function.Arguments += delta.Function.Arguments
+		}
+		if fc := choice.Delta.FunctionCall; fc != nil {
+			call, ok := t.calls[0]
+			if !ok {
+				call = &ToolCall{Type: "function"}
+				t.calls[0] = call
+			}
+			call.Function.Name += fc.Name
+			call.Function.Arguments += fc.Arguments
+		}
+	}
+}
+
+func (t *streamCallTrace) done() *CallRecord {
+	if t.record == nil {
+		return nil
+	}
+	indexes := make([]int, 0, len(t.calls))
+	for i := range t.calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		t.record.ToolCalls = append(t.record.ToolCalls, *t.calls[i])
+	}
+	return t.record
+}
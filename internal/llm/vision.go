@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Image 随消息发送给多模态模型的图片
+type Image struct {
+	MediaType string // 如 image/png
+	Data      string // base64 编码的图片内容
+}
+
+// imageURL OpenAI 分段内容中的图片地址（data URL）
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// DataURL 图片的 data URL 形式
+func (img Image) DataURL() string {
+	return "data:" + img.MediaType + ";base64," + img.Data
+}
+
+// parseDataURL 解析 base64 编码的 data URL，其他形式的地址（http链接）返回 false
+func parseDataURL(url string) (Image, bool) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasPrefix(url, "data:") || !strings.HasSuffix(meta, ";base64") {
+		return Image{}, false
+	}
+	return Image{MediaType: strings.TrimSuffix(meta, ";base64"), Data: data}, true
+}
+
+// UnmarshalJSON 兼容分段形式的内容（带缓存标记或图片的请求、部分服务的响应），文本段拼接为 Content，图片段还原为 Images
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var raw struct {
+		plain
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message(raw.plain)
+
+	content := strings.TrimSpace(string(raw.Content))
+	switch {
+	case content == "" || content == "null":
+		return nil
+	case strings.HasPrefix(content, `"`):
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return fmt.Errorf("无法解析消息内容: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			texts = append(texts, part.Text)
+			m.CacheControl = m.CacheControl || part.CacheControl != nil
+		case part.ImageURL != nil:
+			if img, ok := parseDataURL(part.ImageURL.URL); ok {
+				m.Images = append(m.Images, img)
+			}
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+	return nil
+}
+
+// DescribeImages 请求多模态模型根据提示词描述图片
+func (c *Client) DescribeImages(ctx context.Context, prompt string, images ...Image) (string, error) {
+	messages := []Message{{Role: "user", Content: prompt, Images: images}}
+	resp, err := c.Chat(ctx, messages, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("响应中没有消息")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
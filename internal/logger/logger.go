@@ -1,50 +1,167 @@
 package logger
 
 import (
+	"agentcli/internal/clock"
+	"agentcli/internal/redact"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// 日志输出位置，与 logging.output 配置对应
+const (
+	OutputFile   = "file"
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// 日志格式，与 logging.format 配置对应
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Dir 会话日志的根目录，按日期分子目录
+const Dir = "logs"
+
+// Options 日志级别、输出位置、格式和文件滚动方式
+type Options struct {
+	Level      string // debug、info、warn 或 error，为空时等同于 info
+	Output     string // file、stdout 或 stderr，为空时等同于 file
+	Format     string // text 或 json，为空时等同于 text
+	MaxSizeMB  int    // 单个日志文件的大小上限，超出后滚动，0表示不限
+	MaxBackups int    // 每个会话每天保留的滚动文件数
+	MaxAgeDays int    // 删除早于该天数的日志目录，0表示不清理
+}
+
+// levelRanks 各类记录的严重程度，低于配置级别的记录不输出；用户输入、回答、思考过程和工具调用按 info 处理
+var levelRanks = map[string]int{
+	"DEBUG":        0,
+	"INFO":         1,
+	"USER_INPUT":   1,
+	"AGENT_OUTPUT": 1,
+	"THINKING":     1,
+	"TOOL_CALL":    1,
+	"WARN":         2,
+	"ERROR":        3,
+}
+
+// levelRank 配置级别对应的严重程度，无法识别时按 info
+func levelRank(level string) int {
+	if rank, ok := levelRanks[strings.ToUpper(level)]; ok {
+		return rank
+	}
+	return levelRanks["INFO"]
+}
+
+// 内容记录模式，与 logging.content 配置对应
+const (
+	ContentOff     = "off"
+	ContentSampled = "sampled"
+	ContentAlways  = "always"
+)
+
+// ContentPolicy 用户输入、回答、思考过程和工具调用等内容的记录方式
+type ContentPolicy struct {
+	Mode       string   // off、sampled 或 always，为空时等同于 always
+	SampleRate float64  // sampled 模式下记录完整内容的轮次比例
+	MaxChars   int      // 每条内容最多记录的字符数，0表示不限
+	Redact     bool     // 记录前移除密钥
+	Secrets    []string // 需要移除的已知密钥值
+}
+
 // Logger 日志记录器
 type Logger struct {
 	sessionID string
-	logFile   *os.File
+	out       io.Writer
+	file      *rotatingFile // 输出到文件时非nil
+	minRank   int
+	json      bool
 	mu        sync.Mutex
-}
 
-// NewLogger 创建新的日志记录器
-func NewLogger(sessionID string) (*Logger, error) {
-	// 创建日志目录（当前目录下）
-	today := time.Now().Format("2006-01-02")
-	logDir := filepath.Join("logs", today)
-	
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建日志目录失败: %w", err)
-	}
-
-	// 创建日志文件
-	logPath := filepath.Join(logDir, fmt.Sprintf("%s.log", sessionID))
-	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("创建日志文件失败: %w", err)
-	}
+	content ContentPolicy
+	sampled bool // 当前轮次是否记录完整内容
+}
 
+// NewLogger 创建新的日志记录器；输出到文件时写入当前目录下的 logs/<日期>/<会话ID>.log，并清理过期的日志目录
+func NewLogger(sessionID string, opts Options) (*Logger, error) {
 	logger := &Logger{
 		sessionID: sessionID,
-		logFile:   file,
+		minRank:   levelRank(opts.Level),
+		json:      opts.Format == FormatJSON,
+		sampled:   true,
+	}
+
+	switch opts.Output {
+	case OutputStdout:
+		logger.out = os.Stdout
+	case OutputStderr:
+		logger.out = os.Stderr
+	default:
+		pruneOldLogs(Dir, opts.MaxAgeDays)
+		file, err := openRotatingFile(Dir, sessionID, opts.MaxSizeMB, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		logger.out, logger.file = file, file
 	}
 
 	logger.Info("会话开始", map[string]interface{}{
 		"session_id": sessionID,
-		"timestamp":  time.Now().Format(time.RFC3339),
+		"timestamp":  clock.Format(time.Now()),
 	})
 
 	return logger, nil
 }
 
+// LastSeq 会话已有日志中最后一行的序号，恢复会话时用于接续编号；没有日志时返回0
+func LastSeq(sessionID string) int64 {
+	paths, _ := filepath.Glob(filepath.Join(Dir, "*", sessionID+".log"))
+	var last int64
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		// 通常只需读取文件末尾；末尾是一条超长记录、找不到行首时再读取整个文件
+		matches := seqPattern.FindAllSubmatch(readTail(file, lastLineWindow), -1)
+		if len(matches) == 0 {
+			matches = seqPattern.FindAllSubmatch(readTail(file, 0), -1)
+		}
+		file.Close()
+		for _, m := range matches {
+			if n, err := strconv.ParseInt(string(m[1]), 10, 64); err == nil && n > last {
+				last = n
+			}
+		}
+	}
+	return last
+}
+
+// lastLineWindow 读取日志末尾的字节数
+const lastLineWindow = 64 * 1024
+
+// readTail 读取文件最后 n 字节，n 为0时读取整个文件
+func readTail(file *os.File, n int64) []byte {
+	offset := int64(0)
+	if info, err := file.Stat(); err == nil && n > 0 && info.Size() > n {
+		offset = info.Size() - n
+	}
+	data, _ := io.ReadAll(io.NewSectionReader(file, offset, 1<<62))
+	return data
+}
+
+// seqPattern 日志行开头的序号（文本格式和JSON格式）
+var seqPattern = regexp.MustCompile(`(?m)^(?:\[[^\]]+\] \[#|\{"time":"[^"]*","seq":)(\d+)`)
+
 // Info 记录信息日志
 func (l *Logger) Info(message string, data map[string]interface{}) {
 	l.log("INFO", message, data)
@@ -55,6 +172,11 @@ func (l *Logger) Debug(message string, data map[string]interface{}) {
 	l.log("DEBUG", message, data)
 }
 
+// Warn 记录警告日志
+func (l *Logger) Warn(message string, data map[string]interface{}) {
+	l.log("WARN", message, data)
+}
+
 // Error 记录错误日志
 func (l *Logger) Error(message string, err error, data map[string]interface{}) {
 	if data == nil {
@@ -66,20 +188,61 @@ func (l *Logger) Error(message string, err error, data map[string]interface{}) {
 	l.log("ERROR", message, data)
 }
 
-// UserInput 记录用户输入
+// SetContentPolicy 设置内容记录方式，并为当前轮次重新抽样
+func (l *Logger) SetContentPolicy(policy ContentPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.content = policy
+	l.sampled = l.roll()
+}
+
+// roll 决定一轮对话是否记录完整内容
+func (l *Logger) roll() bool {
+	switch l.content.Mode {
+	case ContentOff:
+		return false
+	case ContentSampled:
+		return rand.Float64() < l.content.SampleRate
+	default:
+		return true
+	}
+}
+
+// filter 按记录方式处理内容：未抽中的轮次只记录长度，其余按配置脱敏和截断
+func (l *Logger) filter(content string) string {
+	l.mu.Lock()
+	policy, sampled := l.content, l.sampled
+	l.mu.Unlock()
+
+	if !sampled {
+		return fmt.Sprintf("[内容未记录，%d字符]", len([]rune(content)))
+	}
+	if policy.Redact {
+		content = redact.Text(content, policy.Secrets)
+	}
+	if runes := []rune(content); policy.MaxChars > 0 && len(runes) > policy.MaxChars {
+		content = fmt.Sprintf("%s...[已截断，共%d字符]", string(runes[:policy.MaxChars]), len(runes))
+	}
+	return content
+}
+
+// UserInput 记录用户输入，每次用户输入开始新的一轮并重新抽样
 func (l *Logger) UserInput(input string) {
-	l.log("USER_INPUT", input, nil)
+	l.mu.Lock()
+	l.sampled = l.roll()
+	l.mu.Unlock()
+	l.log("USER_INPUT", l.filter(input), nil)
 }
 
 // AgentOutput 记录Agent输出
 func (l *Logger) AgentOutput(output string) {
-	l.log("AGENT_OUTPUT", output, nil)
+	l.log("AGENT_OUTPUT", l.filter(output), nil)
 }
 
 // ThinkingProcess 记录思考过程
 func (l *Logger) ThinkingProcess(stage string, content string) {
 	l.log("THINKING", stage, map[string]interface{}{
-		"content": content,
+		"content": l.filter(content),
 	})
 }
 
@@ -87,32 +250,51 @@ func (l *Logger) ThinkingProcess(stage string, content string) {
 func (l *Logger) ToolCall(toolName string, params map[string]interface{}, result interface{}, err error) {
 	data := map[string]interface{}{
 		"tool":   toolName,
-		"params": params,
-		"result": result,
+		"params": l.filter(fmt.Sprintf("%v", params)),
+		"result": l.filter(fmt.Sprintf("%v", result)),
 	}
 	if err != nil {
-		data["error"] = err.Error()
+		data["error"] = l.filter(err.Error())
 	}
 	l.log("TOOL_CALL", toolName, data)
 }
 
-// log 内部日志记录方法
+// entry JSON格式的一行日志
+type entry struct {
+	Time    string                 `json:"time"`
+	Seq     int64                  `json:"seq"`
+	Level   string                 `json:"level"`
+	Session string                 `json:"session"`
+	Msg     string                 `json:"msg"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// log 内部日志记录方法，低于配置级别的记录直接丢弃
 func (l *Logger) log(level, message string, data map[string]interface{}) {
+	if levelRanks[level] < l.minRank {
+		return
+	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	logLine := fmt.Sprintf("[%s] [%s] %s", timestamp, level, message)
-
-	if data != nil && len(data) > 0 {
-		logLine += fmt.Sprintf(" | Data: %+v", data)
+	now, seq := clock.Format(time.Now()), clock.Session.Next()
+	var logLine string
+	if l.json {
+		line, err := json.Marshal(entry{Time: now, Seq: seq, Level: level, Session: l.sessionID, Msg: message, Data: data})
+		if err != nil {
+			// 数据中有无法序列化的值时退回文本形式
+			line, _ = json.Marshal(entry{Time: now, Seq: seq, Level: level, Session: l.sessionID, Msg: message, Data: map[string]interface{}{"raw": fmt.Sprintf("%+v", data)}})
+		}
+		logLine = string(line)
+	} else {
+		logLine = fmt.Sprintf("[%s] [#%d] [%s] %s", now, seq, level, message)
+		if len(data) > 0 {
+			logLine += fmt.Sprintf(" | Data: %+v", data)
+		}
 	}
 
-	logLine += "\n"
-
-	if l.logFile != nil {
-		l.logFile.WriteString(logLine)
-		l.logFile.Sync()
+	if l.out != nil {
+		io.WriteString(l.out, logLine+"\n")
 	}
 }
 
@@ -120,11 +302,11 @@ func (l *Logger) log(level, message string, data map[string]interface{}) {
 func (l *Logger) Close() error {
 	l.Info("会话结束", map[string]interface{}{
 		"session_id": l.sessionID,
-		"timestamp":  time.Now().Format(time.RFC3339),
+		"timestamp":  clock.Format(time.Now()),
 	})
 
-	if l.logFile != nil {
-		return l.logFile.Close()
+	if l.file != nil {
+		return l.file.Close()
 	}
 	return nil
 }
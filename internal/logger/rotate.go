@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dayLayout 按日期分目录的目录名格式
+const dayLayout = "2006-01-02"
+
+// rotatingFile 会话日志文件：跨天时切换到新日期目录，超过大小上限时滚动为 <会话ID>.1.log、.2.log…
+type rotatingFile struct {
+	dir        string
+	sessionID  string
+	maxBytes   int64 // 0表示不按大小滚动
+	maxBackups int
+
+	file *os.File
+	day  string
+	size int64
+}
+
+// openRotatingFile 打开当天的会话日志文件（追加写入）
+// 目录解析为绝对路径，之后切换工作目录（如影子工作区模式）时滚动出的新文件仍在同一目录
+func openRotatingFile(dir, sessionID string, maxSizeMB, maxBackups int) (*rotatingFile, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("解析日志目录失败: %w", err)
+	}
+	dir = absDir
+	r := &rotatingFile{
+		dir:        dir,
+		sessionID:  sessionID,
+		maxBytes:   int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(time.Now().Format(dayLayout)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) path(day string, n int) string {
+	if n == 0 {
+		return filepath.Join(r.dir, day, r.sessionID+".log")
+	}
+	return filepath.Join(r.dir, day, fmt.Sprintf("%s.%d.log", r.sessionID, n))
+}
+
+func (r *rotatingFile) open(day string) error {
+	if err := os.MkdirAll(filepath.Join(r.dir, day), 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(r.path(day, 0), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %w", err)
+	}
+	r.file, r.day, r.size = file, day, info.Size()
+	return nil
+}
+
+// Write 写入一行日志，写入前按日期和大小检查是否需要切换文件
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if day := time.Now().Format(dayLayout); day != r.day {
+		r.closeFile()
+		if err := r.open(day); err != nil {
+			return 0, err
+		}
+	} else if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 当前文件改名为 .1.log，已有的备份依次后移，超出 maxBackups 的删除
+func (r *rotatingFile) rotate() error {
+	r.closeFile()
+	os.Remove(r.path(r.day, r.maxBackups))
+	for n := r.maxBackups - 1; n >= 0; n-- {
+		os.Rename(r.path(r.day, n), r.path(r.day, n+1))
+	}
+	if r.maxBackups <= 0 {
+		os.Remove(r.path(r.day, 0))
+	}
+	return r.open(r.day)
+}
+
+func (r *rotatingFile) Close() error {
+	return r.closeFile()
+}
+
+// closeFile 落盘并关闭当前文件；只在切换文件和关闭时同步，避免每行日志都等待磁盘
+func (r *rotatingFile) closeFile() error {
+	syncErr := r.file.Sync()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// pruneOldLogs 删除早于 maxAgeDays 天的日期目录（包括其中的会话日志和事件日志），maxAgeDays 为0时不清理
+func pruneOldLogs(dir string, maxAgeDays int) {
+	if maxAgeDays <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays).Format(dayLayout)
+	for _, entry := range entries {
+		if _, err := time.Parse(dayLayout, entry.Name()); err == nil && entry.IsDir() && entry.Name() < cutoff {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
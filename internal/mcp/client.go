@@ -0,0 +1,383 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProtocolVersion 客户端使用的MCP协议版本
+const ProtocolVersion = "2024-11-05"
+
+// 传输方式（mcp.servers[].transport）
+const (
+	TransportStdio = "stdio" // 启动本地进程，通过标准输入输出通信（默认）
+	TransportSSE   = "sse"   // 连接远程服务的SSE端点，通过HTTP POST发送请求
+)
+
+// defaultTimeout 连接和单次请求的默认超时时间
+const defaultTimeout = 30 * time.Second
+
+// ServerConfig MCP服务的连接配置
+type ServerConfig struct {
+	Name      string
+	Transport string
+	Command   string   // stdio: 启动命令
+	Args      []string // stdio: 命令参数
+	Env       []string // stdio: 额外的环境变量（KEY=VALUE）
+	URL       string   // sse: SSE端点地址
+	Headers   map[string]string
+	Timeout   time.Duration
+}
+
+// transport 收发JSON-RPC消息
+type transport interface {
+	send(ctx context.Context, msg []byte) error
+	// incoming 服务端发来的消息，连接断开时关闭
+	incoming() <-chan []byte
+	// err 连接断开的原因
+	err() error
+	close() error
+}
+
+// rpcMessage JSON-RPC 2.0 消息（请求、响应或通知）
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError 服务端返回的JSON-RPC错误
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP错误 %d: %s", e.Code, e.Message)
+}
+
+// ServerInfo 服务端在初始化时报告的信息
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Client 一个MCP服务的连接
+type Client struct {
+	Name    string
+	Info    ServerInfo
+	timeout time.Duration
+	trans   transport
+
+	nextID  int64
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	done    chan struct{}
+
+	hasTools     bool
+	hasResources bool
+}
+
+// Connect 连接MCP服务并完成初始化握手
+func Connect(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var trans transport
+	var err error
+	switch strings.ToLower(cfg.Transport) {
+	case "", TransportStdio:
+		trans, err = startStdio(cfg)
+	case TransportSSE:
+		trans, err = dialSSE(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("不支持的MCP传输方式: %s", cfg.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		Name:    cfg.Name,
+		timeout: timeout,
+		trans:   trans,
+		pending: make(map[string]chan *rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("MCP初始化失败: %w", err)
+	}
+	return c, nil
+}
+
+// initialize 协商协议版本和能力
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "agentcli", "version": "1.0.0"},
+	}
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      ServerInfo                 `json:"serverInfo"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	c.Info = result.ServerInfo
+	_, c.hasTools = result.Capabilities["tools"]
+	_, c.hasResources = result.Capabilities["resources"]
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+// readLoop 分发服务端消息：响应交给等待中的请求，服务端请求（如ping）直接回复
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		close(c.done)
+		c.mu.Unlock()
+	}()
+	for data := range c.trans.incoming() {
+		var msg rpcMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue // 跳过无法解析的消息
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			c.reply(&msg)
+		case msg.Method != "":
+			// 通知（如 notifications/tools/list_changed）暂不处理
+		default:
+			c.mu.Lock()
+			ch, ok := c.pending[string(msg.ID)]
+			delete(c.pending, string(msg.ID))
+			c.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		}
+	}
+}
+
+// reply 回复服务端发起的请求，只支持ping
+func (c *Client) reply(req *rpcMessage) {
+	resp := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.trans.send(ctx, data)
+}
+
+// call 发送请求并等待响应，result为nil时忽略响应内容
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := json.RawMessage(fmt.Sprintf("%d", atomic.AddInt64(&c.nextID, 1)))
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("序列化MCP请求失败: %w", err)
+	}
+
+	ch := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return c.closedError()
+	default:
+	}
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	if err := c.trans.send(ctx, data); err != nil {
+		return fmt.Errorf("发送MCP请求失败: %w", err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("解析MCP响应失败: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("MCP请求 %s 超时（%s）", method, c.timeout)
+	case <-c.done:
+		return c.closedError()
+	}
+}
+
+// notify 发送通知（不等待响应）
+func (c *Client) notify(ctx context.Context, method string, params interface{}) error {
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("序列化MCP通知失败: %w", err)
+	}
+	return c.trans.send(ctx, data)
+}
+
+func (c *Client) closedError() error {
+	if err := c.trans.err(); err != nil {
+		return fmt.Errorf("MCP服务 %s 连接已断开: %w", c.Name, err)
+	}
+	return errors.New("MCP服务 " + c.Name + " 连接已断开")
+}
+
+// Close 断开连接（stdio服务会结束进程）
+func (c *Client) Close() error {
+	return c.trans.close()
+}
+
+// ToolInfo 服务提供的工具
+type ToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	} `json:"annotations"`
+}
+
+// ListTools 列出服务提供的工具（服务未声明tools能力时返回空）
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	if !c.hasTools {
+		return nil, nil
+	}
+	var all []ToolInfo
+	cursor := ""
+	for {
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &result); err != nil {
+			return nil, fmt.Errorf("获取MCP工具列表失败: %w", err)
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// Content 工具结果或资源中的一段内容
+type Content struct {
+	Type     string            `json:"type"` // text/image/audio/resource
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// CallResult 工具调用结果
+type CallResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+// CallTool 调用工具
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result CallResult
+	if err := c.call(ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Resource 服务提供的资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents 资源内容（文本或base64编码的二进制）
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ListResources 列出服务提供的资源（服务未声明resources能力时返回空）
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	if !c.hasResources {
+		return nil, nil
+	}
+	var all []Resource
+	cursor := ""
+	for {
+		var result struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &result); err != nil {
+			return nil, fmt.Errorf("获取MCP资源列表失败: %w", err)
+		}
+		all = append(all, result.Resources...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// ReadResource 读取资源内容
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+func cursorParams(cursor string) interface{} {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]string{"cursor": cursor}
+}
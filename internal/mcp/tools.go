@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"agentcli/internal/tools"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxListedResources 读取资源工具的描述中最多列出的资源数
+const maxListedResources = 20
+
+// maxToolName 函数调用接口允许的工具名最大长度
+const maxToolName = 64
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ToolName MCP工具在本地注册的名称：<服务名>_<工具名>，只保留函数调用接口允许的字符
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server+"_"+tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// Register 获取服务的工具和资源并注册到工具注册表，返回注册的工具数和资源数
+func Register(ctx context.Context, registry *tools.ToolRegistry, c *Client) (int, int, error) {
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, info := range infos {
+		registry.Register(&Tool{client: c, info: info})
+	}
+
+	resources, err := c.ListResources(ctx)
+	if err != nil {
+		return len(infos), 0, err
+	}
+	if len(resources) > 0 {
+		registry.Register(&ResourceTool{client: c, resources: resources})
+	}
+	return len(infos), len(resources), nil
+}
+
+// Tool 将MCP服务的工具包装为本地工具
+type Tool struct {
+	client *Client
+	info   ToolInfo
+}
+
+func (t *Tool) Name() string {
+	return ToolName(t.client.Name, t.info.Name)
+}
+
+func (t *Tool) Description() string {
+	return fmt.Sprintf("[MCP:%s] %s", t.client.Name, t.info.Description)
+}
+
+func (t *Tool) GetParams() map[string]string {
+	params := make(map[string]string)
+	for name, prop := range t.properties() {
+		schema, _ := prop.(map[string]interface{})
+		desc, _ := schema["description"].(string)
+		if desc == "" {
+			desc, _ = schema["type"].(string)
+		}
+		params[name] = desc
+	}
+	return params
+}
+
+func (t *Tool) RequiredParams() []string {
+	required := []string{}
+	list, _ := t.info.InputSchema["required"].([]interface{})
+	for _, item := range list {
+		if name, ok := item.(string); ok {
+			required = append(required, name)
+		}
+	}
+	return required
+}
+
+// ParamSchema 服务声明的参数JSON Schema，参数类型不限于字符串
+func (t *Tool) ParamSchema() map[string]interface{} {
+	return t.properties()
+}
+
+func (t *Tool) properties() map[string]interface{} {
+	props, _ := t.info.InputSchema["properties"].(map[string]interface{})
+	return props
+}
+
+// ReadOnly 服务通过readOnlyHint声明的只读工具
+func (t *Tool) ReadOnly() bool {
+	return t.info.Annotations.ReadOnlyHint
+}
+
+func (t *Tool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	result, err := t.client.CallTool(ctx, t.info.Name, params)
+	if err != nil {
+		return nil, fmt.Errorf("调用MCP工具 %s 失败: %w", t.info.Name, err)
+	}
+	text := contentText(result.Content)
+	if result.IsError {
+		return nil, fmt.Errorf("MCP工具 %s 返回错误: %s", t.info.Name, text)
+	}
+	return map[string]interface{}{
+		"server":  t.client.Name,
+		"tool":    t.info.Name,
+		"content": text,
+	}, nil
+}
+
+// ResourceTool 读取MCP服务提供的资源
+type ResourceTool struct {
+	client    *Client
+	resources []Resource
+}
+
+func (t *ResourceTool) Name() string {
+	return ToolName(t.client.Name, "read_resource")
+}
+
+func (t *ResourceTool) Description() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[MCP:%s] 读取该服务提供的资源。可用资源:", t.client.Name)
+	resources := append([]Resource(nil), t.resources...)
+	sort.Slice(resources, func(i, j int) bool { return resources[i].URI < resources[j].URI })
+	for i, r := range resources {
+		if i == maxListedResources {
+			fmt.Fprintf(&sb, " 等共%d个", len(resources))
+			break
+		}
+		fmt.Fprintf(&sb, " %s", r.URI)
+		if r.Name != "" && r.Name != r.URI {
+			fmt.Fprintf(&sb, "(%s)", r.Name)
+		}
+	}
+	return sb.String()
+}
+
+func (t *ResourceTool) GetParams() map[string]string {
+	return map[string]string{"uri": "资源URI"}
+}
+
+func (t *ResourceTool) ReadOnly() bool {
+	return true
+}
+
+func (t *ResourceTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	uri, _ := params["uri"].(string)
+	if uri == "" {
+		return nil, fmt.Errorf("缺少uri参数")
+	}
+	contents, err := t.client.ReadResource(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("读取MCP资源 %s 失败: %w", uri, err)
+	}
+	var parts []string
+	for _, c := range contents {
+		parts = append(parts, resourceText(c))
+	}
+	return map[string]interface{}{
+		"server":  t.client.Name,
+		"uri":     uri,
+		"content": strings.Join(parts, "\n"),
+	}, nil
+}
+
+// contentText 将工具结果的内容转换为文本，非文本内容以占位说明代替
+func contentText(contents []Content) string {
+	var parts []string
+	for _, c := range contents {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Resource != nil:
+			parts = append(parts, resourceText(*c.Resource))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s内容 %s，%d字节base64]", c.Type, c.MimeType, len(c.Data)))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func resourceText(c ResourceContents) string {
+	if c.Blob != "" {
+		return fmt.Sprintf("[二进制资源 %s %s，%d字节base64]", c.URI, c.MimeType, len(c.Blob))
+	}
+	return c.Text
+}
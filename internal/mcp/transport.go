@@ -0,0 +1,280 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMessageSize 单条消息的最大长度
+const maxMessageSize = 16 * 1024 * 1024
+
+// stderrTail 保留stdio服务标准错误输出的末尾字节数，用于报告启动失败的原因
+const stderrTail = 2048
+
+// stdioTransport 本地进程：每行一条JSON-RPC消息
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	msgs   chan []byte
+	stderr *tailBuffer
+
+	mu      sync.Mutex // 保护写入
+	readErr error
+	once    sync.Once
+}
+
+func startStdio(cfg ServerConfig) (*stdioTransport, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("MCP服务 %s 未配置启动命令", cfg.Name)
+	}
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建MCP服务输入管道失败: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建MCP服务输出管道失败: %w", err)
+	}
+	t := &stdioTransport{cmd: cmd, stdin: stdin, msgs: make(chan []byte, 16), stderr: &tailBuffer{}}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动MCP服务 %s 失败: %w", cfg.Name, err)
+	}
+
+	go func() {
+		defer close(t.msgs)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			t.msgs <- append([]byte(nil), line...)
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = errors.New("进程已退出")
+		}
+		if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+			err = fmt.Errorf("%w: %s", err, tail)
+		}
+		t.mu.Lock()
+		t.readErr = err
+		t.mu.Unlock()
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *stdioTransport) incoming() <-chan []byte {
+	return t.msgs
+}
+
+func (t *stdioTransport) err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readErr
+}
+
+// close 关闭输入让服务自行退出，超时后结束进程
+func (t *stdioTransport) close() error {
+	t.once.Do(func() {
+		t.stdin.Close()
+		exited := make(chan struct{})
+		go func() {
+			t.cmd.Wait()
+			close(exited)
+		}()
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			t.cmd.Process.Kill()
+			<-exited
+		}
+	})
+	return nil
+}
+
+// tailBuffer 只保留最后 stderrTail 字节的缓冲区
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > stderrTail {
+		b.buf = b.buf[len(b.buf)-stderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// sseTransport 远程服务：通过SSE接收消息，向服务在endpoint事件中告知的地址POST发送消息
+type sseTransport struct {
+	client   *http.Client
+	headers  map[string]string
+	endpoint string
+	msgs     chan []byte
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	readErr error
+}
+
+func dialSSE(ctx context.Context, cfg ServerConfig) (*sseTransport, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("MCP服务 %s 未配置url", cfg.Name)
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("MCP服务地址无效: %w", err)
+	}
+
+	// SSE连接在整个会话期间保持，不受连接超时影响
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, "GET", cfg.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("创建MCP请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("连接MCP服务 %s 失败: %w", cfg.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("连接MCP服务 %s 失败: HTTP %d", cfg.Name, resp.StatusCode)
+	}
+
+	t := &sseTransport{client: client, headers: cfg.Headers, msgs: make(chan []byte, 16), cancel: cancel}
+	endpoint := make(chan string, 1)
+	go t.readEvents(resp.Body, base, endpoint)
+
+	// 服务首先通过endpoint事件告知发送消息的地址
+	select {
+	case t.endpoint = <-endpoint:
+		return t, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, fmt.Errorf("等待MCP服务 %s 的endpoint事件超时", cfg.Name)
+	}
+}
+
+// readEvents 解析SSE事件：endpoint事件的地址交给endpoint，message事件的内容交给msgs
+func (t *sseTransport) readEvents(body io.ReadCloser, base *url.URL, endpoint chan<- string) {
+	defer body.Close()
+	defer close(t.msgs)
+
+	reader := bufio.NewReaderSize(body, 64*1024)
+	event := ""
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("服务端关闭了连接")
+			}
+			t.mu.Lock()
+			t.readErr = err
+			t.mu.Unlock()
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			// 空行表示一个事件结束
+			payload := strings.Join(data, "\n")
+			switch event {
+			case "endpoint":
+				if ref, err := base.Parse(payload); err == nil {
+					select {
+					case endpoint <- ref.String():
+					default:
+					}
+				}
+			case "", "message":
+				if payload != "" {
+					t.msgs <- []byte(payload)
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (t *sseTransport) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (t *sseTransport) incoming() <-chan []byte {
+	return t.msgs
+}
+
+func (t *sseTransport) err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readErr
+}
+
+func (t *sseTransport) close() error {
+	t.cancel()
+	return nil
+}
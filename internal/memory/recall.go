@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxEntryRunes 单条记忆保存的最大字符数，过长的对话和工具结果截断后再向量化
+const maxEntryRunes = 2000
+
+// Embedder 文本向量化
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Recall 长期记忆：保存对话和工具结果的向量，按与请求的相关度召回
+type Recall struct {
+	store    *Store
+	embedder Embedder
+	topK     int
+	minScore float64
+}
+
+// NewRecall 创建长期记忆，每次召回最多 topK 条相似度不低于 minScore 的记忆
+func NewRecall(store *Store, embedder Embedder, topK int, minScore float64) *Recall {
+	return &Recall{store: store, embedder: embedder, topK: topK, minScore: minScore}
+}
+
+// Store 底层向量存储
+func (r *Recall) Store() *Store {
+	return r.store
+}
+
+// Remember 向量化并保存一组同类记忆
+func (r *Recall) Remember(ctx context.Context, kind, source string, texts []string) error {
+	var kept []string
+	for _, text := range texts {
+		if text = strings.TrimSpace(text); text != "" {
+			kept = append(kept, truncate(text, maxEntryRunes))
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+
+	vectors, err := r.embedder.Embed(ctx, kept)
+	if err != nil {
+		return fmt.Errorf("向量化记忆失败: %w", err)
+	}
+	now := time.Now()
+	entries := make([]Entry, len(kept))
+	for i, text := range kept {
+		entries[i] = Entry{Kind: kind, Text: text, Source: source, Created: now, Vector: vectors[i]}
+	}
+	return r.store.Add(entries...)
+}
+
+// Search 召回与查询最相关的记忆，记忆库为空时不发起向量化请求
+func (r *Recall) Search(ctx context.Context, query string) ([]Result, error) {
+	if r.store.Len() == 0 || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	vectors, err := r.embedder.Embed(ctx, []string{truncate(query, maxEntryRunes)})
+	if err != nil {
+		return nil, fmt.Errorf("向量化请求失败: %w", err)
+	}
+	return r.store.Search(vectors[0], r.topK, r.minScore), nil
+}
+
+// Format 将召回的记忆格式化为提示词
+func Format(results []Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("以下是与当前请求相关的历史记忆（来自以往的对话和工具执行结果，可能已过时，仅供参考）：")
+	for i, result := range results {
+		fmt.Fprintf(&sb, "\n[%d] (%s, %s)\n%s", i+1, result.Created.Format("2006-01-02"), result.Kind, result.Text)
+	}
+	return sb.String()
+}
+
+func truncate(text string, maxRunes int) string {
+	if runes := []rune(text); len(runes) > maxRunes {
+		return string(runes[:maxRunes]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// 记忆类型
+const (
+	KindConversation = "conversation" // 一轮对话（用户请求和回答）
+	KindTool         = "tool"         // 一次工具执行的结果
+)
+
+// Entry 一条长期记忆
+type Entry struct {
+	Kind    string    `json:"kind"`
+	Text    string    `json:"text"`
+	Source  string    `json:"source,omitempty"` // 来源，如对话ID
+	Created time.Time `json:"created"`
+	Vector  []float32 `json:"vector"`
+}
+
+// Result 检索结果
+type Result struct {
+	Entry
+	Score float64 // 与查询的余弦相似度
+}
+
+// Store 本地向量存储：每行一条记忆（JSONL），加载到内存中按余弦相似度检索
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	entries    []Entry
+	maxEntries int
+}
+
+// Open 打开向量存储，文件不存在时创建空存储；maxEntries>0 时超出后丢弃最早的记忆
+// 路径在打开时解析为绝对路径，之后切换工作目录（如影子工作区模式）时仍写入同一个文件
+func Open(path string, maxEntries int) (*Store, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("解析记忆库路径失败: %w", err)
+	}
+	path = absPath
+	s := &Store{path: path, maxEntries: maxEntries}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开记忆库失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// 跳过写入中断产生的不完整行
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || len(entry.Vector) == 0 {
+			continue
+		}
+		s.entries = append(s.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取记忆库失败: %w", err)
+	}
+	return s, nil
+}
+
+// Len 记忆条数
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Add 追加记忆，超出上限时重写文件丢弃最早的记忆
+func (s *Store) Add(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entries...)
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.entries = append([]Entry(nil), s.entries[len(s.entries)-s.maxEntries:]...)
+		return s.rewrite()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建记忆目录失败: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("写入记忆库失败: %w", err)
+	}
+	defer file.Close()
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("写入记忆库失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Clear 删除所有记忆（保留备份）
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+	if err := fsutil.RemoveWithBackup(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除记忆库失败: %w", err)
+	}
+	return nil
+}
+
+// rewrite 用内存中的记忆重写文件
+func (s *Store) rewrite() error {
+	var data []byte
+	for _, entry := range s.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建记忆目录失败: %w", err)
+	}
+	if err := fsutil.ReplaceFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("写入记忆库失败: %w", err)
+	}
+	return nil
+}
+
+// Search 返回与查询向量最相似的 k 条记忆（相似度不低于 minScore），按相似度从高到低排列
+func (s *Store) Search(vector []float32, k int, minScore float64) []Result {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []Result
+	for _, entry := range s.entries {
+		score := Cosine(vector, entry.Vector)
+		if score >= minScore {
+			results = append(results, Result{Entry: entry, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Cosine 两个向量的余弦相似度，维度不同或存在零向量时返回0
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
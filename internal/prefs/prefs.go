@@ -0,0 +1,153 @@
+package prefs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// DefaultDir 默认的偏好设置存储目录（当前目录下），每个用户一个文件
+const DefaultDir = "prefs"
+
+// 支持的偏好设置项
+const (
+	KeyModel       = "model"        // 默认模型
+	KeyVerbosity   = "verbosity"    // 回答详细程度
+	KeyLanguage    = "language"     // 回答语言
+	KeyAutoApprove = "auto_approve" // 执行命令前不询问确认（同 --yes）
+)
+
+// Keys 所有偏好设置项及说明，按名称排序
+var Keys = map[string]string{
+	KeyModel:       "默认模型（--model 优先）",
+	KeyVerbosity:   "回答详细程度: concise / normal / detailed",
+	KeyLanguage:    "回答语言，如 中文、English，留空跟随提问的语言",
+	KeyAutoApprove: "执行命令前不询问确认: true / false（--yes 优先）",
+}
+
+// Prefs 用户的偏好设置，未设置的项使用配置文件中的值
+type Prefs struct {
+	Model       string    `json:"model,omitempty"`
+	Verbosity   string    `json:"verbosity,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	AutoApprove *bool     `json:"auto_approve,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Get 获取偏好设置项的值，未设置时返回空字符串
+func (p *Prefs) Get(key string) (string, error) {
+	switch key {
+	case KeyModel:
+		return p.Model, nil
+	case KeyVerbosity:
+		return p.Verbosity, nil
+	case KeyLanguage:
+		return p.Language, nil
+	case KeyAutoApprove:
+		if p.AutoApprove == nil {
+			return "", nil
+		}
+		return strconv.FormatBool(*p.AutoApprove), nil
+	}
+	return "", unknownKeyError(key)
+}
+
+// Set 设置偏好设置项，value 为空时取消设置
+func (p *Prefs) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	switch key {
+	case KeyModel:
+		p.Model = value
+	case KeyVerbosity:
+		v := strings.ToLower(value)
+		switch v {
+		case "", "concise", "normal", "detailed":
+		default:
+			return fmt.Errorf("不支持的详细程度: %s (可选: concise, normal, detailed)", value)
+		}
+		p.Verbosity = v
+	case KeyLanguage:
+		p.Language = value
+	case KeyAutoApprove:
+		if value == "" {
+			p.AutoApprove = nil
+			return nil
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("auto_approve 只能是 true 或 false，当前为 %q", value)
+		}
+		p.AutoApprove = &enabled
+	default:
+		return unknownKeyError(key)
+	}
+	return nil
+}
+
+func unknownKeyError(key string) error {
+	return fmt.Errorf("未知的偏好设置项: %s (可选: %s)", key, strings.Join(KeyNames(), ", "))
+}
+
+// KeyNames 按名称排序的偏好设置项
+func KeyNames() []string {
+	names := make([]string, 0, len(Keys))
+	for name := range Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Store 用户的偏好设置存储
+type Store struct {
+	filePath string
+}
+
+// NewStore 创建用户的偏好设置存储
+func NewStore(dir, userID string) *Store {
+	return &Store{filePath: filepath.Join(dir, fmt.Sprintf("%s.json", userID))}
+}
+
+// Path 偏好设置文件路径
+func (s *Store) Path() string {
+	return s.filePath
+}
+
+// Load 读取偏好设置，文件不存在时返回空设置
+func (s *Store) Load() (*Prefs, error) {
+	p := &Prefs{}
+	if _, err := fsutil.ReadJSONWithBackup(s.filePath, p); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取偏好设置失败: %w", err)
+	}
+	return p, nil
+}
+
+// Save 写入偏好设置
+func (s *Store) Save(p *Prefs) error {
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("创建偏好设置目录失败: %w", err)
+	}
+	p.UpdatedAt = time.Now()
+	if err := fsutil.WriteJSONAtomic(s.filePath, p, 0644); err != nil {
+		return fmt.Errorf("写入偏好设置失败: %w", err)
+	}
+	return nil
+}
+
+// Set 修改一项偏好设置并保存，value 为空时取消设置
+func (s *Store) Set(key, value string) error {
+	p, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := p.Set(key, value); err != nil {
+		return err
+	}
+	return s.Save(p)
+}
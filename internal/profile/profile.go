@@ -0,0 +1,271 @@
+package profile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion 配置包格式版本
+const FormatVersion = 1
+
+// 配置包中的文件
+const (
+	manifestFile = "manifest.json"
+	configFile   = "config.yaml"
+	memoryFile   = "memory.txt"
+)
+
+// Manifest 配置包描述
+type Manifest struct {
+	Version   int       `json:"version"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []string  `json:"files"`
+}
+
+// Bundle 配置包内容：定制化记忆和去除密钥后的配置文件
+type Bundle struct {
+	Manifest Manifest
+	Memory   string
+	Config   []byte
+}
+
+// Write 将配置包写入 tar.gz 文件
+func Write(path string, bundle *Bundle) error {
+	files := make(map[string][]byte)
+	if bundle.Config != nil {
+		files[configFile] = bundle.Config
+	}
+	if bundle.Memory != "" {
+		files[memoryFile] = []byte(bundle.Memory)
+	}
+
+	bundle.Manifest.Version = FormatVersion
+	bundle.Manifest.Files = nil
+	for _, name := range []string{configFile, memoryFile} {
+		if _, ok := files[name]; ok {
+			bundle.Manifest.Files = append(bundle.Manifest.Files, name)
+		}
+	}
+	manifest, err := json.MarshalIndent(bundle.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化配置包描述失败: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建配置包失败: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	write := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: bundle.Manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := write(manifestFile, manifest); err != nil {
+		return fmt.Errorf("写入配置包失败: %w", err)
+	}
+	for _, name := range bundle.Manifest.Files {
+		if err := write(name, files[name]); err != nil {
+			return fmt.Errorf("写入配置包失败: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("写入配置包失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("写入配置包失败: %w", err)
+	}
+	return f.Close()
+}
+
+// Read 读取 tar.gz 配置包
+func Read(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开配置包失败: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("配置包格式错误: %w", err)
+	}
+	defer gz.Close()
+
+	bundle := &Bundle{}
+	hasManifest := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取配置包失败: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, 16*1024*1024))
+		if err != nil {
+			return nil, fmt.Errorf("读取配置包失败: %w", err)
+		}
+
+		switch header.Name {
+		case manifestFile:
+			if err := json.Unmarshal(data, &bundle.Manifest); err != nil {
+				return nil, fmt.Errorf("解析配置包描述失败: %w", err)
+			}
+			hasManifest = true
+		case configFile:
+			bundle.Config = data
+		case memoryFile:
+			bundle.Memory = string(data)
+		}
+	}
+
+	if !hasManifest {
+		return nil, fmt.Errorf("不是有效的配置包: 缺少 %s", manifestFile)
+	}
+	if bundle.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("配置包版本 %d 过新，当前支持的最高版本为 %d", bundle.Manifest.Version, FormatVersion)
+	}
+	return bundle, nil
+}
+
+// isSecretKey 判断配置项是否为密钥
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "max_") {
+		return false
+	}
+	return key == "key" || strings.HasSuffix(key, "_key") || strings.HasSuffix(key, "apikey") ||
+		strings.Contains(key, "secret") || strings.Contains(key, "password") ||
+		key == "token" || strings.HasSuffix(key, "_token")
+}
+
+// RedactSecrets 清空配置中的密钥字段（保留注释和其他配置）
+func RedactSecrets(config []byte) ([]byte, []string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(config, &root); err != nil {
+		return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	var redacted []string
+	walkScalars(&root, "", func(path string, key string, value *yaml.Node) {
+		if isSecretKey(key) && value.Value != "" {
+			value.Value = ""
+			value.Style = yaml.DoubleQuotedStyle
+			redacted = append(redacted, path)
+		}
+	})
+
+	data, err := encodeYAML(&root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, redacted, nil
+}
+
+// SecretValues 配置中所有非空的密钥值，用于在其他文本中查找并脱敏
+func SecretValues(config []byte) []string {
+	var root yaml.Node
+	if err := yaml.Unmarshal(config, &root); err != nil {
+		return nil
+	}
+	var values []string
+	walkScalars(&root, "", func(path string, key string, value *yaml.Node) {
+		if isSecretKey(key) && value.Value != "" {
+			values = append(values, value.Value)
+		}
+	})
+	return values
+}
+
+// RestoreSecrets 将已有配置中的密钥填回导入的配置，避免覆盖后丢失API Key等信息
+func RestoreSecrets(imported, existing []byte) ([]byte, error) {
+	var existingRoot yaml.Node
+	if err := yaml.Unmarshal(existing, &existingRoot); err != nil {
+		return nil, fmt.Errorf("解析现有配置文件失败: %w", err)
+	}
+	secrets := make(map[string]string)
+	walkScalars(&existingRoot, "", func(path string, key string, value *yaml.Node) {
+		if isSecretKey(key) && value.Value != "" {
+			secrets[path] = value.Value
+		}
+	})
+	if len(secrets) == 0 {
+		return imported, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(imported, &root); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	walkScalars(&root, "", func(path string, key string, value *yaml.Node) {
+		if secret, ok := secrets[path]; ok && value.Value == "" {
+			value.Value = secret
+		}
+	})
+	return encodeYAML(&root)
+}
+
+// walkScalars 遍历YAML映射中的标量值，path为以点分隔的完整键路径
+func walkScalars(node *yaml.Node, prefix string, fn func(path, key string, value *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkScalars(child, prefix, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := key.Value
+			if prefix != "" {
+				path = prefix + "." + key.Value
+			}
+			if value.Kind == yaml.ScalarNode {
+				fn(path, key.Value, value)
+			} else {
+				walkScalars(value, path, fn)
+			}
+		}
+	}
+}
+
+func encodeYAML(root *yaml.Node) ([]byte, error) {
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("序列化配置文件失败: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("序列化配置文件失败: %w", err)
+	}
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,37 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mark 脱敏后的占位符
+const Mark = "[REDACTED]"
+
+// minSecretLength 按值脱敏的最短长度，过短的值容易误伤正常内容
+const minSecretLength = 6
+
+// secretPatterns 常见密钥格式，未出现在配置和环境变量中的密钥也能被移除
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`), Mark},
+	{regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{20,}`), Mark},
+	{regexp.MustCompile(`AKIA[0-9A-Z]{16}`), Mark},
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._\-]{16,}`), "${1}" + Mark},
+	{regexp.MustCompile(`(?i)((?:api[_-]?key|token|secret|password)\\?["']?\s*[:=]\s*\\?["']?)[^\s"'\\]{4,}`), "${1}" + Mark},
+}
+
+// Text 移除文本中已知的密钥值和常见格式的密钥
+func Text(text string, secrets []string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			text = strings.ReplaceAll(text, secret, Mark)
+		}
+	}
+	for _, p := range secretPatterns {
+		text = p.re.ReplaceAllString(text, p.repl)
+	}
+	return text
+}
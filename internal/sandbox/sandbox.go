@@ -0,0 +1,243 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultExcludes 默认不复制到影子工作区的目录
+var DefaultExcludes = []string{".git", "node_modules", "histories", "logs", "memories", "checkpoints", "team", "outputs", "snippets", "telemetry", "audit", "usage", "reminders"}
+
+// ChangeType 变更类型
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"    // 新增
+	ChangeModified ChangeType = "modified" // 修改
+	ChangeDeleted  ChangeType = "deleted"  // 删除
+)
+
+// Change 文件变更
+type Change struct {
+	Path string
+	Type ChangeType
+}
+
+// Sandbox 影子工作区，一轮对话中的文件修改先在副本中进行，确认后再应用到真实目录
+type Sandbox struct {
+	Root     string // 真实工作区
+	Dir      string // 影子工作区
+	excludes []string
+}
+
+// Create 创建影子工作区（复制root目录，跳过排除的目录）
+func Create(root string, excludes []string) (*Sandbox, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("解析工作区路径失败: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "agentcli-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("创建影子工作区失败: %w", err)
+	}
+
+	s := &Sandbox{Root: absRoot, Dir: dir, excludes: excludes}
+	if err := s.copyTree(absRoot, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("复制工作区失败: %w", err)
+	}
+	return s, nil
+}
+
+// Changes 比较影子工作区与真实工作区，返回变更列表
+func (s *Sandbox) Changes() ([]Change, error) {
+	original, err := s.listFiles(s.Root)
+	if err != nil {
+		return nil, err
+	}
+	shadow, err := s.listFiles(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for rel := range shadow {
+		if _, ok := original[rel]; !ok {
+			changes = append(changes, Change{Path: rel, Type: ChangeAdded})
+			continue
+		}
+		same, err := sameContent(filepath.Join(s.Root, rel), filepath.Join(s.Dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, Change{Path: rel, Type: ChangeModified})
+		}
+	}
+	for rel := range original {
+		if _, ok := shadow[rel]; !ok {
+			changes = append(changes, Change{Path: rel, Type: ChangeDeleted})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// Diff 生成变更的统一diff文本（依赖系统diff命令，不可用时仅列出文件）
+func (s *Sandbox) Diff(changes []Change) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		oldPath := filepath.Join(s.Root, c.Path)
+		newPath := filepath.Join(s.Dir, c.Path)
+		switch c.Type {
+		case ChangeAdded:
+			oldPath = os.DevNull
+		case ChangeDeleted:
+			newPath = os.DevNull
+		}
+
+		out, err := exec.Command("diff", "-u", "--label", "a/"+c.Path, "--label", "b/"+c.Path, oldPath, newPath).Output()
+		// diff 在有差异时返回退出码1
+		if len(out) == 0 && err != nil {
+			sb.WriteString(fmt.Sprintf("%s %s\n", c.Type, c.Path))
+			continue
+		}
+		sb.Write(out)
+	}
+	return sb.String()
+}
+
+// Apply 将影子工作区中的变更应用到真实工作区
+func (s *Sandbox) Apply(changes []Change) error {
+	for _, c := range changes {
+		target := filepath.Join(s.Root, c.Path)
+		switch c.Type {
+		case ChangeDeleted:
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("删除文件失败 %s: %w", c.Path, err)
+			}
+		default:
+			if err := copyFile(filepath.Join(s.Dir, c.Path), target); err != nil {
+				return fmt.Errorf("应用变更失败 %s: %w", c.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Cleanup 删除影子工作区
+func (s *Sandbox) Cleanup() error {
+	return os.RemoveAll(s.Dir)
+}
+
+func (s *Sandbox) isExcluded(rel string) bool {
+	first := strings.Split(filepath.ToSlash(rel), "/")[0]
+	for _, ex := range s.excludes {
+		if first == ex {
+			return true
+		}
+	}
+	return false
+}
+
+// listFiles 列出目录下所有普通文件（相对路径）
+func (s *Sandbox) listFiles(root string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel == "." {
+			return nil
+		}
+		if s.isExcluded(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历目录失败: %w", err)
+	}
+	return files, nil
+}
+
+func (s *Sandbox) copyTree(src, dst string) error {
+	files, err := s.listFiles(src)
+	if err != nil {
+		return err
+	}
+	for rel := range files {
+		if err := copyFile(filepath.Join(src, rel), filepath.Join(dst, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func sameContent(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
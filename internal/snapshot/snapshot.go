@@ -0,0 +1,293 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agentcli/internal/sandbox"
+)
+
+// maxSnapshotSize 轮次开始时保存内容的单个文件大小上限，更大的文件只记录哈希
+const maxSnapshotSize = 1 << 20
+
+// maxListedFiles 摘要中最多列出的文件数
+const maxListedFiles = 8
+
+// excludes 不计入改动摘要的目录：AgentCLI自身的历史、日志、用量等存储目录
+var excludes = sandbox.DefaultExcludes
+
+// 文件改动类型
+const (
+	StatusCreated  = "created"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+	StatusWritten  = "written" // 非git目录中工具写入的文件，无法区分新建还是修改
+)
+
+// fileState 轮次开始时文件的状态
+type fileState struct {
+	exists  bool
+	content []byte // 超过 maxSnapshotSize 时为nil，只保留哈希
+	hash    [32]byte
+}
+
+// Snapshot 轮次开始时的工作区状态
+// git仓库中只保存有未提交修改的文件，其余文件的原始内容取自HEAD；非git目录依赖工具记录的写入
+type Snapshot struct {
+	dir   string
+	root  string // git仓库根目录，为空表示不在git仓库中
+	dirty map[string]fileState
+}
+
+// FileChange 一个文件在本轮的改动
+type FileChange struct {
+	Path    string // 相对于工作目录的路径
+	Status  string
+	Added   int
+	Removed int
+	Counted bool // 是否统计了行数（二进制文件、过大的文件或非git目录中不统计）
+}
+
+// Summary 本轮的文件改动摘要
+type Summary struct {
+	Files []FileChange
+}
+
+// Take 记录工作目录当前的状态
+func Take(dir string) *Snapshot {
+	s := &Snapshot{dir: dir, dirty: make(map[string]fileState)}
+	out, err := s.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return s
+	}
+	s.root = strings.TrimSpace(string(out))
+	for _, path := range s.dirtyFiles() {
+		s.dirty[path] = readState(path)
+	}
+	return s
+}
+
+// Summarize 对比轮次开始时的状态，返回本轮的文件改动；written 为工具记录的写入文件，没有改动时返回nil
+func (s *Snapshot) Summarize(written []string) *Summary {
+	candidates := make(map[string]bool)
+	for _, path := range written {
+		if abs, err := filepath.Abs(path); err == nil {
+			candidates[abs] = true
+		}
+	}
+
+	summary := &Summary{}
+	if s.root == "" {
+		// 非git目录：只能列出工具写入的文件
+		for path := range candidates {
+			if rel := s.rel(path); rel != "" {
+				summary.Files = append(summary.Files, FileChange{Path: rel, Status: StatusWritten})
+			}
+		}
+	} else {
+		for path := range s.dirty {
+			candidates[path] = true
+		}
+		for _, path := range s.dirtyFiles() {
+			candidates[path] = true
+		}
+		for path := range candidates {
+			if change, ok := s.compare(path); ok {
+				summary.Files = append(summary.Files, change)
+			}
+		}
+	}
+
+	if len(summary.Files) == 0 {
+		return nil
+	}
+	sort.Slice(summary.Files, func(i, j int) bool { return summary.Files[i].Path < summary.Files[j].Path })
+	return summary
+}
+
+// compare 对比文件轮次开始时（未提交修改的快照或HEAD中的版本）和当前的内容
+func (s *Snapshot) compare(path string) (FileChange, bool) {
+	rel := s.rel(path)
+	if rel == "" {
+		return FileChange{}, false
+	}
+
+	before, ok := s.dirty[path]
+	if !ok {
+		before = fileState{}
+		if gitPath, err := filepath.Rel(s.root, path); err == nil {
+			if content, err := s.git("show", "HEAD:"+filepath.ToSlash(gitPath)); err == nil {
+				before = fileState{exists: true, content: content, hash: sha256.Sum256(content)}
+			}
+		}
+	}
+	after := readState(path)
+	if before.exists == after.exists && before.hash == after.hash {
+		return FileChange{}, false
+	}
+
+	change := FileChange{Path: rel, Status: StatusModified}
+	switch {
+	case !before.exists:
+		change.Status = StatusCreated
+	case !after.exists:
+		change.Status = StatusDeleted
+	}
+	if (before.exists && before.content == nil) || (after.exists && after.content == nil) {
+		return change, true
+	}
+	change.Added, change.Removed, change.Counted = s.countLines(before, path, after.exists)
+	return change, true
+}
+
+// countLines 使用 git diff --numstat 统计新增和删除的行数
+func (s *Snapshot) countLines(before fileState, path string, exists bool) (int, int, bool) {
+	oldPath := os.DevNull
+	if before.exists {
+		tmp, err := os.CreateTemp("", "agentcli-snapshot-*")
+		if err != nil {
+			return 0, 0, false
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(before.content)
+		tmp.Close()
+		if err != nil {
+			return 0, 0, false
+		}
+		oldPath = tmp.Name()
+	}
+	newPath := path
+	if !exists {
+		newPath = os.DevNull
+	}
+
+	// 有差异时 git diff 返回退出码1，只看输出
+	out, _ := s.git("diff", "--no-index", "--numstat", "--", oldPath, newPath)
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+	added, err1 := strconv.Atoi(fields[0])
+	removed, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, false // 二进制文件显示为 "-"
+	}
+	return added, removed, true
+}
+
+// dirtyFiles 列出工作目录中有未提交修改或未跟踪的文件（绝对路径）
+func (s *Snapshot) dirtyFiles() []string {
+	out, err := s.git("-c", "core.quotepath=off", "status", "--porcelain", "-z", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return nil
+	}
+	var files []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		// 重命名和复制的条目后面还跟着原路径
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+		path := filepath.Join(s.root, filepath.FromSlash(entry[3:]))
+		if s.rel(path) != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// rel 返回相对于工作目录的路径，工作目录之外或被排除的文件返回空字符串
+func (s *Snapshot) rel(path string) string {
+	dir, err := filepath.Abs(s.dir)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	first := strings.Split(filepath.ToSlash(rel), "/")[0]
+	for _, ex := range excludes {
+		if first == ex {
+			return ""
+		}
+	}
+	return rel
+}
+
+func (s *Snapshot) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.dir
+	return cmd.Output()
+}
+
+// readState 读取文件当前的状态
+func readState(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return fileState{}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileState{}
+	}
+	state := fileState{exists: true, hash: sha256.Sum256(content)}
+	if len(content) <= maxSnapshotSize {
+		state.content = content
+	}
+	return state
+}
+
+// String 单行摘要，如 "改动 3 个文件（+120/-15 行）：新建 tests/foo_test.go；修改 main.go (+3/-1)"
+func (s *Summary) String() string {
+	added, removed, counted := 0, 0, false
+	groups := map[string][]string{}
+	for i, f := range s.Files {
+		if f.Counted {
+			added += f.Added
+			removed += f.Removed
+			counted = true
+		}
+		if i >= maxListedFiles {
+			continue
+		}
+		item := f.Path
+		if f.Counted && f.Status == StatusModified {
+			item = fmt.Sprintf("%s (+%d/-%d)", f.Path, f.Added, f.Removed)
+		}
+		groups[f.Status] = append(groups[f.Status], item)
+	}
+
+	var sb bytes.Buffer
+	fmt.Fprintf(&sb, "改动 %d 个文件", len(s.Files))
+	if counted {
+		fmt.Fprintf(&sb, "（+%d/-%d 行）", added, removed)
+	}
+	var parts []string
+	for _, g := range []struct{ status, label string }{
+		{StatusCreated, "新建"},
+		{StatusModified, "修改"},
+		{StatusDeleted, "删除"},
+		{StatusWritten, "写入"},
+	} {
+		if items := groups[g.status]; len(items) > 0 {
+			parts = append(parts, g.label+" "+strings.Join(items, "、"))
+		}
+	}
+	sb.WriteString("：" + strings.Join(parts, "；"))
+	if len(s.Files) > maxListedFiles {
+		fmt.Fprintf(&sb, " 等")
+	}
+	return sb.String()
+}
@@ -0,0 +1,111 @@
+package snippets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// DefaultDir 默认的片段存储目录（当前目录下），每个用户一个文件
+const DefaultDir = "snippets"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Snippet 可复用的文本片段（错误模板、风格指南、API示例等）
+type Snippet struct {
+	Name      string    `json:"name"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store 用户的片段存储
+type Store struct {
+	filePath string
+}
+
+// NewStore 创建用户的片段存储
+func NewStore(dir, userID string) *Store {
+	return &Store{filePath: filepath.Join(dir, fmt.Sprintf("%s.json", userID))}
+}
+
+// load 读取所有片段
+func (s *Store) load() (map[string]Snippet, error) {
+	snippets := make(map[string]Snippet)
+	if _, err := fsutil.ReadJSONWithBackup(s.filePath, &snippets); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取片段文件失败: %w", err)
+	}
+	return snippets, nil
+}
+
+// save 写入所有片段
+func (s *Store) save(snippets map[string]Snippet) error {
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("创建片段目录失败: %w", err)
+	}
+	if err := fsutil.WriteJSONAtomic(s.filePath, snippets, 0644); err != nil {
+		return fmt.Errorf("写入片段文件失败: %w", err)
+	}
+	return nil
+}
+
+// Save 保存片段，同名片段会被覆盖
+func (s *Store) Save(name, content string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("无效的片段名: %s（只能包含字母、数字、下划线、点和连字符）", name)
+	}
+	if content == "" {
+		return fmt.Errorf("片段内容不能为空")
+	}
+
+	snippets, err := s.load()
+	if err != nil {
+		return err
+	}
+	snippets[name] = Snippet{Name: name, Content: content, UpdatedAt: time.Now()}
+	return s.save(snippets)
+}
+
+// Get 获取片段
+func (s *Store) Get(name string) (*Snippet, error) {
+	snippets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	snippet, ok := snippets[name]
+	if !ok {
+		return nil, fmt.Errorf("片段不存在: %s", name)
+	}
+	return &snippet, nil
+}
+
+// List 按名称排序列出所有片段
+func (s *Store) List() ([]Snippet, error) {
+	snippets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Snippet, 0, len(snippets))
+	for _, snippet := range snippets {
+		list = append(list, snippet)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Delete 删除片段
+func (s *Store) Delete(name string) error {
+	snippets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := snippets[name]; !ok {
+		return fmt.Errorf("片段不存在: %s", name)
+	}
+	delete(snippets, name)
+	return s.save(snippets)
+}
@@ -0,0 +1,175 @@
+package team
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// DefaultCacheDir 团队共享指令的本地缓存目录（当前目录下）
+const DefaultCacheDir = "team"
+
+// DefaultPath git仓库中团队指令文件的默认路径
+const DefaultPath = "instructions.md"
+
+// maxInstructionsSize 团队指令的最大字节数
+const maxInstructionsSize = 256 * 1024
+
+// cacheFile 最近一次成功同步的指令缓存，远程不可用时使用
+const cacheFile = "instructions.md"
+
+// Source 团队共享指令来源（git仓库或HTTPS地址）
+type Source struct {
+	URL      string        // git仓库地址（.git结尾、git@、ssh://或git+前缀）或HTTPS文件地址
+	Ref      string        // git分支或标签，为空则使用默认分支
+	Path     string        // git仓库中的指令文件路径，默认 instructions.md
+	CacheDir string        // 本地缓存目录，默认 team
+	Timeout  time.Duration // 同步超时
+}
+
+// NewSource 创建团队指令来源
+func NewSource(url, ref, path string, timeout time.Duration) *Source {
+	if path == "" {
+		path = DefaultPath
+	}
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	return &Source{
+		URL:      url,
+		Ref:      ref,
+		Path:     path,
+		CacheDir: DefaultCacheDir,
+		Timeout:  timeout,
+	}
+}
+
+// Sync 从远程拉取最新的团队指令并更新本地缓存
+func (s *Source) Sync(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var content string
+	var err error
+	if url, ok := gitURL(s.URL); ok {
+		content, err = s.syncGit(ctx, url)
+	} else {
+		content, err = s.fetchHTTP(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	content = strings.TrimSpace(content)
+	if err := os.MkdirAll(s.CacheDir, 0755); err != nil {
+		return "", fmt.Errorf("创建团队指令缓存目录失败: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(s.CacheDir, cacheFile), []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("写入团队指令缓存失败: %w", err)
+	}
+	return content, nil
+}
+
+// Cached 读取最近一次成功同步的团队指令，没有缓存时返回空字符串
+func (s *Source) Cached() (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.CacheDir, cacheFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("读取团队指令缓存失败: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// gitURL 判断来源是否为git仓库，返回可用于git命令的地址
+func gitURL(source string) (string, bool) {
+	if strings.HasPrefix(source, "git+") {
+		return strings.TrimPrefix(source, "git+"), true
+	}
+	if strings.HasSuffix(source, ".git") || strings.HasPrefix(source, "git@") || strings.HasPrefix(source, "ssh://") {
+		return source, true
+	}
+	return source, false
+}
+
+func (s *Source) fetchHTTP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取团队指令失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("获取团队指令失败: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxInstructionsSize))
+	if err != nil {
+		return "", fmt.Errorf("读取团队指令失败: %w", err)
+	}
+	return string(data), nil
+}
+
+func (s *Source) syncGit(ctx context.Context, url string) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	repoDir := filepath.Join(s.CacheDir, "repo-"+hex.EncodeToString(sum[:])[:12])
+
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err == nil {
+		ref := s.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if err := runGit(ctx, "-C", repoDir, "fetch", "--depth", "1", "origin", ref); err != nil {
+			return "", err
+		}
+		if err := runGit(ctx, "-C", repoDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	} else {
+		if err := os.MkdirAll(s.CacheDir, 0755); err != nil {
+			return "", fmt.Errorf("创建团队指令缓存目录失败: %w", err)
+		}
+		args := []string{"clone", "--depth", "1"}
+		if s.Ref != "" {
+			args = append(args, "--branch", s.Ref)
+		}
+		if err := runGit(ctx, append(args, url, repoDir)...); err != nil {
+			os.RemoveAll(repoDir)
+			return "", err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(s.Path)))
+	if err != nil {
+		return "", fmt.Errorf("读取仓库中的团队指令文件失败: %w", err)
+	}
+	if len(data) > maxInstructionsSize {
+		data = data[:maxInstructionsSize]
+	}
+	return string(data), nil
+}
+
+func runGit(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// 禁止交互式认证提示，避免启动时卡住
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s 失败: %w\n%s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
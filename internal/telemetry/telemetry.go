@@ -0,0 +1,280 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// DefaultDir 默认的遥测状态目录（当前目录下）
+const DefaultDir = "telemetry"
+
+// stateFile 保存用户授权状态和匿名安装ID的文件名
+const stateFile = "state.json"
+
+// State 遥测授权状态，默认关闭，只有用户执行 telemetry on 后才会上报
+type State struct {
+	Enabled   bool      `json:"enabled"`
+	InstallID string    `json:"install_id,omitempty"` // 随机生成的匿名ID，与用户名和机器信息无关
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadState 读取授权状态，文件不存在时返回关闭状态
+func LoadState(dir string) (*State, error) {
+	var state State
+	if _, err := fsutil.ReadJSONWithBackup(filepath.Join(dir, stateFile), &state); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取遥测状态失败: %w", err)
+	}
+	return &state, nil
+}
+
+// SetEnabled 开启或关闭遥测，首次开启时生成匿名安装ID
+func SetEnabled(dir string, enabled bool) (*State, error) {
+	state, err := LoadState(dir)
+	if err != nil {
+		return nil, err
+	}
+	state.Enabled = enabled
+	state.UpdatedAt = time.Now()
+	if enabled && state.InstallID == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("生成匿名ID失败: %w", err)
+		}
+		state.InstallID = hex.EncodeToString(buf)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建遥测目录失败: %w", err)
+	}
+	if err := fsutil.WriteJSONAtomic(filepath.Join(dir, stateFile), state, 0644); err != nil {
+		return nil, fmt.Errorf("保存遥测状态失败: %w", err)
+	}
+	return state, nil
+}
+
+// toolStats 单个工具的调用统计
+type toolStats struct {
+	calls     int
+	errors    int
+	latencies []float64
+}
+
+// Collector 在会话内聚合匿名用量：只记录命令名、工具名、错误次数和耗时，从不记录提示词或工具参数
+// nil Collector 上的所有方法都不做任何事，未开启遥测时无需判断
+type Collector struct {
+	mu        sync.Mutex
+	started   time.Time
+	commands  map[string]int
+	tools     map[string]*toolStats
+	llm       toolStats
+	latencies map[string][]float64
+}
+
+// NewCollector 创建用量聚合器
+func NewCollector() *Collector {
+	return &Collector{
+		started:   time.Now(),
+		commands:  make(map[string]int),
+		tools:     make(map[string]*toolStats),
+		latencies: make(map[string][]float64),
+	}
+}
+
+// RecordCommand 记录一次交互命令的使用（只记录命令名，如 /model）
+func (c *Collector) RecordCommand(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands[name]++
+}
+
+// RecordTool 记录一次工具调用的耗时和是否失败
+func (c *Collector) RecordTool(name string, duration time.Duration, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.tools[name]
+	if !ok {
+		stats = &toolStats{}
+		c.tools[name] = stats
+	}
+	stats.record(duration, err != nil)
+}
+
+func (s *toolStats) record(duration time.Duration, failed bool) {
+	s.calls++
+	if failed {
+		s.errors++
+	}
+	s.latencies = append(s.latencies, float64(duration.Milliseconds()))
+}
+
+func (s *toolStats) report() ToolReport {
+	report := ToolReport{Calls: s.calls, Errors: s.errors, Latency: percentiles(s.latencies)}
+	if s.calls > 0 {
+		report.ErrorRate = math.Round(float64(s.errors)/float64(s.calls)*1000) / 1000
+	}
+	return report
+}
+
+// RecordLatency 记录一次指定类型操作的耗时
+func (c *Collector) RecordLatency(kind string, duration time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latencies[kind] = append(c.latencies[kind], float64(duration.Milliseconds()))
+}
+
+// Transport 包装HTTP传输层，记录LLM请求到收到响应头的耗时（不读取请求和响应内容）
+func (c *Collector) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		c.mu.Lock()
+		c.llm.record(time.Since(start), err != nil || resp.StatusCode >= 400)
+		c.mu.Unlock()
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Percentiles 耗时分位数（毫秒）
+type Percentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+}
+
+// ToolReport 单个工具的聚合统计
+type ToolReport struct {
+	Calls     int         `json:"calls"`
+	Errors    int         `json:"errors"`
+	ErrorRate float64     `json:"error_rate"`
+	Latency   Percentiles `json:"latency"`
+}
+
+// Report 上报的匿名聚合数据
+type Report struct {
+	InstallID string                 `json:"install_id"`
+	Version   string                 `json:"version"`
+	OS        string                 `json:"os"`
+	Arch      string                 `json:"arch"`
+	Start     time.Time              `json:"period_start"`
+	End       time.Time              `json:"period_end"`
+	Commands  map[string]int         `json:"commands"`
+	Tools     map[string]ToolReport  `json:"tools"`
+	LLM       ToolReport             `json:"llm"` // LLM请求次数、失败率和首个响应耗时
+	Latency   map[string]Percentiles `json:"latency"`
+}
+
+// Empty 本次会话是否没有任何可上报的数据
+func (r *Report) Empty() bool {
+	return len(r.Commands) == 0 && len(r.Tools) == 0 && r.LLM.Calls == 0 && len(r.Latency) == 0
+}
+
+// Report 生成当前会话的聚合报告
+func (c *Collector) Report(installID, version string) *Report {
+	report := &Report{
+		InstallID: installID,
+		Version:   version,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		End:       time.Now(),
+		Commands:  make(map[string]int),
+		Tools:     make(map[string]ToolReport),
+		Latency:   make(map[string]Percentiles),
+	}
+	if c == nil {
+		return report
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report.Start = c.started
+	for name, count := range c.commands {
+		report.Commands[name] = count
+	}
+	for name, stats := range c.tools {
+		report.Tools[name] = stats.report()
+	}
+	report.LLM = c.llm.report()
+	for kind, values := range c.latencies {
+		report.Latency[kind] = percentiles(values)
+	}
+	return report
+}
+
+// percentiles 计算耗时分位数（最近秩法）
+func percentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return Percentiles{Count: len(sorted), P50: at(0.5), P90: at(0.9), P99: at(0.99)}
+}
+
+// Send 将报告POST到上报地址
+func Send(ctx context.Context, endpoint string, report *Report, timeout time.Duration) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("序列化遥测数据失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建遥测请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("上报遥测数据失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("上报遥测数据失败: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,595 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"agentcli/internal/fsutil"
+)
+
+// 补丁中各文件的变更类型
+const (
+	PatchAdded    = "added"
+	PatchModified = "modified"
+	PatchDeleted  = "deleted"
+	PatchRenamed  = "renamed"
+)
+
+// hunkHeader 形如 @@ -12,5 +12,7 @@ 的hunk头
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ApplyPatchTool 应用统一diff格式（git diff）的多文件补丁
+// 所有hunk先与文件当前内容校验，全部通过后才写入；写入中途失败时已写入的文件恢复原状
+type ApplyPatchTool struct{}
+
+// NewApplyPatchTool 创建补丁应用工具
+func NewApplyPatchTool() *ApplyPatchTool {
+	return &ApplyPatchTool{}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "应用统一diff格式（git diff）的补丁，可一次修改、新建、删除、重命名多个文件；所有hunk与文件当前内容校验通过后才整体写入，任一文件失败则全部不变。参数: patch(补丁内容), workdir(执行目录,可选)"
+}
+
+func (t *ApplyPatchTool) GetParams() map[string]string {
+	return map[string]string{
+		"patch":      "统一diff格式的补丁，每个文件以 ---/+++ 行开头（可带 diff --git 行），新建文件的旧路径为 /dev/null，删除文件的新路径为 /dev/null，重命名文件的新旧路径不同（或使用 rename from/rename to 行）",
+		WorkdirParam: workdirParamDescription,
+	}
+}
+
+func (t *ApplyPatchTool) RequiredParams() []string {
+	return []string{"patch"}
+}
+
+// filePatch 补丁中对单个文件的修改
+type filePatch struct {
+	oldPath string // 新建文件时为空
+	newPath string // 删除文件时为空
+	hunks   []patchHunk
+}
+
+// path 补丁作用的文件路径
+func (p *filePatch) path() string {
+	if p.newPath != "" {
+		return p.newPath
+	}
+	return p.oldPath
+}
+
+func (p *filePatch) status() string {
+	switch {
+	case p.oldPath == "":
+		return PatchAdded
+	case p.newPath == "":
+		return PatchDeleted
+	case p.oldPath != p.newPath:
+		return PatchRenamed
+	default:
+		return PatchModified
+	}
+}
+
+// patchHunk 一个hunk，lines 保留行首的 ' '、'-'、'+' 标记
+type patchHunk struct {
+	oldStart int
+	oldLines int
+	newLines int
+	lines    []string
+	oldNoEOL bool // 旧内容末尾没有换行
+	newNoEOL bool // 新内容末尾没有换行
+}
+
+// parsePatch 解析统一diff格式的补丁
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []*filePatch
+	var cur *filePatch
+	gitHeader := false // 当前文件以 diff --git 开头，尚未读到 ---/+++
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = &filePatch{}
+			files = append(files, cur)
+			gitHeader = true
+			if oldPath, newPath, ok := parseGitPaths(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.oldPath, cur.newPath = oldPath, newPath
+			}
+		case gitHeader && strings.HasPrefix(line, "new file mode"):
+			cur.oldPath = ""
+		case gitHeader && strings.HasPrefix(line, "deleted file mode"):
+			cur.newPath = ""
+		case gitHeader && strings.HasPrefix(line, "rename from "):
+			cur.oldPath = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+		case gitHeader && strings.HasPrefix(line, "rename to "):
+			cur.newPath = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if !gitHeader {
+				cur = &filePatch{}
+				files = append(files, cur)
+			}
+			gitHeader = false
+			cur.oldPath = patchPath(strings.TrimPrefix(line, "--- "))
+			cur.newPath = patchPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("第%d行: hunk之前缺少 ---/+++ 文件头", i+1)
+			}
+			gitHeader = false
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, h)
+			i = next - 1
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("补丁中没有文件修改，需要统一diff格式（---/+++ 文件头和 @@ hunk）")
+	}
+	for _, f := range files {
+		if f.path() == "" {
+			return nil, fmt.Errorf("补丁中存在缺少文件路径的修改")
+		}
+		// 新建空文件和内容不变的重命名没有hunk
+		if len(f.hunks) == 0 && f.status() != PatchAdded && f.status() != PatchRenamed {
+			return nil, fmt.Errorf("文件 %s 的补丁中没有hunk", f.path())
+		}
+	}
+	return files, nil
+}
+
+// parseHunk 解析从 start 行开始的hunk，返回hunk和其后的行号
+func parseHunk(lines []string, start int) (patchHunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(lines[start])
+	if m == nil {
+		return patchHunk{}, 0, fmt.Errorf("第%d行: 无法解析hunk头: %s", start+1, lines[start])
+	}
+	h := patchHunk{oldStart: atoiDefault(m[1], 0), oldLines: atoiDefault(m[2], 1), newLines: atoiDefault(m[4], 1)}
+
+	oldCount, newCount := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldCount < h.oldLines || newCount < h.newLines); i++ {
+		line := lines[i]
+		if line == "" {
+			// 部分编辑器会去掉空上下文行行首的空格
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			oldCount++
+			newCount++
+		case '-':
+			oldCount++
+		case '+':
+			newCount++
+		case '\\':
+			h.markNoEOL()
+			continue
+		default:
+			return patchHunk{}, 0, fmt.Errorf("第%d行: hunk内容行必须以空格、-或+开头: %s", i+1, line)
+		}
+		h.lines = append(h.lines, line)
+	}
+	if oldCount != h.oldLines || newCount != h.newLines {
+		return patchHunk{}, 0, fmt.Errorf("第%d行: hunk行数与hunk头不符（应为-%d/+%d，实际-%d/+%d）", start+1, h.oldLines, h.newLines, oldCount, newCount)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		h.markNoEOL()
+		i++
+	}
+	return h, i, nil
+}
+
+// markNoEOL 处理 "\ No newline at end of file"，它作用于紧邻的上一行
+func (h *patchHunk) markNoEOL() {
+	if len(h.lines) == 0 {
+		return
+	}
+	switch h.lines[len(h.lines)-1][0] {
+	case '-':
+		h.oldNoEOL = true
+	case '+':
+		h.newNoEOL = true
+	default:
+		h.oldNoEOL = true
+		h.newNoEOL = true
+	}
+}
+
+// split 拆分出hunk应用前后的行
+func (h *patchHunk) split() (oldLines, newLines []string) {
+	for _, line := range h.lines {
+		switch line[0] {
+		case ' ':
+			oldLines = append(oldLines, line[1:])
+			newLines = append(newLines, line[1:])
+		case '-':
+			oldLines = append(oldLines, line[1:])
+		case '+':
+			newLines = append(newLines, line[1:])
+		}
+	}
+	return oldLines, newLines
+}
+
+// apply 用hunk替换文件中匹配的旧内容；上下文行保留文件中的原内容（匹配时忽略了行尾空白）
+func (h *patchHunk) apply(matched []string) []string {
+	var out []string
+	i := 0
+	for _, line := range h.lines {
+		switch line[0] {
+		case ' ':
+			out = append(out, matched[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			out = append(out, line[1:])
+		}
+	}
+	return out
+}
+
+// parseGitPaths 从 diff --git a/x b/y 中取出两个路径（不支持含空格的路径，此时以 ---/+++ 为准）
+func parseGitPaths(s string) (string, string, bool) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return patchPath(parts[0]), patchPath(parts[1]), true
+}
+
+// patchPath 去掉 a/、b/ 前缀和时间戳，/dev/null 返回空字符串
+func patchPath(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// applyHunks 将hunk依次应用到文件内容上，hunk位置与行号不符时在附近查找匹配的位置
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+	var lines []string
+	eol := true
+	if content != "" {
+		eol = strings.HasSuffix(content, "\n")
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	var out []string
+	pos := 0    // lines 中尚未处理的位置
+	offset := 0 // 上一个hunk的实际位置与hunk头行号的偏差
+	for n, h := range hunks {
+		oldLines, _ := h.split()
+		want := h.oldStart - 1
+		if h.oldLines == 0 {
+			// 纯插入的hunk，旧起始行表示插入在该行之后
+			want = h.oldStart
+		}
+		at := findHunk(lines, oldLines, want+offset, pos)
+		if at < 0 {
+			return "", fmt.Errorf("第%d个hunk（@@ -%d,%d）与文件当前内容不匹配", n+1, h.oldStart, h.oldLines)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, h.apply(lines[at:at+len(oldLines)])...)
+		pos = at + len(oldLines)
+		offset = at - want
+		if pos == len(lines) {
+			if h.newNoEOL {
+				eol = false
+			} else if h.oldNoEOL || len(lines) == 0 {
+				eol = true
+			}
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	if len(out) == 0 {
+		return "", nil
+	}
+	result := strings.Join(out, "\n")
+	if eol {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findHunk 从期望位置开始向两侧查找与旧内容一致的位置，不早于 minPos，找不到时返回-1
+func findHunk(lines, oldLines []string, want, minPos int) int {
+	want = max(want, minPos)
+	for delta := 0; ; delta++ {
+		before, after := want-delta, want+delta
+		if before < minPos && after+len(oldLines) > len(lines) {
+			return -1
+		}
+		if after+len(oldLines) <= len(lines) && linesEqual(lines[after:after+len(oldLines)], oldLines) {
+			return after
+		}
+		if delta > 0 && before >= minPos && before+len(oldLines) <= len(lines) && linesEqual(lines[before:before+len(oldLines)], oldLines) {
+			return before
+		}
+	}
+}
+
+// linesEqual 比较两组行，忽略行尾空白
+func linesEqual(a, b []string) bool {
+	for i := range b {
+		if strings.TrimRight(a[i], " \t\r") != strings.TrimRight(b[i], " \t\r") {
+			return false
+		}
+	}
+	return true
+}
+
+// patchChange 校验通过、待写入的单个文件修改
+type patchChange struct {
+	patch   *filePatch
+	path    string // 解析执行目录后的路径
+	from    string // 重命名时解析执行目录后的原路径
+	content string
+	perm    os.FileMode
+	added   int
+	removed int
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	patch, _ := params["patch"].(string)
+	if strings.TrimSpace(patch) == "" {
+		return nil, fmt.Errorf("缺少补丁内容参数")
+	}
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("解析补丁失败: %w", err)
+	}
+
+	// 先校验所有文件，全部通过后才写入
+	changes := make([]*patchChange, 0, len(files))
+	seen := make(map[string]bool)
+	var failures []string
+	for _, f := range files {
+		change, err := t.prepare(params, f)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", f.path(), err))
+			continue
+		}
+		if seen[change.path] || change.from != "" && seen[change.from] {
+			failures = append(failures, fmt.Sprintf("%s: 补丁中重复修改了同一文件", f.path()))
+			continue
+		}
+		seen[change.path] = true
+		if change.from != "" {
+			seen[change.from] = true
+		}
+		changes = append(changes, change)
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("补丁校验失败，未修改任何文件:\n%s", strings.Join(failures, "\n"))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := commitChanges(changes); err != nil {
+		return nil, err
+	}
+
+	results := make([]map[string]interface{}, 0, len(changes))
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		result := map[string]interface{}{
+			"path":    c.path,
+			"status":  c.patch.status(),
+			"hunks":   len(c.patch.hunks),
+			"added":   c.added,
+			"removed": c.removed,
+		}
+		if c.from != "" {
+			result["from"] = c.from
+		}
+		results = append(results, result)
+		paths = append(paths, c.path)
+	}
+	return map[string]interface{}{
+		"success": true,
+		"files":   results,
+		"message": fmt.Sprintf("补丁已应用到%d个文件: %s", len(changes), strings.Join(paths, ", ")),
+	}, nil
+}
+
+// prepare 读取文件并在内存中应用补丁
+func (t *ApplyPatchTool) prepare(params map[string]interface{}, f *filePatch) (*patchChange, error) {
+	path, err := workdirPath(params, f.path())
+	if err != nil {
+		return nil, err
+	}
+	change := &patchChange{patch: f, path: path, perm: 0644}
+	source := path
+	if f.status() == PatchRenamed {
+		if source, err = workdirPath(params, f.oldPath); err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(path); err == nil {
+			return nil, fmt.Errorf("重命名的目标文件已存在")
+		}
+		change.from = source
+	}
+	for _, h := range f.hunks {
+		for _, line := range h.lines {
+			switch line[0] {
+			case '+':
+				change.added++
+			case '-':
+				change.removed++
+			}
+		}
+	}
+
+	original := ""
+	info, statErr := os.Stat(source)
+	switch {
+	case f.status() == PatchAdded:
+		if statErr == nil {
+			return nil, fmt.Errorf("文件已存在，无法作为新文件创建")
+		}
+	case statErr != nil:
+		if os.IsNotExist(statErr) {
+			return nil, fmt.Errorf("文件不存在")
+		}
+		return nil, fmt.Errorf("读取文件信息失败: %w", statErr)
+	case info.IsDir():
+		return nil, fmt.Errorf("路径是目录")
+	default:
+		change.perm = info.Mode().Perm()
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %w", err)
+		}
+		original = string(data)
+	}
+
+	content, err := applyHunks(original, f.hunks)
+	if err != nil {
+		return nil, err
+	}
+	if f.status() == PatchDeleted && content != "" {
+		return nil, fmt.Errorf("删除文件的补丁未覆盖文件的全部内容")
+	}
+	change.content = content
+	return change, nil
+}
+
+// fileBackup 写入前文件的状态，用于回滚
+type fileBackup struct {
+	path    string
+	existed bool
+	data    []byte
+	perm    os.FileMode
+	dirs    []string // 为新文件创建的目录，由内向外
+}
+
+// commitChanges 依次写入所有修改，任一文件失败时将已写入的文件恢复原状
+func commitChanges(changes []*patchChange) error {
+	var done []fileBackup
+	for _, c := range changes {
+		backup, err := writeChange(c)
+		if err != nil {
+			rollback(done)
+			return fmt.Errorf("写入 %s 失败，已恢复其余文件: %w", c.path, err)
+		}
+		done = append(done, backup)
+
+		// 重命名：新文件写入后删除原文件，原文件同样记录备份以便回滚
+		if c.from != "" {
+			source := fileBackup{path: c.from, perm: c.perm}
+			if data, err := os.ReadFile(c.from); err == nil {
+				source.existed, source.data = true, data
+			}
+			if err := os.Remove(c.from); err != nil {
+				rollback(done)
+				return fmt.Errorf("删除重命名前的文件 %s 失败，已恢复其余文件: %w", c.from, err)
+			}
+			done = append(done, source)
+		}
+	}
+	return nil
+}
+
+func writeChange(c *patchChange) (fileBackup, error) {
+	backup := fileBackup{path: c.path, perm: c.perm}
+	if data, err := os.ReadFile(c.path); err == nil {
+		backup.existed = true
+		backup.data = data
+	}
+
+	if c.patch.status() == PatchDeleted {
+		return backup, os.Remove(c.path)
+	}
+
+	// 记录需要新建的目录，回滚时删除
+	for dir := filepath.Dir(c.path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		backup.dirs = append(backup.dirs, dir)
+	}
+	if len(backup.dirs) > 0 {
+		if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+			return backup, fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+	if err := fsutil.ReplaceFile(c.path, []byte(c.content), c.perm); err != nil {
+		removeDirs(backup.dirs)
+		return backup, err
+	}
+	return backup, nil
+}
+
+// rollback 按相反顺序恢复已写入的文件
+func rollback(done []fileBackup) {
+	for i := len(done) - 1; i >= 0; i-- {
+		b := done[i]
+		if b.existed {
+			fsutil.ReplaceFile(b.path, b.data, b.perm)
+			continue
+		}
+		os.Remove(b.path)
+		removeDirs(b.dirs)
+	}
+}
+
+// removeDirs 删除新建的空目录
+func removeDirs(dirs []string) {
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+}
+
+func (t *ApplyPatchTool) ProducedFiles(params map[string]interface{}, result interface{}) []string {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	files, _ := resultMap["files"].([]map[string]interface{})
+	var paths []string
+	for _, f := range files {
+		if f["status"] == PatchDeleted {
+			continue
+		}
+		if path, ok := f["path"].(string); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,306 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "行号准确",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -2,1 +2,1 @@\n-b\n+B\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "hunk位置偏移",
+			content: "x\ny\nz\na\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "x\ny\nz\na\nB\nc\n",
+		},
+		{
+			name:    "后续hunk沿用前一个hunk的偏移",
+			content: "new\na\nb\nc\nd\ne\nf\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,1 +1,1 @@\n-a\n+A\n@@ -5,1 +5,1 @@\n-e\n+E\n",
+			want:    "new\nA\nb\nc\nd\nE\nf\n",
+		},
+		{
+			name:    "上下文不匹配",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -2,1 +2,1 @@\n-x\n+y\n",
+			wantErr: true,
+		},
+		{
+			name:    "在第0行之后插入",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+			want:    "x\ny\na\nb\n",
+		},
+		{
+			name:    "在末尾插入",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -2,0 +3 @@\n+c\n",
+			want:    "a\nb\nc\n",
+		},
+		{
+			name:    "新内容末尾没有换行",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -2 +2 @@\n-b\n+B\n\\ No newline at end of file\n",
+			want:    "a\nB",
+		},
+		{
+			name:    "旧内容末尾没有换行",
+			content: "a\nb",
+			patch:   "--- a/f\n+++ b/f\n@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+B\n",
+			want:    "a\nB\n",
+		},
+		{
+			name:    "新旧内容末尾都没有换行",
+			content: "a\nb",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n\\ No newline at end of file\n",
+			want:    "A\nb",
+		},
+		{
+			name:    "新建文件",
+			content: "",
+			patch:   "--- /dev/null\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "删除全部内容",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			want:    "",
+		},
+		{
+			name:    "上下文行忽略行尾空白并保留文件原内容",
+			content: "a  \nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want:    "a  \nB\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parsePatch(tt.patch)
+			if err != nil {
+				t.Fatalf("parsePatch: %v", err)
+			}
+			got, err := applyHunks(tt.content, files[0].hunks)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyHunks = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyHunks: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("applyHunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []string // 每个文件的 状态:路径
+		wantErr string
+	}{
+		{
+			name:  "git格式新建、删除和修改",
+			patch: "diff --git a/new.txt b/new.txt\nnew file mode 100644\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+x\ndiff --git a/old.txt b/old.txt\ndeleted file mode 100644\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-y\ndiff --git a/m.txt b/m.txt\n--- a/m.txt\n+++ b/m.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			want:  []string{"added:new.txt", "deleted:old.txt", "modified:m.txt"},
+		},
+		{
+			name:  "新建空文件",
+			patch: "diff --git a/empty b/empty\nnew file mode 100644\n",
+			want:  []string{"added:empty"},
+		},
+		{
+			name:  "不带hunk的重命名",
+			patch: "diff --git a/a.go b/b.go\nsimilarity index 100%\nrename from a.go\nrename to b.go\n",
+			want:  []string{"renamed:b.go"},
+		},
+		{
+			name:  "带hunk的重命名",
+			patch: "--- a/a.go\n+++ b/b.go\n@@ -1 +1 @@\n-a\n+b\n",
+			want:  []string{"renamed:b.go"},
+		},
+		{
+			name:    "hunk行数与hunk头不符",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n-a\n+b\n",
+			wantErr: "hunk行数与hunk头不符",
+		},
+		{
+			name:    "缺少文件头",
+			patch:   "@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "缺少 ---/+++ 文件头",
+		},
+		{
+			name:    "修改文件没有hunk",
+			patch:   "--- a/f\n+++ b/f\n",
+			wantErr: "没有hunk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parsePatch(tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePatch error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePatch: %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, f.status()+":"+f.path())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFiles 在dir下创建文件
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// chdir 切换到补丁路径所基于的工作区目录，测试结束后恢复
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// readFiles 读取dir下的文件，不存在的文件不出现在结果中
+func readFiles(t *testing.T, dir string, names ...string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = string(data)
+	}
+	return files
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		patch   string
+		want    map[string]string // 执行后的文件内容（不在其中的文件应不存在）
+		wantErr string
+	}{
+		{
+			name:  "新建和删除文件",
+			files: map[string]string{"old.txt": "bye\n"},
+			patch: "--- /dev/null\n+++ b/sub/new.txt\n@@ -0,0 +1 @@\n+hello\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n",
+			want:  map[string]string{"sub/new.txt": "hello\n"},
+		},
+		{
+			name:  "重命名并修改",
+			files: map[string]string{"a.txt": "one\ntwo\n"},
+			patch: "diff --git a/a.txt b/b.txt\nrename from a.txt\nrename to b.txt\n--- a/a.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n",
+			want:  map[string]string{"b.txt": "one\n2\n"},
+		},
+		{
+			name:    "重命名的目标已存在",
+			files:   map[string]string{"a.txt": "one\n", "b.txt": "other\n"},
+			patch:   "diff --git a/a.txt b/b.txt\nrename from a.txt\nrename to b.txt\n",
+			want:    map[string]string{"a.txt": "one\n", "b.txt": "other\n"},
+			wantErr: "目标文件已存在",
+		},
+		{
+			name:    "第二个文件校验失败时第一个文件不变",
+			files:   map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-x\n+X\n",
+			want:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			wantErr: "未修改任何文件",
+		},
+		{
+			name:    "第二个文件写入失败时回滚第一个文件",
+			files:   map[string]string{"a.txt": "a\n", "blocker": "not a dir\n"},
+			patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- /dev/null\n+++ b/blocker/new.txt\n@@ -0,0 +1 @@\n+x\n",
+			want:    map[string]string{"a.txt": "a\n", "blocker": "not a dir\n"},
+			wantErr: "已恢复其余文件",
+		},
+		{
+			name:    "新建的文件已存在",
+			files:   map[string]string{"a.txt": "a\n"},
+			patch:   "--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+x\n",
+			want:    map[string]string{"a.txt": "a\n"},
+			wantErr: "文件已存在",
+		},
+	}
+	names := []string{"a.txt", "b.txt", "old.txt", "sub/new.txt", "blocker"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			chdir(t, dir)
+
+			_, err := NewApplyPatchTool().Execute(context.Background(), map[string]interface{}{"patch": tt.patch})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("apply_patch: %v", err)
+			}
+
+			got := readFiles(t, dir, names...)
+			if len(got) != len(tt.want) {
+				t.Fatalf("files = %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Fatalf("%s = %q, want %q", name, got[name], want)
+				}
+			}
+			if _, ok := tt.files["sub/new.txt"]; !ok && tt.wantErr != "" {
+				if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+					t.Fatalf("回滚后不应留下新建的目录")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserTool 浏览器自动化工具（基于headless Chrome）
+type BrowserTool struct {
+	allowedDomains []string
+	headless       bool
+	timeout        time.Duration
+}
+
+// NewBrowserTool 创建浏览器自动化工具
+func NewBrowserTool(allowedDomains []string, headless bool, timeout time.Duration) *BrowserTool {
+	return &BrowserTool{
+		allowedDomains: allowedDomains,
+		headless:       headless,
+		timeout:        timeout,
+	}
+}
+
+func (t *BrowserTool) Name() string {
+	return "browser"
+}
+
+func (t *BrowserTool) Description() string {
+	return "使用headless Chrome操作网页（支持JS渲染和表单交互）。打开url后在同一个页面上依次执行steps中的操作，多步表单交互（输入后点击提交）需放在一次调用的steps中。参数: url(起始页面地址), steps(操作列表，每项包含 action(navigate/click/type/submit/wait/extract_text/screenshot)、selector、text、url、filepath), 只有一步操作时也可直接使用 action、selector、text、filepath 参数"
+}
+
+func (t *BrowserTool) GetParams() map[string]string {
+	return map[string]string{
+		"url":      "起始页面地址",
+		"steps":    `按顺序执行的操作列表（JSON数组），每项为 {"action": "navigate/click/type/submit/wait/extract_text/screenshot", "selector": "CSS选择器", "text": "type输入的内容", "url": "navigate打开的地址", "filepath": "screenshot保存路径"}`,
+		"action":   "只有一步操作时的操作类型: navigate, click, type, submit, wait, extract_text, screenshot（默认navigate）",
+		"selector": "CSS选择器（click/type/submit/wait必填，extract_text默认body）",
+		"text":     "type操作要输入的内容",
+		"filepath": "screenshot操作的保存路径(可选，默认screenshot.png)",
+	}
+}
+
+func (t *BrowserTool) ParamSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"steps": map[string]interface{}{
+			"type":        "array",
+			"description": t.GetParams()["steps"],
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action":   map[string]interface{}{"type": "string", "enum": browserActions},
+					"selector": map[string]interface{}{"type": "string", "description": "CSS选择器"},
+					"text":     map[string]interface{}{"type": "string", "description": "type操作要输入的内容"},
+					"url":      map[string]interface{}{"type": "string", "description": "navigate操作打开的地址"},
+					"filepath": map[string]interface{}{"type": "string", "description": "screenshot操作的保存路径"},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+func (t *BrowserTool) RequiredParams() []string {
+	return []string{"url"}
+}
+
+// browserActions 支持的浏览器操作
+var browserActions = []string{"navigate", "click", "type", "submit", "wait", "extract_text", "screenshot"}
+
+// browserStep 浏览器操作中的一步
+type browserStep struct {
+	Action   string `json:"action"`
+	Selector string `json:"selector"`
+	Text     string `json:"text"`
+	URL      string `json:"url"`
+	Filepath string `json:"filepath"`
+}
+
+func (t *BrowserTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	pageURL, ok := params["url"].(string)
+	if !ok || pageURL == "" {
+		return nil, fmt.Errorf("缺少url参数")
+	}
+	if err := t.checkDomain(pageURL); err != nil {
+		return nil, err
+	}
+
+	steps, err := parseBrowserSteps(params)
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("第%d步: %w", i+1, err)
+		}
+		if step.Action == "navigate" && step.URL != "" {
+			if err := t.checkDomain(step.URL); err != nil {
+				return nil, fmt.Errorf("第%d步: %w", i+1, err)
+			}
+		}
+	}
+
+	// 所有操作在同一个浏览器页面中执行，输入的内容和页面状态在步骤之间保留
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.Flag("headless", t.headless))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancelAlloc()
+
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	browserCtx, cancelTimeout := context.WithTimeout(browserCtx, t.timeout)
+	defer cancelTimeout()
+
+	if err := chromedp.Run(browserCtx, chromedp.Navigate(pageURL)); err != nil {
+		return nil, fmt.Errorf("打开页面失败: %w", err)
+	}
+	location, err := t.checkLocation(browserCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]map[string]interface{}, 0, len(steps))
+	for i, step := range steps {
+		stepResult, err := t.runStep(browserCtx, step)
+		if err != nil {
+			return nil, fmt.Errorf("第%d步(%s)失败: %w", i+1, step.Action, err)
+		}
+		// 点击、提交和跳转可能离开允许的域名，每一步之后重新检查当前地址
+		if location, err = t.checkLocation(browserCtx); err != nil {
+			return nil, fmt.Errorf("第%d步(%s)后%w", i+1, step.Action, err)
+		}
+		stepResult["url"] = location
+		results = append(results, stepResult)
+	}
+
+	var title string
+	if err := chromedp.Run(browserCtx, chromedp.Title(&title)); err != nil {
+		return nil, fmt.Errorf("读取页面标题失败: %w", err)
+	}
+	return map[string]interface{}{
+		"url":   location,
+		"title": title,
+		"steps": results,
+	}, nil
+}
+
+// parseBrowserSteps 解析操作列表（兼容以JSON字符串传入的参数）；没有steps时由 action 等参数组成一步
+func parseBrowserSteps(params map[string]interface{}) ([]browserStep, error) {
+	value, ok := params["steps"]
+	if s, isString := value.(string); isString && strings.TrimSpace(s) == "" {
+		ok = false
+	}
+	if !ok || value == nil {
+		step := browserStep{}
+		step.Action, _ = params["action"].(string)
+		step.Selector, _ = params["selector"].(string)
+		step.Text, _ = params["text"].(string)
+		step.Filepath, _ = params["filepath"].(string)
+		step.Action = strings.ToLower(strings.TrimSpace(step.Action))
+		if step.Action == "" {
+			step.Action = "navigate"
+		}
+		return []browserStep{step}, nil
+	}
+
+	if s, isString := value.(string); isString {
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("steps 不是有效的JSON: %w", err)
+		}
+	}
+	if single, isMap := value.(map[string]interface{}); isMap {
+		value = []interface{}{single}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("steps 参数无效: %w", err)
+	}
+	var steps []browserStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("steps 必须是 {action, selector, text, url, filepath} 组成的数组: %w", err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("steps 为空")
+	}
+	for i := range steps {
+		steps[i].Action = strings.ToLower(strings.TrimSpace(steps[i].Action))
+	}
+	return steps, nil
+}
+
+// validate 检查操作所需的参数
+func (s browserStep) validate() error {
+	switch s.Action {
+	case "navigate", "extract_text", "screenshot":
+		return nil
+	case "click", "type", "submit", "wait":
+		if s.Selector == "" {
+			return fmt.Errorf("%s操作缺少selector参数", s.Action)
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的浏览器操作: %s", s.Action)
+}
+
+// runStep 在当前页面上执行一步操作
+func (t *BrowserTool) runStep(ctx context.Context, step browserStep) (map[string]interface{}, error) {
+	result := map[string]interface{}{"action": step.Action}
+	var content string
+
+	switch step.Action {
+	case "navigate":
+		var actions []chromedp.Action
+		if step.URL != "" {
+			actions = append(actions, chromedp.Navigate(step.URL))
+		}
+		actions = append(actions, chromedp.Text("body", &content, chromedp.ByQuery))
+		if err := chromedp.Run(ctx, actions...); err != nil {
+			return nil, err
+		}
+	case "click", "submit":
+		action := chromedp.Click(step.Selector, chromedp.ByQuery)
+		if step.Action == "submit" {
+			action = chromedp.Submit(step.Selector, chromedp.ByQuery)
+		}
+		if err := chromedp.Run(ctx,
+			chromedp.WaitVisible(step.Selector, chromedp.ByQuery),
+			action,
+			chromedp.Sleep(500*time.Millisecond),
+			chromedp.Text("body", &content, chromedp.ByQuery),
+		); err != nil {
+			return nil, err
+		}
+	case "type":
+		if err := chromedp.Run(ctx,
+			chromedp.WaitVisible(step.Selector, chromedp.ByQuery),
+			chromedp.SendKeys(step.Selector, step.Text, chromedp.ByQuery),
+		); err != nil {
+			return nil, err
+		}
+	case "wait":
+		if err := chromedp.Run(ctx, chromedp.WaitVisible(step.Selector, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+	case "extract_text":
+		selector := step.Selector
+		if selector == "" {
+			selector = "body"
+		}
+		if err := chromedp.Run(ctx, chromedp.Text(selector, &content, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+	case "screenshot":
+		savePath := step.Filepath
+		if savePath == "" {
+			savePath = "screenshot.png"
+		}
+		var buf []byte
+		if err := chromedp.Run(ctx, chromedp.FullScreenshot(&buf, 90)); err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(savePath)
+		if dir != "" && dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建目录失败: %w", err)
+			}
+		}
+		if err := os.WriteFile(savePath, buf, 0644); err != nil {
+			return nil, fmt.Errorf("保存截图失败: %w", err)
+		}
+		result["filepath"] = savePath
+		result["bytes"] = len(buf)
+	}
+
+	// 截断过长的页面文本，避免上下文溢出
+	if len(content) > 20000 {
+		content = content[:20000] + "\n... (页面内容过长，已截断)"
+	}
+	if content != "" {
+		result["content"] = content
+	}
+	return result, nil
+}
+
+// checkLocation 读取页面当前地址（包括重定向和点击后的跳转）并检查是否仍在允许的域名内
+func (t *BrowserTool) checkLocation(ctx context.Context) (string, error) {
+	var location string
+	if err := chromedp.Run(ctx, chromedp.Location(&location)); err != nil {
+		return "", fmt.Errorf("读取页面地址失败: %w", err)
+	}
+	if err := t.checkDomain(location); err != nil {
+		return location, fmt.Errorf("页面跳转到了不允许的地址 %s: %w", location, err)
+	}
+	return location, nil
+}
+
+func (t *BrowserTool) ProducedFiles(params map[string]interface{}, result interface{}) []string {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	steps, _ := resultMap["steps"].([]map[string]interface{})
+	var files []string
+	for _, step := range steps {
+		if path, ok := step["filepath"].(string); ok && path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// checkDomain 检查URL的域名是否在允许列表中（列表为空表示不限制）
+func (t *BrowserTool) checkDomain(rawURL string) error {
+	return checkURLDomain(rawURL, t.allowedDomains)
+}
+
+// checkURLDomain 检查URL是否为http(s)地址且域名（包含子域名）在允许列表中，列表为空表示不限制
+func checkURLDomain(rawURL string, allowedDomains []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("无效的url: %s", rawURL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("不支持的url协议: %s", u.Scheme)
+	}
+
+	if len(allowedDomains) == 0 {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range allowedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("域名不在允许列表中: %s", host)
+}
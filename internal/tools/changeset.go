@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"agentcli/internal/fsutil"
+)
+
+// Change 计划模式下一个文件的待确认修改；同一文件的多次修改合并为一项，Original 为第一次提出修改时的内容
+type Change struct {
+	Path     string
+	Tool     string // 最后一次修改该文件的工具
+	Original string
+	Content  string
+	Existed  bool // 提出修改时文件是否已存在
+	Perm     os.FileMode
+
+	seq   int                       // 最后一次修改的序号，用于找出本轮新提出的修改
+	after func(ctx context.Context) // 写入后执行（如 write_code 的格式化）
+}
+
+// Diff 修改的统一diff
+func (c *Change) Diff() string {
+	oldPath := "a/" + c.Path
+	if !c.Existed {
+		oldPath = "/dev/null"
+	}
+	oldLines := splitLines(c.Original)
+	return fmt.Sprintf("--- %s\n+++ b/%s\n%s", oldPath, c.Path, formatHunks(oldLines, diffLines(oldLines, splitLines(c.Content))))
+}
+
+// Stat 修改新增和删除的行数
+func (c *Change) Stat() (added, removed int) {
+	for _, e := range diffLines(splitLines(c.Original), splitLines(c.Content)) {
+		added += len(e.newLines)
+		removed += len(e.oldLines)
+	}
+	return added, removed
+}
+
+// ChangeSet 计划模式的变更集：开启时 write_code 和 edit_file 不写入文件，而是把修改记录在这里，
+// 由用户确认（/apply）后再写入
+type ChangeSet struct {
+	mu       sync.Mutex
+	active   bool
+	changes  map[string]*Change // 绝对路径 -> 修改
+	seq      int
+	consumed int // ConsumeRecent 已返回到的序号
+}
+
+// NewChangeSet 创建变更集
+func NewChangeSet() *ChangeSet {
+	return &ChangeSet{changes: make(map[string]*Change)}
+}
+
+// SetActive 开启或关闭计划模式，关闭时已记录的修改保留，仍可应用或丢弃
+func (s *ChangeSet) SetActive(active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = active
+}
+
+// Active 是否处于计划模式；变更集为 nil 时视为关闭
+func (s *ChangeSet) Active() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Pending 文件待确认的内容，后续修改应基于该内容而不是磁盘上的文件
+func (s *ChangeSet) Pending(path string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.changes[changeKey(path)]; ok {
+		return c.Content, true
+	}
+	return "", false
+}
+
+// pendingPerm 文件待确认修改的权限，没有待确认修改时返回 false
+func (s *ChangeSet) pendingPerm(path string) (os.FileMode, bool) {
+	if s == nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.changes[changeKey(path)]; ok {
+		return c.Perm, true
+	}
+	return 0, false
+}
+
+// settle 文件已在计划模式之外直接写入（写入内容已包含或取代待确认的修改），移除其待确认修改，
+// 避免之后 /apply 时该项因文件已被改动而无法应用
+func (s *ChangeSet) settle(path string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.changes, changeKey(path))
+}
+
+// changeKey 变更集中的键，同一文件的相对路径和绝对路径对应同一项
+func changeKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// propose 记录对文件的修改，after 在应用修改后执行（为 nil 时保留之前修改设置的）
+func (s *ChangeSet) propose(tool, path, content string, perm os.FileMode, after func(ctx context.Context)) *Change {
+	path = filepath.Clean(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := changeKey(path)
+	c, ok := s.changes[key]
+	if !ok {
+		c = &Change{Path: path, Perm: perm}
+		if data, err := os.ReadFile(path); err == nil {
+			c.Original, c.Existed = string(data), true
+		}
+		s.changes[key] = c
+	}
+	s.seq++
+	c.Tool, c.Content, c.seq = tool, content, s.seq
+	if after != nil {
+		c.after = after
+	}
+	return c
+}
+
+// Changes 按路径排序的全部待确认修改
+func (s *ChangeSet) Changes() []*Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted(0)
+}
+
+// ConsumeRecent 返回上次调用以来新提出或再次修改的文件
+func (s *ChangeSet) ConsumeRecent() []*Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := s.sorted(s.consumed)
+	s.consumed = s.seq
+	return recent
+}
+
+func (s *ChangeSet) sorted(afterSeq int) []*Change {
+	changes := make([]*Change, 0, len(s.changes))
+	for _, c := range s.changes {
+		if c.seq > afterSeq {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// Apply 写入指定文件的修改（paths 为空时写入全部），返回已写入的文件
+// 文件在提出修改后被其他程序改动过时不覆盖，该项保留在变更集中，其余修改照常写入，最后一并返回错误
+func (s *ChangeSet) Apply(ctx context.Context, paths []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selected, err := s.selectChanges(paths)
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	var errs []error
+	for _, c := range selected {
+		if err := ctx.Err(); err != nil {
+			return applied, err
+		}
+		data, readErr := os.ReadFile(c.Path)
+		if exists := readErr == nil; exists != c.Existed || exists && string(data) != c.Original {
+			errs = append(errs, fmt.Errorf("%s 在提出修改后已被改动，未覆盖（可使用 /discard 丢弃该修改）", c.Path))
+			continue
+		}
+		if _, _, err := prepareWrite(c.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := fsutil.ReplaceFile(c.Path, []byte(c.Content), c.Perm); err != nil {
+			errs = append(errs, fmt.Errorf("写入 %s 失败: %w", c.Path, err))
+			continue
+		}
+		if c.after != nil {
+			c.after(ctx)
+		}
+		delete(s.changes, changeKey(c.Path))
+		applied = append(applied, c.Path)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// Discard 丢弃指定文件的修改（paths 为空时丢弃全部），返回丢弃的文件
+func (s *ChangeSet) Discard(paths []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selected, err := s.selectChanges(paths)
+	if err != nil {
+		return nil, err
+	}
+	discarded := make([]string, 0, len(selected))
+	for _, c := range selected {
+		delete(s.changes, changeKey(c.Path))
+		discarded = append(discarded, c.Path)
+	}
+	return discarded, nil
+}
+
+// selectChanges 按路径选出修改，paths 为空时选出全部；路径可以是绝对路径或相对当前目录的路径
+func (s *ChangeSet) selectChanges(paths []string) ([]*Change, error) {
+	if len(paths) == 0 {
+		return s.sorted(0), nil
+	}
+	selected := make([]*Change, 0, len(paths))
+	for _, path := range paths {
+		c, ok := s.changes[changeKey(path)]
+		if !ok {
+			return nil, fmt.Errorf("没有对 %s 的待确认修改", path)
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// proposalResult 计划模式下 write_code、edit_file 返回给模型的结果
+func proposalResult(c *Change) map[string]interface{} {
+	added, removed := c.Stat()
+	return map[string]interface{}{
+		"filepath": c.Path,
+		"proposed": true,
+		"added":    added,
+		"removed":  removed,
+		"message":  fmt.Sprintf("计划模式：对 %s 的修改（新增%d行、删除%d行）已加入待确认的变更集，文件尚未写入，用户确认后才会应用", c.Path, added, removed),
+	}
+}
@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 命令执行前的确认方式（tools.execute_command.require_confirmation）
+const (
+	ConfirmDestructive = "destructive" // 只确认破坏性命令（默认）
+	ConfirmAlways      = "always"      // 每条命令都确认
+	ConfirmNever       = "never"       // 不确认
+)
+
+// CommandPolicy execute_command 的执行策略
+// 名单中的每一项匹配命令的开头若干个词，如 "git" 匹配所有git命令，"git push" 只匹配推送；
+// 由 &&、||、;、|、&、换行连接的每一段命令，以及子shell、$()和反引号中的命令都会分别检查；
+// 黑名单同时检查去掉 sudo、env 等前缀和环境变量赋值后的命令，白名单要求前缀本身也在名单中
+type CommandPolicy struct {
+	Allowlist           []string // 非空时只允许执行匹配的命令
+	Denylist            []string // 禁止执行的命令，优先于白名单
+	RequireConfirmation string   // destructive/always/never
+}
+
+// commandSeparator 连接多条命令的操作符，以及包含另一条命令的子shell、命令替换
+var commandSeparator = regexp.MustCompile("&&|\\|\\||\\$\\(|[;|&\n()`]")
+
+// fdRedirect 文件描述符重定向（如 2>&1、&>file），其中的 & 不是命令连接符
+var fdRedirect = regexp.MustCompile(`\d*[<>]&(\d+|-)?|&>>?`)
+
+// envAssignment 命令前的环境变量赋值，如 FOO=bar
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=\S*$`)
+
+// destructiveCommands 破坏性命令：删除、格式化、覆盖磁盘、强制改写版本历史、结束进程、关机等
+var destructiveCommands = []string{
+	"rm", "rmdir", "del", "erase", "rd", "format", "mkfs", "dd", "shred", "wipefs", "fdisk", "parted", "diskpart",
+	"remove-item", "format-volume", "clear-disk", "clear-content",
+	"git reset --hard", "git clean", "git push --force", "git push -f", "git branch -D", "git checkout --",
+	"chmod -R", "chown -R", "truncate", "kill", "killall", "pkill", "stop-process",
+	"shutdown", "reboot", "halt", "poweroff", "docker system prune", "docker rm", "kubectl delete",
+}
+
+// commandPrefixes 不影响命令本身的前缀，检查黑名单和破坏性命令时跳过（前缀后的选项一并跳过）
+var commandPrefixes = map[string]bool{"sudo": true, "env": true, "nohup": true, "time": true, "command": true, "exec": true, "nice": true}
+
+// prefixOptionArgs 前缀中带参数的选项，如 sudo -u root 中的 root 不是要执行的命令
+var prefixOptionArgs = map[string]map[string]bool{
+	"sudo": {"-u": true, "-g": true, "-h": true, "-p": true, "-C": true, "-D": true, "-r": true, "-t": true, "-U": true},
+	"env":  {"-u": true, "-C": true, "-S": true},
+	"nice": {"-n": true},
+	"exec": {"-a": true},
+}
+
+// shellKeywords 出现在命令开头的shell关键字，如 if true; then rm x; fi 中的 then
+var shellKeywords = map[string]bool{"if": true, "then": true, "else": true, "elif": true, "do": true, "while": true, "until": true, "!": true, "{": true, "}": true}
+
+// ConfirmMode 确认方式，未配置或无法识别时为 destructive
+func (p CommandPolicy) ConfirmMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(p.RequireConfirmation)); mode {
+	case ConfirmAlways, ConfirmNever:
+		return mode
+	default:
+		return ConfirmDestructive
+	}
+}
+
+// Check 检查命令是否允许执行
+func (p CommandPolicy) Check(command string) error {
+	segments := commandSegments(command)
+	for _, segment := range segments {
+		for _, form := range commandForms(segment) {
+			if entry := matchList(form, p.Denylist); entry != "" {
+				return fmt.Errorf("命令被禁止执行（denylist: %s）: %s", entry, segment)
+			}
+		}
+	}
+	if len(p.Allowlist) == 0 {
+		return nil
+	}
+	if strings.Contains(command, "$(") || strings.Contains(command, "`") {
+		return fmt.Errorf("配置了命令白名单时不允许使用命令替换: %s", command)
+	}
+	for _, segment := range segments {
+		forms := commandForms(segment)
+		if len(forms) > 0 && matchList(forms[0], p.Allowlist) == "" {
+			return fmt.Errorf("命令不在允许执行的名单中（allowlist）: %s", segment)
+		}
+	}
+	return nil
+}
+
+// ConfirmReason 返回命令需要用户确认的原因，不需要确认时返回空字符串
+func (p CommandPolicy) ConfirmReason(command string) string {
+	switch p.ConfirmMode() {
+	case ConfirmNever:
+		return ""
+	case ConfirmAlways:
+		return "每条命令执行前都需要确认"
+	}
+	if name := DestructiveCommand(command); name != "" {
+		return fmt.Sprintf("包含破坏性命令 %s", name)
+	}
+	return ""
+}
+
+// DestructiveCommand 返回命令中第一个破坏性操作（如 "rm"、"git reset --hard"），没有时返回空字符串
+func DestructiveCommand(command string) string {
+	for _, segment := range commandSegments(command) {
+		forms := commandForms(segment)
+		if len(forms) == 0 {
+			continue
+		}
+		line := forms[len(forms)-1]
+		for _, name := range destructiveCommands {
+			if matchEntry(line, name) {
+				return name
+			}
+		}
+		// mkfs.ext4 等变体
+		if strings.HasPrefix(strings.ToLower(programName(strings.Fields(line)[0])), "mkfs.") {
+			return "mkfs"
+		}
+	}
+	return ""
+}
+
+// commandForms 一段命令去掉开头的shell关键字和环境变量赋值后的形式，以及依次去掉每个前缀（连同其选项）后的形式
+// 如 "FOO=1 sudo -E rm x" 返回 ["sudo -E rm x", "rm x"]；没有命令时返回nil
+func commandForms(segment string) []string {
+	words := strings.Fields(segment)
+	skip := func() {
+		for len(words) > 0 && (shellKeywords[words[0]] || envAssignment.MatchString(words[0])) {
+			words = words[1:]
+		}
+	}
+	skip()
+	var forms []string
+	for len(words) > 0 {
+		forms = append(forms, strings.Join(words, " "))
+		prefix := programName(words[0])
+		if !commandPrefixes[prefix] {
+			break
+		}
+		words = words[1:]
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+			takesArg := prefixOptionArgs[prefix][words[0]]
+			words = words[1:]
+			if takesArg && len(words) > 0 {
+				words = words[1:]
+			}
+		}
+		skip()
+	}
+	return forms
+}
+
+// commandSegments 按命令连接符拆分出各段命令
+func commandSegments(command string) []string {
+	var segments []string
+	for _, part := range commandSeparator.Split(fdRedirect.ReplaceAllString(command, " "), -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+// matchList 返回名单中第一个匹配该段命令的项
+func matchList(segment string, list []string) string {
+	for _, entry := range list {
+		if strings.TrimSpace(entry) != "" && matchEntry(segment, entry) {
+			return entry
+		}
+	}
+	return ""
+}
+
+// matchEntry 名单项是否匹配命令开头的若干个词（程序名忽略路径和 .exe 后缀，不区分大小写）
+func matchEntry(segment, entry string) bool {
+	words := strings.Fields(segment)
+	want := strings.Fields(entry)
+	if len(words) < len(want) || len(want) == 0 {
+		return false
+	}
+	if !strings.EqualFold(programName(words[0]), programName(want[0])) {
+		return false
+	}
+	for i := 1; i < len(want); i++ {
+		if words[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// programName 去掉路径和 .exe 后缀的程序名
+func programName(word string) string {
+	name := filepath.Base(strings.ReplaceAll(word, "\\", "/"))
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
@@ -0,0 +1,74 @@
+package tools
+
+import "testing"
+
+func TestCommandPolicyCheck(t *testing.T) {
+	deny := CommandPolicy{Denylist: []string{"rm"}}
+	allow := CommandPolicy{Allowlist: []string{"ls", "git status", "echo"}}
+
+	tests := []struct {
+		name    string
+		policy  CommandPolicy
+		command string
+		wantErr bool
+	}{
+		{"黑名单: 直接执行", deny, "rm -rf x", true},
+		{"黑名单: sudo前缀", deny, "sudo rm -rf x", true},
+		{"黑名单: sudo带参数的选项", deny, "sudo -u root rm -rf x", true},
+		{"黑名单: env前缀", deny, "env rm x", true},
+		{"黑名单: env带选项和赋值", deny, "env -i PATH=/bin rm x", true},
+		{"黑名单: 环境变量赋值", deny, "FOO=1 rm -rf x", true},
+		{"黑名单: 子shell", deny, "(rm -rf x)", true},
+		{"黑名单: 后台执行连接", deny, "true & rm -rf x", true},
+		{"黑名单: 命令替换", deny, "echo $(rm -rf x)", true},
+		{"黑名单: 反引号", deny, "echo `rm -rf x`", true},
+		{"黑名单: shell关键字", deny, "if true; then rm -rf x; fi", true},
+		{"黑名单: 带路径的程序名", deny, "/bin/rm x", true},
+		{"黑名单: 前缀本身被禁止", CommandPolicy{Denylist: []string{"sudo"}}, "sudo ls", true},
+		{"黑名单: 未命中", deny, "ls -la 2>&1 | grep rm", false},
+		{"黑名单: 参数中的rm不算命令", deny, "echo rm", false},
+
+		{"白名单: 允许的命令", allow, "ls -la", false},
+		{"白名单: 多词名单项", allow, "git status --short", false},
+		{"白名单: 重定向中的&不拆分命令", allow, "ls -la 2>&1", false},
+		{"白名单: 管道中的命令都需允许", allow, "ls | echo", false},
+		{"白名单: 后台执行连接", allow, "ls & rm -rf ~", true},
+		{"白名单: 子shell", allow, "ls; (rm -rf ~)", true},
+		{"白名单: 命令替换", allow, "echo $(ls)", true},
+		{"白名单: 前缀不在名单中", allow, "sudo ls", true},
+		{"白名单: 环境变量赋值", allow, "LANG=C ls", false},
+		{"白名单: 多词名单项不匹配", allow, "git push", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDestructiveCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"rm -rf x", "rm"},
+		{"sudo rm -rf x", "rm"},
+		{"sudo -u root rm -rf x", "rm"},
+		{"FOO=1 rm -rf x", "rm"},
+		{"ls & rm -rf ~", "rm"},
+		{"(rm -rf x)", "rm"},
+		{"echo `rm -rf x`", "rm"},
+		{"git reset --hard HEAD", "git reset --hard"},
+		{"mkfs.ext4 /dev/sdb", "mkfs"},
+		{"ls -la 2>&1", ""},
+		{"git status", ""},
+	}
+	for _, tt := range tests {
+		if got := DestructiveCommand(tt.command); got != tt.want {
+			t.Errorf("DestructiveCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"agentcli/internal/fsutil"
+)
+
+// EditFileTool 对单个已有文件做局部修改：应用统一diff的hunk，或按搜索/替换块替换内容
+// 修改先在内存中校验（hunk上下文、搜索内容必须与文件当前内容一致），通过后原子写入；dry_run 时只返回预览
+// 计划模式下修改记录到变更集，用户确认后才写入
+type EditFileTool struct {
+	changes *ChangeSet
+}
+
+// NewEditFileTool 创建文件编辑工具
+func NewEditFileTool(changes *ChangeSet) *EditFileTool {
+	return &EditFileTool{changes: changes}
+}
+
+func (t *EditFileTool) Name() string {
+	return "edit_file"
+}
+
+func (t *EditFileTool) Description() string {
+	return "局部修改已有文件，适合只改动几处代码而不重写整个文件。两种方式任选其一: diff(统一diff的@@ hunk，可省略---/+++文件头)，或 search+replace(将与文件内容完全一致的search替换为replace)。修改校验通过后原子写入，返回实际修改的hunk。参数: filepath(文件路径), diff, search, replace, replace_all(search匹配多处时全部替换,可选), dry_run(只预览不写入,可选), workdir(执行目录,可选)"
+}
+
+func (t *EditFileTool) GetParams() map[string]string {
+	return map[string]string{
+		"filepath":    "要修改的文件路径（相对路径基于workdir），文件必须已存在",
+		"diff":        "统一diff格式的hunk（@@ -行,行数 +行,行数 @@ 开头），可带 ---/+++ 文件头",
+		"search":      "要替换的原内容，需与文件内容完全一致（含缩进），应包含足够的上下文以唯一定位",
+		"replace":     "替换后的内容，为空表示删除search",
+		"replace_all": "true 时替换search的所有匹配，默认只允许唯一匹配",
+		"dry_run":     "true 时只返回将要做的修改，不写入文件",
+		WorkdirParam:  workdirParamDescription,
+	}
+}
+
+func (t *EditFileTool) RequiredParams() []string {
+	return []string{"filepath"}
+}
+
+func (t *EditFileTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	filePath, _ := params["filepath"].(string)
+	if filePath == "" {
+		filePath, _ = params["file_path"].(string)
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("缺少文件路径参数")
+	}
+	target, err := workdirPath(params, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计划模式下同一文件的多次修改基于尚未写入的内容
+	original, pending := t.changes.Pending(target)
+	info, err := os.Stat(target)
+	switch {
+	case pending:
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("文件不存在: %s（新建文件请使用 write_code 或 write_file）", filePath)
+	case err != nil:
+		return nil, fmt.Errorf("读取文件信息失败: %w", err)
+	case info.IsDir():
+		return nil, fmt.Errorf("路径是目录: %s", filePath)
+	default:
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return nil, fmt.Errorf("读取文件失败: %w", err)
+		}
+		original = string(data)
+	}
+
+	diff, _ := params["diff"].(string)
+	search, _ := params["search"].(string)
+	hasSearch := search != ""
+	var content string
+	switch {
+	case strings.TrimSpace(diff) != "" && hasSearch:
+		return nil, fmt.Errorf("diff 和 search/replace 只能使用一种")
+	case strings.TrimSpace(diff) != "":
+		content, err = applyFileDiff(original, filePath, diff)
+	case hasSearch:
+		replace, _ := params["replace"].(string)
+		content, err = replaceBlock(original, search, replace, boolParam(params, "replace_all"))
+	default:
+		return nil, fmt.Errorf("缺少修改内容，需要 diff 或 search/replace 参数")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("修改校验失败，文件未改动: %w", err)
+	}
+	if content == original {
+		return nil, fmt.Errorf("修改后的内容与原文件相同，文件未改动")
+	}
+
+	oldLines := splitLines(original)
+	edits := diffLines(oldLines, splitLines(content))
+	added, removed := 0, 0
+	for _, e := range edits {
+		added += len(e.newLines)
+		removed += len(e.oldLines)
+	}
+	result := map[string]interface{}{
+		"filepath": target,
+		"hunks":    formatHunks(oldLines, edits),
+		"added":    added,
+		"removed":  removed,
+	}
+
+	if boolParam(params, "dry_run") {
+		result["dry_run"] = true
+		result["message"] = fmt.Sprintf("预览: %s 将新增%d行、删除%d行，文件未改动", filePath, added, removed)
+		return result, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 有待确认的修改时权限取自该修改（待确认的新建文件在磁盘上还不存在）
+	perm := os.FileMode(0644)
+	if pendingPerm, ok := t.changes.pendingPerm(target); ok {
+		perm = pendingPerm
+	} else if info != nil {
+		perm = info.Mode().Perm()
+	}
+	if t.changes.Active() {
+		return proposalResult(t.changes.propose(t.Name(), target, content, perm, nil)), nil
+	}
+	if pending {
+		if _, _, err := prepareWrite(target); err != nil {
+			return nil, err
+		}
+	}
+	if err := fsutil.ReplaceFile(target, []byte(content), perm); err != nil {
+		return nil, fmt.Errorf("写入文件失败: %w", err)
+	}
+	// 写入的内容基于待确认的修改，该修改已随之写入
+	t.changes.settle(target)
+	result["message"] = fmt.Sprintf("已修改 %s: 新增%d行、删除%d行", filePath, added, removed)
+	return result, nil
+}
+
+func (t *EditFileTool) ProducedFiles(params map[string]interface{}, result interface{}) []string {
+	resultMap, ok := result.(map[string]interface{})
+	if !ok || resultMap["dry_run"] == true || resultMap["proposed"] == true {
+		return nil
+	}
+	if path, ok := resultMap["filepath"].(string); ok && path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// applyFileDiff 将单个文件的统一diff应用到内容上，diff缺少文件头时补上
+func applyFileDiff(content, filePath, diff string) (string, error) {
+	if !strings.Contains(diff, "\n+++ ") && !strings.HasPrefix(diff, "--- ") {
+		diff = fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", filePath, filePath, strings.TrimLeft(diff, "\n"))
+	}
+	files, err := parsePatch(diff)
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("diff 中包含%d个文件，edit_file 只修改一个文件（多文件修改请使用 apply_patch）", len(files))
+	}
+	if status := files[0].status(); status != PatchModified {
+		return "", fmt.Errorf("edit_file 只修改已有文件，不支持新建、删除或重命名文件（请使用 apply_patch）")
+	}
+	return applyHunks(content, files[0].hunks)
+}
+
+// replaceBlock 将 search 替换为 replace；search 必须存在，匹配多处时需要 replaceAll
+func replaceBlock(content, search, replace string, replaceAll bool) (string, error) {
+	count := strings.Count(content, search)
+	switch {
+	case count == 0:
+		return "", fmt.Errorf("文件中没有找到 search 的内容，请先用 read_file 确认原文（包括缩进和空白）")
+	case count > 1 && !replaceAll:
+		return "", fmt.Errorf("search 的内容在文件中出现了%d次，请加入更多上下文使其唯一，或设置 replace_all=true", count)
+	}
+	return strings.ReplaceAll(content, search, replace), nil
+}
+
+// boolParam 读取布尔参数，兼容 "true"/"1"/"yes" 等字符串形式
+func boolParam(params map[string]interface{}, name string) bool {
+	switch v := params[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true") || v == "1" || strings.EqualFold(v, "yes")
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 计划模式下提出新建文件、关闭计划模式后再用 edit_file 修改该文件：文件尚不存在，不应panic，
+// 修改应基于待确认的内容写入，且该待确认修改随之移除
+func TestEditFilePendingNewFileAfterPlanOff(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pkg", "new.go")
+
+	changes := NewChangeSet()
+	changes.SetActive(true)
+	writer := NewWriteCodeTool(1000, []string{"go"}, nil, nil, changes)
+	if _, err := writer.Execute(ctx, map[string]interface{}{
+		"filepath": path,
+		"code":     "package pkg\n\nconst Name = \"old\"\n",
+	}); err != nil {
+		t.Fatalf("write_code: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("计划模式下文件不应被写入: %v", err)
+	}
+
+	changes.SetActive(false)
+	editor := NewEditFileTool(changes)
+	if _, err := editor.Execute(ctx, map[string]interface{}{
+		"filepath": path,
+		"search":   "\"old\"",
+		"replace":  "\"new\"",
+	}); err != nil {
+		t.Fatalf("edit_file: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取文件失败: %v", err)
+	}
+	if want := "package pkg\n\nconst Name = \"new\"\n"; string(data) != want {
+		t.Fatalf("文件内容 = %q, want %q", data, want)
+	}
+	if pending := changes.Changes(); len(pending) != 0 {
+		t.Fatalf("直接写入后仍有待确认修改: %v", pending[0].Path)
+	}
+}
+
+// 计划模式下对已有文件的多次修改基于待确认的内容，并保留原文件权限
+func TestEditFilePlanModeBuildsOnPendingContent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(path, []byte("echo a\necho b\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := NewChangeSet()
+	changes.SetActive(true)
+	editor := NewEditFileTool(changes)
+	for _, edit := range [][2]string{{"echo a", "echo A"}, {"echo b", "echo B"}} {
+		if _, err := editor.Execute(ctx, map[string]interface{}{
+			"filepath": path,
+			"search":   edit[0],
+			"replace":  edit[1],
+		}); err != nil {
+			t.Fatalf("edit_file %q: %v", edit[0], err)
+		}
+	}
+
+	pending := changes.Changes()
+	if len(pending) != 1 {
+		t.Fatalf("待确认修改数 = %d, want 1", len(pending))
+	}
+	if c := pending[0]; c.Content != "echo A\necho B\n" || c.Original != "echo a\necho b\n" || c.Perm != 0755 {
+		t.Fatalf("待确认修改 = %+v", c)
+	}
+	if _, err := changes.Apply(ctx, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("权限 = %v, want 0755", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretMask 日志和结果中替换密钥值的占位符
+const secretMask = "****"
+
+// minMaskedLength 短于该长度的值不做替换，避免把常见短字符串误替换
+const minMaskedLength = 4
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type envContextKey struct{}
+
+// WithEnv 在上下文中附加对话级环境变量，execute_command 执行时注入到子进程
+func WithEnv(ctx context.Context, env map[string]string) context.Context {
+	if len(env) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, envContextKey{}, env)
+}
+
+// envFromContext 获取上下文中的对话级环境变量
+func envFromContext(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(envContextKey{}).(map[string]string)
+	return env
+}
+
+// envList 将环境变量转换为按名称排序的 KEY=VALUE 列表
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for name, value := range env {
+		list = append(list, name+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ValidEnvName 判断环境变量名是否合法
+func ValidEnvName(name string) bool {
+	return envNamePattern.MatchString(name)
+}
+
+// IsSecretEnv 根据变量名判断环境变量是否为密钥（KEY、TOKEN、SECRET、PASSWORD等）
+func IsSecretEnv(name string) bool {
+	name = strings.ToUpper(name)
+	for _, marker := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "AUTH"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskEnvValue 展示用的环境变量值，密钥显示为占位符
+func MaskEnvValue(name, value string) string {
+	if IsSecretEnv(name) && value != "" {
+		return secretMask
+	}
+	return value
+}
+
+// MaskSecrets 将文本中出现的密钥类环境变量值替换为占位符
+func MaskSecrets(text string, env map[string]string) string {
+	for name, value := range env {
+		if IsSecretEnv(name) && len(value) >= minMaskedLength {
+			text = strings.ReplaceAll(text, value, secretMask)
+		}
+	}
+	return text
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"time"
@@ -11,6 +12,7 @@ import (
 // ExecuteCommandTool 执行命令工具
 type ExecuteCommandTool struct {
 	timeout time.Duration
+	policy  CommandPolicy
 }
 
 // NewExecuteCommandTool 创建执行命令工具
@@ -20,24 +22,34 @@ func NewExecuteCommandTool(timeout time.Duration) *ExecuteCommandTool {
 	}
 }
 
+// SetPolicy 设置命令执行策略（白名单、黑名单）
+func (t *ExecuteCommandTool) SetPolicy(policy CommandPolicy) {
+	t.policy = policy
+}
+
 func (t *ExecuteCommandTool) Name() string {
 	return "execute_command"
 }
 
 func (t *ExecuteCommandTool) Description() string {
 	if runtime.GOOS == "windows" {
-		return "执行系统命令（Windows 使用 PowerShell 语法）。示例: Get-ChildItem -Recurse -Filter hello.py, Get-Content .\\file.txt, Select-String -Pattern \"foo\" -Path .\\ -Recurse。参数: command(命令), args(参数列表,可选)"
+		return "执行系统命令（Windows 使用 PowerShell 语法）。示例: Get-ChildItem -Recurse -Filter hello.py, Get-Content .\\file.txt, Select-String -Pattern \"foo\" -Path .\\ -Recurse。参数: command(命令), args(参数列表,可选), workdir(执行目录,可选)"
 	}
-	return "执行系统命令（Unix 使用 sh -c 语法）。参数: command(命令), args(参数列表,可选)"
+	return "执行系统命令（Unix 使用 sh -c 语法）。参数: command(命令), args(参数列表,可选), workdir(执行目录,可选)"
 }
 
 func (t *ExecuteCommandTool) GetParams() map[string]string {
 	return map[string]string{
-		"command": "要执行的系统命令（Windows: PowerShell 语法）",
-		"args":    "命令参数列表(可选)",
+		"command":    "要执行的系统命令（Windows: PowerShell 语法）",
+		"args":       "命令参数列表(可选)",
+		WorkdirParam: workdirParamDescription,
 	}
 }
 
+func (t *ExecuteCommandTool) RequiredParams() []string {
+	return []string{"command"}
+}
+
 func (t *ExecuteCommandTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	// 获取参数
 	command, ok := params["command"].(string)
@@ -57,6 +69,16 @@ func (t *ExecuteCommandTool) Execute(ctx context.Context, params map[string]inte
 		}
 	}
 
+	if err := t.policy.Check(fullCommand); err != nil {
+		return nil, err
+	}
+
+	dir, _ := params[WorkdirParam].(string)
+	workdir, err := ResolveWorkdir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建超时上下文
 	cmdCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
@@ -69,25 +91,41 @@ func (t *ExecuteCommandTool) Execute(ctx context.Context, params map[string]inte
 	} else {
 		cmd = exec.CommandContext(cmdCtx, "sh", "-c", fullCommand)
 	}
+	cmd.Dir = workdir
+
+	// 注入对话级环境变量（/env），输出中的密钥值替换为占位符
+	env := envFromContext(ctx)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), envList(env)...)
+	}
 
 	// 执行命令
-	output, err := cmd.CombinedOutput()
+	rawOutput, err := cmd.CombinedOutput()
+	output := MaskSecrets(string(rawOutput), env)
 	if err != nil {
 		// 检查是否超时
 		if cmdCtx.Err() == context.DeadlineExceeded {
 			return nil, fmt.Errorf("命令执行超时")
 		}
-		return map[string]interface{}{
+		return withWorkdir(map[string]interface{}{
 			"command": command,
-			"output":  string(output),
+			"output":  output,
 			"error":   err.Error(),
 			"success": false,
-		}, nil
+		}, workdir), nil
 	}
 
-	return map[string]interface{}{
+	return withWorkdir(map[string]interface{}{
 		"command": command,
-		"output":  string(output),
+		"output":  output,
 		"success": true,
-	}, nil
+	}, workdir), nil
+}
+
+// withWorkdir 指定了执行目录时在结果中注明
+func withWorkdir(result map[string]interface{}, workdir string) map[string]interface{} {
+	if workdir != "" {
+		result[WorkdirParam] = workdir
+	}
+	return result
 }
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FetchURLTool 通过HTTP GET读取网页或文本内容，HTML页面提取为纯文本
+type FetchURLTool struct {
+	client         *http.Client
+	maxBytes       int64
+	maxChars       int
+	allowedDomains []string
+}
+
+// NewFetchURLTool 创建网页读取工具，maxSizeKB 为下载的最大大小，maxChars 为每次返回的最多字符数
+func NewFetchURLTool(timeout time.Duration, maxSizeKB, maxChars int, allowedDomains []string) *FetchURLTool {
+	if maxSizeKB <= 0 {
+		maxSizeKB = 2048
+	}
+	if maxChars <= 0 {
+		maxChars = 20000
+	}
+	return &FetchURLTool{
+		client:         &http.Client{Timeout: timeout},
+		maxBytes:       int64(maxSizeKB) * 1024,
+		maxChars:       maxChars,
+		allowedDomains: allowedDomains,
+	}
+}
+
+func (t *FetchURLTool) Name() string {
+	return "fetch_url"
+}
+
+func (t *FetchURLTool) Description() string {
+	return "读取网页或在线文本（HTTP GET），HTML页面会提取为纯文本，用于查看搜索结果的详细内容、在线文档、发布说明等最新信息；不执行JavaScript，需要交互或渲染的页面请用browser。参数: url(http/https地址), offset(从第几个字符开始返回,内容较长时用于继续读取,可选)"
+}
+
+func (t *FetchURLTool) GetParams() map[string]string {
+	return map[string]string{
+		"url":    "要读取的http或https地址",
+		"offset": fmt.Sprintf("从提取后内容的第几个字符开始返回（每次最多返回%d字符），默认0", t.maxChars),
+	}
+}
+
+func (t *FetchURLTool) RequiredParams() []string {
+	return []string{"url"}
+}
+
+func (t *FetchURLTool) ReadOnly() bool {
+	return true
+}
+
+func (t *FetchURLTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	pageURL, _ := params["url"].(string)
+	pageURL = strings.TrimSpace(pageURL)
+	if pageURL == "" {
+		return nil, fmt.Errorf("url参数不能为空")
+	}
+	if err := checkURLDomain(pageURL, t.allowedDomains); err != nil {
+		return nil, err
+	}
+	offset, _, err := intParam(params, "offset")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("User-Agent", "agentcli")
+	req.Header.Set("Accept", "text/html,text/plain,application/json,application/xml;q=0.9,*/*;q=0.5")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	// 重定向后的地址同样需要在允许列表中
+	if err := checkURLDomain(resp.Request.URL.String(), t.allowedDomains); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("请求失败 (status %d)", resp.StatusCode)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !isTextContent(contentType) {
+		return nil, fmt.Errorf("不支持的内容类型: %s（fetch_url 只读取网页和文本）", contentType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	partial := int64(len(body)) > t.maxBytes
+	if partial {
+		body = body[:t.maxBytes]
+	}
+
+	result := map[string]interface{}{
+		"url":          resp.Request.URL.String(),
+		"content_type": contentType,
+	}
+	content := string(body)
+	if contentType == "text/html" || contentType == "application/xhtml+xml" {
+		if title := htmlTitle(content); title != "" {
+			result["title"] = title
+		}
+		content = htmlToText(content)
+	}
+
+	runes := []rune(content)
+	result["length"] = len(runes)
+	if offset >= len(runes) && len(runes) > 0 {
+		return nil, fmt.Errorf("offset 超出内容长度（共%d字符）", len(runes))
+	}
+	end := min(len(runes), offset+t.maxChars)
+	result["content"] = string(runes[offset:end])
+	if end < len(runes) {
+		result["truncated"] = true
+		result["next_offset"] = end
+		result["note"] = fmt.Sprintf("内容较长，只返回了第%d~%d字符，需要更多内容时用 offset=%d 继续读取", offset, end, end)
+	}
+	if partial {
+		result["partial"] = true
+		result["note"] = fmt.Sprintf("页面超过%dKB，只读取了开头部分", t.maxBytes/1024)
+	}
+	return result, nil
+}
+
+// isTextContent 是否为可以作为文本返回的内容类型（未声明类型时按文本处理）
+func isTextContent(contentType string) bool {
+	switch {
+	case contentType == "", strings.HasPrefix(contentType, "text/"):
+		return true
+	case strings.HasSuffix(contentType, "json"), strings.HasSuffix(contentType, "xml"):
+		return true
+	case contentType == "application/javascript", contentType == "application/x-yaml", contentType == "application/yaml":
+		return true
+	}
+	return false
+}
+
+var (
+	htmlTitlePattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<(script|style|noscript|svg|template|head)[^>]*>.*?</(script|style|noscript|svg|template|head)>|<!--.*?-->`)
+	htmlBlockPattern  = regexp.MustCompile(`(?i)<(br|/p|/div|/section|/article|/h[1-6]|/tr|/table|/ul|/ol|/pre|/blockquote|hr)[^>]*>`)
+	htmlItemPattern   = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlAnyTagPattern = regexp.MustCompile(`(?s)<[^>]+>`)
+	inlineSpace       = regexp.MustCompile(`[ \t\f\v\p{Zs}]+`)
+	blankLines        = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlTitle 页面标题
+func htmlTitle(page string) string {
+	m := htmlTitlePattern.FindStringSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(inlineSpace.ReplaceAllString(html.UnescapeString(htmlAnyTagPattern.ReplaceAllString(m[1], "")), " "))
+}
+
+// htmlToText 将HTML提取为可读文本：去掉脚本、样式等不可见内容，块级元素换行，列表项加 "- "
+func htmlToText(page string) string {
+	text := htmlHiddenPattern.ReplaceAllString(page, " ")
+	text = htmlBlockPattern.ReplaceAllString(text, "\n")
+	text = htmlItemPattern.ReplaceAllString(text, "\n- ")
+	text = htmlAnyTagPattern.ReplaceAllString(text, " ")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r", ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(inlineSpace.ReplaceAllString(line, " "))
+	}
+	text = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
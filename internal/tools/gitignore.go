@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule .gitignore 中的一条规则
+type ignoreRule struct {
+	base     string // 规则所在 .gitignore 的目录（绝对路径）
+	pattern  string
+	negate   bool // ! 开头，重新包含被忽略的路径
+	dirOnly  bool // / 结尾，只匹配目录
+	anchored bool // 包含 /，相对于 base 匹配；否则匹配任意层级的文件名
+}
+
+// gitignore 按目录逐级加载的忽略规则
+type gitignore struct {
+	rules  []ignoreRule
+	loaded map[string]bool
+}
+
+func newGitignore() *gitignore {
+	return &gitignore{loaded: make(map[string]bool)}
+}
+
+// load 加载目录下的 .gitignore（每个目录只加载一次）
+func (g *gitignore) load(dir string) {
+	if g.loaded[dir] {
+		return
+	}
+	g.loaded[dir] = true
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// loadParents 加载 dir 到仓库根目录（包含 .git 的目录）之间各级的 .gitignore，不在仓库中时只加载 dir 本身
+func (g *gitignore) loadParents(dir string) {
+	var dirs []string
+	for cur := dir; ; {
+		dirs = append(dirs, cur)
+		if _, err := os.Stat(filepath.Join(cur, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			dirs = dirs[:1]
+			break
+		}
+		cur = parent
+	}
+	// 从仓库根目录向下加载，后加载的规则优先
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g.load(dirs[i])
+	}
+}
+
+// ignored 路径（绝对路径）是否被忽略，最后一条匹配的规则生效
+func (g *gitignore) ignored(abs string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		var matched bool
+		if rule.anchored {
+			matched = matchGlob(rule.pattern, rel)
+		} else {
+			matched, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlob 按路径段匹配通配符，** 匹配任意层级（包括零层）
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,497 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GoInspectTool Go代码智能分析工具
+type GoInspectTool struct {
+	maxResults int
+}
+
+// NewGoInspectTool 创建Go代码智能分析工具
+func NewGoInspectTool(maxResults int) *GoInspectTool {
+	if maxResults <= 0 {
+		maxResults = 200
+	}
+	return &GoInspectTool{
+		maxResults: maxResults,
+	}
+}
+
+func (t *GoInspectTool) Name() string {
+	return "go_inspect"
+}
+
+func (t *GoInspectTool) Description() string {
+	return "基于go list和go/types精确分析Go代码。参数: action(symbols/signature/references/build_tags), package(包路径或模式,默认./...), symbol(符号名,如 NewAgent 或 Agent.ProcessRequest), dir(模块目录,可选)"
+}
+
+func (t *GoInspectTool) GetParams() map[string]string {
+	return map[string]string{
+		"action":  "操作类型: symbols(列出符号), signature(签名与文档), references(查找引用), build_tags(构建约束)",
+		"package": "包路径或模式(可选，默认 ./...)",
+		"symbol":  "signature/references操作的符号名，方法使用 类型.方法 形式",
+		"dir":     "Go模块所在目录(可选，默认当前目录)",
+	}
+}
+
+func (t *GoInspectTool) RequiredParams() []string {
+	return []string{"action"}
+}
+
+func (t *GoInspectTool) ReadOnly() bool {
+	return true
+}
+
+func (t *GoInspectTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	action, _ := params["action"].(string)
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action == "" {
+		action = "symbols"
+	}
+
+	pattern, _ := params["package"].(string)
+	if strings.TrimSpace(pattern) == "" {
+		pattern = "./..."
+	}
+	dir, _ := params["dir"].(string)
+	symbol, _ := params["symbol"].(string)
+	symbol = strings.TrimSpace(symbol)
+
+	if (action == "signature" || action == "references") && symbol == "" {
+		return nil, fmt.Errorf("%s操作缺少symbol参数", action)
+	}
+
+	pkgs, err := loadGoPackages(ctx, dir, pattern, action != "build_tags")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("未找到匹配的Go包: %s", pattern)
+	}
+
+	var loadErrors []string
+	for _, p := range pkgs {
+		loadErrors = append(loadErrors, p.Errors...)
+	}
+
+	var result map[string]interface{}
+	switch action {
+	case "symbols":
+		result = t.listSymbols(pkgs)
+	case "signature":
+		result, err = t.describeSymbol(pkgs, symbol)
+	case "references":
+		result, err = t.findReferences(pkgs, symbol)
+	case "build_tags":
+		result = t.buildConstraints(pkgs)
+	default:
+		return nil, fmt.Errorf("不支持的go_inspect操作: %s", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(loadErrors) > 0 {
+		if len(loadErrors) > 10 {
+			loadErrors = loadErrors[:10]
+		}
+		result["load_errors"] = loadErrors
+	}
+	return result, nil
+}
+
+// goPackage 已加载并完成类型检查的Go包
+type goPackage struct {
+	PkgPath      string
+	Dir          string
+	GoFiles      []string
+	IgnoredFiles []string
+	Fset         *token.FileSet
+	Syntax       []*ast.File
+	Types        *types.Package
+	TypesInfo    *types.Info
+	Errors       []string
+}
+
+// goListPackage go list -json 的输出
+type goListPackage struct {
+	Dir            string
+	ImportPath     string
+	Export         string
+	DepOnly        bool
+	GoFiles        []string
+	CgoFiles       []string
+	IgnoredGoFiles []string
+	ImportMap      map[string]string
+	Error          *struct {
+		Err string
+	}
+}
+
+// loadGoPackages 在dir下通过go list解析包模式，并使用go/types进行类型检查
+// 导入的包从go list -export生成的导出数据读取，与在dir下执行go build时解析到的包一致（而不是按进程当前目录解析）
+func loadGoPackages(ctx context.Context, dir, pattern string, typeCheck bool) ([]*goPackage, error) {
+	args := []string{"list", "-e", "-json"}
+	if typeCheck {
+		args = append(args, "-deps", "-export")
+	}
+	cmd := exec.CommandContext(ctx, "go", append(args, pattern)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var listed []goListPackage
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var lp goListPackage
+		if err := decoder.Decode(&lp); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("解析go list输出失败: %w", err)
+		}
+		listed = append(listed, lp)
+	}
+
+	fset := token.NewFileSet()
+	exports := make(map[string]string)
+	for _, lp := range listed {
+		if lp.Export != "" {
+			exports[lp.ImportPath] = lp.Export
+		}
+	}
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("没有 %s 的导出数据（包可能无法编译）", path)
+		}
+		return os.Open(file)
+	})
+
+	var pkgs []*goPackage
+	for _, lp := range listed {
+		if lp.DepOnly {
+			continue
+		}
+		pkg := &goPackage{
+			PkgPath: lp.ImportPath,
+			Dir:     lp.Dir,
+			Fset:    fset,
+		}
+		for _, f := range append(append([]string{}, lp.GoFiles...), lp.CgoFiles...) {
+			pkg.GoFiles = append(pkg.GoFiles, filepath.Join(lp.Dir, f))
+		}
+		for _, f := range lp.IgnoredGoFiles {
+			pkg.IgnoredFiles = append(pkg.IgnoredFiles, filepath.Join(lp.Dir, f))
+		}
+		if lp.Error != nil {
+			pkg.Errors = append(pkg.Errors, lp.Error.Err)
+		}
+
+		if typeCheck && len(pkg.GoFiles) > 0 {
+			typeCheckPackage(pkg, &mappedImporter{imp: imp, importMap: lp.ImportMap})
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// mappedImporter 按包的ImportMap（vendor目录等）将源码中的导入路径映射为实际的包路径
+type mappedImporter struct {
+	imp       types.Importer
+	importMap map[string]string
+}
+
+func (m *mappedImporter) Import(path string) (*types.Package, error) {
+	if mapped, ok := m.importMap[path]; ok {
+		path = mapped
+	}
+	return m.imp.Import(path)
+}
+
+// typeCheckPackage 解析并类型检查包（类型错误不会中断分析）
+func typeCheckPackage(pkg *goPackage, imp types.Importer) {
+	for _, file := range pkg.GoFiles {
+		f, err := parser.ParseFile(pkg.Fset, file, nil, parser.ParseComments)
+		if err != nil {
+			pkg.Errors = append(pkg.Errors, err.Error())
+			continue
+		}
+		pkg.Syntax = append(pkg.Syntax, f)
+	}
+
+	pkg.TypesInfo = &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if len(pkg.Errors) < 20 {
+				pkg.Errors = append(pkg.Errors, err.Error())
+			}
+		},
+	}
+	pkg.Types, _ = conf.Check(pkg.PkgPath, pkg.Fset, pkg.Syntax, pkg.TypesInfo)
+}
+
+// listSymbols 列出包内的顶层符号及类型方法
+func (t *GoInspectTool) listSymbols(pkgs []*goPackage) map[string]interface{} {
+	var symbols []string
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		qualifier := types.RelativeTo(pkg.Types)
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			symbols = append(symbols, fmt.Sprintf("%s: %s", pkg.PkgPath, types.ObjectString(obj, qualifier)))
+
+			// 列出命名类型的方法
+			if tn, ok := obj.(*types.TypeName); ok {
+				if named, ok := tn.Type().(*types.Named); ok {
+					for i := 0; i < named.NumMethods(); i++ {
+						symbols = append(symbols, fmt.Sprintf("%s: %s", pkg.PkgPath, types.ObjectString(named.Method(i), qualifier)))
+					}
+				}
+			}
+		}
+	}
+
+	total := len(symbols)
+	truncated := false
+	if total > t.maxResults {
+		symbols = symbols[:t.maxResults]
+		truncated = true
+	}
+
+	return map[string]interface{}{
+		"count":     total,
+		"symbols":   symbols,
+		"truncated": truncated,
+	}
+}
+
+// describeSymbol 显示符号的签名、文档和定义位置
+func (t *GoInspectTool) describeSymbol(pkgs []*goPackage, symbol string) (map[string]interface{}, error) {
+	for _, pkg := range pkgs {
+		obj := lookupSymbol(pkg, symbol)
+		if obj == nil {
+			continue
+		}
+
+		return map[string]interface{}{
+			"package":   pkg.PkgPath,
+			"symbol":    symbol,
+			"signature": types.ObjectString(obj, types.RelativeTo(pkg.Types)),
+			"doc":       findDoc(pkg, obj.Pos()),
+			"position":  pkg.Fset.Position(obj.Pos()).String(),
+		}, nil
+	}
+	return nil, fmt.Errorf("未找到符号: %s", symbol)
+}
+
+// findReferences 查找符号在已加载包中的所有引用
+func (t *GoInspectTool) findReferences(pkgs []*goPackage, symbol string) (map[string]interface{}, error) {
+	var target types.Object
+	for _, pkg := range pkgs {
+		if target = lookupSymbol(pkg, symbol); target != nil {
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("未找到符号: %s", symbol)
+	}
+
+	key := objectKey(target)
+	seen := make(map[string]bool)
+	var refs []string
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Uses {
+			if obj == nil || objectKey(obj) != key {
+				continue
+			}
+			pos := pkg.Fset.Position(ident.Pos()).String()
+			if !seen[pos] {
+				seen[pos] = true
+				refs = append(refs, pos)
+			}
+		}
+	}
+	sort.Strings(refs)
+
+	total := len(refs)
+	truncated := false
+	if total > t.maxResults {
+		refs = refs[:t.maxResults]
+		truncated = true
+	}
+
+	return map[string]interface{}{
+		"symbol":     symbol,
+		"count":      total,
+		"references": refs,
+		"truncated":  truncated,
+	}, nil
+}
+
+// buildConstraints 报告各文件的构建约束（//go:build）
+func (t *GoInspectTool) buildConstraints(pkgs []*goPackage) map[string]interface{} {
+	constraints := make(map[string]string)
+	var ignored []string
+	for _, pkg := range pkgs {
+		files := append(append([]string{}, pkg.GoFiles...), pkg.IgnoredFiles...)
+		for _, file := range files {
+			if expr := readBuildConstraint(file); expr != "" {
+				constraints[file] = expr
+			}
+		}
+		ignored = append(ignored, pkg.IgnoredFiles...)
+	}
+
+	return map[string]interface{}{
+		"constraints":   constraints,
+		"ignored_files": ignored,
+	}
+}
+
+// lookupSymbol 在包中查找符号，支持 类型.方法/字段 形式
+func lookupSymbol(pkg *goPackage, symbol string) types.Object {
+	if pkg.Types == nil {
+		return nil
+	}
+
+	typeName, member, hasMember := strings.Cut(symbol, ".")
+	obj := pkg.Types.Scope().Lookup(typeName)
+	if obj == nil || !hasMember {
+		return obj
+	}
+
+	found, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg.Types, member)
+	return found
+}
+
+// objectKey 生成跨包比较用的符号标识
+func objectKey(obj types.Object) string {
+	pkgPath := ""
+	if obj.Pkg() != nil {
+		pkgPath = obj.Pkg().Path()
+	}
+
+	recv := ""
+	if fn, ok := obj.(*types.Func); ok {
+		if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+			recv = types.TypeString(sig.Recv().Type(), nil)
+			recv = strings.TrimPrefix(recv, "*")
+		}
+	}
+	return pkgPath + "|" + recv + "|" + obj.Name()
+}
+
+// findDoc 查找声明对应的文档注释
+func findDoc(pkg *goPackage, pos token.Pos) string {
+	for _, file := range pkg.Syntax {
+		if file.Pos() > pos || pos > file.End() {
+			continue
+		}
+		var doc string
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n == nil || doc != "" {
+				return false
+			}
+			if n.Pos() > pos || pos > n.End() {
+				return false
+			}
+			switch d := n.(type) {
+			case *ast.FuncDecl:
+				if d.Name.Pos() == pos && d.Doc != nil {
+					doc = d.Doc.Text()
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					var specDoc *ast.CommentGroup
+					var names []*ast.Ident
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						specDoc, names = s.Doc, []*ast.Ident{s.Name}
+					case *ast.ValueSpec:
+						specDoc, names = s.Doc, s.Names
+					}
+					for _, name := range names {
+						if name.Pos() != pos {
+							continue
+						}
+						if specDoc == nil {
+							specDoc = d.Doc
+						}
+						if specDoc != nil {
+							doc = specDoc.Text()
+						}
+					}
+				}
+			case *ast.Field:
+				for _, name := range d.Names {
+					if name.Pos() == pos && d.Doc != nil {
+						doc = d.Doc.Text()
+					}
+				}
+			}
+			return true
+		})
+		return strings.TrimSpace(doc)
+	}
+	return ""
+}
+
+// readBuildConstraint 读取文件头部的 //go:build 约束
+func readBuildConstraint(filename string) string {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if constraint.IsGoBuild(c.Text) {
+				if expr, err := constraint.Parse(c.Text); err == nil {
+					return expr.String()
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// 模块位于进程当前目录之外时，跨包的引用也应按dir所在模块解析
+func TestGoInspectReferencesAcrossPackagesOutsideCwd(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("需要go命令")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/m\n\ngo 1.21\n",
+		"a/a.go":  "package a\n\n// Hello 返回问候语\nfunc Hello() string { return \"hello\" }\n",
+		"b/b.go":  "package b\n\nimport \"example.com/m/a\"\n\nfunc Greet() string { return a.Hello() }\n",
+		"b/c.go":  "//go:build ignore\n\npackage b\n",
+		"a/a2.go": "package a\n\nfunc twice() string { return Hello() + Hello() }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewGoInspectTool(0)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "references",
+		"symbol": "Hello",
+		"dir":    dir,
+	})
+	if err != nil {
+		t.Fatalf("references: %v", err)
+	}
+	m := result.(map[string]interface{})
+	if loadErrors, ok := m["load_errors"]; ok {
+		t.Fatalf("load_errors: %v", loadErrors)
+	}
+	if m["count"] != 3 {
+		t.Fatalf("count = %v, want 3 (references: %v)", m["count"], m["references"])
+	}
+
+	result, err = tool.Execute(context.Background(), map[string]interface{}{
+		"action": "build_tags",
+		"dir":    dir,
+	})
+	if err != nil {
+		t.Fatalf("build_tags: %v", err)
+	}
+	constraints := result.(map[string]interface{})["constraints"].(map[string]string)
+	if got := constraints[filepath.Join(dir, "b", "c.go")]; got != "ignore" {
+		t.Fatalf("b/c.go 构建约束 = %q, want ignore", got)
+	}
+}
@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffEdits 逐行比较的最大编辑距离，超出时把首尾相同部分之间的内容视为一整段替换
+const maxDiffEdits = 2000
+
+// diffContext 生成hunk时保留的上下文行数
+const diffContext = 3
+
+// lineEdit 将原内容第 at 行（从0开始）起的 oldLines 替换为 newLines
+type lineEdit struct {
+	at       int
+	oldLines []string
+	newLines []string
+}
+
+// splitLines 按行拆分文本，末尾的换行不产生空行
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// UnifiedDiff 文件修改前后内容的统一diff
+func UnifiedDiff(path, before, after string) string {
+	oldLines := splitLines(before)
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", path, path, formatHunks(oldLines, diffLines(oldLines, splitLines(after))))
+}
+
+// diffLines 计算从 a 到 b 的逐行编辑（Myers算法）
+func diffLines(a, b []string) []lineEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	edits, ok := myersDiff(a, b)
+	if !ok {
+		edits = []lineEdit{{at: 0, oldLines: a, newLines: b}}
+	}
+	for i := range edits {
+		edits[i].at += prefix
+	}
+	return edits
+}
+
+// myersDiff 最短编辑脚本，编辑距离超过 maxDiffEdits 时返回 false
+func myersDiff(a, b []string) ([]lineEdit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= min(n+m, maxDiffEdits) && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// 从终点回溯，得到从后往前的操作序列：' ' 相同，'-' 删除 a[x]，'+' 插入 b[y]
+	type op struct {
+		kind byte
+		x, y int
+	}
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[offset+k-1] < prev[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{' ', x, y})
+		}
+		if x == prevX {
+			ops = append(ops, op{'+', x, prevY})
+		} else {
+			ops = append(ops, op{'-', prevX, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	// 合并连续的删除和插入
+	var edits []lineEdit
+	var cur *lineEdit
+	for i := len(ops) - 1; i >= 0; i-- {
+		o := ops[i]
+		if o.kind == ' ' {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			edits = append(edits, lineEdit{at: o.x})
+			cur = &edits[len(edits)-1]
+		}
+		if o.kind == '-' {
+			cur.oldLines = append(cur.oldLines, a[o.x])
+		} else {
+			cur.newLines = append(cur.newLines, b[o.y])
+		}
+	}
+	return edits, true
+}
+
+// formatHunks 将编辑格式化为带上下文的统一diff hunk，相距较近的编辑合并为一个hunk
+func formatHunks(original []string, edits []lineEdit) string {
+	var sb strings.Builder
+	shift := 0 // 之前的hunk造成的新旧行号偏差
+	for i := 0; i < len(edits); {
+		j := i + 1
+		for j < len(edits) && edits[j].at-(edits[j-1].at+len(edits[j-1].oldLines)) <= 2*diffContext {
+			j++
+		}
+		group := edits[i:j]
+		last := group[len(group)-1]
+		start := max(group[0].at-diffContext, 0)
+		end := min(last.at+len(last.oldLines)+diffContext, len(original))
+
+		var body strings.Builder
+		oldCount, newCount := 0, 0
+		pos := start
+		for _, e := range group {
+			for ; pos < e.at; pos++ {
+				body.WriteString(" " + original[pos] + "\n")
+				oldCount++
+				newCount++
+			}
+			for _, line := range e.oldLines {
+				body.WriteString("-" + line + "\n")
+			}
+			for _, line := range e.newLines {
+				body.WriteString("+" + line + "\n")
+			}
+			oldCount += len(e.oldLines)
+			newCount += len(e.newLines)
+			pos = e.at + len(e.oldLines)
+		}
+		for ; pos < end; pos++ {
+			body.WriteString(" " + original[pos] + "\n")
+			oldCount++
+			newCount++
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n%s", hunkRange(start, oldCount), hunkRange(start+shift, newCount), body.String())
+		for _, e := range group {
+			shift += len(e.newLines) - len(e.oldLines)
+		}
+		i = j
+	}
+	return sb.String()
+}
+
+// hunkRange hunk头中的行范围，空范围按惯例使用其前一行的行号
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
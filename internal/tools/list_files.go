@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// list_files 的默认限制
+const (
+	listDefaultDepth = 3   // 未指定 pattern 时默认列出的目录层级
+	listMaxEntries   = 500 // 返回的最多条目数
+)
+
+// ListFilesTool 列出工作区中的文件和目录，支持通配符、深度限制、.gitignore 和大小/扩展名过滤
+type ListFilesTool struct{}
+
+// NewListFilesTool 创建文件列表工具
+func NewListFilesTool() *ListFilesTool {
+	return &ListFilesTool{}
+}
+
+func (t *ListFilesTool) Name() string {
+	return "list_files"
+}
+
+func (t *ListFilesTool) Description() string {
+	return "列出目录中的文件和子目录，用于在读取或修改文件前确认真实存在的路径，不要猜测文件路径。默认遵循 .gitignore、跳过隐藏文件和 .git 目录。参数: path(目录,默认当前目录), pattern(通配符,如 *.go、**/*_test.go、cmd/**), max_depth(最大层级,可选), extensions(扩展名,如 go,md,可选), min_size_kb/max_size_kb(文件大小范围,可选), include_hidden, include_ignored(可选), workdir(执行目录,可选)"
+}
+
+func (t *ListFilesTool) GetParams() map[string]string {
+	return map[string]string{
+		"path":            "要列出的目录（相对路径基于workdir），默认为当前目录",
+		"pattern":         "匹配相对于path的路径的通配符，** 匹配任意层级；不含 / 时匹配文件名。指定后只返回匹配的文件",
+		"max_depth":       fmt.Sprintf("最多向下列出的目录层级，1表示只列出path本身的内容，0表示不限；未指定pattern时默认%d，指定pattern时默认不限", listDefaultDepth),
+		"extensions":      "只返回这些扩展名的文件，逗号分隔，如 go,md",
+		"min_size_kb":     "只返回不小于该大小（KB）的文件",
+		"max_size_kb":     "只返回不大于该大小（KB）的文件",
+		"include_hidden":  "true 时包含以 . 开头的文件和目录（.git 始终跳过）",
+		"include_ignored": "true 时包含被 .gitignore 忽略的文件",
+		WorkdirParam:      workdirParamDescription,
+	}
+}
+
+func (t *ListFilesTool) RequiredParams() []string {
+	return nil
+}
+
+func (t *ListFilesTool) ReadOnly() bool {
+	return true
+}
+
+// listFilter list_files 的过滤条件
+type listFilter struct {
+	walkOptions
+	pattern    string
+	maxDepth   int // 0 表示不限
+	extensions map[string]bool
+	minSize    int64
+	maxSize    int64 // 0 表示不限
+}
+
+// filesOnly 指定了匹配或过滤条件时只返回文件
+func (f *listFilter) filesOnly() bool {
+	return f.pattern != "" || len(f.extensions) > 0 || f.minSize > 0 || f.maxSize > 0
+}
+
+// matchExtension 文件名是否符合扩展名过滤（未指定扩展名时总是符合）
+func (f *listFilter) matchExtension(name string) bool {
+	return len(f.extensions) == 0 || f.extensions[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]
+}
+
+func (t *ListFilesTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	dir, _ := params["path"].(string)
+	if dir == "" {
+		dir = "."
+	}
+	root, err := workdirPath(params, dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("目录不存在: %s", dir)
+		}
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录: %s", dir)
+	}
+
+	filter, err := parseListFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("解析目录失败: %w", err)
+	}
+
+	var entries []string
+	full := false
+	truncated, err := walkWorkspace(ctx, absRoot, filter.walkOptions, func(p, rel string, d fs.DirEntry) error {
+		if len(entries) >= listMaxEntries {
+			full = true
+			return filepath.SkipAll
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if !filter.filesOnly() {
+				entries = append(entries, rel+"/")
+			}
+			if filter.maxDepth > 0 && strings.Count(rel, "/")+1 >= filter.maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filter.pattern != "" {
+			target := rel
+			if !strings.Contains(filter.pattern, "/") {
+				target = name
+			}
+			if !matchGlob(filter.pattern, target) {
+				return nil
+			}
+		}
+		if !filter.matchExtension(name) {
+			return nil
+		}
+		fileInfo, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size := fileInfo.Size()
+		if size < filter.minSize || (filter.maxSize > 0 && size > filter.maxSize) {
+			return nil
+		}
+		entries = append(entries, fmt.Sprintf("%s (%s)", rel, formatSize(size)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	truncated = truncated || full
+
+	sort.Strings(entries)
+	result := map[string]interface{}{
+		"path":    root,
+		"entries": entries,
+		"count":   len(entries),
+	}
+	if truncated {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("结果过多，只返回了前%d条，请缩小path、使用pattern或减小max_depth", len(entries))
+	}
+	if len(entries) == 0 {
+		result["note"] = "没有匹配的文件"
+	}
+	return result, nil
+}
+
+// parseListFilter 解析过滤参数，数值参数兼容字符串形式
+func parseListFilter(params map[string]interface{}) (*listFilter, error) {
+	filter := &listFilter{walkOptions: walkOptions{
+		includeHidden:  boolParam(params, "include_hidden"),
+		includeIgnored: boolParam(params, "include_ignored"),
+	}}
+	filter.pattern, _ = params["pattern"].(string)
+	filter.pattern = strings.TrimPrefix(strings.TrimSpace(filter.pattern), "./")
+	if filter.pattern != "" {
+		if _, err := path.Match(strings.ReplaceAll(filter.pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("无效的通配符: %s", filter.pattern)
+		}
+	}
+
+	depth, ok, err := intParam(params, "max_depth")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ok:
+		filter.maxDepth = depth
+	case filter.pattern == "":
+		filter.maxDepth = listDefaultDepth
+	}
+
+	for _, name := range []string{"min_size_kb", "max_size_kb"} {
+		kb, _, err := intParam(params, name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "min_size_kb" {
+			filter.minSize = int64(kb) * 1024
+		} else {
+			filter.maxSize = int64(kb) * 1024
+		}
+	}
+
+	filter.extensions = extensionSet(params["extensions"])
+	return filter, nil
+}
+
+// extensionSet 解析扩展名列表（逗号分隔的字符串或字符串数组），忽略大小写和开头的 .
+func extensionSet(value interface{}) map[string]bool {
+	var exts []string
+	switch v := value.(type) {
+	case string:
+		exts = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				exts = append(exts, s)
+			}
+		}
+	}
+	var set map[string]bool
+	for _, ext := range exts {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			if set == nil {
+				set = make(map[string]bool)
+			}
+			set[ext] = true
+		}
+	}
+	return set
+}
+
+// intParam 读取非负整数参数，兼容数字和字符串形式；参数不存在时 ok 为 false
+func intParam(params map[string]interface{}, name string) (value int, ok bool, err error) {
+	switch v := params[name].(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		value = int(v)
+	case int:
+		value = v
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return 0, false, nil
+		}
+		if value, err = strconv.Atoi(strings.TrimSpace(v)); err != nil {
+			return 0, false, fmt.Errorf("参数 %s 必须是整数: %s", name, v)
+		}
+	default:
+		return 0, false, fmt.Errorf("参数 %s 必须是整数", name)
+	}
+	if value < 0 {
+		return 0, false, fmt.Errorf("参数 %s 不能为负数", name)
+	}
+	return value, true, nil
+}
+
+// formatSize 格式化文件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
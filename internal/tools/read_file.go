@@ -27,21 +27,34 @@ func (t *ReadFileTool) Name() string {
 }
 
 func (t *ReadFileTool) Description() string {
-	return "读取文件内容。参数: filepath(文件路径)"
+	return "读取文件内容。参数: filepath(文件路径), workdir(执行目录,可选)"
 }
 
 func (t *ReadFileTool) GetParams() map[string]string {
 	return map[string]string{
-		"filepath": "要读取的文件路径",
+		"filepath":   "要读取的文件路径（相对路径基于workdir）",
+		WorkdirParam: workdirParamDescription,
 	}
 }
 
+func (t *ReadFileTool) RequiredParams() []string {
+	return []string{"filepath"}
+}
+
+func (t *ReadFileTool) ReadOnly() bool {
+	return true
+}
+
 func (t *ReadFileTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	// 获取参数
 	filePath, ok := params["filepath"].(string)
 	if !ok || filePath == "" {
 		return nil, fmt.Errorf("缺少文件路径参数")
 	}
+	filePath, err := workdirPath(params, filePath)
+	if err != nil {
+		return nil, err
+	}
 
 	// 检查文件是否存在
 	info, err := os.Stat(filePath)
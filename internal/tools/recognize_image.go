@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,11 +17,14 @@ type RecognizeImageTool struct {
 	apiClient        ImageAPIClient
 }
 
-// ImageAPIClient 图片API客户端接口
+// ImageAPIClient 图片API客户端接口，imageData 为base64编码的图片内容，prompt 为识别要求
 type ImageAPIClient interface {
-	RecognizeImage(ctx context.Context, imageData string) (string, error)
+	RecognizeImage(ctx context.Context, mediaType, imageData, prompt string) (string, error)
 }
 
+// defaultImagePrompt 未指定问题时的识别要求
+const defaultImagePrompt = "详细描述这张图片的内容。如果包含文字、代码、报错信息、表格或界面元素，请完整准确地转写出来。"
+
 // NewRecognizeImageTool 创建图片识别工具
 func NewRecognizeImageTool(maxSizeMB int, supportedFormats []string, apiClient ImageAPIClient) *RecognizeImageTool {
 	return &RecognizeImageTool{
@@ -35,15 +39,24 @@ func (t *RecognizeImageTool) Name() string {
 }
 
 func (t *RecognizeImageTool) Description() string {
-	return "识别图片内容。参数: filepath(图片文件路径)"
+	return "识别图片内容（截图、报错信息、设计稿、图表等），由多模态模型描述图片或回答关于图片的问题。参数: filepath(图片文件路径), question(关于图片的问题,可选,默认描述图片并转写其中的文字)"
 }
 
 func (t *RecognizeImageTool) GetParams() map[string]string {
 	return map[string]string{
 		"filepath": "要识别的图片文件路径",
+		"question": "关于图片的问题，为空时描述图片内容并转写其中的文字",
 	}
 }
 
+func (t *RecognizeImageTool) RequiredParams() []string {
+	return []string{"filepath"}
+}
+
+func (t *RecognizeImageTool) ReadOnly() bool {
+	return true
+}
+
 func (t *RecognizeImageTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	// 获取参数
 	filePath, ok := params["filepath"].(string)
@@ -83,7 +96,11 @@ func (t *RecognizeImageTool) Execute(ctx context.Context, params map[string]inte
 
 	// 调用API识别图片
 	if t.apiClient != nil {
-		description, err := t.apiClient.RecognizeImage(ctx, base64Data)
+		prompt, _ := params["question"].(string)
+		if strings.TrimSpace(prompt) == "" {
+			prompt = defaultImagePrompt
+		}
+		description, err := t.apiClient.RecognizeImage(ctx, imageMediaType(imageData, ext), base64Data, prompt)
 		if err != nil {
 			return nil, fmt.Errorf("图片识别失败: %w", err)
 		}
@@ -104,6 +121,17 @@ func (t *RecognizeImageTool) Execute(ctx context.Context, params map[string]inte
 	}, nil
 }
 
+// imageMediaType 图片的MIME类型，优先按文件内容判断，无法识别时按扩展名
+func imageMediaType(data []byte, ext string) string {
+	if detected := http.DetectContentType(data); strings.HasPrefix(detected, "image/") {
+		return detected
+	}
+	if ext == "jpg" {
+		ext = "jpeg"
+	}
+	return "image/" + ext
+}
+
 func (t *RecognizeImageTool) isFormatSupported(format string) bool {
 	for _, supported := range t.supportedFormats {
 		if strings.EqualFold(supported, format) {
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// DefaultReminderFile 默认的提醒事项存储文件（当前目录下）
+const DefaultReminderFile = "reminders/reminders.json"
+
+// Reminder 提醒事项
+type Reminder struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	DueAt     time.Time `json:"due_at"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReminderStore 提醒事项本地存储
+type ReminderStore struct {
+	filePath string
+}
+
+// NewReminderStore 创建提醒事项存储（路径解析为绝对路径，影子工作区模式下仍写入真实目录）
+func NewReminderStore(filePath string) *ReminderStore {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	return &ReminderStore{filePath: filePath}
+}
+
+// Load 加载所有提醒事项
+func (s *ReminderStore) Load() ([]Reminder, error) {
+	var reminders []Reminder
+	if _, err := fsutil.ReadJSONWithBackup(s.filePath, &reminders); err != nil {
+		if os.IsNotExist(err) {
+			return []Reminder{}, nil
+		}
+		return nil, fmt.Errorf("读取提醒文件失败: %w", err)
+	}
+	return reminders, nil
+}
+
+// Save 保存所有提醒事项
+func (s *ReminderStore) Save(reminders []Reminder) error {
+	if dir := filepath.Dir(s.filePath); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建提醒目录失败: %w", err)
+		}
+	}
+
+	if err := fsutil.WriteJSONAtomic(s.filePath, reminders, 0644); err != nil {
+		return fmt.Errorf("写入提醒文件失败: %w", err)
+	}
+	return nil
+}
+
+// Due 获取已到期且未完成的提醒事项
+func (s *ReminderStore) Due(now time.Time) ([]Reminder, error) {
+	reminders, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var due []Reminder
+	for _, r := range reminders {
+		if !r.Done && !r.DueAt.After(now) {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+// RemindersTool 提醒事项工具
+type RemindersTool struct {
+	store *ReminderStore
+}
+
+// NewRemindersTool 创建提醒事项工具
+func NewRemindersTool(store *ReminderStore) *RemindersTool {
+	return &RemindersTool{store: store}
+}
+
+func (t *RemindersTool) Name() string {
+	return "reminders"
+}
+
+func (t *RemindersTool) Description() string {
+	return fmt.Sprintf("管理提醒事项（到期后会在会话开始时提示用户）。当前时间: %s。参数: action(create/list/complete/delete), title(提醒内容), due(到期时间, 格式 2006-01-02 15:04 或 RFC3339), id(提醒ID)",
+		time.Now().Format("2006-01-02 15:04 Monday"))
+}
+
+func (t *RemindersTool) GetParams() map[string]string {
+	return map[string]string{
+		"action": "操作类型: create, list, complete, delete",
+		"title":  "create操作的提醒内容",
+		"due":    "create操作的到期时间（2006-01-02 15:04 或 RFC3339）",
+		"id":     "complete/delete操作的提醒ID",
+	}
+}
+
+func (t *RemindersTool) RequiredParams() []string {
+	return []string{"action"}
+}
+
+func (t *RemindersTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	action, _ := params["action"].(string)
+	action = strings.ToLower(strings.TrimSpace(action))
+	if action == "" {
+		action = "list"
+	}
+
+	reminders, err := t.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "create":
+		title, _ := params["title"].(string)
+		if strings.TrimSpace(title) == "" {
+			return nil, fmt.Errorf("缺少提醒内容参数")
+		}
+		dueStr, _ := params["due"].(string)
+		dueAt, err := parseReminderTime(dueStr)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		reminder := Reminder{
+			ID:        fmt.Sprintf("r%d", now.UnixNano()),
+			Title:     strings.TrimSpace(title),
+			DueAt:     dueAt,
+			CreatedAt: now,
+		}
+		reminders = append(reminders, reminder)
+		if err := t.store.Save(reminders); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"id":     reminder.ID,
+			"title":  reminder.Title,
+			"due_at": reminder.DueAt.Format("2006-01-02 15:04"),
+		}, nil
+
+	case "list":
+		sort.Slice(reminders, func(i, j int) bool {
+			return reminders[i].DueAt.Before(reminders[j].DueAt)
+		})
+		items := make([]map[string]interface{}, 0, len(reminders))
+		for _, r := range reminders {
+			items = append(items, map[string]interface{}{
+				"id":     r.ID,
+				"title":  r.Title,
+				"due_at": r.DueAt.Format("2006-01-02 15:04"),
+				"done":   r.Done,
+			})
+		}
+		return map[string]interface{}{
+			"count":     len(items),
+			"reminders": items,
+		}, nil
+
+	case "complete", "delete":
+		id, _ := params["id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("缺少提醒ID参数")
+		}
+		found := false
+		for i := range reminders {
+			if reminders[i].ID != id {
+				continue
+			}
+			found = true
+			if action == "complete" {
+				reminders[i].Done = true
+			} else {
+				reminders = append(reminders[:i], reminders[i+1:]...)
+			}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("提醒不存在: %s", id)
+		}
+		if err := t.store.Save(reminders); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"id":     id,
+			"action": action,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("不支持的提醒操作: %s", action)
+	}
+}
+
+// parseReminderTime 解析到期时间
+func parseReminderTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("缺少到期时间参数")
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析到期时间: %s", value)
+}
@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// search_files 的默认限制
+const (
+	searchDefaultMatches = 50      // 默认返回的最多匹配数
+	searchMaxMatches     = 500     // max_matches 的上限
+	searchMaxContext     = 10      // context_lines 的上限
+	searchMaxFileSize    = 1 << 20 // 超过该大小的文件不搜索
+	searchMaxLineChars   = 300     // 每行最多返回的字符数
+)
+
+// SearchFilesTool 在工作区文件中按正则或字面文本搜索，返回匹配的文件、行号和上下文
+type SearchFilesTool struct{}
+
+// NewSearchFilesTool 创建文件搜索工具
+func NewSearchFilesTool() *SearchFilesTool {
+	return &SearchFilesTool{}
+}
+
+func (t *SearchFilesTool) Name() string {
+	return "search_files"
+}
+
+func (t *SearchFilesTool) Description() string {
+	return "在工作区文件中搜索文本，返回匹配的文件、行号和上下文，用于查找函数定义、调用位置、配置项等，无需逐个读取文件。默认遵循 .gitignore、跳过隐藏文件和二进制文件。参数: pattern(正则表达式或文本), path(目录或文件,默认当前目录), literal(按字面文本搜索,可选), ignore_case(可选), glob(文件名通配符,如 *.go、cmd/**,可选), extensions(扩展名,如 go,md,可选), context_lines(上下文行数,可选), max_matches(最多匹配数,可选), include_hidden, include_ignored(可选), workdir(执行目录,可选)"
+}
+
+func (t *SearchFilesTool) GetParams() map[string]string {
+	return map[string]string{
+		"pattern":         "要搜索的内容，默认为正则表达式（Go RE2 语法），如 func\\s+Execute\\(",
+		"path":            "搜索的目录或文件（相对路径基于workdir），默认为当前目录",
+		"literal":         "true 时将 pattern 作为字面文本搜索，不解析正则",
+		"ignore_case":     "true 时忽略大小写",
+		"glob":            "只搜索匹配该通配符的文件，** 匹配任意层级；不含 / 时匹配文件名，如 *.go",
+		"extensions":      "只搜索这些扩展名的文件，逗号分隔，如 go,md",
+		"context_lines":   fmt.Sprintf("每个匹配前后附带的行数，默认0，最多%d", searchMaxContext),
+		"max_matches":     fmt.Sprintf("最多返回的匹配数，默认%d，最多%d", searchDefaultMatches, searchMaxMatches),
+		"include_hidden":  "true 时搜索以 . 开头的文件和目录（.git 始终跳过）",
+		"include_ignored": "true 时搜索被 .gitignore 忽略的文件",
+		WorkdirParam:      workdirParamDescription,
+	}
+}
+
+func (t *SearchFilesTool) RequiredParams() []string {
+	return []string{"pattern"}
+}
+
+func (t *SearchFilesTool) ReadOnly() bool {
+	return true
+}
+
+// searchMatch 一处匹配
+type searchMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+func (t *SearchFilesTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	pattern, _ := params["pattern"].(string)
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern参数不能为空")
+	}
+	expr := pattern
+	if boolParam(params, "literal") {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if boolParam(params, "ignore_case") {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的正则表达式（可设置 literal 按字面文本搜索）: %w", err)
+	}
+
+	dir, _ := params["path"].(string)
+	if dir == "" {
+		dir = "."
+	}
+	root, err := workdirPath(params, dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("路径不存在: %s", dir)
+		}
+		return nil, fmt.Errorf("读取路径失败: %w", err)
+	}
+
+	contextLines, _, err := intParam(params, "context_lines")
+	if err != nil {
+		return nil, err
+	}
+	contextLines = min(contextLines, searchMaxContext)
+	maxMatches, ok, err := intParam(params, "max_matches")
+	if err != nil {
+		return nil, err
+	}
+	if !ok || maxMatches == 0 {
+		maxMatches = searchDefaultMatches
+	}
+	maxMatches = min(maxMatches, searchMaxMatches)
+
+	glob, _ := params["glob"].(string)
+	glob = strings.TrimPrefix(strings.TrimSpace(glob), "./")
+	filter := &listFilter{pattern: glob, extensions: extensionSet(params["extensions"])}
+
+	var matches []searchMatch
+	files, truncated := 0, false
+	search := func(p, rel string) {
+		found := searchFile(p, rel, re, contextLines, maxMatches-len(matches))
+		if len(found) > 0 {
+			files++
+			matches = append(matches, found...)
+		}
+		if len(matches) >= maxMatches {
+			truncated = true
+		}
+	}
+
+	if !info.IsDir() {
+		search(root, filepath.Base(root))
+	} else {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("解析目录失败: %w", err)
+		}
+		opts := walkOptions{
+			includeHidden:  boolParam(params, "include_hidden"),
+			includeIgnored: boolParam(params, "include_ignored"),
+		}
+		scanTruncated, err := walkWorkspace(ctx, absRoot, opts, func(p, rel string, d fs.DirEntry) error {
+			if d.IsDir() {
+				return nil
+			}
+			if glob != "" {
+				target := rel
+				if !strings.Contains(glob, "/") {
+					target = d.Name()
+				}
+				if !matchGlob(glob, target) {
+					return nil
+				}
+			}
+			if !filter.matchExtension(d.Name()) {
+				return nil
+			}
+			search(p, rel)
+			if truncated {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		truncated = truncated || scanTruncated
+	}
+
+	if matches == nil {
+		matches = []searchMatch{}
+	}
+	result := map[string]interface{}{
+		"pattern": pattern,
+		"path":    root,
+		"matches": matches,
+		"count":   len(matches),
+		"files":   files,
+	}
+	if truncated {
+		result["truncated"] = true
+		result["note"] = fmt.Sprintf("匹配过多，只返回了前%d处，请缩小path、使用glob/extensions或使用更具体的pattern", len(matches))
+	}
+	if len(matches) == 0 {
+		result["note"] = "没有找到匹配的内容"
+	}
+	return result, nil
+}
+
+// searchFile 在单个文件中搜索，跳过过大的文件和二进制文件，最多返回 limit 处匹配
+func searchFile(p, rel string, re *regexp.Regexp, contextLines, limit int) []searchMatch {
+	info, err := os.Stat(p)
+	if err != nil || info.Size() > searchMaxFileSize || limit <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil
+	}
+
+	lines := splitLines(string(data))
+	var matches []searchMatch
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if !re.MatchString(line) {
+			continue
+		}
+		match := searchMatch{File: rel, Line: i + 1, Text: truncateLine(line)}
+		for j := max(0, i-contextLines); j < i; j++ {
+			match.Before = append(match.Before, truncateLine(strings.TrimRight(lines[j], "\r\n")))
+		}
+		for j := i + 1; j < len(lines) && j <= i+contextLines; j++ {
+			match.After = append(match.After, truncateLine(strings.TrimRight(lines[j], "\r\n")))
+		}
+		matches = append(matches, match)
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches
+}
+
+// truncateLine 截断过长的行（如压缩后的代码）
+func truncateLine(line string) string {
+	if runes := []rune(line); len(runes) > searchMaxLineChars {
+		return string(runes[:searchMaxLineChars]) + "..."
+	}
+	return line
+}
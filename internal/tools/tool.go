@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sync"
 )
 
 // Tool 工具接口
@@ -13,9 +14,52 @@ type Tool interface {
 	Execute(ctx context.Context, params map[string]interface{}) (interface{}, error)
 }
 
+// ArtifactProducer 会在磁盘上生成文件的工具（可选实现），用于追踪会话产物
+type ArtifactProducer interface {
+	ProducedFiles(params map[string]interface{}, result interface{}) []string
+}
+
+// RequiredParamsProvider 声明必填参数的工具（可选实现），未实现时所有参数都视为必填
+type RequiredParamsProvider interface {
+	RequiredParams() []string
+}
+
+// RequiredParams 获取工具的必填参数
+func RequiredParams(tool Tool) []string {
+	if p, ok := tool.(RequiredParamsProvider); ok {
+		return p.RequiredParams()
+	}
+	names := make([]string, 0, len(tool.GetParams()))
+	for name := range tool.GetParams() {
+		names = append(names, name)
+	}
+	return names
+}
+
+// SchemaProvider 声明完整参数JSON Schema的工具（可选实现），未实现时所有参数都按字符串类型描述
+type SchemaProvider interface {
+	// ParamSchema 返回参数名到参数schema的映射（即JSON Schema中的properties）
+	ParamSchema() map[string]interface{}
+}
+
+// ReadOnlyTool 不修改外部状态的工具（可选实现），用于判断重复调用能否直接复用结果
+type ReadOnlyTool interface {
+	ReadOnly() bool
+}
+
+// IsReadOnly 判断工具是否只读（未实现ReadOnlyTool的工具视为有副作用）
+func IsReadOnly(tool Tool) bool {
+	if r, ok := tool.(ReadOnlyTool); ok {
+		return r.ReadOnly()
+	}
+	return false
+}
+
 // ToolRegistry 工具注册表
 type ToolRegistry struct {
-	tools map[string]Tool
+	mu     sync.RWMutex
+	tools  map[string]Tool
+	forbid func(name string) bool // 禁止注册的工具（组织策略）
 }
 
 // NewToolRegistry 创建新的工具注册表
@@ -27,11 +71,37 @@ func NewToolRegistry() *ToolRegistry {
 
 // Register 注册工具
 func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.forbid != nil && r.forbid(tool.Name()) {
+		return
+	}
 	r.tools[tool.Name()] = tool
 }
 
+// SetForbidden 设置禁止注册的工具，之后注册的匹配工具（包括MCP工具）会被忽略，已注册的会被移除
+func (r *ToolRegistry) SetForbidden(forbid func(name string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.forbid = forbid
+	for name := range r.tools {
+		if forbid(name) {
+			delete(r.tools, name)
+		}
+	}
+}
+
+// Unregister 移除工具（配置修改后重新注册内置工具时使用）
+func (r *ToolRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
 // Get 获取工具
 func (r *ToolRegistry) Get(name string) (Tool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	tool, ok := r.tools[name]
 	if !ok {
 		return nil, fmt.Errorf("工具 %s 不存在", name)
@@ -41,6 +111,8 @@ func (r *ToolRegistry) Get(name string) (Tool, error) {
 
 // List 列出所有工具
 func (r *ToolRegistry) List() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	tools := make([]Tool, 0, len(r.tools))
 	for _, tool := range r.tools {
 		tools = append(tools, tool)
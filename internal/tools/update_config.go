@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agentcli/internal/config"
+)
+
+// ConfigApprover 展示配置修改的diff和理由并请用户确认，返回是否同意；无法询问用户时返回错误
+type ConfigApprover func(diff, reason string) (bool, error)
+
+// UpdateConfigTool 修改代理自身的配置（启用工具、调整限制等）
+// 修改先以diff形式交给用户确认，确认后经校验写入配置文件并记录到修改历史，可通过 /config revert 撤销
+// 只允许修改 config.CheckEditable 放行的配置项，密钥和命令执行策略不能由代理修改
+type UpdateConfigTool struct {
+	path    string
+	approve ConfigApprover
+	applied func(cfg *config.Config) // 写入后使新配置在当前会话中生效
+}
+
+// NewUpdateConfigTool 创建配置修改工具，path 为当前使用的配置文件
+func NewUpdateConfigTool(path string, approve ConfigApprover, applied func(cfg *config.Config)) *UpdateConfigTool {
+	return &UpdateConfigTool{path: path, approve: approve, applied: applied}
+}
+
+func (t *UpdateConfigTool) Name() string {
+	return "update_config"
+}
+
+func (t *UpdateConfigTool) Description() string {
+	return "修改代理自身的配置，如在 tools.enabled 中启用/停用工具、调整 response.max_tokens 等限制。修改以diff形式请用户确认后才写入，可撤销。只能修改 tools.、response.、intent.、dag.、context.、doc_lookup.、recall.、ui. 开头的配置项，不能修改密钥和命令执行策略。参数: changes(修改列表，每项包含 key、action(set/add/remove)、value), reason(修改理由)"
+}
+
+func (t *UpdateConfigTool) GetParams() map[string]string {
+	return map[string]string{
+		"changes": `修改列表（JSON数组），每项为 {"key": "配置项路径，如 tools.enabled", "action": "set 设置值 / add 向列表追加 / remove 从列表删除（不带value时删除该配置项，恢复默认值）", "value": 值}`,
+		"reason":  "修改理由，展示给用户",
+	}
+}
+
+func (t *UpdateConfigTool) ParamSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"changes": map[string]interface{}{
+			"type":        "array",
+			"description": t.GetParams()["changes"],
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key":    map[string]interface{}{"type": "string", "description": "以点分隔的配置项路径"},
+					"action": map[string]interface{}{"type": "string", "enum": []string{config.EditSet, config.EditAdd, config.EditRemove}},
+					"value":  map[string]interface{}{"description": "修改后的值或列表元素"},
+				},
+				"required": []string{"key", "action"},
+			},
+		},
+		"reason": map[string]interface{}{
+			"type":        "string",
+			"description": t.GetParams()["reason"],
+		},
+	}
+}
+
+func (t *UpdateConfigTool) RequiredParams() []string {
+	return []string{"changes", "reason"}
+}
+
+func (t *UpdateConfigTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	edits, err := parseConfigEdits(params["changes"])
+	if err != nil {
+		return nil, err
+	}
+	reason, _ := params["reason"].(string)
+
+	before, after, err := config.ProposeEdits(t.path, edits)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := t.approve(UnifiedDiff(t.path, string(before), string(after)), reason)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("用户拒绝修改配置")
+	}
+
+	cfg, err := config.CommitEdits(t.path, before, after, edits, reason)
+	if cfg != nil && t.applied != nil {
+		t.applied(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 只返回修改摘要，不返回diff，避免配置中相邻的密钥出现在上下文中
+	summary := make([]string, len(edits))
+	for i, edit := range edits {
+		summary[i] = edit.String()
+	}
+	return map[string]interface{}{
+		"config":  t.path,
+		"changes": summary,
+		"message": fmt.Sprintf("已修改配置 %s 并在当前会话中生效，用户可通过 /config revert 撤销", t.path),
+	}, nil
+}
+
+// parseConfigEdits 解析修改列表，兼容以JSON字符串传入的参数
+func parseConfigEdits(value interface{}) ([]config.Edit, error) {
+	if s, ok := value.(string); ok {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, fmt.Errorf("缺少 changes 参数")
+		}
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("changes 不是有效的JSON: %w", err)
+		}
+	}
+	if single, ok := value.(map[string]interface{}); ok {
+		value = []interface{}{single}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("changes 参数无效: %w", err)
+	}
+	var edits []config.Edit
+	if err := json.Unmarshal(data, &edits); err != nil {
+		return nil, fmt.Errorf("changes 必须是 {key, action, value} 组成的数组: %w", err)
+	}
+	for i := range edits {
+		edits[i].Key = strings.TrimSpace(edits[i].Key)
+		edits[i].Action = strings.ToLower(strings.TrimSpace(edits[i].Action))
+	}
+	return edits, nil
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// walkMaxScanned 最多遍历的文件和目录数，避免在超大目录树上耗时过长
+const walkMaxScanned = 50000
+
+// walkOptions 遍历工作区时的过滤选项
+type walkOptions struct {
+	includeHidden  bool // 包含以 . 开头的文件和目录（.git 始终跳过）
+	includeIgnored bool // 包含被 .gitignore 忽略的文件
+}
+
+// walkWorkspace 遍历 root 下的文件和目录，跳过 .git、隐藏路径和 .gitignore 忽略的路径；
+// fn 收到绝对路径和相对于 root 的路径（以 / 分隔），可返回 filepath.SkipDir 或 filepath.SkipAll。
+// 遍历的条目数超出限制时停止并返回 truncated
+func walkWorkspace(ctx context.Context, root string, opts walkOptions, fn func(p, rel string, d fs.DirEntry) error) (truncated bool, err error) {
+	ignore := newGitignore()
+	if !opts.includeIgnored {
+		ignore.loadParents(root)
+	}
+
+	scanned := 0
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			// 无权限等无法读取的目录跳过
+			if d != nil && d.IsDir() && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if scanned++; scanned > walkMaxScanned {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		name := d.Name()
+		hidden := !opts.includeHidden && strings.HasPrefix(name, ".")
+		if d.IsDir() {
+			if name == ".git" || hidden {
+				return filepath.SkipDir
+			}
+			if !opts.includeIgnored {
+				if ignore.ignored(p, true) {
+					return filepath.SkipDir
+				}
+				ignore.load(p)
+			}
+		} else if hidden || (!opts.includeIgnored && ignore.ignored(p, false)) {
+			return nil
+		}
+		return fn(p, filepath.ToSlash(strings.TrimPrefix(p, root+string(filepath.Separator))), d)
+	})
+	return truncated, err
+}
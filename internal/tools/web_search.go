@@ -0,0 +1,267 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 支持的搜索服务
+const (
+	SearchProviderBing    = "bing"
+	SearchProviderBrave   = "brave"
+	SearchProviderSearxNG = "searxng"
+)
+
+// 搜索结果条数的默认值和上限
+const (
+	searchDefaultResults = 5
+	searchMaxResults     = 20
+)
+
+// SearchResult 一条网页搜索结果
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchProvider 网页搜索服务
+type SearchProvider interface {
+	Name() string
+	Search(ctx context.Context, query string, count int) ([]SearchResult, error)
+}
+
+// NewSearchProvider 按名称创建搜索服务；bing、brave 需要 apiKey，searxng 需要实例地址 baseURL
+func NewSearchProvider(name, apiKey, baseURL string, timeout time.Duration) (SearchProvider, error) {
+	client := &http.Client{Timeout: timeout}
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case SearchProviderBing:
+		if apiKey == "" {
+			return nil, fmt.Errorf("bing 搜索需要配置 api_key")
+		}
+		if baseURL == "" {
+			baseURL = "https://api.bing.microsoft.com/v7.0/search"
+		}
+		return &bingSearch{client: client, apiKey: apiKey, endpoint: baseURL}, nil
+	case SearchProviderBrave:
+		if apiKey == "" {
+			return nil, fmt.Errorf("brave 搜索需要配置 api_key")
+		}
+		if baseURL == "" {
+			baseURL = "https://api.search.brave.com/res/v1/web/search"
+		}
+		return &braveSearch{client: client, apiKey: apiKey, endpoint: baseURL}, nil
+	case SearchProviderSearxNG:
+		if baseURL == "" {
+			return nil, fmt.Errorf("searxng 搜索需要配置实例地址 base_url")
+		}
+		return &searxngSearch{client: client, endpoint: baseURL + "/search"}, nil
+	default:
+		return nil, fmt.Errorf("不支持的搜索服务: %s（可选 bing、brave、searxng）", name)
+	}
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func getJSON(ctx context.Context, client *http.Client, endpoint string, query url.Values, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "agentcli")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求搜索服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if err != nil {
+		return fmt.Errorf("读取搜索结果失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("搜索服务返回错误 (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析搜索结果失败: %w", err)
+	}
+	return nil
+}
+
+// bingSearch Bing Web Search API
+type bingSearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (s *bingSearch) Name() string {
+	return SearchProviderBing
+}
+
+func (s *bingSearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	params := url.Values{"q": {query}, "count": {fmt.Sprint(count)}, "textFormat": {"Raw"}}
+	if err := getJSON(ctx, s.client, s.endpoint, params, map[string]string{"Ocp-Apim-Subscription-Key": s.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, v := range resp.WebPages.Value {
+		results = append(results, SearchResult{Title: v.Name, URL: v.URL, Snippet: v.Snippet})
+	}
+	return results, nil
+}
+
+// braveSearch Brave Search API
+type braveSearch struct {
+	client   *http.Client
+	apiKey   string
+	endpoint string
+}
+
+func (s *braveSearch) Name() string {
+	return SearchProviderBrave
+}
+
+func (s *braveSearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	params := url.Values{"q": {query}, "count": {fmt.Sprint(count)}}
+	if err := getJSON(ctx, s.client, s.endpoint, params, map[string]string{"X-Subscription-Token": s.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, v := range resp.Web.Results {
+		// Brave 的摘要中带有 <strong> 等高亮标签
+		results = append(results, SearchResult{Title: htmlToText(v.Title), URL: v.URL, Snippet: htmlToText(v.Description)})
+	}
+	return results, nil
+}
+
+// searxngSearch 自建的 SearxNG 实例（需在实例设置中开启 json 格式）
+type searxngSearch struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (s *searxngSearch) Name() string {
+	return SearchProviderSearxNG
+}
+
+func (s *searxngSearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, s.client, s.endpoint, url.Values{"q": {query}, "format": {"json"}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, v := range resp.Results {
+		results = append(results, SearchResult{Title: v.Title, URL: v.URL, Snippet: v.Content})
+	}
+	// SearxNG 不支持指定条数
+	if len(results) > count {
+		results = results[:count]
+	}
+	return results, nil
+}
+
+// WebSearchTool 通过配置的搜索服务搜索网页
+type WebSearchTool struct {
+	provider   SearchProvider
+	maxResults int
+}
+
+// NewWebSearchTool 创建网页搜索工具，maxResults 为默认返回的结果条数
+func NewWebSearchTool(provider SearchProvider, maxResults int) *WebSearchTool {
+	if maxResults <= 0 {
+		maxResults = searchDefaultResults
+	}
+	return &WebSearchTool{provider: provider, maxResults: min(maxResults, searchMaxResults)}
+}
+
+func (t *WebSearchTool) Name() string {
+	return "web_search"
+}
+
+func (t *WebSearchTool) Description() string {
+	return "搜索网页，返回标题、链接和摘要，用于回答需要最新信息的问题（新版本、近期事件、报错信息等）；需要详细内容时再用 fetch_url 读取结果页面。参数: query(搜索关键词), count(结果条数,可选)"
+}
+
+func (t *WebSearchTool) GetParams() map[string]string {
+	return map[string]string{
+		"query": "搜索关键词",
+		"count": fmt.Sprintf("返回的结果条数，默认%d，最多%d", t.maxResults, searchMaxResults),
+	}
+}
+
+func (t *WebSearchTool) RequiredParams() []string {
+	return []string{"query"}
+}
+
+func (t *WebSearchTool) ReadOnly() bool {
+	return true
+}
+
+func (t *WebSearchTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	query, _ := params["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("query参数不能为空")
+	}
+	count, ok, err := intParam(params, "count")
+	if err != nil {
+		return nil, err
+	}
+	if !ok || count == 0 {
+		count = t.maxResults
+	}
+	count = min(count, searchMaxResults)
+
+	results, err := t.provider.Search(ctx, query, count)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	result := map[string]interface{}{
+		"query":    query,
+		"provider": t.provider.Name(),
+		"results":  results,
+		"count":    len(results),
+	}
+	if len(results) == 0 {
+		result["note"] = "没有找到结果，可以换用更通用的关键词"
+	}
+	return result, nil
+}
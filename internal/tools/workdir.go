@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorkdirParam 工具执行目录参数名（execute_command、read_file、write_code、write_file、apply_patch、edit_file、list_files、search_files 支持）
+const WorkdirParam = "workdir"
+
+// workdirParamDescription 执行目录参数的说明
+const workdirParamDescription = "执行目录(可选)，相对路径基于工作区根目录，不能位于工作区之外"
+
+// ResolveWorkdir 校验执行目录并返回相对于工作区根目录（进程当前目录）的路径，dir 为空时返回空字符串
+// 目录必须已存在，且解析符号链接后仍位于工作区之内
+func ResolveWorkdir(dir string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return "", nil
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("获取工作区目录失败: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("解析工作区目录失败: %w", err)
+	}
+
+	path := dir
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("执行目录不存在: %s", dir)
+		}
+		return "", fmt.Errorf("解析执行目录失败: %w", err)
+	}
+
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("执行目录 %s 位于工作区 %s 之外", dir, root)
+	}
+	if info, err := os.Stat(realPath); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("执行目录不是目录: %s", dir)
+	}
+	return rel, nil
+}
+
+// workdirPath 将相对路径解析到工具调用指定的执行目录下，未指定执行目录或路径为绝对路径时原样返回
+func workdirPath(params map[string]interface{}, path string) (string, error) {
+	dir, _ := params[WorkdirParam].(string)
+	workdir, err := ResolveWorkdir(dir)
+	if err != nil {
+		return "", err
+	}
+	if workdir == "" || filepath.IsAbs(path) {
+		return path, nil
+	}
+	return filepath.Join(workdir, path), nil
+}
@@ -1,24 +1,51 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"agentcli/internal/fsutil"
 )
 
+// formatTimeout 单次格式化命令的超时时间
+const formatTimeout = 30 * time.Second
+
+// sourceExtensions 源代码文件扩展名及其编程语言，write_code 只对这些文件验证语言
+var sourceExtensions = map[string]string{
+	".py":   "python",
+	".go":   "go",
+	".js":   "javascript",
+	".ts":   "typescript",
+	".java": "java",
+	".c":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".cxx":  "cpp",
+}
+
 // WriteCodeTool 写代码工具
 type WriteCodeTool struct {
 	maxLines           int
 	supportedLanguages []string
+	formatters         map[string]string // 语言 -> 格式化命令，{file} 替换为文件路径
+	headers            map[string]string // 扩展名（不含点）-> 新文件必须包含的文件头
+	changes            *ChangeSet        // 计划模式下修改记录到变更集，确认后才写入
 }
 
 // NewWriteCodeTool 创建写代码工具
-func NewWriteCodeTool(maxLines int, supportedLanguages []string) *WriteCodeTool {
+func NewWriteCodeTool(maxLines int, supportedLanguages []string, formatters, headers map[string]string, changes *ChangeSet) *WriteCodeTool {
 	return &WriteCodeTool{
 		maxLines:           maxLines,
 		supportedLanguages: supportedLanguages,
+		formatters:         formatters,
+		headers:            headers,
+		changes:            changes,
 	}
 }
 
@@ -27,17 +54,22 @@ func (t *WriteCodeTool) Name() string {
 }
 
 func (t *WriteCodeTool) Description() string {
-	return "写入代码到文件。参数: filepath(文件路径), code(代码内容), language(编程语言)"
+	return "写入代码到文件，写入后按配置自动运行格式化工具；文档、配置等非源代码文件建议使用write_file。参数: filepath(文件路径), code(代码内容), language(编程语言), workdir(执行目录,可选)"
 }
 
 func (t *WriteCodeTool) GetParams() map[string]string {
 	return map[string]string{
-		"filepath": "要写入的文件路径",
-		"code":     "要写入的代码内容",
-		"language": "编程语言(可选，可从文件扩展名推断)",
+		"filepath":   "要写入的文件路径（相对路径基于workdir）",
+		"code":       "要写入的代码内容",
+		"language":   "编程语言(可选，可从文件扩展名推断)",
+		WorkdirParam: workdirParamDescription,
 	}
 }
 
+func (t *WriteCodeTool) RequiredParams() []string {
+	return []string{"filepath", "code"}
+}
+
 func (t *WriteCodeTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	// 获取参数 - 支持filepath和file_path两种参数名
 	filePath, ok := params["filepath"].(string)
@@ -47,6 +79,10 @@ func (t *WriteCodeTool) Execute(ctx context.Context, params map[string]interface
 			return nil, fmt.Errorf("缺少文件路径参数")
 		}
 	}
+	filePath, err := workdirPath(params, filePath)
+	if err != nil {
+		return nil, err
+	}
 
 	code, ok := params["code"].(string)
 	if !ok || code == "" {
@@ -54,32 +90,14 @@ func (t *WriteCodeTool) Execute(ctx context.Context, params map[string]interface
 	}
 
 	// 获取语言参数 - 如果未提供，从文件扩展名推断
+	sourceLanguage, isSource := sourceExtensions[strings.ToLower(filepath.Ext(filePath))]
 	language, ok := params["language"].(string)
 	if !ok || language == "" {
-		// 从文件扩展名推断语言
-		ext := filepath.Ext(filePath)
-		switch strings.ToLower(ext) {
-		case ".py":
-			language = "python"
-		case ".go":
-			language = "go"
-		case ".js":
-			language = "javascript"
-		case ".ts":
-			language = "typescript"
-		case ".java":
-			language = "java"
-		case ".c":
-			language = "c"
-		case ".cpp", ".cc", ".cxx":
-			language = "cpp"
-		default:
-			return nil, fmt.Errorf("无法推断编程语言，请指定language参数")
-		}
+		language = sourceLanguage
 	}
 
-	// 验证编程语言
-	if !t.isLanguageSupported(language) {
+	// 只对源代码文件验证编程语言，Markdown、YAML、Dockerfile等文件按普通文本写入
+	if isSource && !t.isLanguageSupported(language) {
 		return nil, fmt.Errorf("不支持的编程语言: %s", language)
 	}
 
@@ -89,24 +107,144 @@ func (t *WriteCodeTool) Execute(ctx context.Context, params map[string]interface
 		return nil, fmt.Errorf("代码行数超过限制: %d > %d", len(lines), t.maxLines)
 	}
 
-	// 创建目录
-	dir := filepath.Dir(filePath)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("创建目录失败: %w", err)
-		}
+	if t.changes.Active() {
+		return t.propose(filePath, code, language)
+	}
+
+	perm, exists, err := prepareWrite(filePath)
+	if err != nil {
+		return nil, err
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, []byte(code), 0644); err != nil {
+	// 新建文件时按扩展名插入要求的文件头（版权声明、SPDX等）
+	headerAdded := false
+	if !exists {
+		code, headerAdded = t.addHeader(filePath, code)
+		lines = strings.Split(code, "\n")
+	}
+
+	// 原子写入文件，执行中途退出时不会留下只写了一半的文件
+	if err := fsutil.ReplaceFile(filePath, []byte(code), perm); err != nil {
 		return nil, fmt.Errorf("写入文件失败: %w", err)
 	}
+	// 直接写入的完整内容取代了计划模式下对该文件尚未确认的修改
+	t.changes.settle(filePath)
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"filepath": filePath,
 		"lines":    len(lines),
 		"bytes":    len(code),
-	}, nil
+	}
+	if headerAdded {
+		result["header_added"] = true
+	}
+	t.format(ctx, language, filePath, code, result)
+	return result, nil
+}
+
+// propose 计划模式：把写入记录到变更集而不写文件，格式化在用户确认写入后执行
+func (t *WriteCodeTool) propose(filePath, code, language string) (interface{}, error) {
+	perm := os.FileMode(0644)
+	info, err := os.Stat(filePath)
+	switch {
+	case os.IsNotExist(err):
+		code, _ = t.addHeader(filePath, code)
+	case err != nil:
+		return nil, fmt.Errorf("读取文件信息失败: %w", err)
+	case info.IsDir():
+		return nil, fmt.Errorf("路径是目录: %s", filePath)
+	default:
+		perm = info.Mode().Perm()
+	}
+
+	change := t.changes.propose(t.Name(), filePath, code, perm, func(ctx context.Context) {
+		t.format(ctx, language, filePath, code, map[string]interface{}{})
+	})
+	return proposalResult(change), nil
+}
+
+// addHeader 在代码开头插入扩展名对应的文件头，{year} 替换为当前年份
+// 代码已包含文件头的第一行（如模型已自行写入版权声明）时不重复插入；shebang和编码声明保持在最前面
+func (t *WriteCodeTool) addHeader(filePath, code string) (string, bool) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+	header := strings.TrimSpace(t.headers[ext])
+	if header == "" {
+		return code, false
+	}
+	header = strings.ReplaceAll(header, "{year}", fmt.Sprintf("%d", time.Now().Year()))
+
+	firstLine, _, _ := strings.Cut(header, "\n")
+	if strings.Contains(code, strings.TrimSpace(firstLine)) {
+		return code, false
+	}
+
+	var preamble strings.Builder
+	rest := code
+	for {
+		line, remaining, found := strings.Cut(rest, "\n")
+		if !strings.HasPrefix(line, "#!") && !strings.Contains(line, "-*- coding") && !strings.HasPrefix(line, "# coding") {
+			break
+		}
+		preamble.WriteString(line + "\n")
+		if !found {
+			rest = ""
+			break
+		}
+		rest = remaining
+	}
+	return preamble.String() + header + "\n\n" + rest, true
+}
+
+// format 使用配置的格式化命令格式化写入的文件，并在结果中报告格式化是否改变了内容
+// 格式化工具不可用或执行失败时保留原始内容，不影响写入结果
+func (t *WriteCodeTool) format(ctx context.Context, language, filePath, code string, result map[string]interface{}) {
+	command := strings.TrimSpace(t.formatters[strings.ToLower(language)])
+	if command == "" {
+		return
+	}
+	args := strings.Fields(command)
+	result["formatter"] = args[0]
+	if _, err := exec.LookPath(args[0]); err != nil {
+		result["format_error"] = fmt.Sprintf("格式化工具 %s 不可用，已跳过", args[0])
+		return
+	}
+
+	placeholder := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			args[i] = strings.ReplaceAll(arg, "{file}", filePath)
+			placeholder = true
+		}
+	}
+	if !placeholder {
+		args = append(args, filePath)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		result["format_error"] = strings.TrimSpace(fmt.Sprintf("%v\n%s", err, output))
+		return
+	}
+
+	formatted, err := os.ReadFile(filePath)
+	if err != nil {
+		result["format_error"] = fmt.Sprintf("读取格式化结果失败: %v", err)
+		return
+	}
+	result["formatted"] = !bytes.Equal(formatted, []byte(code))
+	result["lines"] = len(strings.Split(string(formatted), "\n"))
+	result["bytes"] = len(formatted)
+}
+
+func (t *WriteCodeTool) ProducedFiles(params map[string]interface{}, result interface{}) []string {
+	if resultMap, ok := result.(map[string]interface{}); ok && resultMap["proposed"] != true {
+		if path, ok := resultMap["filepath"].(string); ok && path != "" {
+			return []string{path}
+		}
+	}
+	return nil
 }
 
 func (t *WriteCodeTool) isLanguageSupported(lang string) bool {
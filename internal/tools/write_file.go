@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agentcli/internal/fsutil"
+)
+
+// WriteFileTool 写入任意文本文件（文档、配置、Dockerfile等），不限制文件类型
+type WriteFileTool struct {
+	maxSizeKB int
+}
+
+// NewWriteFileTool 创建文件写入工具，maxSizeKB<=0 时不限制大小
+func NewWriteFileTool(maxSizeKB int) *WriteFileTool {
+	return &WriteFileTool{maxSizeKB: maxSizeKB}
+}
+
+func (t *WriteFileTool) Name() string {
+	return "write_file"
+}
+
+func (t *WriteFileTool) Description() string {
+	return "写入文本文件，不限制文件类型，适用于Markdown文档、YAML/JSON配置、Dockerfile、纯文本等非源代码文件。参数: filepath(文件路径), content(文件内容), workdir(执行目录,可选)"
+}
+
+func (t *WriteFileTool) GetParams() map[string]string {
+	return map[string]string{
+		"filepath":   "要写入的文件路径（相对路径基于workdir）",
+		"content":    "要写入的文件内容",
+		WorkdirParam: workdirParamDescription,
+	}
+}
+
+func (t *WriteFileTool) RequiredParams() []string {
+	return []string{"filepath", "content"}
+}
+
+func (t *WriteFileTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	filePath, _ := params["filepath"].(string)
+	if filePath == "" {
+		filePath, _ = params["file_path"].(string)
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("缺少文件路径参数")
+	}
+	filePath, err := workdirPath(params, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, ok := params["content"].(string)
+	if !ok {
+		content, ok = params["code"].(string)
+	}
+	if !ok {
+		return nil, fmt.Errorf("缺少文件内容参数")
+	}
+	if t.maxSizeKB > 0 && len(content) > t.maxSizeKB*1024 {
+		return nil, fmt.Errorf("文件内容超过大小限制: %d 字节 > %d KB", len(content), t.maxSizeKB)
+	}
+
+	perm, exists, err := prepareWrite(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsutil.ReplaceFile(filePath, []byte(content), perm); err != nil {
+		return nil, fmt.Errorf("写入文件失败: %w", err)
+	}
+
+	return map[string]interface{}{
+		"filepath": filePath,
+		"lines":    len(strings.Split(content, "\n")),
+		"bytes":    len(content),
+		"created":  !exists,
+	}, nil
+}
+
+func (t *WriteFileTool) ProducedFiles(params map[string]interface{}, result interface{}) []string {
+	if resultMap, ok := result.(map[string]interface{}); ok {
+		if path, ok := resultMap["filepath"].(string); ok && path != "" {
+			return []string{path}
+		}
+	}
+	return nil
+}
+
+// prepareWrite 创建目标文件所在的目录，返回写入时使用的权限和文件是否已存在
+// 覆盖已有文件时保留原文件权限（如脚本的可执行位）
+func prepareWrite(filePath string) (os.FileMode, bool, error) {
+	if dir := filepath.Dir(filePath); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, false, fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+	info, err := os.Stat(filePath)
+	switch {
+	case os.IsNotExist(err):
+		return 0644, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("读取文件信息失败: %w", err)
+	case info.IsDir():
+		return 0, false, fmt.Errorf("路径是目录: %s", filePath)
+	}
+	return info.Mode().Perm(), true, nil
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// 输出风格（ui.style）
+const (
+	StyleEmoji   = "emoji"   // 使用emoji标记（默认）
+	StylePlain   = "plain"   // 状态标记改为 [OK]、[ERROR]、[WARN] 等ASCII标签，装饰性图标去掉
+	StyleMinimal = "minimal" // 去掉所有标记，只保留文字
+)
+
+// marker 输出中的emoji标记，含变体选择符和零宽连接的组合（如 ⚠️、🧑‍⚖️）及其后的空格
+var marker = regexp.MustCompile(`[\x{1F300}-\x{1FAFF}\x{2600}-\x{27BF}\x{2B50}\x{23E9}-\x{23FA}]\x{FE0F}?(?:\x{200D}[\x{1F300}-\x{1FAFF}\x{2600}-\x{27BF}]\x{FE0F}?)* *`)
+
+// plainTags plain风格下状态标记对应的ASCII标签，未列出的装饰性图标直接去掉
+var plainTags = map[string]string{
+	"✅": "[OK]",
+	"✓": "[OK]",
+	"❌": "[ERROR]",
+	"✗": "[X]",
+	"⚠": "[WARN]",
+	"💡": "[TIP]",
+	"❓": "[?]",
+	"⏹": "[STOP]",
+	"⏳": "[WAIT]",
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(StyleEmoji)
+}
+
+// ValidStyle 是否为支持的输出风格（空字符串视为默认）
+func ValidStyle(style string) bool {
+	switch strings.ToLower(strings.TrimSpace(style)) {
+	case "", StyleEmoji, StylePlain, StyleMinimal:
+		return true
+	}
+	return false
+}
+
+// SetStyle 设置输出风格，空字符串或无法识别时使用 emoji
+func SetStyle(style string) {
+	style = strings.ToLower(strings.TrimSpace(style))
+	if style == "" || !ValidStyle(style) {
+		style = StyleEmoji
+	}
+	current.Store(style)
+}
+
+// Style 当前输出风格
+func Style() string {
+	return current.Load().(string)
+}
+
+// Text 按当前输出风格转换文本中的emoji标记
+func Text(s string) string {
+	style := Style()
+	if style == StyleEmoji {
+		return s
+	}
+	return marker.ReplaceAllStringFunc(s, func(m string) string {
+		if style == StyleMinimal {
+			return ""
+		}
+		icon := strings.TrimRight(m, " ")
+		base := strings.SplitN(strings.ReplaceAll(icon, "\uFE0F", ""), "\u200D", 2)[0]
+		if tag, ok := plainTags[base]; ok {
+			return tag + " "
+		}
+		return ""
+	})
+}
+
+// Mark 单独使用的标记：emoji风格下返回 icon，其他风格返回 fallback（如列表中的选中、置顶标记）
+func Mark(icon, fallback string) string {
+	if Style() == StyleEmoji {
+		return icon
+	}
+	return fallback
+}
+
+// Sprintf 按当前输出风格转换格式串中的标记后格式化，参数（模型输出、文件名等）保持原样
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(Text(format), args...)
+}
+
+// Printf 同 Sprintf，输出到标准输出
+func Printf(format string, args ...interface{}) {
+	fmt.Printf(Text(format), args...)
+}
+
+// Fprintf 同 Sprintf，输出到 w（如非交互模式下写到标准错误的提示）
+func Fprintf(w io.Writer, format string, args ...interface{}) {
+	fmt.Fprintf(w, Text(format), args...)
+}
+
+// Print 转换字符串参数中的标记后输出，只应用于固定的提示文本
+func Print(args ...interface{}) {
+	fmt.Print(textArgs(args)...)
+}
+
+// Println 转换字符串参数中的标记后输出并换行，只应用于固定的提示文本
+func Println(args ...interface{}) {
+	fmt.Println(textArgs(args)...)
+}
+
+func textArgs(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			arg = Text(s)
+		}
+		out[i] = arg
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agentcli/internal/fsutil"
+)
+
+// UnknownModel 旧版用量文件没有按模型拆分时使用的模型名
+const UnknownModel = "(未记录)"
+
+// ReportRow 用量报告中的一行：某用户某天某个模型的用量
+type ReportRow struct {
+	Date   string
+	UserID string
+	Model  string
+	Totals Totals
+}
+
+// Report 汇总用量目录中 [from, to] 日期范围内的每日、每模型用量；userID 为空时汇总所有用户
+func Report(dir, userID string, from, to time.Time) ([]ReportRow, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取用量目录失败: %w", err)
+	}
+
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+	var rows []ReportRow
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		// 文件名格式：<用户>_<日期>.json，用户名本身可能包含下划线
+		base := strings.TrimSuffix(name, ".json")
+		i := strings.LastIndex(base, "_")
+		if i <= 0 {
+			continue
+		}
+		user, date := base[:i], base[i+1:]
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+		if (userID != "" && user != userID) || date < fromDate || date > toDate {
+			continue
+		}
+
+		var record dailyRecord
+		if _, err := fsutil.ReadJSONWithBackup(filepath.Join(dir, name), &record); err != nil {
+			return nil, fmt.Errorf("读取用量文件 %s 失败: %w", name, err)
+		}
+		rows = append(rows, recordRows(user, date, record)...)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date < rows[j].Date
+		}
+		if rows[i].UserID != rows[j].UserID {
+			return rows[i].UserID < rows[j].UserID
+		}
+		return rows[i].Model < rows[j].Model
+	})
+	return rows, nil
+}
+
+// recordRows 将一天的用量拆成每模型的行，未按模型记录的部分归入 UnknownModel
+func recordRows(user, date string, record dailyRecord) []ReportRow {
+	var rows []ReportRow
+	rest := record.Totals
+	for model, totals := range record.Models {
+		rows = append(rows, ReportRow{Date: date, UserID: user, Model: model, Totals: totals})
+		rest = rest.Sub(totals)
+	}
+	rest.ToolCalls = 0
+	if rest.Requests > 0 || rest.Tokens() > 0 {
+		rows = append(rows, ReportRow{Date: date, UserID: user, Model: UnknownModel, Totals: rest})
+	}
+	return rows
+}
+
+// SumRows 计算报告的合计
+func SumRows(rows []ReportRow) Totals {
+	var sum Totals
+	for _, row := range rows {
+		sum.Requests += row.Totals.Requests
+		sum.PromptTokens += row.Totals.PromptTokens
+		sum.CachedTokens += row.Totals.CachedTokens
+		sum.CompletionTokens += row.Totals.CompletionTokens
+		sum.Cost += row.Totals.Cost
+		sum.CacheSavings += row.Totals.CacheSavings
+	}
+	return sum
+}
+
+// reportHeader CSV和表格的列名
+var reportHeader = []string{"date", "user", "model", "requests", "prompt_tokens", "cached_tokens", "completion_tokens", "total_tokens", "cost", "cache_savings"}
+
+func reportFields(date, user, model string, totals Totals) []string {
+	return []string{
+		date, user, model,
+		strconv.Itoa(totals.Requests),
+		strconv.Itoa(totals.PromptTokens),
+		strconv.Itoa(totals.CachedTokens),
+		strconv.Itoa(totals.CompletionTokens),
+		strconv.Itoa(totals.Tokens()),
+		strconv.FormatFloat(totals.Cost, 'f', 6, 64),
+		strconv.FormatFloat(totals.CacheSavings, 'f', 6, 64),
+	}
+}
+
+// WriteCSV 以CSV格式输出报告，最后一行为合计
+func WriteCSV(w io.Writer, rows []ReportRow) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{reportHeader}
+	for _, row := range rows {
+		records = append(records, reportFields(row.Date, row.UserID, row.Model, row.Totals))
+	}
+	records = append(records, reportFields("total", "", "", SumRows(rows)))
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("写入CSV失败: %w", err)
+	}
+	return nil
+}
+
+// WriteTable 以对齐的表格输出报告，最后一行为合计
+func WriteTable(w io.Writer, rows []ReportRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(reportHeader, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(reportFields(row.Date, row.UserID, row.Model, row.Totals), "\t"))
+	}
+	fmt.Fprintln(tw, strings.Join(reportFields("total", "", "", SumRows(rows)), "\t"))
+	return tw.Flush()
+}
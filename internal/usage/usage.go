@@ -0,0 +1,290 @@
+package usage
+
+import (
+	"agentcli/internal/config"
+	"agentcli/internal/fsutil"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Totals 用量统计
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	CacheSavings     float64 `json:"cache_savings"`
+	ToolCalls        int     `json:"tool_calls"`
+}
+
+// Tokens 总token数
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// Sub 两次统计之间的增量
+func (t Totals) Sub(o Totals) Totals {
+	return Totals{
+		Requests:         t.Requests - o.Requests,
+		PromptTokens:     t.PromptTokens - o.PromptTokens,
+		CachedTokens:     t.CachedTokens - o.CachedTokens,
+		CompletionTokens: t.CompletionTokens - o.CompletionTokens,
+		Cost:             t.Cost - o.Cost,
+		CacheSavings:     t.CacheSavings - o.CacheSavings,
+		ToolCalls:        t.ToolCalls - o.ToolCalls,
+	}
+}
+
+// dailyRecord 每日用量文件
+type dailyRecord struct {
+	UserID string            `json:"user_id"`
+	Date   string            `json:"date"`
+	Totals Totals            `json:"totals"`
+	Models map[string]Totals `json:"models,omitempty"` // 按模型拆分的token用量和费用
+}
+
+// Tracker 用量追踪器，负责统计用量并执行预算限制
+type Tracker struct {
+	mu      sync.Mutex
+	userID  string
+	budget  config.BudgetConfig
+	dir     string
+	date    string
+	session Totals
+	daily   Totals
+	models  map[string]Totals // 今日按模型拆分的用量
+	warned  map[string]bool
+
+	// Warn 达到警告阈值时的回调
+	Warn func(message string)
+	// Recorded 每次记录LLM请求用量后的回调，request 为该次请求的用量，session 为本次会话累计用量
+	Recorded func(model string, request, session Totals)
+}
+
+// NewTracker 创建用量追踪器，并加载用户当天已有的用量（目录解析为绝对路径，影子工作区模式下仍写入真实目录）
+func NewTracker(budget config.BudgetConfig, userID, dir string) *Tracker {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	t := &Tracker{
+		userID: userID,
+		budget: budget,
+		dir:    dir,
+		warned: make(map[string]bool),
+	}
+	t.loadDaily(time.Now().Format("2006-01-02"))
+	return t
+}
+
+// Check 检查token和费用预算，超出时返回错误
+func (t *Tracker) Check() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	if err := checkLimits("会话", t.session, t.budget.Session, false); err != nil {
+		return err
+	}
+	return checkLimits("今日", t.daily, t.budget.Daily, false)
+}
+
+// CheckToolCall 检查工具调用次数预算，超出时返回错误
+func (t *Tracker) CheckToolCall() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	if err := checkLimits("会话", t.session, t.budget.Session, true); err != nil {
+		return err
+	}
+	return checkLimits("今日", t.daily, t.budget.Daily, true)
+}
+
+// RecordTokens 记录一次LLM请求的token用量（cachedTokens为prompt中命中缓存的部分）
+func (t *Tracker) RecordTokens(model string, promptTokens, cachedTokens, completionTokens int) {
+	t.mu.Lock()
+	t.rollover()
+
+	cost, savings := t.cost(model, promptTokens, cachedTokens, completionTokens)
+	for _, totals := range []*Totals{&t.session, &t.daily} {
+		totals.Requests++
+		totals.PromptTokens += promptTokens
+		totals.CachedTokens += cachedTokens
+		totals.CompletionTokens += completionTokens
+		totals.Cost += cost
+		totals.CacheSavings += savings
+	}
+	perModel := t.models[model]
+	perModel.Requests++
+	perModel.PromptTokens += promptTokens
+	perModel.CachedTokens += cachedTokens
+	perModel.CompletionTokens += completionTokens
+	perModel.Cost += cost
+	perModel.CacheSavings += savings
+	t.models[model] = perModel
+	warnings := t.collectWarnings()
+	t.saveDaily()
+	session := t.session
+	t.mu.Unlock()
+
+	if t.Recorded != nil {
+		t.Recorded(model, Totals{
+			Requests:         1,
+			PromptTokens:     promptTokens,
+			CachedTokens:     cachedTokens,
+			CompletionTokens: completionTokens,
+			Cost:             cost,
+			CacheSavings:     savings,
+		}, session)
+	}
+	t.emit(warnings)
+}
+
+// RecordToolCall 记录一次工具调用
+func (t *Tracker) RecordToolCall() {
+	t.mu.Lock()
+	t.rollover()
+
+	t.session.ToolCalls++
+	t.daily.ToolCalls++
+	warnings := t.collectWarnings()
+	t.saveDaily()
+	t.mu.Unlock()
+
+	t.emit(warnings)
+}
+
+// Session 获取本次会话的用量
+func (t *Tracker) Session() Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session
+}
+
+// Daily 获取今日用量
+func (t *Tracker) Daily() Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	return t.daily
+}
+
+// Budget 获取预算配置
+func (t *Tracker) Budget() config.BudgetConfig {
+	return t.budget
+}
+
+// checkLimits 检查用量是否超出限额
+func checkLimits(scope string, totals Totals, limits config.BudgetLimits, toolCall bool) error {
+	if toolCall {
+		if limits.MaxToolCalls > 0 && totals.ToolCalls >= limits.MaxToolCalls {
+			return fmt.Errorf("已达到%s工具调用次数上限 (%d/%d)，如需继续请调整 budget 配置", scope, totals.ToolCalls, limits.MaxToolCalls)
+		}
+		return nil
+	}
+
+	if limits.MaxTokens > 0 && totals.Tokens() >= limits.MaxTokens {
+		return fmt.Errorf("已达到%stoken预算上限 (%d/%d)，如需继续请调整 budget 配置", scope, totals.Tokens(), limits.MaxTokens)
+	}
+	if limits.MaxCost > 0 && totals.Cost >= limits.MaxCost {
+		return fmt.Errorf("已达到%s费用预算上限 (%.4f/%.4f)，如需继续请调整 budget 配置", scope, totals.Cost, limits.MaxCost)
+	}
+	return nil
+}
+
+// collectWarnings 收集新达到警告阈值的限额（每项只警告一次）
+func (t *Tracker) collectWarnings() []string {
+	ratio := t.budget.WarnRatio
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 0.8
+	}
+
+	var warnings []string
+	check := func(key, scope, name string, used, limit float64) {
+		if limit <= 0 || used < limit*ratio || t.warned[key] {
+			return
+		}
+		t.warned[key] = true
+		warnings = append(warnings, fmt.Sprintf("%s%s已使用 %.0f%% (%g/%g)", scope, name, used/limit*100, used, limit))
+	}
+
+	for _, item := range []struct {
+		scope  string
+		totals Totals
+		limits config.BudgetLimits
+	}{
+		{"会话", t.session, t.budget.Session},
+		{"今日", t.daily, t.budget.Daily},
+	} {
+		check(t.date+item.scope+"tokens", item.scope, "token预算", float64(item.totals.Tokens()), float64(item.limits.MaxTokens))
+		check(t.date+item.scope+"cost", item.scope, "费用预算", item.totals.Cost, item.limits.MaxCost)
+		check(t.date+item.scope+"tools", item.scope, "工具调用次数", float64(item.totals.ToolCalls), float64(item.limits.MaxToolCalls))
+	}
+	return warnings
+}
+
+func (t *Tracker) emit(warnings []string) {
+	if t.Warn == nil {
+		return
+	}
+	for _, w := range warnings {
+		t.Warn(w)
+	}
+}
+
+// cost 根据配置的价格计算费用，以及缓存命中节省的费用
+func (t *Tracker) cost(model string, promptTokens, cachedTokens, completionTokens int) (float64, float64) {
+	for _, price := range t.budget.Prices {
+		if price.Model != model {
+			continue
+		}
+		cachedPrice := price.Cached
+		if cachedPrice <= 0 {
+			cachedPrice = price.Prompt
+		}
+		uncached := promptTokens - cachedTokens
+		cost := float64(uncached)/1000*price.Prompt + float64(cachedTokens)/1000*cachedPrice + float64(completionTokens)/1000*price.Completion
+		savings := float64(cachedTokens) / 1000 * (price.Prompt - cachedPrice)
+		return cost, savings
+	}
+	return 0, 0
+}
+
+// rollover 跨天时切换到新一天的用量
+func (t *Tracker) rollover() {
+	if today := time.Now().Format("2006-01-02"); today != t.date {
+		t.loadDaily(today)
+	}
+}
+
+func (t *Tracker) dailyPath(date string) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s_%s.json", t.userID, date))
+}
+
+func (t *Tracker) loadDaily(date string) {
+	t.date = date
+	t.daily = Totals{}
+	t.models = make(map[string]Totals)
+
+	var record dailyRecord
+	if _, err := fsutil.ReadJSONWithBackup(t.dailyPath(date), &record); err == nil {
+		t.daily = record.Totals
+		for model, totals := range record.Models {
+			t.models[model] = totals
+		}
+	}
+}
+
+func (t *Tracker) saveDaily() {
+	if t.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return
+	}
+	fsutil.WriteJSONAtomic(t.dailyPath(t.date), dailyRecord{UserID: t.userID, Date: t.date, Totals: t.daily, Models: t.models}, 0644)
+}